@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/cronjob"
+	"github.com/cybertec-postgresql/pg_timetable/internal/crontab"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgagent"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgcron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/cybertec-postgresql/pg_timetable/internal/scheduler"
+	"github.com/cybertec-postgresql/pg_timetable/internal/secrets"
+	"github.com/cybertec-postgresql/pg_timetable/internal/signature"
+	"github.com/cybertec-postgresql/pg_timetable/internal/top"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// icalStatsDays is the number of days of the execution history the median durations of chains are taken from
+// for events of the ical command
+const icalStatsDays = 30
+
+// runCommand executes the management subcommand, e.g. "chain start", and writes the result to w.
+// Imported chain definitions must be signed if verifier is not nil
+func runCommand(ctx context.Context, pge *pgengine.PgEngine, verifier *signature.Verifier, command string, args []string, w io.Writer) error {
+	switch command {
+	case "chain list":
+		opts := pge.Commands.Chain.List
+		filter := pgengine.ChainOverviewFilter{Client: opts.Client, LiveOnly: opts.Live, Tags: opts.Tags}
+		if filter.Client == "" {
+			filter.Client = pge.ClientName
+		}
+		chains, err := pge.SelectChainOverview(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if opts.JSON {
+			if chains == nil {
+				chains = []pgengine.ChainOverview{}
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(chains)
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSCHEDULE\tLIVE\tCLIENT\tTAGS\tLAST RUN\tSTATUS\tNEXT RUN")
+		for _, c := range chains {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%s\t%s\t%s\t%s\t%s\n", c.ChainID, c.ChainName, orDash(c.Schedule), c.Live,
+				orDash(c.ClientName), orDash(strings.Join(c.Tags, ",")), formatRunTime(c.LastRun), orDash(c.LastStatus),
+				formatRunTime(c.NextRun))
+		}
+		return tw.Flush()
+	case "chain start", "chain stop":
+		for _, arg := range args {
+			chainID, err := pge.SelectChainID(ctx, arg)
+			if err != nil {
+				return fmt.Errorf("cannot find chain %s: %w", arg, err)
+			}
+			if command == "chain start" {
+				err = pge.NotifyChainStart(ctx, chainID)
+			} else {
+				err = pge.NotifyChainStop(ctx, chainID)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Chain %d notified to %s\n", chainID, command[len("chain "):])
+		}
+		return nil
+	case "chain enable", "chain disable":
+		opts := pge.Commands.Chain.Enable
+		if command == "chain disable" {
+			opts = pge.Commands.Chain.Disable
+		}
+		names, err := pge.SetChainsLive(ctx, command == "chain enable", pgengine.ChainSelector{Tags: opts.Tags, Pattern: opts.Pattern})
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Fprintf(w, "Chain %s %sd\n", name, command[len("chain "):])
+		}
+		fmt.Fprintf(w, "%d chains %sd\n", len(names), command[len("chain "):])
+		return nil
+	case "chain handoff":
+		client := args[0]
+		for _, arg := range args[1:] {
+			chainID, err := pge.SelectChainID(ctx, arg)
+			if err != nil {
+				return fmt.Errorf("cannot find chain %s: %w", arg, err)
+			}
+			if err = pge.HandoffChain(ctx, chainID, client); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Chain %d handed off to %s\n", chainID, client)
+		}
+		return nil
+	case "export":
+		enc := yaml.NewEncoder(w)
+		defer enc.Close()
+		for _, arg := range args {
+			chainID, err := pge.SelectChainID(ctx, arg)
+			if err != nil {
+				return fmt.Errorf("cannot find chain %s: %w", arg, err)
+			}
+			def, err := pge.ExportChain(ctx, chainID)
+			if err != nil {
+				return err
+			}
+			if err = enc.Encode(def); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "graph":
+		chainID, err := pge.SelectChainID(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("cannot find chain %s: %w", args[0], err)
+		}
+		def, err := pge.ExportChain(ctx, chainID)
+		if err != nil {
+			return err
+		}
+		if pge.Commands.Graph.Format == "dot" {
+			return pgengine.WriteChainDOT(w, def)
+		}
+		var dot bytes.Buffer
+		_ = pgengine.WriteChainDOT(&dot, def)
+		svg, err := pgengine.RenderSVG(ctx, dot.Bytes())
+		if err != nil {
+			return fmt.Errorf("cannot render SVG: %w", err)
+		}
+		_, err = w.Write(svg)
+		return err
+	case "import":
+		for _, file := range args {
+			defs, err := pgengine.ReadChainDefinitions(file, verifier)
+			if err != nil {
+				return fmt.Errorf("cannot import %s: %w", file, err)
+			}
+			for _, def := range defs {
+				chainID, err := pge.ImportChain(ctx, def)
+				if err != nil {
+					return fmt.Errorf("cannot import chain %s: %w", def.Name, err)
+				}
+				fmt.Fprintf(w, "Chain %d imported from %s\n", chainID, file)
+			}
+		}
+		return nil
+	case "schedule":
+		entries, err := pge.SelectSchedule(ctx)
+		if err != nil {
+			return err
+		}
+		if !pge.Commands.Schedule.JSON {
+			return pgengine.WriteScheduleReport(w, entries, time.Now())
+		}
+		if entries == nil {
+			entries = []pgengine.ScheduleEntry{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "ical":
+		entries, err := pge.SelectSchedule(ctx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		stats, err := pge.SelectChainStats(ctx, now.AddDate(0, 0, -icalStatsDays))
+		if err != nil {
+			return err
+		}
+		durations := make(map[int]time.Duration, len(stats))
+		for _, s := range stats {
+			if s.P50Duration != nil {
+				durations[s.ChainID] = time.Duration(*s.P50Duration * float64(time.Second))
+			}
+		}
+		return pgengine.WriteScheduleICal(w, entries, durations, pge.Commands.ICal.Count, now)
+	case "stats":
+		var since time.Time
+		if days := pge.Commands.Stats.Days; days > 0 {
+			since = time.Now().AddDate(0, 0, -days)
+		}
+		stats, err := pge.SelectChainStats(ctx, since)
+		if err != nil {
+			return err
+		}
+		if pge.Commands.Stats.Format == "csv" {
+			return pgengine.WriteChainStatsCSV(w, stats)
+		}
+		if stats == nil {
+			stats = []pgengine.ChainStats{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	case "pgagent":
+		opts := pge.Commands.PgAgent
+		if opts.Source == "" {
+			return convertPgAgentJobs(ctx, pge.ConfigDb, opts.Prefix, log.GetLogger(ctx), w)
+		}
+		conn, err := pgx.Connect(ctx, opts.Source)
+		if err != nil {
+			return fmt.Errorf("cannot connect to %s: %w", opts.Source, err)
+		}
+		defer conn.Close(ctx)
+		return convertPgAgentJobs(ctx, conn, opts.Prefix, log.GetLogger(ctx), w)
+	case "pgcron":
+		opts := pge.Commands.PgCron
+		if opts.Source == "" {
+			return convertPgCronJobs(ctx, pge, pge.ConfigDb, opts, w)
+		}
+		conn, err := pgx.Connect(ctx, opts.Source)
+		if err != nil {
+			return fmt.Errorf("cannot connect to %s: %w", opts.Source, err)
+		}
+		defer conn.Close(ctx)
+		return convertPgCronJobs(ctx, pge, conn, opts, w)
+	case "top":
+		interval := time.Duration(pge.Commands.Top.Refresh) * time.Second
+		if pge.Commands.Top.Once {
+			interval = 0
+		}
+		return top.Run(ctx, pge, w, interval)
+	case "chain apply", "chain diff":
+		var defs []pgengine.ChainDefinition
+		for _, file := range args {
+			fileDefs, err := pgengine.ReadChainDefinitions(file, verifier)
+			if err != nil {
+				return fmt.Errorf("cannot %s %s: %w", command[len("chain "):], file, err)
+			}
+			defs = append(defs, fileDefs...)
+		}
+		if command == "chain diff" {
+			diffs, err := pge.DiffChains(ctx, defs, pge.Commands.Chain.Diff.Prune)
+			if err != nil {
+				return err
+			}
+			printChainDiffs(w, diffs)
+			return nil
+		}
+		opts := pge.Commands.Chain.Apply
+		changes, err := pge.ApplyChains(ctx, defs, opts.Prune, opts.DryRun)
+		if err != nil {
+			return err
+		}
+		suffix := ""
+		if opts.DryRun {
+			suffix = " (dry run)"
+		}
+		for _, change := range changes {
+			fmt.Fprintf(w, "Chain %s %s%s\n", change.Name, change.Action, suffix)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command: %s", command)
+}
+
+// orDash returns the value or - if it's empty, so columns of tables are not shifted
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatRunTime returns the time of the run in the local time zone or - if it's nil
+func formatRunTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// printChainDiffs outputs differences of changed chains followed by the number of chains per action
+func printChainDiffs(w io.Writer, diffs []pgengine.ChainDiff) {
+	counts := make(map[string]int)
+	for _, diff := range diffs {
+		counts[diff.Action]++
+		if diff.Action == "unchanged" {
+			continue
+		}
+		fmt.Fprintf(w, "Chain %s will be %s:\n%s\n", diff.Name, diff.Action, diff.Diff)
+	}
+	fmt.Fprintf(w, "%d to create, %d to update, %d to delete, %d unchanged\n",
+		counts["created"], counts["updated"], counts["deleted"], counts["unchanged"])
+}
+
+// encryptValues writes encrypted:// references to the values, one per line
+func encryptValues(ctx context.Context, r *secrets.Resolver, values []string, w io.Writer) error {
+	for _, value := range values {
+		encrypted, err := r.Encrypt(ctx, value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, encrypted)
+	}
+	return nil
+}
+
+// convertCrontabs outputs chain definitions equivalent to entries of the crontab files, the output may be used
+// by the chain apply command. Entries which cannot be converted are logged and skipped
+func convertCrontabs(files []string, opts config.CrontabOpts, l log.LoggerIface, w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	defer enc.Close()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		prefix := opts.Prefix
+		if prefix == "" {
+			prefix = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		defs, warnings, err := crontab.Parse(f, prefix, opts.System)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", file, err)
+		}
+		for _, warning := range warnings {
+			l.WithField("file", file).Warn("Crontab line not converted: ", warning)
+		}
+		for _, def := range defs {
+			if err = enc.Encode(def); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// convertCronJobs outputs chain definitions equivalent to CronJobs of the Kubernetes manifest files, the output may
+// be used by the chain apply command. Parts of CronJobs which cannot be converted exactly are logged
+func convertCronJobs(files []string, opts config.CronJobOpts, l log.LoggerIface, w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	defer enc.Close()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defs, warnings, err := cronjob.Parse(f, opts.Prefix)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("cannot read %s: %w", file, err)
+		}
+		for _, warning := range warnings {
+			l.WithField("file", file).Warn("CronJob not converted exactly: ", warning)
+		}
+		for _, def := range defs {
+			if err = enc.Encode(def); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// convertPgAgentJobs outputs chain definitions equivalent to jobs of pgAgent, the output may be used
+// by the chain apply command. Parts of jobs which cannot be converted exactly are logged
+func convertPgAgentJobs(ctx context.Context, db pgxscan.Querier, prefix string, l log.LoggerIface, w io.Writer) error {
+	jobs, err := pgagent.Load(ctx, db)
+	if err != nil {
+		return fmt.Errorf("cannot read pgAgent jobs: %w", err)
+	}
+	defs, warnings := pgagent.Convert(jobs, prefix)
+	for _, warning := range warnings {
+		l.Warn("pgAgent job not converted exactly: ", warning)
+	}
+	enc := yaml.NewEncoder(w)
+	defer enc.Close()
+	for _, def := range defs {
+		if err = enc.Encode(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgCronSource is the database with the pg_cron extension
+type pgCronSource interface {
+	pgxscan.Querier
+	pgcron.Execer
+}
+
+// convertPgCronJobs outputs chain definitions equivalent to jobs of pg_cron or imports them if opts.Apply is set.
+// Converted jobs are disabled in pg_cron after the chains are imported if opts.Disable is set
+func convertPgCronJobs(ctx context.Context, pge *pgengine.PgEngine, db pgCronSource, opts config.PgCronOpts, w io.Writer) error {
+	jobs, err := pgcron.Load(ctx, db)
+	if err != nil {
+		return fmt.Errorf("cannot read pg_cron jobs: %w", err)
+	}
+	var target pgcron.Target
+	if err = pge.ConfigDb.QueryRow(ctx, "SELECT current_database(), current_user").Scan(&target.Database, &target.User); err != nil {
+		return err
+	}
+	defs, ids, warnings := pgcron.Convert(jobs, opts.Prefix, target)
+	for _, warning := range warnings {
+		log.GetLogger(ctx).Warn("pg_cron job not converted: ", warning)
+	}
+	if !opts.Apply {
+		enc := yaml.NewEncoder(w)
+		defer enc.Close()
+		for _, def := range defs {
+			if err = enc.Encode(def); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, def := range defs {
+		chainID, err := pge.ImportChain(ctx, def)
+		if err != nil {
+			return fmt.Errorf("cannot import chain %s: %w", def.Name, err)
+		}
+		fmt.Fprintf(w, "Chain %d imported from pg_cron as %s\n", chainID, def.Name)
+	}
+	if !opts.Disable || len(ids) == 0 {
+		return nil
+	}
+	if err = pgcron.Disable(ctx, db, ids); err != nil {
+		return fmt.Errorf("cannot disable pg_cron jobs: %w", err)
+	}
+	fmt.Fprintf(w, "%d pg_cron jobs disabled\n", len(ids))
+	return nil
+}
+
+// printCronNext outputs upcoming fire times of the cron expression after now evaluated in the time zone of opts
+func printCronNext(expr string, opts config.CronNextCommand, now time.Time, w io.Writer) error {
+	schedule, err := cron.Parse(expr)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(opts.TZ)
+	if err != nil {
+		return err
+	}
+	times := schedule.NextN(now.In(loc), opts.Count)
+	if len(times) == 0 {
+		return fmt.Errorf("%q never fires in the foreseeable future", expr)
+	}
+	for _, t := range times {
+		fmt.Fprintln(w, t.Format("Mon 2006-01-02 15:04 MST"))
+	}
+	return nil
+}
+
+// printChainResult outputs the result of the chain executed by the --run-chain option or the exec command
+// with outputs of its tasks
+func printChainResult(w io.Writer, res scheduler.ChainResult) {
+	status := "succeeded"
+	if !res.Succeeded {
+		status = "failed"
+	}
+	fmt.Fprintf(w, "Chain %q (ID %d) %s in %s, run ID %s\n", res.ChainName, res.ChainID, status,
+		res.Duration.Round(time.Millisecond), res.RunID)
+	for _, task := range res.Tasks {
+		fmt.Fprintf(w, "Task %d %s %s: return code %d in %s\n", task.TaskID, task.Kind, strings.Join(strings.Fields(task.Command), " "),
+			task.ReturnCode, task.Duration.Round(time.Millisecond))
+		if output := strings.TrimSpace(task.Output); output > "" {
+			fmt.Fprintln(w, "  "+strings.ReplaceAll(output, "\n", "\n  "))
+		}
+	}
+}
+
+// execTask returns the task definition of the exec command
+func execTask(opts config.ExecOpts) pgengine.TaskDefinition {
+	if opts.SQL > "" {
+		return pgengine.TaskDefinition{Kind: "SQL", Command: opts.SQL}
+	}
+	return pgengine.TaskDefinition{Kind: "PROGRAM", Command: opts.Shell, Parameters: []interface{}{[]string{"-c", opts.Program}}}
+}
+
+// osUser returns the name of the operating system user recorded as the operator of the exec command in the audit
+func osUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
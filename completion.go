@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/sirupsen/logrus"
+)
+
+// completionTimeout limits the time spent on the database lookup of chain names, so the shell is not blocked
+const completionTimeout = 3 * time.Second
+
+// completionScripts are output by the completion command. Scripts call the application with the words being
+// completed and the GO_FLAGS_COMPLETION variable set, so the command line parser outputs completions instead of
+// parsing them
+var completionScripts = map[string]string{
+	"bash": `# bash completion for pg_timetable, e.g. source <(pg_timetable completion bash)
+_pg_timetable() {
+    local args=("${COMP_WORDS[@]:1:$COMP_CWORD}")
+    local IFS=$'\n'
+    COMPREPLY=($(GO_FLAGS_COMPLETION=1 "${COMP_WORDS[0]}" "${args[@]}" 2>/dev/null))
+    return 0
+}
+complete -o default -F _pg_timetable pg_timetable
+`,
+	"zsh": `#compdef pg_timetable
+# zsh completion for pg_timetable, e.g. source <(pg_timetable completion zsh)
+_pg_timetable() {
+    local -a completions
+    completions=(${(f)"$(GO_FLAGS_COMPLETION=1 ${words[1]} "${(@)words[2,$CURRENT]}" 2>/dev/null)"})
+    compadd -a completions
+}
+compdef _pg_timetable pg_timetable
+`,
+	"fish": `# fish completion for pg_timetable, e.g. pg_timetable completion fish | source
+function __pg_timetable_complete
+    set -l args (commandline -opc) (commandline -ct)
+    env GO_FLAGS_COMPLETION=1 $args[1] $args[2..-1] 2>/dev/null
+end
+complete -c pg_timetable -f -a '(__pg_timetable_complete)'
+`,
+}
+
+// completeChainNames returns names of chains starting with the prefix for the shell completion. Connection options
+// are taken from the command line being completed, the configuration file and the environment. Errors are ignored,
+// so nothing is completed if the database is not available
+func completeChainNames(prefix string) []string {
+	completion := os.Getenv("GO_FLAGS_COMPLETION")
+	_ = os.Unsetenv("GO_FLAGS_COMPLETION") // parse the command line instead of completing it again
+	defer os.Setenv("GO_FLAGS_COMPLETION", completion)
+	cmdOpts, _ := config.NewConfig(io.Discard) // the client name is not required to look up chains
+	if cmdOpts == nil {
+		return nil
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard // stdout is read by the shell
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	pge, err := pgengine.Connect(ctx, *cmdOpts, logger)
+	if err != nil {
+		return nil
+	}
+	defer pge.ConfigDb.Close()
+	names, _ := pge.SelectChainNames(ctx, prefix)
+	return names
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/cybertec-postgresql/pg_timetable/internal/scheduler"
+	pgx "github.com/jackc/pgx/v4"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	maxNextRuns        = 1000    // maximum number of upcoming runs returned at once
+	maxChainDefinition = 1 << 20 // maximum size of the imported chain definition in bytes
+)
+
+// signatureHeader holds the base64 encoded detached signature of the imported chain definition
+const signatureHeader = "X-Chain-Signature"
+
+// errorStatus returns HTTP status code corresponding to the error
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, cron.ErrNotCron), errors.Is(err, pgengine.ErrInvalidChainDefinition):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, pgengine.ErrNoChainSelector):
+		return http.StatusBadRequest
+	case errors.Is(err, scheduler.ErrQueueFull):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, exec.ErrNotFound):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// chainsHandler serves /chains/{id}/... requests
+func (Server *RestApiServer) chainsHandler(w http.ResponseWriter, r *http.Request) {
+	Server.l.WithField("path", r.URL.Path).Debug("Received /chains REST API request")
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/chains/"), "/"), "/")
+	if len(parts) == 1 && parts[0] == "import" && r.Method == http.MethodPost {
+		Server.importChainHandler(w, r)
+		return
+	}
+	if len(parts) == 1 && (parts[0] == "enable" || parts[0] == "disable") && r.Method == http.MethodPost {
+		Server.chainsLiveHandler(w, r, parts[0] == "enable")
+		return
+	}
+	chainID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "invalid chain ID: "+parts[0], http.StatusBadRequest)
+		return
+	}
+	switch {
+	case len(parts) == 2 && parts[1] == "next" && r.Method == http.MethodGet:
+		Server.nextRunsHandler(w, r, chainID)
+	case len(parts) == 2 && parts[1] == "export" && r.Method == http.MethodGet:
+		// definitions contain database connection strings and environment of tasks
+		if !config.Authenticated(r.TLS, r.Header.Get("Authorization"), Server.token) {
+			unauthorized(Server.l, w, r)
+			return
+		}
+		Server.exportChainHandler(w, r, chainID)
+	case len(parts) == 2 && parts[1] == "graph" && r.Method == http.MethodGet:
+		Server.chainGraphHandler(w, r, chainID)
+	case len(parts) == 2 && parts[1] == "log-level" && (r.Method == http.MethodPut || r.Method == http.MethodDelete):
+		Server.chainLogLevelHandler(w, r, chainID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (Server *RestApiServer) nextRunsHandler(w http.ResponseWriter, r *http.Request, chainID int) {
+	count, err := intParam(r, "count", 10)
+	if err != nil || count <= 0 || count > maxNextRuns {
+		http.Error(w, "count must be a positive integer not greater than "+strconv.Itoa(maxNextRuns), http.StatusBadRequest)
+		return
+	}
+	runs, err := Server.Reporter.GetChainNextRuns(r.Context(), chainID, count)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (Server *RestApiServer) chainsLiveHandler(w http.ResponseWriter, r *http.Request, live bool) {
+	selector := pgengine.ChainSelector{Tags: r.URL.Query()["tag"], Pattern: r.URL.Query().Get("pattern")}
+	names, err := Server.Reporter.SetChainsLive(r.Context(), live, selector)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if names == nil {
+		names = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"live": live, "chains": names})
+}
+
+func (Server *RestApiServer) chainLogLevelHandler(w http.ResponseWriter, r *http.Request, chainID int) {
+	level := r.URL.Query().Get("level")
+	if r.Method == http.MethodDelete {
+		level = ""
+	} else if level == "" {
+		http.Error(w, "level parameter is required", http.StatusBadRequest)
+		return
+	}
+	if err := Server.Reporter.SetChainLogLevel(chainID, level); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"chain_id": chainID, "log_level": level})
+}
+
+// isYAML returns true if the chain definition should be encoded as YAML instead of JSON
+func isYAML(r *http.Request, header string) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "yaml"
+	}
+	return strings.Contains(r.Header.Get(header), "yaml")
+}
+
+func (Server *RestApiServer) exportChainHandler(w http.ResponseWriter, r *http.Request, chainID int) {
+	def, err := Server.Reporter.ExportChain(r.Context(), chainID)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if !isYAML(r, "Accept") {
+		writeJSON(w, http.StatusOK, def)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	_ = yaml.NewEncoder(w).Encode(def)
+}
+
+func (Server *RestApiServer) chainGraphHandler(w http.ResponseWriter, r *http.Request, chainID int) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "dot"
+	}
+	if format != "dot" && format != "svg" {
+		http.Error(w, "format must be dot or svg", http.StatusBadRequest)
+		return
+	}
+	def, err := Server.Reporter.ExportChain(r.Context(), chainID)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	var dot bytes.Buffer
+	_ = pgengine.WriteChainDOT(&dot, def)
+	if format == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(dot.Bytes())
+		return
+	}
+	svg, err := pgengine.RenderSVG(r.Context(), dot.Bytes())
+	if err != nil {
+		Server.l.WithError(err).Error("Cannot render chain graph")
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(svg)
+}
+
+func (Server *RestApiServer) importChainHandler(w http.ResponseWriter, r *http.Request) {
+	var def pgengine.ChainDefinition
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxChainDefinition))
+	if err == nil && Server.Verifier != nil {
+		sig, err := base64.StdEncoding.DecodeString(r.Header.Get(signatureHeader))
+		if err != nil {
+			http.Error(w, "invalid "+signatureHeader+" header: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = Server.Verifier.Verify(body, sig); err != nil {
+			Server.l.WithError(err).Warn("Chain definition rejected")
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	if err == nil {
+		if isYAML(r, "Content-Type") {
+			err = yaml.Unmarshal(body, &def)
+		} else {
+			err = json.Unmarshal(body, &def)
+		}
+	}
+	if err != nil {
+		http.Error(w, "cannot parse chain definition: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !Server.programs && def.HasProgramTasks() {
+		http.Error(w, "chains with PROGRAM tasks cannot be imported, see --rest-allow-program", http.StatusForbidden)
+		return
+	}
+	chainID, err := Server.Reporter.ImportChain(r.Context(), def)
+	if err != nil {
+		Server.l.WithError(err).WithField("name", def.Name).Error("Cannot import chain definition")
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"chain_id": chainID})
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+// ClusterStatus is the status document of all clients connected to the database
+type ClusterStatus struct {
+	Clients      []pgengine.ClientStatus `json:"clients"`
+	Healthy      int                     `json:"healthy"`       // the number of clients reported the status recently
+	ActiveChains int                     `json:"active_chains"` // the number of chains running on all clients
+	QueuedChains int                     `json:"queued_chains"` // the number of chains waiting for workers on all clients
+	Versions     []string                `json:"versions"`      // distinct versions of clients, e.g. to track rolling upgrades
+}
+
+// newClusterStatus aggregates the status of clients into the single document
+func newClusterStatus(clients []pgengine.ClientStatus) ClusterStatus {
+	status := ClusterStatus{Clients: clients, Versions: []string{}}
+	versions := make(map[string]struct{})
+	for _, c := range clients {
+		if c.Healthy {
+			status.Healthy++
+		}
+		status.ActiveChains += c.ActiveChains
+		if c.QueuedChains != nil {
+			status.QueuedChains += *c.QueuedChains
+		}
+		if c.Version != nil {
+			if _, ok := versions[*c.Version]; !ok {
+				versions[*c.Version] = struct{}{}
+				status.Versions = append(status.Versions, *c.Version)
+			}
+		}
+	}
+	sort.Strings(status.Versions)
+	return status
+}
+
+func (Server *RestApiServer) clusterHandler(w http.ResponseWriter, r *http.Request) {
+	Server.l.Debug("Received /cluster REST API request")
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	clients, err := Server.Reporter.GetClusterStatus(r.Context())
+	if err != nil {
+		Server.l.WithError(err).Error("Cannot fetch cluster status")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, newClusterStatus(clients))
+}
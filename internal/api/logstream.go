@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// subscriberCapacity specifies how many log entries can be buffered for a slow subscriber before dropping
+const subscriberCapacity = 256
+
+// logStream is the logrus hook broadcasting log entries to all subscribed HTTP clients
+type logStream struct {
+	mutex       sync.Mutex
+	subscribers map[chan logrus.Entry]struct{}
+	formatter   logrus.Formatter
+}
+
+func newLogStream() *logStream {
+	return &logStream{
+		subscribers: make(map[chan logrus.Entry]struct{}),
+		formatter:   &logrus.JSONFormatter{},
+	}
+}
+
+// Levels returns the available logging levels
+func (ls *logStream) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire sends log entry to every subscriber, entries are dropped for subscribers not keeping up
+func (ls *logStream) Fire(entry *logrus.Entry) error {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	for ch := range ls.subscribers {
+		select {
+		case ch <- *entry:
+		default:
+		}
+	}
+	return nil
+}
+
+func (ls *logStream) subscribe() chan logrus.Entry {
+	ch := make(chan logrus.Entry, subscriberCapacity)
+	ls.mutex.Lock()
+	ls.subscribers[ch] = struct{}{}
+	ls.mutex.Unlock()
+	return ch
+}
+
+func (ls *logStream) unsubscribe(ch chan logrus.Entry) {
+	ls.mutex.Lock()
+	delete(ls.subscribers, ch)
+	ls.mutex.Unlock()
+}
+
+// ServeHTTP streams log entries as Server-Sent Events, optionally filtered by the `chain` query parameter
+func (ls *logStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	chainID := r.URL.Query().Get("chain")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// ask client to reconnect immediately if the connection is lost
+	if _, err := fmt.Fprint(w, "retry: 1000\n\n"); err != nil {
+		return
+	}
+	flusher.Flush()
+	ch := ls.subscribe()
+	defer ls.unsubscribe(ch)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry := <-ch:
+			if chainID != "" && fmt.Sprint(entry.Data["chain"]) != chainID {
+				continue
+			}
+			data, err := ls.formatter.Format(&entry)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: log\ndata: %s\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
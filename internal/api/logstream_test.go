@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogStream(t *testing.T) {
+	ls := newLogStream()
+	assert.Equal(t, logrus.AllLevels, ls.Levels())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/log/stream?chain=42", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		ls.ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		ls.mutex.Lock()
+		defer ls.mutex.Unlock()
+		return len(ls.subscribers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	l := logrus.New()
+	assert.NoError(t, ls.Fire(l.WithField("chain", 24).WithField("message", "skipped")))
+	assert.NoError(t, ls.Fire(l.WithField("chain", 42).WithField("message", "streamed")))
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "streamed")
+	assert.NotContains(t, rec.Body.String(), "skipped")
+	assert.Empty(t, ls.subscribers)
+}
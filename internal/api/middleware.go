@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"golang.org/x/time/rate"
+)
+
+// clients not seen for this period are removed from the rate limiter
+const limiterIdleTimeout = 3 * time.Minute
+
+// maximum time to store the request in the API audit trail
+const apiAuditTimeout = 5 * time.Second
+
+// maximum time to handle the request and to write the response, see routeTimeout
+const handlerTimeout = 10 * time.Second
+
+// maximum time to handle requests returning long lists, e.g. the execution history or exported chains
+const listTimeout = time.Minute
+
+// rateLimiter limits the number of requests per second for each client IP address
+type rateLimiter struct {
+	sync.Mutex
+	limit     rate.Limit
+	burst     int
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(rps int, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = rps
+	}
+	return &rateLimiter{
+		limit:     rate.Limit(rps),
+		burst:     burst,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow reports whether the request from the client IP address may happen now
+func (rl *rateLimiter) Allow(ip string) bool {
+	rl.Lock()
+	defer rl.Unlock()
+	now := time.Now()
+	if now.Sub(rl.lastSweep) > limiterIdleTimeout {
+		for k, c := range rl.clients {
+			if now.Sub(c.lastSeen) > limiterIdleTimeout {
+				delete(rl.clients, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+	c, ok := rl.clients[ip]
+	if !ok {
+		c = &clientLimiter{Limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.clients[ip] = c
+	}
+	c.lastSeen = now
+	return c.Allow()
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitHandler rejects requests exceeding the rate limit with 429 status code.
+// Liveness and readiness probes are never limited
+func rateLimitHandler(rl *rateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/liveness", "/readiness":
+		default:
+			if !rl.Allow(clientIP(r)) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsHandler adds CORS headers for requests from the allowed origins and answers preflight requests.
+// The "*" origin allows requests from any origin
+func corsHandler(origins []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.TrimRight(strings.TrimSpace(o), "/")] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !allowed["*"] && !allowed[origin] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		h.Set("Access-Control-Allow-Origin", origin)
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeAuthHandler rejects requests changing the scheduler state unless such endpoints are enabled with `--rest-write`
+// and the client is authenticated with the bearer token or the verified client certificate.
+// Webhooks validate requests with their own secrets
+func writeAuthHandler(l log.LoggerIface, opts config.RestApiOpts, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions,
+			strings.HasPrefix(r.URL.Path, "/hooks/"):
+		case !opts.Write:
+			http.Error(w, "endpoints changing the scheduler state are disabled, see --rest-write", http.StatusForbidden)
+			return
+		case !config.Authenticated(r.TLS, r.Header.Get("Authorization"), opts.Token):
+			unauthorized(l, w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authHandler rejects all requests of clients not authenticated with the bearer token or the verified client
+// certificate, e.g. of profiling data exposing the command line
+func authHandler(l log.LoggerIface, opts config.RestApiOpts, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.Authenticated(r.TLS, r.Header.Get("Authorization"), opts.Token) {
+			unauthorized(l, w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(l log.LoggerIface, w http.ResponseWriter, r *http.Request) {
+	l.WithField("client", clientIP(r)).WithField("path", r.URL.Path).Warn("Unauthenticated REST API request rejected")
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// operatorHandler passes the identity of the client certificate to handlers with the request context,
+// so changes are attributed to the operator in the audit, and logs requests changing the scheduler state
+func operatorHandler(l log.LoggerIface, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if operator := config.ClientIdentity(r.TLS); operator != "" {
+			r = r.WithContext(pgengine.WithOperator(r.Context(), operator))
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				l.WithField("operator", operator).WithField("method", r.Method).
+					WithField("path", r.URL.Path).Info("REST API request")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeTimeout returns the maximum time to handle the request of the path, zero if the request is served
+// until the client disconnects, e.g. the log stream, or for the requested duration, e.g. CPU profiles
+func routeTimeout(path string) time.Duration {
+	switch {
+	case path == "/log/stream", strings.HasPrefix(path, "/debug/pprof/"):
+		return 0
+	case path == "/runs", path == "/audit", path == "/audit/api", strings.HasSuffix(path, "/export"):
+		return listTimeout
+	}
+	return handlerTimeout
+}
+
+// timeoutHandler replies with 503 status code to requests not handled in time and sets the write deadline
+// of the connection, so slow clients cannot hold it. The server has no write timeout, since it would apply
+// to every route, limits of routes are returned by routeTimeout
+func timeoutHandler(next http.Handler) http.Handler {
+	limited := map[time.Duration]http.Handler{
+		handlerTimeout: http.TimeoutHandler(next, handlerTimeout, ""),
+		listTimeout:    http.TimeoutHandler(next, listTimeout, ""),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := routeTimeout(r.URL.Path)
+		var deadline time.Time // zero value removes the deadline
+		if timeout > 0 {
+			// the response is buffered until the handler finishes, leave time to write it
+			deadline = time.Now().Add(timeout + handlerTimeout)
+		}
+		_ = http.NewResponseController(w).SetWriteDeadline(deadline)
+		if timeout == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limited[timeout].ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder remembers the status code of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the original writer, so http.ResponseController reaches the connection
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// pathChainID returns the chain ID of /chains/{id}/... requests or 0
+func pathChainID(path string) int {
+	if !strings.HasPrefix(path, "/chains/") {
+		return 0
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(path, "/chains/"), "/")
+	chainID, _ := strconv.Atoi(id)
+	return chainID
+}
+
+// apiAuditHandler stores requests changing the scheduler state, e.g. starting chains or switching the maintenance mode,
+// in the API audit trail with the operator, the client address and the response status.
+// The request is stored even if the client has gone, so the context of the request is not used
+func (Server *RestApiServer) apiAuditHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if Server.Reporter == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), apiAuditTimeout)
+		defer cancel()
+		Server.Reporter.LogAPICall(ctx, pgengine.APICall{
+			API:        "REST",
+			Operation:  r.Method + " " + r.URL.RequestURI(),
+			ChainID:    pathChainID(r.URL.Path),
+			Operator:   pgengine.Operator(r.Context()),
+			RemoteAddr: clientIP(r),
+			Status:     strconv.Itoa(rec.status),
+		})
+	})
+}
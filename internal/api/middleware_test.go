@@ -0,0 +1,82 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	h := rateLimitHandler(newRateLimiter(1, 2), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(path string, addr string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, do("/runs", "10.0.0.1:1000"))
+	assert.Equal(t, http.StatusOK, do("/runs", "10.0.0.1:1001"))
+	assert.Equal(t, http.StatusTooManyRequests, do("/runs", "10.0.0.1:1002"))
+	assert.Equal(t, http.StatusOK, do("/liveness", "10.0.0.1:1003"), "probes are never limited")
+	assert.Equal(t, http.StatusOK, do("/runs", "10.0.0.2:1000"), "limits are per client")
+}
+
+func TestCORSHandler(t *testing.T) {
+	h := corsHandler([]string{"https://example.com/", " https://foo.bar"},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(method string, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/runs", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := do(http.MethodGet, "https://example.com")
+	assert.Equal(t, "https://example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	rec = do(http.MethodOptions, "https://foo.bar")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+	rec = do(http.MethodGet, "https://evil.com")
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	h = corsHandler([]string{"*"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	assert.Equal(t, "https://evil.com", do(http.MethodGet, "https://evil.com").Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestOperatorHandler(t *testing.T) {
+	var operator string
+	h := operatorHandler(log.Init(config.LoggingOpts{LogLevel: "error"}),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { operator = pgengine.Operator(r.Context()) }))
+	req := httptest.NewRequest(http.MethodPost, "/chains/import", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, operator, "plain HTTP requests have no operator")
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "alice"}}}}}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "alice", operator)
+}
+
+func TestTimeoutHandler(t *testing.T) {
+	var flushable bool
+	h := timeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, flushable = w.(http.Flusher) }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/log/stream", nil))
+	assert.True(t, flushable, "log stream must not be limited")
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/runs", nil))
+	assert.False(t, flushable, "requests must be limited")
+
+	assert.Zero(t, routeTimeout("/log/stream"))
+	assert.Equal(t, listTimeout, routeTimeout("/runs"))
+	assert.Equal(t, listTimeout, routeTimeout("/chains/42/export"))
+	assert.Equal(t, handlerTimeout, routeTimeout("/chains/42/start"))
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+// default and maximum number of execution history entries returned at once
+const (
+	defaultRunsLimit = 100
+	maxRunsLimit     = 1000
+)
+
+var errInvalidStatus = errors.New("status must be one of: success, failure")
+
+// RunsPage is the paginated response of the execution history
+type RunsPage struct {
+	Runs   []pgengine.ExecutionLogEntry `json:"runs"`
+	Limit  int                          `json:"limit"`
+	Offset int                          `json:"offset"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func intParam(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	return strconv.Atoi(s)
+}
+
+func parseRunsFilter(r *http.Request) (f pgengine.ExecutionLogFilter, err error) {
+	if f.ChainID, err = intParam(r, "chain_id", 0); err != nil {
+		return
+	}
+	if f.Limit, err = intParam(r, "limit", defaultRunsLimit); err != nil {
+		return
+	}
+	if f.Offset, err = intParam(r, "offset", 0); err != nil {
+		return
+	}
+	if f.Limit <= 0 || f.Limit > maxRunsLimit {
+		f.Limit = maxRunsLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	switch f.Status = r.URL.Query().Get("status"); f.Status {
+	case "", "success", "failure":
+	default:
+		return f, errInvalidStatus
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		f.Since, err = time.Parse(time.RFC3339, since)
+	}
+	return
+}
+
+func (Server *RestApiServer) runsHandler(w http.ResponseWriter, r *http.Request) {
+	Server.l.Debug("Received /runs REST API request")
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	filter, err := parseRunsFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	runs, err := Server.Reporter.GetRuns(r.Context(), filter)
+	if err != nil {
+		Server.l.WithError(err).Error("Cannot fetch execution history")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, RunsPage{Runs: runs, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+func (Server *RestApiServer) activeRunsHandler(w http.ResponseWriter, r *http.Request) {
+	Server.l.Debug("Received /runs/active REST API request")
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, http.StatusOK, Server.Reporter.GetActiveChains())
+}
+
+// AuditPage is the paginated response of the configuration changes log
+type AuditPage struct {
+	Changes []pgengine.AuditEntry `json:"changes"`
+	Limit   int                   `json:"limit"`
+	Offset  int                   `json:"offset"`
+}
+
+func parseAuditFilter(r *http.Request) (f pgengine.AuditFilter, err error) {
+	if f.ChainID, err = intParam(r, "chain_id", 0); err != nil {
+		return
+	}
+	if f.Limit, err = intParam(r, "limit", defaultRunsLimit); err != nil {
+		return
+	}
+	if f.Offset, err = intParam(r, "offset", 0); err != nil {
+		return
+	}
+	if f.Limit <= 0 || f.Limit > maxRunsLimit {
+		f.Limit = maxRunsLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		f.Since, err = time.Parse(time.RFC3339, since)
+	}
+	return
+}
+
+func (Server *RestApiServer) auditHandler(w http.ResponseWriter, r *http.Request) {
+	Server.l.Debug("Received /audit REST API request")
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	changes, err := Server.Reporter.GetAudit(r.Context(), filter)
+	if err != nil {
+		Server.l.WithError(err).Error("Cannot fetch configuration changes")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, AuditPage{Changes: changes, Limit: filter.Limit, Offset: filter.Offset})
+}
+
+// APICallsPage is the paginated response of the API audit trail
+type APICallsPage struct {
+	Calls  []pgengine.APICall `json:"calls"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+}
+
+func parseAPICallFilter(r *http.Request) (f pgengine.APICallFilter, err error) {
+	if f.ChainID, err = intParam(r, "chain_id", 0); err != nil {
+		return
+	}
+	if f.Limit, err = intParam(r, "limit", defaultRunsLimit); err != nil {
+		return
+	}
+	if f.Offset, err = intParam(r, "offset", 0); err != nil {
+		return
+	}
+	if f.Limit <= 0 || f.Limit > maxRunsLimit {
+		f.Limit = maxRunsLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	f.Operator = r.URL.Query().Get("operator")
+	if since := r.URL.Query().Get("since"); since != "" {
+		f.Since, err = time.Parse(time.RFC3339, since)
+	}
+	return
+}
+
+func (Server *RestApiServer) apiCallsHandler(w http.ResponseWriter, r *http.Request) {
+	Server.l.Debug("Received /audit/api REST API request")
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	filter, err := parseAPICallFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	calls, err := Server.Reporter.GetAPICalls(r.Context(), filter)
+	if err != nil {
+		Server.l.WithError(err).Error("Cannot fetch API audit trail")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, APICallsPage{Calls: calls, Limit: filter.Limit, Offset: filter.Offset})
+}
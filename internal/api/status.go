@@ -1,12 +1,19 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
+	"strings"
 	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/config"
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/cybertec-postgresql/pg_timetable/internal/scheduler"
+	"github.com/cybertec-postgresql/pg_timetable/internal/signature"
 )
 
 // StatusReporter is a common interface describing the current status of a connection
@@ -14,30 +21,101 @@ type StatusReporter interface {
 	IsReady() bool
 }
 
+// RestHandler is the interface used by the REST API server to interact with the scheduler
+type RestHandler interface {
+	StatusReporter
+	GetRuns(ctx context.Context, filter pgengine.ExecutionLogFilter) ([]pgengine.ExecutionLogEntry, error)
+	GetAudit(ctx context.Context, filter pgengine.AuditFilter) ([]pgengine.AuditEntry, error)
+	GetAPICalls(ctx context.Context, filter pgengine.APICallFilter) ([]pgengine.APICall, error)
+	LogAPICall(ctx context.Context, call pgengine.APICall)
+	GetActiveChains() []scheduler.ActiveChain
+	GetClusterStatus(ctx context.Context) ([]pgengine.ClientStatus, error)
+	GetChainNextRuns(ctx context.Context, chainID int, count int) ([]time.Time, error)
+	ExportChain(ctx context.Context, chainID int) (pgengine.ChainDefinition, error)
+	ImportChain(ctx context.Context, def pgengine.ChainDefinition) (int, error)
+	SetChainsLive(ctx context.Context, live bool, selector pgengine.ChainSelector) ([]string, error)
+	StartChain(ctx context.Context, chainName string, payload string) (int, error)
+	SetChainLogLevel(chainID int, level string) error
+	Reload() error
+	SetMaintenance(on bool)
+	InMaintenance() bool
+}
+
 type RestApiServer struct {
-	Reporter StatusReporter
+	Reporter RestHandler
+	Verifier *signature.Verifier // imported chain definitions must be signed if set
 	l        log.LoggerIface
+	webhooks []config.WebhookOpts
+	programs bool   // chains with PROGRAM tasks may be imported
+	token    string // authenticates clients of endpoints exposing credentials, e.g. the chain export
 	http.Server
 }
 
-func Init(opts config.RestApiOpts, logger log.LoggerIface) *RestApiServer {
+// Init creates the REST API server and starts listening if the port is specified.
+// The server uses TLS if tlsConfig is not nil
+func Init(opts config.RestApiOpts, tlsConfig *tls.Config, logger log.LoggerHookerIface) *RestApiServer {
+	// use own multiplexer, so handlers registered to the default one, e.g. by net/http/pprof, are not exposed
+	mux := http.NewServeMux()
 	s := &RestApiServer{
+		nil,
 		nil,
 		logger,
+		opts.Webhooks,
+		opts.Programs,
+		opts.Token,
 		http.Server{
 			Addr:           fmt.Sprintf(":%d", opts.Port),
+			Handler:        mux,
 			ReadTimeout:    10 * time.Second,
-			WriteTimeout:   10 * time.Second,
 			MaxHeaderBytes: 1 << 20,
+			TLSConfig:      tlsConfig,
 		},
 	}
-	http.HandleFunc("/liveness", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/liveness", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK) // i'm serving hence I'm alive
 	})
-	http.HandleFunc("/readiness", s.readinessHandler)
+	mux.HandleFunc("/readiness", s.readinessHandler)
+	mux.HandleFunc("/runs", s.runsHandler)
+	mux.HandleFunc("/runs/active", s.activeRunsHandler)
+	mux.HandleFunc("/audit", s.auditHandler)
+	mux.HandleFunc("/audit/api", s.apiCallsHandler)
+	mux.HandleFunc("/cluster", s.clusterHandler)
+	mux.HandleFunc("/chains/", s.chainsHandler)
+	mux.HandleFunc("/reload", s.reloadHandler)
+	mux.HandleFunc("/maintenance", s.maintenanceHandler)
+	mux.HandleFunc("/maintenance/", s.maintenanceHandler)
+	mux.HandleFunc("/hooks/", s.webhookHandler)
+	mux.HandleFunc("/api/docs/openapi.json", openAPIHandler)
+	logs := newLogStream()
+	logger.AddHook(log.OutputHook(logs))
+	mux.Handle("/log/stream", logs)
+	if opts.SwaggerUI {
+		mux.HandleFunc("/api/docs/", swaggerUIHandler)
+	}
+	if opts.Pprof {
+		// profiles and the command line with passwords are available only to authenticated clients
+		mux.Handle("/debug/pprof/", authHandler(logger, opts, http.HandlerFunc(pprof.Index)))
+		mux.Handle("/debug/pprof/cmdline", authHandler(logger, opts, http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("/debug/pprof/profile", authHandler(logger, opts, http.HandlerFunc(pprof.Profile)))
+		mux.Handle("/debug/pprof/symbol", authHandler(logger, opts, http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("/debug/pprof/trace", authHandler(logger, opts, http.HandlerFunc(pprof.Trace)))
+	}
+	s.Handler = operatorHandler(logger, writeAuthHandler(logger, opts, s.apiAuditHandler(timeoutHandler(s.Handler))))
+	if opts.RateLimit > 0 {
+		s.Handler = rateLimitHandler(newRateLimiter(opts.RateLimit, opts.RateBurst), s.Handler)
+	}
+	if opts.CORSOrigins != "" {
+		s.Handler = corsHandler(strings.Split(opts.CORSOrigins, ","), s.Handler)
+	}
 	if opts.Port != 0 {
 		logger.WithField("port", opts.Port).Info("Starting REST API server...")
-		go func() { logger.Error(s.ListenAndServe()) }()
+		go func() {
+			if tlsConfig != nil {
+				logger.Error(s.ListenAndServeTLS("", ""))
+			} else {
+				logger.Error(s.ListenAndServe())
+			}
+		}()
 	}
 	return s
 }
@@ -50,3 +128,40 @@ func (Server *RestApiServer) readinessHandler(w http.ResponseWriter, r *http.Req
 	}
 	w.WriteHeader(http.StatusOK)
 }
+
+func (Server *RestApiServer) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	Server.l.Debug("Received /reload REST API request")
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if err := Server.Reporter.Reload(); err != nil {
+		Server.l.WithError(err).Error("Cannot reload configuration")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (Server *RestApiServer) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	Server.l.WithField("path", r.URL.Path).Debug("Received /maintenance REST API request")
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	switch {
+	case r.URL.Path == "/maintenance" && r.Method == http.MethodGet:
+	case r.URL.Path == "/maintenance/on" && r.Method == http.MethodPost:
+		Server.Reporter.SetMaintenance(true)
+	case r.URL.Path == "/maintenance/off" && r.Method == http.MethodPost:
+		Server.Reporter.SetMaintenance(false)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"maintenance": Server.Reporter.InMaintenance()})
+}
@@ -1,24 +1,179 @@
 package api_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/api"
 	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/cybertec-postgresql/pg_timetable/internal/scheduler"
+	"github.com/cybertec-postgresql/pg_timetable/internal/signature"
+	pgx "github.com/jackc/pgx/v4"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
 )
 
 type reporter struct {
+	maintenance bool
+	mu          sync.Mutex
+	calls       []pgengine.APICall
 }
 
 func (r *reporter) IsReady() bool {
 	return true
 }
 
+func (r *reporter) GetRuns(ctx context.Context, filter pgengine.ExecutionLogFilter) ([]pgengine.ExecutionLogEntry, error) {
+	if filter.ChainID < 0 {
+		return nil, errors.New("invalid chain")
+	}
+	return []pgengine.ExecutionLogEntry{{ChainID: filter.ChainID}}, nil
+}
+
+func (r *reporter) GetAudit(ctx context.Context, filter pgengine.AuditFilter) ([]pgengine.AuditEntry, error) {
+	if filter.ChainID < 0 {
+		return nil, errors.New("invalid chain")
+	}
+	return []pgengine.AuditEntry{{ChainID: filter.ChainID, Operation: "UPDATE"}}, nil
+}
+
+func (r *reporter) GetAPICalls(ctx context.Context, filter pgengine.APICallFilter) ([]pgengine.APICall, error) {
+	if filter.ChainID < 0 {
+		return nil, errors.New("invalid chain")
+	}
+	return []pgengine.APICall{{ChainID: filter.ChainID, Operator: filter.Operator}}, nil
+}
+
+func (r *reporter) LogAPICall(ctx context.Context, call pgengine.APICall) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *reporter) GetActiveChains() []scheduler.ActiveChain {
+	return []scheduler.ActiveChain{{ChainID: 42, TaskID: 24}}
+}
+
+func (r *reporter) GetClusterStatus(ctx context.Context) ([]pgengine.ClientStatus, error) {
+	v1, v2, queued, leader := "v5.1.0", "v5.2.0", 3, true
+	return []pgengine.ClientStatus{
+		{ClientName: "worker01", Version: &v1, Healthy: true, ActiveChains: 2, QueuedChains: &queued, Leader: &leader},
+		{ClientName: "worker02", Version: &v2, Healthy: true, ActiveChains: 1, QueuedChains: &queued, Leader: &leader},
+		{ClientName: "worker03", Version: &v1, ActiveChains: 1},
+		{ClientName: "legacy"}, // older clients do not report the status
+	}, nil
+}
+
+func (r *reporter) Reload() error {
+	return nil
+}
+
+func (r *reporter) SetMaintenance(on bool) {
+	r.maintenance = on
+}
+
+func (r *reporter) InMaintenance() bool {
+	return r.maintenance
+}
+
+func (r *reporter) GetChainNextRuns(ctx context.Context, chainID int, count int) ([]time.Time, error) {
+	switch chainID {
+	case 0:
+		return nil, pgx.ErrNoRows
+	case 1:
+		return nil, cron.ErrNotCron
+	}
+	return make([]time.Time, count), nil
+}
+
+func (r *reporter) ExportChain(ctx context.Context, chainID int) (pgengine.ChainDefinition, error) {
+	if chainID == 0 {
+		return pgengine.ChainDefinition{}, pgx.ErrNoRows
+	}
+	return pgengine.ChainDefinition{Name: "foo", Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1"}}}, nil
+}
+
+func (r *reporter) SetChainsLive(ctx context.Context, live bool, selector pgengine.ChainSelector) ([]string, error) {
+	if len(selector.Tags) == 0 && selector.Pattern == "" {
+		return nil, pgengine.ErrNoChainSelector
+	}
+	return []string{"etl_load"}, nil
+}
+
+func (r *reporter) ImportChain(ctx context.Context, def pgengine.ChainDefinition) (int, error) {
+	if err := def.Validate(); err != nil {
+		return 0, err
+	}
+	return 42, nil
+}
+
+func (r *reporter) StartChain(ctx context.Context, chainName string, payload string) (int, error) {
+	switch chainName {
+	case "unknown":
+		return 0, pgx.ErrNoRows
+	case "busy":
+		return 0, scheduler.ErrQueueFull
+	}
+	return len(payload), nil
+}
+
+func (r *reporter) SetChainLogLevel(chainID int, level string) error {
+	if level != "" && level != "debug" {
+		return errors.New("invalid level")
+	}
+	return nil
+}
+
+// testToken authenticates requests changing the scheduler state
+const testToken = "secret-token"
+
+// post sends the authenticated POST request
+func post(url string, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	return http.DefaultClient.Do(req)
+}
+
+// get sends the authenticated GET request
+func get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	return http.DefaultClient.Do(req)
+}
+
 func TestStatus(t *testing.T) {
-	restsrv := api.Init(config.RestApiOpts{Port: 8080}, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	restsrv := api.Init(config.RestApiOpts{Port: 8080, Pprof: true, Write: true, Token: testToken, Webhooks: []config.WebhookOpts{
+		{Name: "foo", Chain: "foo", Secret: "secret", PassBody: true},
+		{Name: "bar", Chain: "unknown", Secret: "secret"},
+		{Name: "baz", Chain: "busy", Secret: "secret"},
+		{Name: "qux", Chain: "foo"},
+	}}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
 	r, err := http.Get("http://localhost:8080/liveness")
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, r.StatusCode)
@@ -32,3 +187,387 @@ func TestStatus(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, r.StatusCode)
 }
+
+func TestOpenAPI(t *testing.T) {
+	r, err := http.Get("http://localhost:8080/api/docs/openapi.json")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var spec map[string]interface{}
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&spec))
+	assert.Contains(t, spec, "paths")
+}
+
+func TestPprof(t *testing.T) {
+	r, err := get("http://localhost:8080/debug/pprof/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap"} {
+		r, err = http.Get("http://localhost:8080" + path)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, r.StatusCode, path)
+	}
+
+	// the profile longer than the default handler timeout must not be cut
+	r, err = get("http://localhost:8080/debug/pprof/profile?seconds=11")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	b, err := io.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, b)
+}
+
+func TestRuns(t *testing.T) {
+	r, err := http.Get("http://localhost:8080/runs?chain_id=42&status=failure&since=2022-01-01T00:00:00Z&limit=5")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var page api.RunsPage
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&page))
+	assert.Equal(t, 5, page.Limit)
+	assert.Equal(t, 42, page.Runs[0].ChainID)
+
+	for _, query := range []string{"chain_id=foo", "status=bar", "since=yesterday", "limit=-"} {
+		r, err = http.Get("http://localhost:8080/runs?" + query)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, r.StatusCode, query)
+	}
+
+	r, err = http.Get("http://localhost:8080/runs?chain_id=-1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, r.StatusCode)
+}
+
+func TestAudit(t *testing.T) {
+	r, err := http.Get("http://localhost:8080/audit?chain_id=42&since=2022-01-01T00:00:00Z&limit=5&offset=10")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var page api.AuditPage
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&page))
+	assert.Equal(t, 5, page.Limit)
+	assert.Equal(t, 10, page.Offset)
+	assert.Equal(t, 42, page.Changes[0].ChainID)
+
+	for _, query := range []string{"chain_id=foo", "since=yesterday", "offset=-"} {
+		r, err = http.Get("http://localhost:8080/audit?" + query)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, r.StatusCode, query)
+	}
+
+	r, err = http.Get("http://localhost:8080/audit?chain_id=-1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, r.StatusCode)
+}
+
+func TestAPIAudit(t *testing.T) {
+	srv := api.Init(config.RestApiOpts{Write: true, Token: testToken}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	rep := &reporter{}
+	srv.Reporter = rep
+	do := func(method string, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+	do(http.MethodPost, "/maintenance/on")
+	do(http.MethodPut, "/chains/42/log-level?level=foo")
+	do(http.MethodGet, "/maintenance")
+	assert.Equal(t, []pgengine.APICall{
+		{API: "REST", Operation: "POST /maintenance/on", RemoteAddr: "192.0.2.1", Status: "200"},
+		{API: "REST", Operation: "PUT /chains/42/log-level?level=foo", ChainID: 42, RemoteAddr: "192.0.2.1", Status: "400"},
+	}, rep.calls, "only requests changing the scheduler state are stored")
+
+	rec := do(http.MethodGet, "/audit/api?chain_id=42&operator=alice&since=2022-01-01T00:00:00Z&limit=5")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var page api.APICallsPage
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	assert.Equal(t, 5, page.Limit)
+	assert.Equal(t, "alice", page.Calls[0].Operator)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/audit/api?since=yesterday").Code)
+	assert.Equal(t, http.StatusInternalServerError, do(http.MethodGet, "/audit/api?chain_id=-1").Code)
+}
+
+func TestActiveRuns(t *testing.T) {
+	r, err := http.Get("http://localhost:8080/runs/active")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var chains []scheduler.ActiveChain
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&chains))
+	assert.Equal(t, 42, chains[0].ChainID)
+	assert.Equal(t, 24, chains[0].TaskID)
+}
+
+func TestCluster(t *testing.T) {
+	r, err := http.Get("http://localhost:8080/cluster")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var status api.ClusterStatus
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+	assert.Len(t, status.Clients, 4)
+	assert.Equal(t, 2, status.Healthy)
+	assert.Equal(t, 4, status.ActiveChains)
+	assert.Equal(t, 6, status.QueuedChains)
+	assert.Equal(t, []string{"v5.1.0", "v5.2.0"}, status.Versions)
+
+	r, err = post("http://localhost:8080/cluster", "application/json", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, r.StatusCode)
+}
+
+func TestChainNextRuns(t *testing.T) {
+	r, err := http.Get("http://localhost:8080/chains/42/next?count=3")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var runs []time.Time
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&runs))
+	assert.Len(t, runs, 3)
+
+	for url, status := range map[string]int{
+		"/chains/0/next":          http.StatusNotFound,
+		"/chains/1/next":          http.StatusUnprocessableEntity,
+		"/chains/foo/next":        http.StatusBadRequest,
+		"/chains/42/next?count=0": http.StatusBadRequest,
+		"/chains/42/unknown":      http.StatusNotFound,
+	} {
+		r, err = http.Get("http://localhost:8080" + url)
+		assert.NoError(t, err)
+		assert.Equal(t, status, r.StatusCode, url)
+	}
+}
+
+func TestReload(t *testing.T) {
+	r, err := http.Get("http://localhost:8080/reload")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, r.StatusCode)
+
+	r, err = post("http://localhost:8080/reload", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+}
+
+func TestMaintenance(t *testing.T) {
+	var m map[string]bool
+	r, err := post("http://localhost:8080/maintenance/on", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+	assert.True(t, m["maintenance"])
+
+	r, err = http.Get("http://localhost:8080/maintenance")
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+	assert.True(t, m["maintenance"])
+
+	r, err = post("http://localhost:8080/maintenance/off", "", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+	assert.False(t, m["maintenance"])
+
+	r, err = http.Get("http://localhost:8080/maintenance/on")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, r.StatusCode)
+}
+
+func TestChainsLive(t *testing.T) {
+	r, err := post("http://localhost:8080/chains/disable?tag=etl&pattern=etl_*", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var res struct {
+		Live   bool     `json:"live"`
+		Chains []string `json:"chains"`
+	}
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&res))
+	assert.False(t, res.Live)
+	assert.Equal(t, []string{"etl_load"}, res.Chains)
+
+	r, err = post("http://localhost:8080/chains/enable", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, r.StatusCode)
+
+	r, err = http.Get("http://localhost:8080/chains/enable?tag=etl")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, r.StatusCode, "enable is not a chain ID")
+}
+
+func TestChainExportImport(t *testing.T) {
+	r, err := http.Get("http://localhost:8080/chains/42/export")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, r.StatusCode, "definitions contain credentials")
+
+	r, err = get("http://localhost:8080/chains/42/export")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var def pgengine.ChainDefinition
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&def))
+	assert.Equal(t, "foo", def.Name)
+
+	r, err = get("http://localhost:8080/chains/42/export?format=yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/yaml", r.Header.Get("Content-Type"))
+
+	r, err = get("http://localhost:8080/chains/0/export")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, r.StatusCode)
+
+	r, err = http.Get("http://localhost:8080/chains/42/graph")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	assert.Equal(t, "text/vnd.graphviz", r.Header.Get("Content-Type"))
+	b, _ := io.ReadAll(r.Body)
+	assert.Contains(t, string(b), "schedule -> task1;")
+
+	for path, status := range map[string]int{
+		"/chains/0/graph":             http.StatusNotFound,
+		"/chains/42/graph?format=png": http.StatusBadRequest,
+	} {
+		r, err = http.Get("http://localhost:8080" + path)
+		assert.NoError(t, err)
+		assert.Equal(t, status, r.StatusCode, path)
+	}
+
+	for body, status := range map[string]int{
+		`{"name": "foo", "tasks": [{"command": "SELECT 1"}]}`: http.StatusOK,
+		`{"tasks": []}`: http.StatusUnprocessableEntity,
+		`not a json`:    http.StatusBadRequest,
+	} {
+		r, err = post("http://localhost:8080/chains/import", "application/json", strings.NewReader(body))
+		assert.NoError(t, err)
+		assert.Equal(t, status, r.StatusCode, body)
+	}
+
+	r, err = post("http://localhost:8080/chains/import", "application/yaml",
+		strings.NewReader("name: foo\ntasks:\n  - command: SELECT $1\n    parameters: [[42]]\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var res map[string]int
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&res))
+	assert.Equal(t, 42, res["chain_id"])
+}
+
+func TestSignedChainImport(t *testing.T) {
+	entity, err := openpgp.NewEntity("pg_timetable", "", "deploy@example.com", nil)
+	assert.NoError(t, err)
+	var keyring bytes.Buffer
+	w, err := armor.Encode(&keyring, openpgp.PublicKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, entity.Serialize(w))
+	assert.NoError(t, w.Close())
+	keyFile := filepath.Join(t.TempDir(), "keyring.asc")
+	assert.NoError(t, os.WriteFile(keyFile, keyring.Bytes(), 0644))
+
+	srv := api.Init(config.RestApiOpts{Write: true, Token: testToken}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	srv.Reporter = &reporter{}
+	srv.Verifier, err = signature.Load(keyFile)
+	assert.NoError(t, err)
+
+	body := `{"name": "foo", "tasks": [{"command": "SELECT 1"}]}`
+	var sig bytes.Buffer
+	assert.NoError(t, openpgp.DetachSign(&sig, entity, strings.NewReader(body), nil))
+	do := func(body string, sig string) int {
+		req := httptest.NewRequest(http.MethodPost, "/chains/import", strings.NewReader(body))
+		req.Header.Set("X-Chain-Signature", sig)
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	encoded := base64.StdEncoding.EncodeToString(sig.Bytes())
+	assert.Equal(t, http.StatusOK, do(body, encoded))
+	assert.Equal(t, http.StatusForbidden, do(body, ""), "signature is missing")
+	assert.Equal(t, http.StatusForbidden, do(strings.Replace(body, "SELECT 1", "SELECT 2", 1), encoded), "definition is tampered")
+	assert.Equal(t, http.StatusBadRequest, do(body, "not base64!"))
+}
+
+func TestWriteAuth(t *testing.T) {
+	do := func(srv *api.RestApiServer, method string, target string, body string, token string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	program := `{"name": "foo", "tasks": [{"kind": "PROGRAM", "command": "rm"}]}`
+
+	srv := api.Init(config.RestApiOpts{Token: testToken}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	srv.Reporter = &reporter{}
+	assert.Equal(t, http.StatusForbidden, do(srv, http.MethodPost, "/maintenance/on", "", testToken), "writes are disabled by default")
+	assert.Equal(t, http.StatusOK, do(srv, http.MethodGet, "/maintenance", "", ""), "reads need no authentication")
+
+	srv = api.Init(config.RestApiOpts{Write: true, Token: testToken}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	srv.Reporter = &reporter{}
+	assert.Equal(t, http.StatusUnauthorized, do(srv, http.MethodPost, "/reload", "", ""))
+	assert.Equal(t, http.StatusUnauthorized, do(srv, http.MethodPost, "/reload", "", "wrong"))
+	assert.Equal(t, http.StatusOK, do(srv, http.MethodPost, "/reload", "", testToken))
+	assert.Equal(t, http.StatusForbidden, do(srv, http.MethodPost, "/chains/import", program, testToken),
+		"PROGRAM tasks are not imported by default")
+
+	srv = api.Init(config.RestApiOpts{Write: true, Token: testToken, Programs: true}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	srv.Reporter = &reporter{}
+	assert.Equal(t, http.StatusOK, do(srv, http.MethodPost, "/chains/import", program, testToken))
+}
+
+func TestChainLogLevel(t *testing.T) {
+	do := func(method string, url string) *http.Response {
+		req, err := http.NewRequest(method, "http://localhost:8080"+url, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		r, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return r
+	}
+	r := do(http.MethodPut, "/chains/42/log-level?level=debug")
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+	var res map[string]interface{}
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&res))
+	assert.Equal(t, "debug", res["log_level"])
+
+	r = do(http.MethodDelete, "/chains/42/log-level")
+	assert.Equal(t, http.StatusOK, r.StatusCode)
+
+	for url, status := range map[string]int{
+		"/chains/42/log-level":             http.StatusBadRequest,
+		"/chains/42/log-level?level=foo":   http.StatusBadRequest,
+		"/chains/foo/log-level?level=info": http.StatusBadRequest,
+	} {
+		r = do(http.MethodPut, url)
+		assert.Equal(t, status, r.StatusCode, url)
+	}
+	r = do(http.MethodGet, "/chains/42/log-level")
+	assert.Equal(t, http.StatusNotFound, r.StatusCode)
+}
+
+func TestWebhooks(t *testing.T) {
+	post := func(hook string, body string, header string, value string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/hooks/"+hook, strings.NewReader(body))
+		assert.NoError(t, err)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		r, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		return r
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write([]byte("payload"))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	r := post("foo", "payload", "X-Hub-Signature-256", signature)
+	assert.Equal(t, http.StatusAccepted, r.StatusCode)
+	var res map[string]int
+	assert.NoError(t, json.NewDecoder(r.Body).Decode(&res))
+	assert.Equal(t, len("payload"), res["chain_id"])
+
+	assert.Equal(t, http.StatusAccepted, post("foo", "payload", "X-Webhook-Secret", "secret").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, post("foo", "payload", "X-Webhook-Secret", "wrong").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, post("foo", "tampered", "X-Hub-Signature-256", signature).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, post("foo", "payload", "", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, post("bar", "", "X-Webhook-Secret", "secret").StatusCode)
+	assert.Equal(t, http.StatusServiceUnavailable, post("baz", "", "X-Webhook-Secret", "secret").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, post("qux", "", "", "").StatusCode, "webhook without the secret")
+	assert.Equal(t, http.StatusNotFound, post("unknown", "", "", "").StatusCode)
+
+	r, err := http.Get("http://localhost:8080/hooks/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, r.StatusCode)
+}
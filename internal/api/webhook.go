@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+)
+
+// maximum size of the webhook request body in bytes
+const maxWebhookPayload = 1 << 20
+
+// validSignature checks if the request is signed with the webhook secret. Both the plain secret passed
+// in the X-Webhook-Secret header and the HMAC-SHA256 signature of the body passed in the
+// X-Hub-Signature-256 header (GitHub and compatible services) are accepted. Requests of webhooks
+// without the secret are never valid
+func validSignature(r *http.Request, body []byte, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	if s := r.Header.Get("X-Webhook-Secret"); s != "" {
+		return subtle.ConstantTimeCompare([]byte(s), []byte(secret)) == 1
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="))
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// webhookHandler serves /hooks/{name} requests starting the chain mapped to the webhook
+func (Server *RestApiServer) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/hooks/"), "/")
+	l := Server.l.WithField("webhook", name)
+	l.Debug("Received /hooks REST API request")
+	var hook *config.WebhookOpts
+	for i := range Server.webhooks {
+		if Server.webhooks[i].Name == name {
+			hook = &Server.webhooks[i]
+			break
+		}
+	}
+	if hook == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if Server.Reporter == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validSignature(r, body, hook.Secret) {
+		l.Warn("Webhook request with invalid secret rejected")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var payload string
+	if hook.PassBody {
+		payload = string(body)
+	}
+	chainID, err := Server.Reporter.StartChain(r.Context(), hook.Chain, payload)
+	if err != nil {
+		l.WithError(err).Error("Cannot start chain")
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int{"chain_id": chainID})
+}
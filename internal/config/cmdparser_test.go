@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	flags "github.com/jessevdk/go-flags"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,6 +34,8 @@ func TestParseSuccess(t *testing.T) {
 func TestLogLevel(t *testing.T) {
 	c := &CmdOptions{Logging: LoggingOpts{LogLevel: "debug"}}
 	assert.True(t, c.Verbose())
+	c = &CmdOptions{Logging: LoggingOpts{LogLevel: "trace"}}
+	assert.True(t, c.Verbose())
 	c = &CmdOptions{Logging: LoggingOpts{LogLevel: "info"}}
 	assert.False(t, c.Verbose())
 }
@@ -45,7 +48,138 @@ func TestVersionOnly(t *testing.T) {
 	assert.False(t, c.VersionOnly())
 }
 
+func TestBuiltinDisabled(t *testing.T) {
+	c := NewCmdOptions("--disable-builtins=Shutdown, CopyFromFile")
+	assert.True(t, c.BuiltinDisabled("Shutdown"))
+	assert.True(t, c.BuiltinDisabled("CopyFromFile"))
+	assert.False(t, c.BuiltinDisabled("CopyToFile"))
+	assert.False(t, NewCmdOptions().BuiltinDisabled("Shutdown"))
+}
+
+func TestTags(t *testing.T) {
+	assert.Equal(t, []string{"etl", "reports"}, NewCmdOptions("--chain-tags=etl, reports,,").Tags())
+	assert.NotNil(t, NewCmdOptions().Tags(), "empty list is passed to the database instead of NULL")
+	assert.Empty(t, NewCmdOptions().Tags())
+}
+
+func TestClientLabels(t *testing.T) {
+	labels, err := NewCmdOptions("--client-labels=os=linux, has_gpu=true").Labels()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"os": "linux", "has_gpu": "true"}, labels)
+	labels, err = NewCmdOptions().Labels()
+	assert.NoError(t, err)
+	assert.NotNil(t, labels, "empty object is passed to the database instead of NULL")
+	_, err = NewCmdOptions("--client-labels=linux").Labels()
+	assert.Error(t, err)
+}
+
+func TestLogLabels(t *testing.T) {
+	labels, err := NewCmdOptions("--log-labels=env=prod, region = eu,,team=").Logging.Labels()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "region": "eu", "team": ""}, labels)
+	labels, err = NewCmdOptions().Logging.Labels()
+	assert.NoError(t, err)
+	assert.Empty(t, labels)
+	_, err = NewCmdOptions("--log-labels=env").Logging.Labels()
+	assert.Error(t, err)
+	_, err = NewCmdOptions("--log-labels==prod").Logging.Labels()
+	assert.Error(t, err)
+}
+
 func TestNewCmdOptions(t *testing.T) {
 	c := NewCmdOptions("-c", "config_unit_test", "--password=somestrong")
 	assert.NotNil(t, c)
 }
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		args    []string
+		command string
+		cmdArgs []string
+		pgurl   string
+	}{
+		{[]string{0: "go-test", "-c", "client01", "postgres://localhost/db"}, "", nil, "postgres://localhost/db"},
+		{[]string{0: "go-test", "run", "-c", "client01", "postgres://localhost/db"}, "run", nil, "postgres://localhost/db"},
+		{[]string{0: "go-test", "-c", "client01", "upgrade"}, "upgrade", nil, ""},
+		{[]string{0: "go-test", "-c", "client01", "doctor"}, "doctor", nil, ""},
+		{[]string{0: "go-test", "-c", "client01", "chain", "start", "foo", "42"}, "chain start", []string{"foo", "42"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "chain", "handoff", "client02", "foo"}, "chain handoff", []string{"client02", "foo"}, ""},
+		{[]string{0: "go-test", "export", "foo", "-c", "client01"}, "export", []string{"foo"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "encrypt", "postgres://localhost/db"}, "encrypt", []string{"postgres://localhost/db"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "import", "chain.yaml"}, "import", []string{"chain.yaml"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "chain", "apply", "-f", "a.yaml", "--file=b.yaml", "--prune"}, "chain apply", []string{"a.yaml", "b.yaml"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "crontab", "--system", "/etc/crontab"}, "crontab", []string{"/etc/crontab"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "pgagent", "--prefix=agent", "--source=dbname=postgres"}, "pgagent", nil, ""},
+		{[]string{0: "go-test", "-c", "client01", "pgcron", "--apply", "--disable"}, "pgcron", nil, ""},
+		{[]string{0: "go-test", "-c", "client01", "cronjob", "--prefix=k8s", "backup.yaml"}, "cronjob", []string{"backup.yaml"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "ical", "--count=3"}, "ical", nil, ""},
+		{[]string{0: "go-test", "-c", "client01", "graph", "backup", "--format=svg"}, "graph", []string{"backup"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "chain", "list", "--live", "--client=foo", "--tag=etl", "--json"}, "chain list", nil, ""},
+		{[]string{0: "go-test", "-c", "client01", "chain", "diff", "-f", "a.yaml", "--prune"}, "chain diff", []string{"a.yaml"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "chain", "disable", "--tag=etl", "--pattern=etl_*"}, "chain disable", nil, ""},
+		{[]string{0: "go-test", "completion", "zsh"}, "completion", []string{"zsh"}, ""},
+		{[]string{0: "go-test", "-c", "client01", "top", "--refresh=5"}, "top", nil, ""},
+		{[]string{0: "go-test", "-c", "client01", "stats", "--days=7", "--format=json"}, "stats", nil, ""},
+		{[]string{0: "go-test", "-c", "client01", "exec", "--sql=VACUUM"}, "exec", nil, ""},
+		{[]string{0: "go-test", "cron-next", "0 */2 * * *", "--count=3", "--tz=Europe/Vienna"}, "cron-next", []string{"0 */2 * * *"}, ""},
+	}
+	for _, tc := range tests {
+		os.Args = tc.args
+		cfg, err := NewConfig(nil)
+		assert.NoError(t, err, tc.args)
+		assert.Equal(t, tc.command, cfg.Command, tc.args)
+		assert.Equal(t, tc.cmdArgs, cfg.CommandArgs, tc.args)
+		assert.Equal(t, tc.pgurl, cfg.Connection.PgURL, tc.args)
+		assert.Equal(t, tc.command == "" || tc.command == "run", cfg.IsRunCommand())
+	}
+
+	os.Args = []string{0: "go-test", "-c", "client01", "chain", "apply", "-f", "a.yaml", "--dry-run"}
+	cfg, err := NewConfig(nil)
+	assert.NoError(t, err)
+	assert.True(t, cfg.Commands.Chain.Apply.DryRun)
+	assert.False(t, cfg.Commands.Chain.Apply.Prune)
+
+	for _, args := range [][]string{
+		{0: "go-test", "-c", "client01", "chain"},
+		{0: "go-test", "-c", "client01", "chain", "start"},
+		{0: "go-test", "-c", "client01", "chain", "handoff", "client02"},
+		{0: "go-test", "-c", "client01", "chain", "apply", "--prune"},
+		{0: "go-test", "-c", "client01", "export"},
+		{0: "go-test", "-c", "client01", "encrypt"},
+		{0: "go-test", "-c", "client01", "import"},
+		{0: "go-test", "-c", "client01", "crontab", "--system"},
+		{0: "go-test", "-c", "client01", "pgcron", "--disable"},
+		{0: "go-test", "-c", "client01", "cronjob"},
+		{0: "go-test", "-c", "client01", "ical", "--count=0"},
+		{0: "go-test", "-c", "client01", "graph"},
+		{0: "go-test", "-c", "client01", "graph", "backup", "--format=png"},
+		{0: "go-test", "-c", "client01", "chain", "diff"},
+		{0: "go-test", "-c", "client01", "chain", "enable"},
+		{0: "go-test", "completion"},
+		{0: "go-test", "completion", "powershell"},
+		{0: "go-test", "-c", "client01", "top", "--refresh=0"},
+		{0: "go-test", "-c", "client01", "stats", "--days=-1"},
+		{0: "go-test", "-c", "client01", "exec"},
+		{0: "go-test", "-c", "client01", "exec", "--sql=VACUUM", "--program=vacuumdb"},
+		{0: "go-test", "-c", "client01", "stats", "--format=xml"},
+		{0: "go-test", "cron-next"},
+		{0: "go-test", "cron-next", "0 25 * * *"},
+		{0: "go-test", "cron-next", "@reboot"},
+		{0: "go-test", "cron-next", "* * * * *", "--count=0"},
+		{0: "go-test", "cron-next", "* * * * *", "--tz=Mars/Olympus"},
+	} {
+		os.Args = args
+		_, err := NewConfig(nil)
+		assert.Error(t, err, args)
+	}
+}
+
+func TestCompleters(t *testing.T) {
+	assert.Nil(t, ChainName("").Complete("va"), "nothing is completed without the database lookup")
+	ChainNames = func(prefix string) []string { return []string{prefix + "cuum", prefix + "lidate"} }
+	defer func() { ChainNames = nil }()
+	assert.Equal(t, []flags.Completion{{Item: "vacuum"}, {Item: "validate"}}, ChainName("").Complete("va"))
+
+	assert.Equal(t, []flags.Completion{{Item: "fish"}}, Shell("").Complete("f"))
+	assert.Len(t, Shell("").Complete(""), len(Shells))
+}
@@ -5,6 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	flags "github.com/jessevdk/go-flags"
 	"github.com/spf13/viper"
@@ -15,8 +20,18 @@ type cmdArg struct {
 	*flags.Option
 }
 
+// HasChanged returns true if the value is specified in the command line or in the environment variable,
+// so it takes precedence over the configuration file
 func (a cmdArg) HasChanged() bool {
-	return a.IsSet() && !a.IsSetDefault()
+	return a.IsSet() && (!a.IsSetDefault() || a.isSetFromEnv())
+}
+
+func (a cmdArg) isSetFromEnv() bool {
+	if key := a.EnvKeyWithNamespace(); key != "" {
+		_, ok := os.LookupEnv(key)
+		return ok
+	}
+	return false
 }
 
 func (a cmdArg) Name() string {
@@ -75,6 +90,80 @@ func (cmdSet cmdArgSet) setDefaults(v *viper.Viper) {
 	})
 }
 
+// readConfigFile merges the configuration file into v. Files listed in the `include` section
+// are read first, so the settings of the including file take precedence over included ones.
+// Relative include paths are resolved against the directory of the including file
+func readConfigFile(v *viper.Viper, file string, visited map[string]bool) error {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	if visited[file] {
+		return fmt.Errorf("circular include of %s", file)
+	}
+	visited[file] = true
+	defer delete(visited, file)
+	fv := viper.New()
+	fv.SetConfigFile(file)
+	if err = fv.ReadInConfig(); err != nil {
+		return err
+	}
+	for _, include := range fv.GetStringSlice("include") {
+		include = expandEnvString(include)
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(file), include)
+		}
+		if err = readConfigFile(v, include, visited); err != nil {
+			return err
+		}
+	}
+	settings := expandEnv(fv.AllSettings()).(map[string]interface{})
+	delete(settings, "include")
+	return v.MergeConfigMap(settings)
+}
+
+var envVarRegexp = regexp.MustCompile(`\$\{(\w+)(?::-([^}]*))?\}`)
+
+// expandEnvString replaces ${VAR} and ${VAR:-default} references with the values of environment variables
+func expandEnvString(s string) string {
+	return envVarRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envVarRegexp.FindStringSubmatch(ref)
+		if value, ok := os.LookupEnv(m[1]); ok {
+			return value
+		}
+		return m[2]
+	})
+}
+
+// expandEnv expands environment variable references in all string values of the configuration
+func expandEnv(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return expandEnvString(v)
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = expandEnv(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = expandEnv(val)
+		}
+	}
+	return value
+}
+
+// applyProfile overrides the configuration file settings with the ones from the named profile
+func applyProfile(v *viper.Viper, profile string) error {
+	if profile == "" {
+		return nil
+	}
+	sub := v.Sub("profiles." + profile)
+	if sub == nil {
+		return fmt.Errorf("profile %q is not defined in the configuration file", profile)
+	}
+	return v.MergeConfigMap(sub.AllSettings())
+}
+
 // NewConfig returns a new instance of CmdOptions
 func NewConfig(writer io.Writer) (*CmdOptions, error) {
 	v := viper.New()
@@ -88,17 +177,104 @@ func NewConfig(writer io.Writer) (*CmdOptions, error) {
 	}
 	flagSet.setDefaults(v)
 	if v.IsSet("config") {
-		v.SetConfigFile(v.GetString("config"))
-		err := v.ReadInConfig() // Find and read the config file
-		if err != nil {         // Handle errors reading the config file
+		if err = readConfigFile(v, v.GetString("config"), map[string]bool{}); err != nil {
 			return nil, fmt.Errorf("Fatal error reading config file: %w", err)
 		}
 	}
+	if err = applyProfile(v, v.GetString("profile")); err != nil {
+		return nil, err
+	}
 	conf := &CmdOptions{}
 	if err = v.Unmarshal(conf); err != nil {
 		return nil, fmt.Errorf("Fatal error unmarshalling config file: %w", err)
 	}
-	if conf.ClientName == "" {
+	conf.Command, conf.CommandArgs, conf.Commands = command, commandArgs, commands
+	if _, err = conf.Logging.Labels(); err != nil {
+		return conf, err
+	}
+	if _, err = conf.Labels(); err != nil {
+		return conf, err
+	}
+	if _, err = regexp.Compile(conf.Logging.LogRedact); err != nil {
+		return conf, fmt.Errorf("invalid log redaction pattern: %w", err)
+	}
+	if f := conf.Resource.DurationAnomaly; f != 0 && f <= 1 {
+		return conf, fmt.Errorf("invalid duration anomaly factor %v, value greater than 1 or 0 to disable expected", f)
+	}
+	if conf.Sentry.SampleRate <= 0 || conf.Sentry.SampleRate > 1 {
+		return conf, fmt.Errorf("invalid Sentry sample rate %v, value greater than 0 and not greater than 1 expected", conf.Sentry.SampleRate)
+	}
+	if _, _, err = conf.Digest.Schedule(); err != nil {
+		return conf, err
+	}
+	if conf.Digest.SMTP > "" && conf.Digest.MailTo == "" {
+		return conf, errors.New("digest mail recipients are not specified with the `--digest-mail-to` option")
+	}
+	if conf.Resource.QueueCapacity < 0 {
+		return conf, fmt.Errorf("invalid queue capacity %d, non-negative number expected", conf.Resource.QueueCapacity)
+	}
+	if conf.Resource.QueueTimeout <= 0 {
+		return conf, fmt.Errorf("invalid queue timeout %d, positive number of seconds expected", conf.Resource.QueueTimeout)
+	}
+	if conf.Resource.StartDebounce < 0 {
+		return conf, fmt.Errorf("invalid START debounce window %d, non-negative number of milliseconds expected", conf.Resource.StartDebounce)
+	}
+	if conf.Resource.RemoteConnLimit < 0 {
+		return conf, fmt.Errorf("invalid remote connection limit %d, non-negative number expected", conf.Resource.RemoteConnLimit)
+	}
+	if conf.Resource.AutoDisable < 0 {
+		return conf, fmt.Errorf("invalid auto-disable limit %d, non-negative number of failed runs expected", conf.Resource.AutoDisable)
+	}
+	if conf.Resource.MinInterval <= 0 {
+		return conf, fmt.Errorf("invalid minimum interval %d, positive number of seconds expected", conf.Resource.MinInterval)
+	}
+	if conf.Resource.ProgramKillGrace < 0 {
+		return conf, fmt.Errorf("invalid program kill grace period %d, non-negative number of seconds expected", conf.Resource.ProgramKillGrace)
+	}
+	for _, pattern := range strings.Split(conf.Resource.ProgramEnvAllow+","+conf.Resource.ProgramEnvDeny, ",") {
+		if _, err = path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return conf, fmt.Errorf("invalid PROGRAM environment variable pattern %q: %w", pattern, err)
+		}
+	}
+	for _, hook := range conf.RestApi.Webhooks {
+		if hook.Secret == "" {
+			return conf, fmt.Errorf("secret of the webhook %q is not specified", hook.Name)
+		}
+	}
+	if (conf.TLS.Cert == "") != (conf.TLS.Key == "") {
+		return conf, errors.New("both `--api-tls-cert` and `--api-tls-key` options must be specified")
+	}
+	if conf.TLS.ClientCA != "" && conf.TLS.Cert == "" {
+		return conf, errors.New("client certificates require TLS enabled with the `--api-tls-cert` option")
+	}
+	if (conf.HA.Enabled() || conf.HA.WaitPrimary()) && conf.HA.CheckInterval <= 0 {
+		return conf, fmt.Errorf("invalid leadership check interval %d, positive number of seconds expected", conf.HA.CheckInterval)
+	}
+	if conf.HA.WaitPrimary() && (conf.Start.Init || conf.Start.Upgrade || conf.Start.Debug) {
+		return conf, errors.New("the `--standby` and `--takeover` options cannot be used with `--init`, `--upgrade` or `--debug`")
+	}
+	if conf.Start.StaleLock < 0 || conf.Start.StaleLock > 0 && conf.Start.StaleLock <= conf.Resource.CronInterval {
+		return conf, fmt.Errorf("invalid stale lock timeout %d, number of seconds greater than the cron interval or 0 expected", conf.Start.StaleLock)
+	}
+	if conf.Start.StealLock && conf.Start.StaleLock == 0 {
+		return conf, errors.New("the `--steal-stale-lock` option requires the `--stale-lock-timeout` option")
+	}
+	if conf.GitOps.Repo > "" && conf.GitOps.Dir == "" {
+		return conf, errors.New("the `--gitops-repo` option requires the `--gitops-dir` option to clone the repository into")
+	}
+	if conf.GitOps.Enabled() && conf.GitOps.Interval <= 0 {
+		return conf, fmt.Errorf("invalid GitOps interval %d, positive number of seconds expected", conf.GitOps.Interval)
+	}
+	if conf.Archive.Days < 0 {
+		return conf, fmt.Errorf("invalid archive age %d, non-negative number of days expected", conf.Archive.Days)
+	}
+	if conf.Archive.Enabled() && conf.Archive.Interval <= 0 {
+		return conf, fmt.Errorf("invalid archive interval %d, positive number of seconds expected", conf.Archive.Interval)
+	}
+	if conf.RestApi.Write && conf.RestApi.Token == "" && conf.TLS.ClientCA == "" {
+		return conf, errors.New("the `--rest-write` option requires the `--rest-token` or `--api-client-ca` option to authenticate clients")
+	}
+	if conf.ClientName == "" && conf.Command != "completion" && conf.Command != "cron-next" {
 		buf := bytes.NewBufferString("The required flag `-c, --clientname` was not specified\n")
 		p.WriteHelp(buf)
 		return conf, errors.New(buf.String())
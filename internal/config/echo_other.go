@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package config
+
+import "errors"
+
+// disableEcho is not supported on this platform
+func disableEcho(fd int) (restore func(), err error) {
+	return nil, errors.New("password prompt is not supported on this platform")
+}
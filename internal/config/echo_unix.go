@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package config
+
+import "golang.org/x/sys/unix"
+
+// disableEcho turns off echoing of the terminal input and returns function restoring the previous state
+func disableEcho(fd int) (restore func(), err error) {
+	termios, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+	state := *termios
+	state.Lflag &^= unix.ECHO
+	state.Lflag |= unix.ICANON | unix.ISIG
+	state.Iflag |= unix.ICRNL
+	if err = unix.IoctlSetTermios(fd, ioctlWriteTermios, &state); err != nil {
+		return nil, err
+	}
+	return func() { _ = unix.IoctlSetTermios(fd, ioctlWriteTermios, termios) }, nil
+}
@@ -0,0 +1,15 @@
+package config
+
+import "golang.org/x/sys/windows"
+
+// disableEcho turns off echoing of the console input and returns function restoring the previous state
+func disableEcho(fd int) (restore func(), err error) {
+	var mode uint32
+	if err = windows.GetConsoleMode(windows.Handle(fd), &mode); err != nil {
+		return nil, err
+	}
+	if err = windows.SetConsoleMode(windows.Handle(fd), mode&^windows.ENABLE_ECHO_INPUT|windows.ENABLE_LINE_INPUT); err != nil {
+		return nil, err
+	}
+	return func() { _ = windows.SetConsoleMode(windows.Handle(fd), mode) }, nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadPassword sets the connection password read from the file descriptor or entered interactively
+// if the `--password-fd` or `--password-prompt` option is specified, so the password never appears
+// in the process list or the shell history
+func (c *CmdOptions) ReadPassword(prompt io.Writer) error {
+	switch {
+	case c.Connection.PasswordFD != "":
+		fd, err := strconv.Atoi(c.Connection.PasswordFD)
+		if err != nil || fd < 0 {
+			return fmt.Errorf("invalid password file descriptor: %s", c.Connection.PasswordFD)
+		}
+		f := os.NewFile(uintptr(fd), "password")
+		if f == nil {
+			return fmt.Errorf("invalid password file descriptor: %d", fd)
+		}
+		c.Connection.Password, err = readPasswordLine(f)
+		return err
+	case c.Connection.PasswordPrompt:
+		restore, err := disableEcho(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("cannot prompt for the password, stdin is not a terminal: %w", err)
+		}
+		fmt.Fprint(prompt, "Password: ")
+		c.Connection.Password, err = readPasswordLine(os.Stdin)
+		restore()
+		fmt.Fprintln(prompt)
+		return err
+	}
+	return nil
+}
+
+// readPasswordLine returns the first line read from r without the line terminator
+func readPasswordLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPasswordLine(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":                 "",
+		"secret":           "secret",
+		"secret\n":         "secret",
+		"secret\r\nfoo\n":  "secret",
+		" spaced secret\n": " spaced secret",
+	} {
+		password, err := readPasswordLine(strings.NewReader(input))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, password)
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	t.Run("no options", func(t *testing.T) {
+		c := NewCmdOptions("--password=foo")
+		assert.NoError(t, c.ReadPassword(io.Discard))
+		assert.Equal(t, "foo", c.Connection.Password)
+	})
+
+	t.Run("file descriptor", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		assert.NoError(t, err)
+		defer r.Close()
+		_, _ = w.WriteString("secret\n")
+		w.Close()
+		c := NewCmdOptions("--password=foo", "--password-fd="+strconv.Itoa(int(r.Fd())))
+		assert.NoError(t, c.ReadPassword(io.Discard))
+		assert.Equal(t, "secret", c.Connection.Password)
+	})
+
+	t.Run("stdin by default", func(t *testing.T) {
+		c := NewCmdOptions("--password-fd")
+		assert.Equal(t, "0", c.Connection.PasswordFD)
+	})
+
+	t.Run("invalid file descriptor", func(t *testing.T) {
+		c := NewCmdOptions("--password-fd=foo")
+		assert.Error(t, c.ReadPassword(io.Discard))
+	})
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"strings"
+)
+
+// ServerConfig returns the TLS configuration of the management API servers or nil if TLS is not enabled.
+// Clients must present certificates signed by the client CA if it's specified
+func (o TLSOpts) ServerConfig() (*tls.Config, error) {
+	if o.Cert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(o.Cert, o.Key)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if o.ClientCA != "" {
+		pem, err := os.ReadFile(o.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no CA certificates found in " + o.ClientCA)
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ClientIdentity returns the operator identity of the verified client certificate: the common name,
+// or the first email, DNS or URI subject alternative name if the common name is empty.
+// Empty string is returned if the client is not authenticated with the certificate
+func ClientIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	cert := state.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+	return ""
+}
+
+// Authenticated returns true if the client presented the certificate verified by the server
+// or the `Bearer <token>` authorization with the specified token
+func Authenticated(state *tls.ConnectionState, authorization string, token string) bool {
+	if state != nil && len(state.VerifiedChains) > 0 {
+		return true
+	}
+	if token == "" || !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(authorization, "Bearer ")), []byte(token)) == 1
+}
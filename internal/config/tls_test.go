@@ -0,0 +1,86 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes the self-signed certificate and its key to the directory and returns file names
+func writeCert(t *testing.T, dir string) (certFile string, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	certFile, keyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return
+}
+
+func TestServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := TLSOpts{}.ServerConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, cfg, "TLS is disabled by default")
+
+	certFile, keyFile := writeCert(t, dir)
+	cfg, err = TLSOpts{Cert: certFile, Key: keyFile}.ServerConfig()
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Certificates, 1)
+	assert.Equal(t, tls.NoClientCert, cfg.ClientAuth)
+
+	cfg, err = TLSOpts{Cert: certFile, Key: keyFile, ClientCA: certFile}.ServerConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+
+	_, err = TLSOpts{Cert: certFile, Key: keyFile, ClientCA: keyFile}.ServerConfig()
+	assert.Error(t, err, "key file contains no CA certificates")
+	_, err = TLSOpts{Cert: filepath.Join(dir, "missing.crt"), Key: keyFile}.ServerConfig()
+	assert.Error(t, err)
+}
+
+func TestClientIdentity(t *testing.T) {
+	state := func(cert *x509.Certificate) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	uri, _ := url.Parse("spiffe://example.com/operator")
+	assert.Equal(t, "", ClientIdentity(nil))
+	assert.Equal(t, "", ClientIdentity(&tls.ConnectionState{}), "unverified clients have no identity")
+	assert.Equal(t, "alice", ClientIdentity(state(&x509.Certificate{Subject: pkix.Name{CommonName: "alice"},
+		EmailAddresses: []string{"bob@example.com"}})))
+	assert.Equal(t, "bob@example.com", ClientIdentity(state(&x509.Certificate{EmailAddresses: []string{"bob@example.com"}})))
+	assert.Equal(t, "ops.example.com", ClientIdentity(state(&x509.Certificate{DNSNames: []string{"ops.example.com"}})))
+	assert.Equal(t, "spiffe://example.com/operator", ClientIdentity(state(&x509.Certificate{URIs: []*url.URL{uri}})))
+	assert.Equal(t, "", ClientIdentity(state(&x509.Certificate{})))
+}
+
+func TestAuthenticated(t *testing.T) {
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	assert.True(t, Authenticated(verified, "", ""), "verified client certificate")
+	assert.True(t, Authenticated(nil, "Bearer secret", "secret"))
+	assert.False(t, Authenticated(nil, "Bearer wrong", "secret"))
+	assert.False(t, Authenticated(nil, "secret", "secret"), "bearer scheme is required")
+	assert.False(t, Authenticated(&tls.ConnectionState{}, "Bearer ", ""), "empty token never matches")
+}
@@ -0,0 +1,136 @@
+// Package cron implements the evaluation of cron-style schedules in the same way
+// the timetable.is_cron_in_time() database function does it
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchHorizon limits how far in the future the next fire time is searched for,
+// e.g. "0 0 30 2 *" will never fire
+const searchHorizon = 5 * 366 * 24 * time.Hour
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{{"minute", 0, 59}, {"hour", 0, 23}, {"day", 1, 31}, {"month", 1, 12}, {"day of week", 0, 7}}
+
+// Schedule is the parsed cron-style expression
+type Schedule struct {
+	mins, hours, days, months, dows [61]bool
+}
+
+// ErrNotCron is returned for the extended notation values not representing the cron expression,
+// e.g. @reboot, @every and @after
+var ErrNotCron = errors.New("schedule is not a cron-style expression")
+
+// Parse parses the cron-style expression consisting of five space separated fields. All forms accepted by
+// the timetable.cron domain are supported, ranges starting with *, e.g. *-5, start with the minimum field value
+func Parse(expr string) (*Schedule, error) {
+	if strings.HasPrefix(strings.TrimSpace(expr), "@") {
+		return nil, ErrNotCron
+	}
+	elements := strings.Fields(expr)
+	if len(elements) != len(fields) {
+		return nil, fmt.Errorf("cron expression must consist of %d fields, got %d", len(fields), len(elements))
+	}
+	s := &Schedule{}
+	sets := []*[61]bool{&s.mins, &s.hours, &s.days, &s.months, &s.dows}
+	for i, element := range elements {
+		if err := parseField(element, fields[i], sets[i]); err != nil {
+			return nil, err
+		}
+	}
+	if s.dows[7] { // both 0 and 7 stand for Sunday
+		s.dows[0] = true
+	}
+	return s, nil
+}
+
+func parseField(element string, f field, set *[61]bool) error {
+	for _, item := range strings.Split(element, ",") {
+		from, to, step := f.min, f.max, 1
+		var err error
+		rng := item
+		if i := strings.Index(item, "/"); i >= 0 {
+			rng = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return fmt.Errorf("invalid %s step value: %s", f.name, item)
+			}
+		}
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			if bounds[0] == "*" {
+				from = f.min
+			} else if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return fmt.Errorf("invalid %s value: %s", f.name, item)
+			}
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return fmt.Errorf("invalid %s value: %s", f.name, item)
+			}
+		default:
+			if from, err = strconv.Atoi(rng); err != nil {
+				return fmt.Errorf("invalid %s value: %s", f.name, item)
+			}
+			if rng == item { // single value without step
+				to = from
+			}
+		}
+		if from < f.min || to > f.max || from > to {
+			return fmt.Errorf("%s value is out of range [%d, %d]: %s", f.name, f.min, f.max, item)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// Match returns true if the moment specified is listed in the schedule
+func (s *Schedule) Match(t time.Time) bool {
+	return s.months[t.Month()] && s.days[t.Day()] && s.dows[t.Weekday()] &&
+		s.hours[t.Hour()] && s.mins[t.Minute()]
+}
+
+// Next returns the first moment after t listed in the schedule using the location of t.
+// Zero time is returned if there is no such moment in the foreseeable future
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(searchHorizon)
+	for t.Before(limit) {
+		switch {
+		case !s.months[t.Month()]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.days[t.Day()] || !s.dows[t.Weekday()]:
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !s.hours[t.Hour()]:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !s.mins[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// NextN returns up to count moments after t listed in the schedule
+func (s *Schedule) NextN(t time.Time, count int) []time.Time {
+	res := make([]time.Time, 0, count)
+	for i := 0; i < count; i++ {
+		if t = s.Next(t); t.IsZero() {
+			break
+		}
+		res = append(res, t)
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+package cron
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "0 */2 * * *", "0,15,30,45 1-5 1 1-12/2 0-7", "30 23 * * 7", "1-10/3 * 31 12 *"} {
+		_, err := Parse(expr)
+		assert.NoError(t, err, expr)
+	}
+	for _, expr := range []string{"@reboot", "@every 1 hour", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "1-a * * * *", "*/a * * * *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+	_, err := Parse("@after 10 minutes")
+	assert.ErrorIs(t, err, ErrNotCron)
+}
+
+// TestParseDomainForms checks all forms of fields accepted by the timetable.cron domain are parsed,
+// otherwise chains stored in the database would never be scheduled
+func TestParseDomainForms(t *testing.T) {
+	ddl, err := os.ReadFile("../pgengine/sql/ddl.sql")
+	assert.NoError(t, err)
+	m := regexp.MustCompile(`OR VALUE ~ '([^']+)'`).FindSubmatch(ddl)
+	assert.NotNil(t, m, "cron domain check is not found")
+	domain := regexp.MustCompile(string(m[1]))
+	for _, form := range []string{"*", "5", "1,2,3", "1-5", "5/15", "*/15", "*-5"} {
+		expr := strings.TrimSpace(strings.Repeat(form+" ", 5))
+		assert.True(t, domain.MatchString(expr), expr)
+		_, err := Parse(expr)
+		assert.NoError(t, err, expr)
+	}
+
+	s, err := Parse("*-5 * * * *")
+	assert.NoError(t, err)
+	assert.True(t, s.Match(time.Date(2022, 10, 15, 10, 0, 0, 0, time.UTC)))
+	assert.True(t, s.Match(time.Date(2022, 10, 15, 10, 5, 0, 0, time.UTC)))
+	assert.False(t, s.Match(time.Date(2022, 10, 15, 10, 6, 0, 0, time.UTC)))
+	s, err = Parse("0 0 *-2 * *")
+	assert.NoError(t, err)
+	assert.True(t, s.Match(time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)), "days start with 1")
+}
+
+func TestNext(t *testing.T) {
+	start := time.Date(2022, 10, 15, 10, 30, 45, 0, time.UTC)
+	tests := []struct {
+		expr string
+		next []time.Time
+	}{
+		{"* * * * *", []time.Time{
+			time.Date(2022, 10, 15, 10, 31, 0, 0, time.UTC),
+			time.Date(2022, 10, 15, 10, 32, 0, 0, time.UTC)}},
+		{"0 */2 * * *", []time.Time{
+			time.Date(2022, 10, 15, 12, 0, 0, 0, time.UTC),
+			time.Date(2022, 10, 15, 14, 0, 0, 0, time.UTC)}},
+		{"30 1 * * 7", []time.Time{
+			time.Date(2022, 10, 16, 1, 30, 0, 0, time.UTC),
+			time.Date(2022, 10, 23, 1, 30, 0, 0, time.UTC)}},
+		{"0 0 29 2 *", []time.Time{
+			time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)}},
+		{"0 0 30 2 *", []time.Time{}},
+	}
+	for _, test := range tests {
+		s, err := Parse(test.expr)
+		assert.NoError(t, err)
+		assert.Equal(t, test.next, s.NextN(start, 2)[:len(test.next)], test.expr)
+		for _, ts := range test.next {
+			assert.True(t, s.Match(ts))
+		}
+	}
+}
+
+func TestNextLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	s, err := Parse("0 1 * * *")
+	assert.NoError(t, err)
+	next := s.Next(time.Date(2022, 10, 15, 0, 0, 0, 0, time.UTC).In(loc))
+	assert.Equal(t, time.Date(2022, 10, 15, 23, 0, 0, 0, time.UTC), next.UTC())
+}
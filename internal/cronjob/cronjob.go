@@ -0,0 +1,210 @@
+// Package cronjob converts Kubernetes CronJob manifests to equivalent PROGRAM chains running containers,
+// easing the consolidation of jobs scattered across clusters
+package cronjob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"gopkg.in/yaml.v3"
+)
+
+// macros are the nonstandard schedules supported by Kubernetes
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// nonName matches characters not allowed in generated chain names
+var nonName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Manifest is the Kubernetes object, only fields of CronJob objects and lists of them are decoded
+type Manifest struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec  CronJobSpec `yaml:"spec"`
+	Items []Manifest  `yaml:"items"`
+}
+
+// CronJobSpec is the specification of the CronJob
+type CronJobSpec struct {
+	Schedule          string `yaml:"schedule"`
+	TimeZone          string `yaml:"timeZone"`
+	Suspend           bool   `yaml:"suspend"`
+	ConcurrencyPolicy string `yaml:"concurrencyPolicy"`
+	JobTemplate       struct {
+		Spec struct {
+			ActiveDeadlineSeconds int `yaml:"activeDeadlineSeconds"`
+			Template              struct {
+				Spec struct {
+					InitContainers []Container `yaml:"initContainers"`
+					Containers     []Container `yaml:"containers"`
+					Volumes        []struct{}  `yaml:"volumes"`
+				} `yaml:"spec"`
+			} `yaml:"template"`
+		} `yaml:"spec"`
+	} `yaml:"jobTemplate"`
+}
+
+// Container is the container of the pod created by the CronJob
+type Container struct {
+	Name       string   `yaml:"name"`
+	Image      string   `yaml:"image"`
+	Command    []string `yaml:"command"`
+	Args       []string `yaml:"args"`
+	WorkingDir string   `yaml:"workingDir"`
+	Env        []struct {
+		Name      string     `yaml:"name"`
+		Value     string     `yaml:"value"`
+		ValueFrom *yaml.Node `yaml:"valueFrom"`
+	} `yaml:"env"`
+	VolumeMounts []struct{} `yaml:"volumeMounts"`
+}
+
+// Parse converts CronJob manifests, several documents separated with --- or lists of objects, to chain definitions.
+// Chains are named <prefix>_<name>, the namespace of the CronJob is the prefix if empty. Init containers and
+// containers of the pod become PROGRAM tasks running in containers one after another. Suspended CronJobs result in
+// chains which are not live. Objects of other kinds are ignored, parts of CronJobs which cannot be converted exactly
+// are reported as warnings
+func Parse(r io.Reader, prefix string) (defs []pgengine.ChainDefinition, warnings []string, err error) {
+	names := make(map[string]int)
+	dec := yaml.NewDecoder(r)
+	for {
+		var m Manifest
+		if err = dec.Decode(&m); errors.Is(err, io.EOF) {
+			return defs, warnings, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		objects := append(m.Items, m)
+		for _, obj := range objects {
+			if obj.Kind != "CronJob" {
+				continue
+			}
+			def, objWarnings, err := convert(obj, prefix)
+			for _, warning := range objWarnings {
+				warnings = append(warnings, fmt.Sprintf("CronJob %s: %s", obj.Metadata.Name, warning))
+			}
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("CronJob %s: %s, skipped", obj.Metadata.Name, err))
+				continue
+			}
+			if names[def.Name]++; names[def.Name] > 1 {
+				def.Name = fmt.Sprintf("%s_%d", def.Name, names[def.Name])
+			}
+			defs = append(defs, def)
+		}
+	}
+}
+
+// convert returns the chain definition equivalent to the CronJob
+func convert(obj Manifest, prefix string) (def pgengine.ChainDefinition, warnings []string, err error) {
+	spec := obj.Spec
+	if def.Schedule, err = convertSchedule(spec.Schedule); err != nil {
+		return
+	}
+	if spec.TimeZone != "" {
+		warnings = append(warnings, fmt.Sprintf("time zone %s ignored, schedules are evaluated in the time zone of the scheduler", spec.TimeZone))
+	}
+	if prefix == "" {
+		prefix = obj.Metadata.Namespace
+	}
+	def.Name = strings.Trim(nonName.ReplaceAllString(strings.ToLower(prefix+"_"+obj.Metadata.Name), "_"), "_")
+	def.Live = !spec.Suspend
+	switch spec.ConcurrencyPolicy {
+	case "Forbid":
+		def.MaxInstances = 1
+	case "Replace":
+		def.MaxInstances = 1
+		warnings = append(warnings, "concurrency policy Replace is converted to Forbid, the running chain is not replaced")
+	}
+	job := spec.JobTemplate.Spec
+	def.Timeout = job.ActiveDeadlineSeconds * 1000
+	pod := job.Template.Spec
+	if len(pod.Volumes) > 0 {
+		warnings = append(warnings, "volumes are not converted, set mounts of task containers")
+	}
+	if len(pod.Containers) > 1 {
+		warnings = append(warnings, "containers are run one after another instead of at the same time")
+	}
+	for _, c := range append(pod.InitContainers, pod.Containers...) {
+		task, taskWarnings, err := convertContainer(c)
+		for _, warning := range taskWarnings {
+			warnings = append(warnings, fmt.Sprintf("container %s: %s", c.Name, warning))
+		}
+		if err != nil {
+			return def, warnings, fmt.Errorf("container %s: %w", c.Name, err)
+		}
+		def.Tasks = append(def.Tasks, task)
+	}
+	if len(def.Tasks) == 0 {
+		err = errors.New("no containers")
+	}
+	return
+}
+
+// convertSchedule returns the schedule of the chain equivalent to the schedule of the CronJob
+func convertSchedule(schedule string) (string, error) {
+	schedule = strings.Join(strings.Fields(schedule), " ")
+	if strings.HasPrefix(schedule, "@") {
+		if macro, ok := macros[strings.ToLower(schedule)]; ok {
+			return macro, nil
+		}
+		return "", fmt.Errorf("unknown schedule %s", schedule)
+	}
+	if strings.HasPrefix(schedule, "TZ=") || strings.HasPrefix(schedule, "CRON_TZ=") {
+		return "", fmt.Errorf("time zone in schedule %q is not supported", schedule)
+	}
+	if _, err := cron.Parse(schedule); err != nil {
+		return "", fmt.Errorf("invalid schedule %q: %w", schedule, err)
+	}
+	return schedule, nil
+}
+
+// convertContainer returns the PROGRAM task running the command of the container. The command of the container
+// replaces the entrypoint of the image, while arguments alone are passed to the entrypoint, the same as Kubernetes does
+func convertContainer(c Container) (task pgengine.TaskDefinition, warnings []string, err error) {
+	if c.Image == "" {
+		return task, nil, errors.New("image is missing")
+	}
+	task = pgengine.TaskDefinition{Name: c.Name, Kind: "PROGRAM", Container: &pgengine.TaskContainer{Image: c.Image}}
+	args := c.Args
+	if len(c.Command) > 0 {
+		task.Container.Options = append(task.Container.Options, "--entrypoint=")
+		args = append(append([]string{}, c.Command...), c.Args...)
+	}
+	if len(args) == 0 {
+		return task, nil, errors.New("the default command of the image cannot be run, specify command or args")
+	}
+	task.Command = args[0]
+	if len(args) > 1 {
+		task.Parameters = []interface{}{args[1:]}
+	}
+	if c.WorkingDir != "" {
+		task.Container.Options = append(task.Container.Options, "--workdir="+c.WorkingDir)
+	}
+	for _, env := range c.Env {
+		if env.ValueFrom != nil {
+			warnings = append(warnings, fmt.Sprintf("variable %s set from the object is not converted", env.Name))
+			continue
+		}
+		task.Env = append(task.Env, env.Name+"="+env.Value)
+	}
+	if len(c.VolumeMounts) > 0 {
+		warnings = append(warnings, "volume mounts are not converted")
+	}
+	return
+}
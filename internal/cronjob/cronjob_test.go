@@ -0,0 +1,95 @@
+package cronjob
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	defs, warnings, err := Parse(strings.NewReader(`apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: db-backup
+  namespace: billing
+spec:
+  schedule: "30 2 * * 1-5"
+  timeZone: Europe/Vienna
+  concurrencyPolicy: Forbid
+  jobTemplate:
+    spec:
+      activeDeadlineSeconds: 600
+      template:
+        spec:
+          initContainers:
+            - name: wait
+              image: busybox
+              command: ["sh", "-c", "sleep 5"]
+          containers:
+            - name: dump
+              image: postgres:15
+              args: ["pg_dump", "-Fc", "billing"]
+              workingDir: /backup
+              env:
+                - name: PGHOST
+                  value: db
+                - name: PGPASSWORD
+                  valueFrom:
+                    secretKeyRef: {name: db, key: password}
+              volumeMounts:
+                - {name: backup, mountPath: /backup}
+          volumes:
+            - {name: backup, emptyDir: {}}
+---
+apiVersion: v1
+kind: List
+items:
+  - kind: CronJob
+    metadata: {name: report}
+    spec:
+      schedule: "@hourly"
+      suspend: true
+      jobTemplate: {spec: {template: {spec: {containers: [{name: report, image: report:1, command: ["/report"]}]}}}}
+  - kind: CronJob
+    metadata: {name: broken}
+    spec:
+      schedule: "61 * * * *"
+  - kind: CronJob
+    metadata: {name: default}
+    spec:
+      schedule: "@daily"
+      jobTemplate: {spec: {template: {spec: {containers: [{name: main, image: tool:1}]}}}}
+---
+kind: ConfigMap
+metadata: {name: settings}
+`), "")
+	assert.NoError(t, err)
+	if assert.Len(t, warnings, 6) {
+		assert.Equal(t, []string{
+			"CronJob db-backup: time zone Europe/Vienna ignored, schedules are evaluated in the time zone of the scheduler",
+			"CronJob db-backup: volumes are not converted, set mounts of task containers",
+			"CronJob db-backup: container dump: variable PGPASSWORD set from the object is not converted",
+			"CronJob db-backup: container dump: volume mounts are not converted",
+		}, warnings[:4])
+		assert.Contains(t, warnings[4], `CronJob broken: invalid schedule "61 * * * *"`)
+		assert.Equal(t, "CronJob default: container main: the default command of the image cannot be run, specify command or args, skipped", warnings[5])
+	}
+	assert.Equal(t, []pgengine.ChainDefinition{
+		{
+			Name: "billing_db_backup", Schedule: "30 2 * * 1-5", Live: true, MaxInstances: 1, Timeout: 600000,
+			Tasks: []pgengine.TaskDefinition{
+				{Name: "wait", Kind: "PROGRAM", Command: "sh", Parameters: []interface{}{[]string{"-c", "sleep 5"}},
+					Container: &pgengine.TaskContainer{Image: "busybox", Options: []string{"--entrypoint="}}},
+				{Name: "dump", Kind: "PROGRAM", Command: "pg_dump", Parameters: []interface{}{[]string{"-Fc", "billing"}},
+					Env: []string{"PGHOST=db"}, Container: &pgengine.TaskContainer{Image: "postgres:15", Options: []string{"--workdir=/backup"}}},
+			},
+		},
+		{
+			Name: "report", Schedule: "0 * * * *", Live: false,
+			Tasks: []pgengine.TaskDefinition{{Name: "report", Kind: "PROGRAM", Command: "/report",
+				Container: &pgengine.TaskContainer{Image: "report:1", Options: []string{"--entrypoint="}}}},
+		},
+	}, defs)
+}
@@ -0,0 +1,158 @@
+// Package crontab converts entries of classic crontab files to equivalent PROGRAM chains,
+// easing the migration of jobs from cron
+package crontab
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+// defaultShell runs commands if the crontab doesn't set SHELL, the same as cron does
+const defaultShell = "/bin/sh"
+
+// macros are the nonstandard schedules supported by cron implementations
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+	"@reboot":   "@reboot",
+}
+
+var (
+	monthNames = strings.NewReplacer("jan", "1", "feb", "2", "mar", "3", "apr", "4", "may", "5", "jun", "6",
+		"jul", "7", "aug", "8", "sep", "9", "oct", "10", "nov", "11", "dec", "12")
+	dayNames = strings.NewReplacer("sun", "0", "mon", "1", "tue", "2", "wed", "3", "thu", "4", "fri", "5", "sat", "6")
+	// envLine matches environment settings, e.g. PATH=/usr/bin or MAILTO="admin@example.com"
+	envLine = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
+	// nonName matches characters not allowed in generated chain names
+	nonName = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Entry is the job of the crontab file
+type Entry struct {
+	Line     int
+	Schedule string
+	User     string // user field of system crontab files, e.g. /etc/crontab
+	Command  string
+}
+
+// Parse converts crontab entries to chain definitions. Chains are named <prefix>_<program>_<hash>, where hash is
+// derived from the schedule and the command, so converting the edited file again keeps names of unchanged entries.
+// System crontab files have the user field, it's ignored, since commands are executed by the scheduler user.
+// Lines which cannot be converted are reported as warnings
+func Parse(r io.Reader, prefix string, system bool) (defs []pgengine.ChainDefinition, warnings []string, err error) {
+	var env []string
+	shell := defaultShell
+	names := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if m := envLine.FindStringSubmatch(text); m != nil {
+			value := strings.Trim(m[2], `"'`)
+			switch m[1] {
+			case "SHELL":
+				shell = value
+			case "MAILTO", "MAILFROM", "CRON_TZ":
+				warnings = append(warnings, fmt.Sprintf("line %d: %s is not supported, task output is stored in the execution log", line, m[1]))
+			default:
+				env = append(env, m[1]+"="+value)
+			}
+			continue
+		}
+		entry, err := parseEntry(line, text, system)
+		if err != nil {
+			warnings = append(warnings, err.Error())
+			continue
+		}
+		name := chainName(prefix, entry)
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, names[name])
+		}
+		task := pgengine.TaskDefinition{
+			Kind:       "PROGRAM",
+			Command:    shell,
+			Parameters: []interface{}{[]string{"-c", entry.Command}},
+		}
+		if len(env) > 0 {
+			task.Env = append([]string{}, env...)
+		}
+		defs = append(defs, pgengine.ChainDefinition{
+			Name:     name,
+			Schedule: entry.Schedule,
+			Live:     true,
+			Tasks:    []pgengine.TaskDefinition{task},
+		})
+	}
+	return defs, warnings, scanner.Err()
+}
+
+// parseEntry parses the job line, the schedule is validated and names of months and days are replaced with numbers
+func parseEntry(line int, text string, system bool) (entry Entry, err error) {
+	entry.Line = line
+	fields := strings.Fields(text)
+	scheduleFields := 5
+	if strings.HasPrefix(fields[0], "@") {
+		scheduleFields = 1
+	}
+	commandAt := scheduleFields
+	if system {
+		commandAt++
+	}
+	if len(fields) <= commandAt {
+		return entry, fmt.Errorf("line %d: command is missing", line)
+	}
+	if scheduleFields == 1 {
+		var ok bool
+		if entry.Schedule, ok = macros[strings.ToLower(fields[0])]; !ok {
+			return entry, fmt.Errorf("line %d: unknown schedule %s", line, fields[0])
+		}
+	} else {
+		schedule := append([]string{}, fields[:5]...)
+		schedule[3] = monthNames.Replace(strings.ToLower(schedule[3]))
+		schedule[4] = dayNames.Replace(strings.ToLower(schedule[4]))
+		entry.Schedule = strings.Join(schedule, " ")
+		if _, err = cron.Parse(entry.Schedule); err != nil {
+			return entry, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if system {
+		entry.User = fields[commandAt-1]
+	}
+	// the command is the rest of the line with the original spacing
+	rest := text
+	for i := 0; i < commandAt; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		rest = rest[len(fields[i]):]
+	}
+	entry.Command = strings.TrimSpace(rest)
+	// unescaped % starts the standard input of the command in crontab, it is not supported
+	if strings.Contains(strings.ReplaceAll(entry.Command, `\%`, ""), "%") {
+		return entry, fmt.Errorf("line %d: standard input specified with %% is not supported", line)
+	}
+	entry.Command = strings.ReplaceAll(entry.Command, `\%`, "%")
+	return entry, nil
+}
+
+// chainName returns the name of the chain derived from the program name and the hash of the entry
+func chainName(prefix string, entry Entry) string {
+	program := strings.Fields(entry.Command)[0]
+	program = strings.Trim(nonName.ReplaceAllString(strings.ToLower(filepath.Base(program)), "_"), "_")
+	hash := sha1.Sum([]byte(entry.Schedule + "\n" + entry.Command)) // #nosec
+	return strings.Trim(prefix+"_"+program, "_") + "_" + hex.EncodeToString(hash[:4])
+}
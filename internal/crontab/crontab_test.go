@@ -0,0 +1,74 @@
+package crontab
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	defs, warnings, err := Parse(strings.NewReader(`# backups
+MAILTO=admin@example.com
+PATH=/usr/local/bin:/usr/bin
+30 2 * * mon-fri   pg_dump -Fc timetable >  /backup/timetable.dump
+@daily find /tmp -mtime +7 -delete
+
+SHELL=/bin/bash
+*/5 * * jan,dec * date +\%s >> /var/log/ticks
+0 * * * * mail -s report admin%body
+61 * * * * echo wrong
+@often echo wrong
+`), "backup", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"line 2: MAILTO is not supported, task output is stored in the execution log",
+		"line 9: standard input specified with % is not supported",
+		"line 10: minute value is out of range [0, 59]: 61",
+		"line 11: unknown schedule @often",
+	}, warnings)
+	if assert.Len(t, defs, 3) {
+		assert.Regexp(t, `^backup_pg_dump_[0-9a-f]{8}$`, defs[0].Name)
+		assert.Equal(t, "30 2 * * 1-5", defs[0].Schedule)
+		assert.True(t, defs[0].Live)
+		assert.Equal(t, []pgengine.TaskDefinition{{
+			Kind:       "PROGRAM",
+			Command:    "/bin/sh",
+			Env:        []string{"PATH=/usr/local/bin:/usr/bin"},
+			Parameters: []interface{}{[]string{"-c", "pg_dump -Fc timetable >  /backup/timetable.dump"}},
+		}}, defs[0].Tasks)
+		assert.Equal(t, "0 0 * * *", defs[1].Schedule)
+		assert.Regexp(t, `^backup_find_`, defs[1].Name)
+		assert.Equal(t, "*/5 * * 1,12 *", defs[2].Schedule)
+		assert.Equal(t, "/bin/bash", defs[2].Tasks[0].Command)
+		assert.Equal(t, []interface{}{[]string{"-c", "date +%s >> /var/log/ticks"}}, defs[2].Tasks[0].Parameters)
+	}
+
+	again, _, _ := Parse(strings.NewReader("30 2 * * 1-5 pg_dump -Fc timetable >  /backup/timetable.dump\n"), "backup", false)
+	assert.Equal(t, defs[0].Name, again[0].Name, "names are stable if other entries change")
+}
+
+func TestParseSystem(t *testing.T) {
+	defs, warnings, err := Parse(strings.NewReader(`17 * * * * root cd / && run-parts --report /etc/cron.hourly
+@reboot postgres /usr/local/bin/warmup.sh
+0 0 * * * root
+`), "", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 3: command is missing"}, warnings)
+	if assert.Len(t, defs, 2) {
+		assert.Regexp(t, `^cd_[0-9a-f]{8}$`, defs[0].Name)
+		assert.Equal(t, []interface{}{[]string{"-c", "cd / && run-parts --report /etc/cron.hourly"}}, defs[0].Tasks[0].Parameters)
+		assert.Nil(t, defs[0].Tasks[0].Env)
+		assert.Equal(t, "@reboot", defs[1].Schedule)
+		assert.Regexp(t, `^warmup_sh_`, defs[1].Name)
+	}
+}
+
+func TestChainNameDuplicates(t *testing.T) {
+	defs, _, err := Parse(strings.NewReader("* * * * * true\n* * * * * true\n"), "dup", false)
+	assert.NoError(t, err)
+	if assert.Len(t, defs, 2) {
+		assert.Equal(t, defs[0].Name+"_2", defs[1].Name)
+	}
+}
@@ -0,0 +1,295 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: timetable.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Timetable_ListChains_FullMethodName  = "/pg_timetable.v1.Timetable/ListChains"
+	Timetable_RunChain_FullMethodName    = "/pg_timetable.v1.Timetable/RunChain"
+	Timetable_StopChain_FullMethodName   = "/pg_timetable.v1.Timetable/StopChain"
+	Timetable_GetStatus_FullMethodName   = "/pg_timetable.v1.Timetable/GetStatus"
+	Timetable_WatchStatus_FullMethodName = "/pg_timetable.v1.Timetable/WatchStatus"
+)
+
+// TimetableClient is the client API for Timetable service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TimetableClient interface {
+	// ListChains returns the chains available to the scheduler
+	ListChains(ctx context.Context, in *ListChainsRequest, opts ...grpc.CallOption) (*ListChainsResponse, error)
+	// RunChain queues the chain for immediate execution
+	RunChain(ctx context.Context, in *RunChainRequest, opts ...grpc.CallOption) (*RunChainResponse, error)
+	// StopChain aborts the chain being executed
+	StopChain(ctx context.Context, in *StopChainRequest, opts ...grpc.CallOption) (*StopChainResponse, error)
+	// GetStatus returns the current status of the scheduler
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Status, error)
+	// WatchStatus streams the status of the scheduler periodically until cancelled by the client
+	WatchStatus(ctx context.Context, in *WatchStatusRequest, opts ...grpc.CallOption) (Timetable_WatchStatusClient, error)
+}
+
+type timetableClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTimetableClient(cc grpc.ClientConnInterface) TimetableClient {
+	return &timetableClient{cc}
+}
+
+func (c *timetableClient) ListChains(ctx context.Context, in *ListChainsRequest, opts ...grpc.CallOption) (*ListChainsResponse, error) {
+	out := new(ListChainsResponse)
+	err := c.cc.Invoke(ctx, Timetable_ListChains_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timetableClient) RunChain(ctx context.Context, in *RunChainRequest, opts ...grpc.CallOption) (*RunChainResponse, error) {
+	out := new(RunChainResponse)
+	err := c.cc.Invoke(ctx, Timetable_RunChain_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timetableClient) StopChain(ctx context.Context, in *StopChainRequest, opts ...grpc.CallOption) (*StopChainResponse, error) {
+	out := new(StopChainResponse)
+	err := c.cc.Invoke(ctx, Timetable_StopChain_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timetableClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*Status, error) {
+	out := new(Status)
+	err := c.cc.Invoke(ctx, Timetable_GetStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timetableClient) WatchStatus(ctx context.Context, in *WatchStatusRequest, opts ...grpc.CallOption) (Timetable_WatchStatusClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Timetable_ServiceDesc.Streams[0], Timetable_WatchStatus_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &timetableWatchStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Timetable_WatchStatusClient interface {
+	Recv() (*Status, error)
+	grpc.ClientStream
+}
+
+type timetableWatchStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *timetableWatchStatusClient) Recv() (*Status, error) {
+	m := new(Status)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TimetableServer is the server API for Timetable service.
+// All implementations must embed UnimplementedTimetableServer
+// for forward compatibility
+type TimetableServer interface {
+	// ListChains returns the chains available to the scheduler
+	ListChains(context.Context, *ListChainsRequest) (*ListChainsResponse, error)
+	// RunChain queues the chain for immediate execution
+	RunChain(context.Context, *RunChainRequest) (*RunChainResponse, error)
+	// StopChain aborts the chain being executed
+	StopChain(context.Context, *StopChainRequest) (*StopChainResponse, error)
+	// GetStatus returns the current status of the scheduler
+	GetStatus(context.Context, *GetStatusRequest) (*Status, error)
+	// WatchStatus streams the status of the scheduler periodically until cancelled by the client
+	WatchStatus(*WatchStatusRequest, Timetable_WatchStatusServer) error
+	mustEmbedUnimplementedTimetableServer()
+}
+
+// UnimplementedTimetableServer must be embedded to have forward compatible implementations.
+type UnimplementedTimetableServer struct {
+}
+
+func (UnimplementedTimetableServer) ListChains(context.Context, *ListChainsRequest) (*ListChainsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChains not implemented")
+}
+func (UnimplementedTimetableServer) RunChain(context.Context, *RunChainRequest) (*RunChainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunChain not implemented")
+}
+func (UnimplementedTimetableServer) StopChain(context.Context, *StopChainRequest) (*StopChainResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopChain not implemented")
+}
+func (UnimplementedTimetableServer) GetStatus(context.Context, *GetStatusRequest) (*Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedTimetableServer) WatchStatus(*WatchStatusRequest, Timetable_WatchStatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchStatus not implemented")
+}
+func (UnimplementedTimetableServer) mustEmbedUnimplementedTimetableServer() {}
+
+// UnsafeTimetableServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TimetableServer will
+// result in compilation errors.
+type UnsafeTimetableServer interface {
+	mustEmbedUnimplementedTimetableServer()
+}
+
+func RegisterTimetableServer(s grpc.ServiceRegistrar, srv TimetableServer) {
+	s.RegisterService(&Timetable_ServiceDesc, srv)
+}
+
+func _Timetable_ListChains_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChainsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimetableServer).ListChains(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Timetable_ListChains_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimetableServer).ListChains(ctx, req.(*ListChainsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Timetable_RunChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimetableServer).RunChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Timetable_RunChain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimetableServer).RunChain(ctx, req.(*RunChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Timetable_StopChain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopChainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimetableServer).StopChain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Timetable_StopChain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimetableServer).StopChain(ctx, req.(*StopChainRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Timetable_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimetableServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Timetable_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimetableServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Timetable_WatchStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TimetableServer).WatchStatus(m, &timetableWatchStatusServer{stream})
+}
+
+type Timetable_WatchStatusServer interface {
+	Send(*Status) error
+	grpc.ServerStream
+}
+
+type timetableWatchStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *timetableWatchStatusServer) Send(m *Status) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Timetable_ServiceDesc is the grpc.ServiceDesc for Timetable service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Timetable_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pg_timetable.v1.Timetable",
+	HandlerType: (*TimetableServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListChains",
+			Handler:    _Timetable_ListChains_Handler,
+		},
+		{
+			MethodName: "RunChain",
+			Handler:    _Timetable_RunChain_Handler,
+		},
+		{
+			MethodName: "StopChain",
+			Handler:    _Timetable_StopChain_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _Timetable_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStatus",
+			Handler:       _Timetable_WatchStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "timetable.proto",
+}
@@ -0,0 +1,282 @@
+// Package grpcapi implements the gRPC management API of the scheduler
+package grpcapi
+
+//go:generate protoc -I pb --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative timetable.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/grpcapi/pb"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/cybertec-postgresql/pg_timetable/internal/scheduler"
+	pgx "github.com/jackc/pgx/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// default period between status updates sent by WatchStatus
+const defaultWatchInterval = 5 * time.Second
+
+// maximum time to store the call in the API audit trail
+const apiAuditTimeout = 5 * time.Second
+
+// Handler is the interface used by the gRPC server to interact with the scheduler
+type Handler interface {
+	IsReady() bool
+	Config() config.CmdOptions
+	ListChains(ctx context.Context, liveOnly bool) ([]pgengine.ChainInfo, error)
+	StartChain(ctx context.Context, chainName string, payload string) (int, error)
+	StartChainByID(ctx context.Context, chainID int, payload string) error
+	StopChain(chainID int) bool
+	GetActiveChains() []scheduler.ActiveChain
+	QueueLength() int
+	LogAPICall(ctx context.Context, call pgengine.APICall)
+}
+
+// Server implements the Timetable gRPC service
+type Server struct {
+	pb.UnimplementedTimetableServer
+	Handler Handler
+	l       log.LoggerIface
+	write   bool   // calls changing the scheduler state are enabled
+	token   string // bearer token authenticating calls changing the scheduler state
+	*grpc.Server
+}
+
+// Init creates the gRPC server and starts listening if the port is specified.
+// The server uses TLS if tlsConfig is not nil. Calls changing the scheduler state are authorized
+// with the `--rest-write` and `--rest-token` options the same way as REST API requests
+func Init(opts config.GrpcOpts, restOpts config.RestApiOpts, tlsConfig *tls.Config, logger log.LoggerIface) *Server {
+	s := &Server{l: logger, write: restOpts.Write, token: restOpts.Token}
+	serverOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(s.operatorInterceptor, s.authInterceptor, s.auditInterceptor)}
+	if tlsConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s.Server = grpc.NewServer(serverOpts...)
+	pb.RegisterTimetableServer(s.Server, s)
+	if opts.Port != 0 {
+		logger.WithField("port", opts.Port).Info("Starting gRPC server...")
+		go func() {
+			lis, err := net.Listen("tcp", fmt.Sprintf(":%d", opts.Port))
+			if err == nil {
+				err = s.Serve(lis)
+			}
+			logger.Error(err)
+		}()
+	}
+	return s
+}
+
+// readOnlyMethods do not change the scheduler state, so they are not logged
+var readOnlyMethods = map[string]bool{
+	pb.Timetable_ListChains_FullMethodName: true,
+	pb.Timetable_GetStatus_FullMethodName:  true,
+}
+
+// operatorInterceptor passes the identity of the client certificate to handlers with the request context,
+// so changes are attributed to the operator in the audit, and logs requests changing the scheduler state
+func (s *Server) operatorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if operator := config.ClientIdentity(&tlsInfo.State); operator != "" {
+				ctx = pgengine.WithOperator(ctx, operator)
+				if !readOnlyMethods[info.FullMethod] {
+					s.l.WithField("operator", operator).WithField("method", info.FullMethod).Info("gRPC API request")
+				}
+			}
+		}
+	}
+	return handler(ctx, req)
+}
+
+// authInterceptor rejects calls changing the scheduler state unless such calls are enabled with `--rest-write`
+// and the client is authenticated with the bearer token or the verified client certificate
+func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if readOnlyMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	if !s.write {
+		return nil, status.Error(codes.PermissionDenied, "calls changing the scheduler state are disabled, see --rest-write")
+	}
+	var state *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &tlsInfo.State
+		}
+	}
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	if !config.Authenticated(state, authorization, s.token) {
+		s.l.WithField("method", info.FullMethod).Warn("Unauthenticated gRPC API request rejected")
+		return nil, status.Error(codes.Unauthenticated, "bearer token or client certificate required")
+	}
+	return handler(ctx, req)
+}
+
+// auditInterceptor stores calls changing the scheduler state, e.g. starting or stopping chains, in the API audit trail
+// with the operator, the client address and the status code
+func (s *Server) auditInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if readOnlyMethods[info.FullMethod] || s.Handler == nil {
+		return resp, err
+	}
+	call := pgengine.APICall{
+		API:       "gRPC",
+		Operation: info.FullMethod,
+		Operator:  pgengine.Operator(ctx),
+		Status:    status.Code(err).String(),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		call.RemoteAddr = p.Addr.String()
+		if host, _, e := net.SplitHostPort(call.RemoteAddr); e == nil {
+			call.RemoteAddr = host
+		}
+	}
+	// the chain started by name is known from the response only
+	for _, msg := range []interface{}{req, resp} {
+		if m, ok := msg.(interface{ GetChainId() int64 }); ok && call.ChainID == 0 {
+			call.ChainID = int(m.GetChainId())
+		}
+	}
+	auditCtx, cancel := context.WithTimeout(context.Background(), apiAuditTimeout)
+	defer cancel()
+	s.Handler.LogAPICall(auditCtx, call)
+	return resp, err
+}
+
+// grpcError converts the error to the gRPC status error
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return status.Error(codes.NotFound, "chain not found")
+	case errors.Is(err, scheduler.ErrQueueFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *Server) handler() (Handler, error) {
+	if s.Handler == nil {
+		return nil, status.Error(codes.Unavailable, "scheduler is not ready yet")
+	}
+	return s.Handler, nil
+}
+
+// ListChains returns the chains available to the scheduler
+func (s *Server) ListChains(ctx context.Context, req *pb.ListChainsRequest) (*pb.ListChainsResponse, error) {
+	h, err := s.handler()
+	if err != nil {
+		return nil, err
+	}
+	chains, err := h.ListChains(ctx, req.GetLiveOnly())
+	if err != nil {
+		s.l.WithError(err).Error("Cannot list chains")
+		return nil, grpcError(err)
+	}
+	resp := &pb.ListChainsResponse{Chains: make([]*pb.Chain, 0, len(chains))}
+	for _, c := range chains {
+		resp.Chains = append(resp.Chains, &pb.Chain{
+			ChainId:            int64(c.ChainID),
+			ChainName:          c.ChainName,
+			RunAt:              c.RunAt,
+			MaxInstances:       int32(c.MaxInstances),
+			Timeout:            int32(c.Timeout),
+			Live:               c.Live,
+			SelfDestruct:       c.SelfDestruct,
+			ExclusiveExecution: c.ExclusiveExecution,
+			ClientName:         c.ClientName,
+		})
+	}
+	return resp, nil
+}
+
+// RunChain queues the chain specified by ID or name for immediate execution
+func (s *Server) RunChain(ctx context.Context, req *pb.RunChainRequest) (*pb.RunChainResponse, error) {
+	h, err := s.handler()
+	if err != nil {
+		return nil, err
+	}
+	chainID := int(req.GetChainId())
+	switch req.GetChain().(type) {
+	case *pb.RunChainRequest_ChainId:
+		err = h.StartChainByID(ctx, chainID, req.GetPayload())
+	case *pb.RunChainRequest_ChainName:
+		chainID, err = h.StartChain(ctx, req.GetChainName(), req.GetPayload())
+	default:
+		return nil, status.Error(codes.InvalidArgument, "chain ID or name must be specified")
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.RunChainResponse{ChainId: int64(chainID)}, nil
+}
+
+// StopChain aborts the chain being executed
+func (s *Server) StopChain(ctx context.Context, req *pb.StopChainRequest) (*pb.StopChainResponse, error) {
+	h, err := s.handler()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.StopChainResponse{Stopped: h.StopChain(int(req.GetChainId()))}, nil
+}
+
+func (s *Server) currentStatus() *pb.Status {
+	st := &pb.Status{Timestamp: timestamppb.Now()}
+	if s.Handler == nil {
+		return st
+	}
+	st.Ready = s.Handler.IsReady()
+	st.ClientName = s.Handler.Config().ClientName
+	st.QueuedChains = int32(s.Handler.QueueLength())
+	for _, ac := range s.Handler.GetActiveChains() {
+		st.ActiveChains = append(st.ActiveChains, &pb.ActiveChain{
+			ChainId:   int64(ac.ChainID),
+			ChainName: ac.ChainName,
+			StartedAt: timestamppb.New(ac.StartedAt),
+			TaskId:    int64(ac.TaskID),
+			Txid:      int64(ac.Txid),
+		})
+	}
+	return st
+}
+
+// GetStatus returns the current status of the scheduler
+func (s *Server) GetStatus(ctx context.Context, req *pb.GetStatusRequest) (*pb.Status, error) {
+	return s.currentStatus(), nil
+}
+
+// WatchStatus sends the status of the scheduler periodically until the client cancels the stream
+func (s *Server) WatchStatus(req *pb.WatchStatusRequest, stream pb.Timetable_WatchStatusServer) error {
+	interval := defaultWatchInterval
+	if req.GetIntervalSeconds() > 0 {
+		interval = time.Duration(req.GetIntervalSeconds()) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := stream.Send(s.currentStatus()); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
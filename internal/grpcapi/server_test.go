@@ -0,0 +1,167 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/grpcapi/pb"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/cybertec-postgresql/pg_timetable/internal/scheduler"
+	pgx "github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testToken = "grpc_unit_test_token"
+
+type handler struct {
+	mu    sync.Mutex
+	calls []pgengine.APICall
+}
+
+func (h *handler) IsReady() bool { return true }
+
+func (h *handler) Config() config.CmdOptions { return config.CmdOptions{ClientName: "grpc_unit_test"} }
+
+func (h *handler) ListChains(ctx context.Context, liveOnly bool) ([]pgengine.ChainInfo, error) {
+	return []pgengine.ChainInfo{{ChainID: 42, ChainName: "foo", Live: liveOnly}}, nil
+}
+
+func (h *handler) StartChain(ctx context.Context, chainName string, payload string) (int, error) {
+	if chainName == "busy" {
+		return 0, scheduler.ErrQueueFull
+	}
+	return 42, nil
+}
+
+func (h *handler) StartChainByID(ctx context.Context, chainID int, payload string) error {
+	if chainID == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (h *handler) StopChain(chainID int) bool { return chainID == 42 }
+
+func (h *handler) GetActiveChains() []scheduler.ActiveChain {
+	return []scheduler.ActiveChain{{ChainID: 42, TaskID: 24, StartedAt: time.Now()}}
+}
+
+func (h *handler) QueueLength() int { return 1 }
+
+func (h *handler) LogAPICall(ctx context.Context, call pgengine.APICall) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+}
+
+func newClient(t *testing.T, s *Server) pb.TimetableClient {
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewTimetableClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	s := Init(config.GrpcOpts{}, config.RestApiOpts{Write: true, Token: testToken}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	client := newClient(t, s)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+testToken)
+
+	_, err := client.ListChains(ctx, &pb.ListChainsRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	st, err := client.GetStatus(ctx, &pb.GetStatusRequest{})
+	assert.NoError(t, err)
+	assert.False(t, st.Ready)
+
+	h := &handler{}
+	s.Handler = h
+
+	chains, err := client.ListChains(ctx, &pb.ListChainsRequest{LiveOnly: true})
+	assert.NoError(t, err)
+	assert.Len(t, chains.Chains, 1)
+	assert.True(t, chains.Chains[0].Live)
+
+	run, err := client.RunChain(ctx, &pb.RunChainRequest{Chain: &pb.RunChainRequest_ChainName{ChainName: "foo"}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 42, run.ChainId)
+	_, err = client.RunChain(ctx, &pb.RunChainRequest{Chain: &pb.RunChainRequest_ChainName{ChainName: "busy"}})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	run, err = client.RunChain(ctx, &pb.RunChainRequest{Chain: &pb.RunChainRequest_ChainId{ChainId: 24}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 24, run.ChainId)
+	_, err = client.RunChain(ctx, &pb.RunChainRequest{Chain: &pb.RunChainRequest_ChainId{ChainId: 0}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.RunChain(ctx, &pb.RunChainRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stop, err := client.StopChain(ctx, &pb.StopChainRequest{ChainId: 42})
+	assert.NoError(t, err)
+	assert.True(t, stop.Stopped)
+
+	h.mu.Lock()
+	assert.Len(t, h.calls, 6, "only calls changing the scheduler state are stored")
+	assert.Equal(t, pgengine.APICall{API: "gRPC", Operation: pb.Timetable_RunChain_FullMethodName,
+		ChainID: 42, RemoteAddr: "bufconn", Status: "OK"}, h.calls[0], "chain started by name")
+	assert.Equal(t, "ResourceExhausted", h.calls[1].Status)
+	assert.Equal(t, pgengine.APICall{API: "gRPC", Operation: pb.Timetable_StopChain_FullMethodName,
+		ChainID: 42, RemoteAddr: "bufconn", Status: "OK"}, h.calls[5])
+	h.mu.Unlock()
+
+	st, err = client.GetStatus(ctx, &pb.GetStatusRequest{})
+	assert.NoError(t, err)
+	assert.True(t, st.Ready)
+	assert.Equal(t, "grpc_unit_test", st.ClientName)
+	assert.EqualValues(t, 1, st.QueuedChains)
+	assert.EqualValues(t, 24, st.ActiveChains[0].TaskId)
+
+	wctx, cancel := context.WithCancel(ctx)
+	stream, err := client.WatchStatus(wctx, &pb.WatchStatusRequest{IntervalSeconds: 1})
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		st, err = stream.Recv()
+		assert.NoError(t, err)
+		assert.True(t, st.Ready)
+	}
+	cancel()
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	s := Init(config.GrpcOpts{}, config.RestApiOpts{Token: testToken}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	s.Handler = &handler{}
+	client := newClient(t, s)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+testToken)
+	_, err := client.StopChain(ctx, &pb.StopChainRequest{ChainId: 42})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "calls changing the state are disabled by default")
+
+	s = Init(config.GrpcOpts{}, config.RestApiOpts{Write: true, Token: testToken}, nil, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	h := &handler{}
+	s.Handler = h
+	client = newClient(t, s)
+	_, err = client.StopChain(context.Background(), &pb.StopChainRequest{ChainId: 42})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.RunChain(metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong"),
+		&pb.RunChainRequest{Chain: &pb.RunChainRequest_ChainId{ChainId: 42}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.ListChains(context.Background(), &pb.ListChainsRequest{})
+	assert.NoError(t, err, "read-only calls need no authentication")
+	_, err = client.StopChain(ctx, &pb.StopChainRequest{ChainId: 42})
+	assert.NoError(t, err)
+	h.mu.Lock()
+	assert.Len(t, h.calls, 1, "rejected calls are not stored")
+	h.mu.Unlock()
+}
@@ -0,0 +1,169 @@
+// Package kerberos authenticates connections to the configuration database with GSSAPI (Kerberos),
+// e.g. in PostgreSQL environments integrated with Active Directory
+package kerberos
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/jackc/pgconn"
+	"github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// provider creates Kerberos clients for connections requesting GSSAPI authentication
+type provider struct {
+	opts config.KerberosOpts
+	l    log.LoggerIface
+	mu   sync.Mutex
+	// client logged in with the keytab, reused by connections until the keytab file changes
+	client *client.Client
+	// modification time of the keytab file the client is logged in with
+	modTime time.Time
+}
+
+// Init registers the GSSAPI provider used by connections if the server requests Kerberos authentication.
+// Credentials are taken from the keytab if specified, otherwise from the credentials cache, e.g. obtained with kinit
+func Init(opts config.KerberosOpts, logger log.LoggerIface) {
+	p := &provider{opts: opts, l: logger}
+	pgconn.RegisterGSSProvider(func() (pgconn.GSS, error) {
+		cl, err := p.getClient()
+		if err != nil {
+			return nil, fmt.Errorf("kerberos error: %w", err)
+		}
+		return &gss{cl}, nil
+	})
+}
+
+// getClient returns the client logged in with the keytab or the one using the credentials cache.
+// The cache is read for each connection, so tickets renewed externally, e.g. with k5start, are picked up
+func (p *provider) getClient() (*client.Client, error) {
+	cfg, err := loadConfig(p.opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.Keytab != "" {
+		return p.keytabClient(cfg)
+	}
+	ccache, err := credentials.LoadCCache(ccachePath())
+	if err != nil {
+		return nil, fmt.Errorf("cannot load credentials cache: %w", err)
+	}
+	return client.NewFromCCache(ccache, cfg, client.DisablePAFXFAST(true))
+}
+
+// keytabClient returns the client logged in with the keytab. The keytab is reloaded when the file changes,
+// e.g. after the password rotation, while tickets are renewed by the client itself
+func (p *provider) keytabClient(cfg *krb5config.Config) (*client.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info, err := os.Stat(p.opts.Keytab)
+	if err != nil {
+		return nil, err
+	}
+	if p.client != nil && info.ModTime().Equal(p.modTime) {
+		return p.client, p.client.AffirmLogin()
+	}
+	kt, err := keytab.Load(p.opts.Keytab)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load keytab: %w", err)
+	}
+	username, realm, err := principal(p.opts.Principal, kt, cfg.LibDefaults.DefaultRealm)
+	if err != nil {
+		return nil, err
+	}
+	cl := client.NewWithKeytab(username, realm, kt, cfg, client.DisablePAFXFAST(true))
+	if err = cl.Login(); err != nil {
+		return nil, err
+	}
+	if p.client != nil {
+		p.client.Destroy()
+		p.l.WithField("keytab", p.opts.Keytab).Info("Kerberos keytab reloaded")
+	}
+	p.client, p.modTime = cl, info.ModTime()
+	return cl, nil
+}
+
+// principal returns the user name and the realm of the principal specified as user@REALM, the first principal
+// of the keytab is used if not specified, the default realm of the configuration if the realm is omitted
+func principal(name string, kt *keytab.Keytab, defaultRealm string) (username string, realm string, err error) {
+	if name == "" {
+		if len(kt.Entries) == 0 {
+			return "", "", errors.New("keytab is empty")
+		}
+		p := kt.Entries[0].Principal
+		return strings.Join(p.Components, "/"), p.Realm, nil
+	}
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		return name[:i], name[i+1:], nil
+	}
+	return name, defaultRealm, nil
+}
+
+// loadConfig reads the Kerberos configuration file, $KRB5_CONFIG or /etc/krb5.conf by default
+func loadConfig(file string) (*krb5config.Config, error) {
+	if file == "" {
+		file = os.Getenv("KRB5_CONFIG")
+	}
+	if file == "" {
+		file = "/etc/krb5.conf"
+	}
+	cfg, err := krb5config.Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load configuration %s: %w", file, err)
+	}
+	return cfg, nil
+}
+
+// ccachePath returns the file of the credentials cache, $KRB5CCNAME or /tmp/krb5cc_<uid> by default
+func ccachePath() string {
+	if name := os.Getenv("KRB5CCNAME"); name != "" {
+		return strings.TrimPrefix(name, "FILE:")
+	}
+	uid := ""
+	if u, err := user.Current(); err == nil {
+		uid = u.Uid
+	}
+	return "/tmp/krb5cc_" + uid
+}
+
+// gss implements the SPNEGO initiator of the GSSAPI authentication exchange
+type gss struct {
+	cl *client.Client
+}
+
+// GetInitToken returns the initial token for the service principal service/host
+func (g *gss) GetInitToken(host string, service string) ([]byte, error) {
+	return g.GetInitTokenFromSPN(service + "/" + host)
+}
+
+// GetInitTokenFromSPN returns the initial token for the service principal
+func (g *gss) GetInitTokenFromSPN(spn string) ([]byte, error) {
+	token, err := spnego.SPNEGOClient(g.cl, spn).InitSecContext()
+	if err != nil {
+		return nil, fmt.Errorf("kerberos error: cannot get service ticket for %s: %w", spn, err)
+	}
+	return token.Marshal()
+}
+
+// Continue checks the response of the server, the exchange is done if the server accepted the initial token
+func (g *gss) Continue(inToken []byte) (done bool, outToken []byte, err error) {
+	var token spnego.SPNEGOToken
+	if err = token.Unmarshal(inToken); err != nil {
+		return true, nil, fmt.Errorf("kerberos error: cannot unmarshal server token: %w", err)
+	}
+	if !token.Resp || token.NegTokenResp.State() != spnego.NegStateAcceptCompleted {
+		return true, nil, errors.New("kerberos error: authentication is not accepted by the server")
+	}
+	return true, nil, nil
+}
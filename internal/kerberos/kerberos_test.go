@@ -0,0 +1,76 @@
+package kerberos
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipal(t *testing.T) {
+	kt := keytab.New()
+	_, _, err := principal("", kt, "EXAMPLE.COM")
+	assert.Error(t, err, "empty keytab")
+
+	assert.NoError(t, kt.AddEntry("postgres/scheduler", "CORP.EXAMPLE.COM", "secret", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96))
+	for name, expected := range map[string][2]string{
+		"":                      {"postgres/scheduler", "CORP.EXAMPLE.COM"},
+		"scheduler":             {"scheduler", "EXAMPLE.COM"},
+		"scheduler@OTHER.REALM": {"scheduler", "OTHER.REALM"},
+	} {
+		username, realm, err := principal(name, kt, "EXAMPLE.COM")
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, [2]string{username, realm}, name)
+	}
+}
+
+func TestCCachePath(t *testing.T) {
+	t.Setenv("KRB5CCNAME", "FILE:/run/krb5cc_scheduler")
+	assert.Equal(t, "/run/krb5cc_scheduler", ccachePath())
+	t.Setenv("KRB5CCNAME", "")
+	assert.Contains(t, ccachePath(), "krb5cc_")
+}
+
+func TestGetClient(t *testing.T) {
+	dir := t.TempDir()
+	krb5conf := filepath.Join(dir, "krb5.conf")
+	assert.NoError(t, os.WriteFile(krb5conf, []byte(`[libdefaults]
+  default_realm = EXAMPLE.COM
+  dns_lookup_kdc = false
+[realms]
+  EXAMPLE.COM = {
+    kdc = 127.0.0.1:1
+  }
+`), 0644))
+	kt := keytab.New()
+	assert.NoError(t, kt.AddEntry("scheduler", "EXAMPLE.COM", "secret", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96))
+	data, err := kt.Marshal()
+	assert.NoError(t, err)
+	ktFile := filepath.Join(dir, "scheduler.keytab")
+	assert.NoError(t, os.WriteFile(ktFile, data, 0600))
+	logger := log.Init(config.LoggingOpts{LogLevel: "error"})
+
+	p := &provider{opts: config.KerberosOpts{Config: filepath.Join(dir, "missing.conf")}, l: logger}
+	_, err = p.getClient()
+	assert.ErrorContains(t, err, "cannot load configuration")
+
+	p = &provider{opts: config.KerberosOpts{Config: krb5conf, Keytab: filepath.Join(dir, "missing.keytab")}, l: logger}
+	_, err = p.getClient()
+	assert.Error(t, err)
+
+	p = &provider{opts: config.KerberosOpts{Config: krb5conf, Keytab: ktFile}, l: logger}
+	_, err = p.getClient()
+	assert.Error(t, err, "KDC is not available")
+	assert.Nil(t, p.client, "client is cached only after the successful login")
+
+	t.Setenv("KRB5CCNAME", filepath.Join(dir, "missing_ccache"))
+	p = &provider{opts: config.KerberosOpts{Config: krb5conf}, l: logger}
+	_, err = p.getClient()
+	assert.ErrorContains(t, err, "cannot load credentials cache")
+}
@@ -0,0 +1,123 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fields carrying the trace context of the chain execution, see tracing.LogFields
+const (
+	TraceIDField = "trace_id"
+	SpanIDField  = "span_id"
+)
+
+// GCPFormatter formats log records as JSON lines understood by the Google Cloud Logging agent,
+// so severity, source location and trace are recognized without rewriting
+type GCPFormatter struct {
+	// ProjectID is used to link records to Cloud Trace, default: GOOGLE_CLOUD_PROJECT environment variable
+	ProjectID string
+}
+
+var gcpSeverity = map[logrus.Level]string{
+	logrus.PanicLevel: "ALERT",
+	logrus.FatalLevel: "CRITICAL",
+	logrus.ErrorLevel: "ERROR",
+	logrus.WarnLevel:  "WARNING",
+	logrus.InfoLevel:  "INFO",
+	logrus.DebugLevel: "DEBUG",
+	logrus.TraceLevel: "DEBUG",
+}
+
+// Format renders the log record as the Cloud Logging structured payload
+func (f *GCPFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := jsonFields(entry)
+	data["severity"] = gcpSeverity[entry.Level]
+	data["message"] = entry.Message
+	data["time"] = entry.Time.UTC().Format(time.RFC3339Nano)
+	if traceID, ok := data[TraceIDField].(string); ok {
+		delete(data, TraceIDField)
+		if f.ProjectID > "" {
+			traceID = "projects/" + f.ProjectID + "/traces/" + traceID
+		}
+		data["logging.googleapis.com/trace"] = traceID
+	}
+	if spanID, ok := data[SpanIDField].(string); ok {
+		delete(data, SpanIDField)
+		data["logging.googleapis.com/spanId"] = spanID
+	}
+	if entry.HasCaller() {
+		data["logging.googleapis.com/sourceLocation"] = map[string]string{
+			"file":     entry.Caller.File,
+			"line":     fmt.Sprint(entry.Caller.Line),
+			"function": entry.Caller.Function,
+		}
+	}
+	return marshalLine(data)
+}
+
+// CloudWatchFormatter formats log records as JSON lines following the AWS Lambda and Powertools
+// conventions, so CloudWatch Logs Insights discovers level, message and X-Ray trace fields
+type CloudWatchFormatter struct{}
+
+// Format renders the log record as the CloudWatch structured log event
+func (f *CloudWatchFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := jsonFields(entry)
+	data["timestamp"] = entry.Time.UTC().Format("2006-01-02T15:04:05.000Z")
+	data["level"] = strings.ToUpper(entry.Level.String())
+	data["message"] = entry.Message
+	if traceID, ok := data[TraceIDField].(string); ok && len(traceID) == 32 {
+		delete(data, TraceIDField)
+		data["xray_trace_id"] = "1-" + traceID[:8] + "-" + traceID[8:]
+	}
+	if entry.HasCaller() {
+		data["location"] = fmt.Sprintf("%s:%d", entry.Caller.Function, entry.Caller.Line)
+	}
+	return marshalLine(data)
+}
+
+// NewFormatter returns the formatter for the format name: text, json, gcp or cloudwatch.
+// The text format is rendered with the console formatter if console is true
+func NewFormatter(format string, console bool) logrus.Formatter {
+	switch format {
+	case "json":
+		return &logrus.JSONFormatter{}
+	case "gcp":
+		return &GCPFormatter{ProjectID: os.Getenv("GOOGLE_CLOUD_PROJECT")}
+	case "cloudwatch":
+		return &CloudWatchFormatter{}
+	}
+	if !console {
+		return &logrus.TextFormatter{}
+	}
+	return &Formatter{
+		HideKeys:        false,
+		FieldsOrder:     []string{"chain", "task", "sql", "params"},
+		TimestampFormat: "2006-01-02 15:04:05.000",
+		ShowFullLevel:   true,
+	}
+}
+
+// jsonFields returns the copy of the record fields with errors converted to strings
+func jsonFields(entry *logrus.Entry) map[string]interface{} {
+	data := make(map[string]interface{}, len(entry.Data)+4)
+	for key, value := range entry.Data {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		data[key] = value
+	}
+	return data
+}
+
+func marshalLine(data map[string]interface{}) ([]byte, error) {
+	line, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON: %w", err)
+	}
+	return append(line, '\n'), nil
+}
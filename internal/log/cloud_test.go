@@ -0,0 +1,47 @@
+package log
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+func TestGCPFormatter(t *testing.T) {
+	entry := newEntry(logrus.WarnLevel, "Task failed", logrus.Fields{
+		"chain": 42, TraceIDField: traceID, SpanIDField: "00f067aa0ba902b7", logrus.ErrorKey: errors.New("boom")})
+	entry.Caller = &runtime.Frame{File: "chain.go", Line: 10, Function: "executeChain"}
+	entry.Logger.ReportCaller = true
+	line, err := (&GCPFormatter{ProjectID: "my-project"}).Format(entry)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"severity":"WARNING","message":"Task failed","time":"2022-10-01T12:30:00Z","chain":42,"error":"boom",
+		"logging.googleapis.com/trace":"projects/my-project/traces/`+traceID+`",
+		"logging.googleapis.com/spanId":"00f067aa0ba902b7",
+		"logging.googleapis.com/sourceLocation":{"file":"chain.go","line":"10","function":"executeChain"}}`, string(line))
+
+	line, err = (&GCPFormatter{}).Format(newEntry(logrus.PanicLevel, "Panic", logrus.Fields{TraceIDField: traceID}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"severity":"ALERT","message":"Panic","time":"2022-10-01T12:30:00Z",
+		"logging.googleapis.com/trace":"`+traceID+`"}`, string(line))
+}
+
+func TestCloudWatchFormatter(t *testing.T) {
+	line, err := (&CloudWatchFormatter{}).Format(newEntry(logrus.InfoLevel, "Starting chain", logrus.Fields{
+		"chain": 42, TraceIDField: traceID, SpanIDField: "00f067aa0ba902b7"}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"timestamp":"2022-10-01T12:30:00.000Z","level":"INFO","message":"Starting chain","chain":42,
+		"xray_trace_id":"1-4bf92f35-77b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7"}`, string(line))
+}
+
+func TestNewFormatter(t *testing.T) {
+	assert.IsType(t, &Formatter{}, NewFormatter("text", true))
+	assert.IsType(t, &logrus.TextFormatter{}, NewFormatter("text", false))
+	assert.IsType(t, &logrus.JSONFormatter{}, NewFormatter("json", true))
+	assert.IsType(t, &GCPFormatter{}, NewFormatter("gcp", false))
+	assert.IsType(t, &CloudWatchFormatter{}, NewFormatter("cloudwatch", true))
+}
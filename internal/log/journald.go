@@ -0,0 +1,86 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// JournaldSocket is the socket of the systemd journal native protocol
+var JournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldHook sends log records to the systemd journal, the record fields are stored as journal fields
+type JournaldHook struct {
+	conn *net.UnixConn
+	mu   sync.Mutex
+}
+
+// NewJournaldHook returns the hook sending log records to the systemd journal
+func NewJournaldHook() *JournaldHook {
+	return &JournaldHook{}
+}
+
+// journalFieldName converts the record field name to the journal one, i.e. uppercase letters,
+// digits and underscores, not starting with an underscore reserved for trusted fields
+func journalFieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.TrimLeft(name, "_")
+}
+
+// writeJournalField appends the field to the native protocol message,
+// values containing newlines are written in the binary form
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(b, "%s=%s\n", name, value)
+		return
+	}
+	b.WriteString(name + "\n")
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+func (hook *JournaldHook) format(entry *logrus.Entry) []byte {
+	b := &bytes.Buffer{}
+	writeJournalField(b, "MESSAGE", entry.Message)
+	writeJournalField(b, "PRIORITY", strconv.Itoa(severity(entry.Level)))
+	writeJournalField(b, "SYSLOG_IDENTIFIER", appName)
+	for key, value := range entry.Data {
+		if name := journalFieldName(key); name != "" {
+			writeJournalField(b, name, fmt.Sprint(value))
+		}
+	}
+	return b.Bytes()
+}
+
+// Fire sends the log record to the systemd journal
+func (hook *JournaldHook) Fire(entry *logrus.Entry) (err error) {
+	msg := hook.format(entry)
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if hook.conn == nil {
+		if hook.conn, err = net.DialUnix("unixgram", nil, &net.UnixAddr{Name: JournaldSocket, Net: "unixgram"}); err != nil {
+			return err
+		}
+	}
+	_, err = hook.conn.Write(msg)
+	return err
+}
+
+// Levels returns the available logging levels
+func (hook *JournaldHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
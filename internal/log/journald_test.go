@@ -0,0 +1,47 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalFieldName(t *testing.T) {
+	assert.Equal(t, "CHAIN", journalFieldName("chain"))
+	assert.Equal(t, "PGX_LOG_LEVEL", journalFieldName("PGX_LOG_LEVEL"))
+	assert.Equal(t, "RETRY_AFTER", journalFieldName("_retry-after"))
+	assert.Equal(t, "", journalFieldName("__"))
+}
+
+func TestJournaldHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix datagram sockets are not supported")
+	}
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+	defer func(s string) { JournaldSocket = s }(JournaldSocket)
+	JournaldSocket = socket
+
+	hook := NewJournaldHook()
+	require.NoError(t, hook.Fire(newEntry(logrus.WarnLevel, "line1\nline2", logrus.Fields{"chain": 42})))
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+
+	expected := &bytes.Buffer{}
+	expected.WriteString("MESSAGE\n")
+	_ = binary.Write(expected, binary.LittleEndian, uint64(11))
+	expected.WriteString("line1\nline2\nPRIORITY=4\nSYSLOG_IDENTIFIER=pg_timetable\nCHAIN=42\n")
+	assert.Equal(t, expected.String(), string(buf[:n]))
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// outputFormatter formats records written to the console, records more verbose than the output level
+// are skipped. The logger level may be more verbose than the output one to feed the database log
+type outputFormatter struct {
+	logrus.Formatter
+	level   uint32 // the output level, accessed atomically
+	dbLevel logrus.Level
+}
+
+// Format returns nothing for records more verbose than the output level
+func (f *outputFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if !f.enabled(entry.Level) {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
+
+func (f *outputFormatter) enabled(level logrus.Level) bool {
+	return level <= logrus.Level(atomic.LoadUint32(&f.level))
+}
+
+// loggerLevel returns the level of the logger letting through records for both outputs and the database log
+func (f *outputFormatter) loggerLevel() logrus.Level {
+	if lvl := logrus.Level(atomic.LoadUint32(&f.level)); lvl > f.dbLevel {
+		return lvl
+	}
+	return f.dbLevel
+}
+
+// outputHook passes to the wrapped output, e.g. file or syslog, only records matching the output level
+type outputHook struct {
+	logrus.Hook
+}
+
+// OutputHook wraps the hook, so it receives records of the output level only, i.e. `--log-level`,
+// no matter how verbose the database log is
+func OutputHook(hook logrus.Hook) logrus.Hook {
+	return outputHook{hook}
+}
+
+// Fire passes the record to the wrapped hook if the output level allows it
+func (hook outputHook) Fire(entry *logrus.Entry) error {
+	if f, ok := entry.Logger.Formatter.(*outputFormatter); ok && !f.enabled(entry.Level) {
+		return nil
+	}
+	return hook.Hook.Fire(entry)
+}
@@ -2,7 +2,10 @@ package log
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"sync/atomic"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/config"
 	"github.com/jackc/pgx/v4"
@@ -24,31 +27,127 @@ type (
 
 // Init creates logging facilities for the application
 func Init(opts config.LoggingOpts) LoggerHookerIface {
-	var err error
 	l := logrus.New()
 	l.Out = os.Stdout
+	if r, err := NewRedactor(opts.LogRedact, SensitiveEnvValues()...); err == nil {
+		redactor = r
+		l.AddHook(r) // must go first, so secrets never reach other hooks and outputs
+	}
+	labels, _ := opts.Labels()
+	if len(labels) > 0 {
+		l.AddHook(labelsHook(labels)) // must go before outputs, so other hooks receive labels as well
+	}
 	if opts.LogFile > "" {
-		var f logrus.Formatter
-		f = &logrus.JSONFormatter{}
-		if opts.LogFileFormat == "text" {
-			f = &logrus.TextFormatter{}
-		}
-		l.AddHook(lfshook.NewHook(opts.LogFile, f))
+		l.AddHook(OutputHook(lfshook.NewHook(opts.LogFile, NewFormatter(opts.LogFileFormat, false))))
+	}
+	if opts.LogJournald {
+		l.AddHook(OutputHook(NewJournaldHook()))
+	}
+	if opts.LogLoki > "" {
+		l.AddHook(OutputHook(NewLokiHook(opts.LogLoki, labels)))
 	}
-	l.Level, err = logrus.ParseLevel(opts.LogLevel)
+	if opts.LogElasticsearch > "" {
+		l.AddHook(OutputHook(NewElasticsearchHook(opts.LogElasticsearch, opts.LogElasticIndex)))
+	}
+	level, err := logrus.ParseLevel(opts.LogLevel)
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+	dbLevel, err := logrus.ParseLevel(opts.LogDBLevel)
 	if err != nil {
-		l.Level = logrus.InfoLevel
-	}
-	l.SetFormatter(&Formatter{
-		HideKeys:        false,
-		FieldsOrder:     []string{"chain", "task", "sql", "params"},
-		TimestampFormat: "2006-01-02 15:04:05.000",
-		ShowFullLevel:   true,
-	})
-	l.SetReportCaller(l.Level > logrus.InfoLevel)
+		dbLevel = level
+	}
+	f := &outputFormatter{Formatter: NewFormatter(opts.LogFormat, true), level: uint32(level), dbLevel: dbLevel}
+	l.SetFormatter(f)
+	l.Level = f.loggerLevel()
+	l.SetReportCaller(level > logrus.InfoLevel)
+	if opts.LogSyslog > "" {
+		if hook, err := NewSyslogHook(opts.LogSyslog); err != nil {
+			l.WithError(err).Error("Cannot send logs to syslog")
+		} else {
+			l.AddHook(OutputHook(hook))
+		}
+	}
 	return l
 }
 
+// labelsHook adds static fields to every log record unless the field is set explicitly
+type labelsHook map[string]string
+
+// Fire adds labels to the log record
+func (hook labelsHook) Fire(entry *logrus.Entry) error {
+	for key, value := range hook {
+		if _, ok := entry.Data[key]; !ok {
+			entry.Data[key] = value
+		}
+	}
+	return nil
+}
+
+// Levels returns the available logging levels
+func (hook labelsHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// SetLevel changes the verbosity level of the logger created by Init
+func SetLevel(l LoggerIface, level string) error {
+	logger, ok := l.(*logrus.Logger)
+	if !ok {
+		return errors.New("logger doesn't support changing of the level")
+	}
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if f, ok := logger.Formatter.(*outputFormatter); ok {
+		atomic.StoreUint32(&f.level, uint32(lvl))
+		logger.SetLevel(f.loggerLevel())
+	} else {
+		logger.SetLevel(lvl)
+	}
+	logger.SetReportCaller(lvl > logrus.InfoLevel)
+	return nil
+}
+
+// WithLevel returns the logger sharing the output, formatter and hooks with l but using the specified
+// verbosity level, so the log of a single chain may be more or less verbose than the application one
+func WithLevel(l LoggerIface, level string) (LoggerIface, error) {
+	var (
+		logger *logrus.Logger
+		fields logrus.Fields
+	)
+	switch v := l.(type) {
+	case *logrus.Logger:
+		logger = v
+	case *logrus.Entry:
+		logger, fields = v.Logger, v.Data
+	default:
+		return nil, errors.New("logger doesn't support changing of the level")
+	}
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	clone := &logrus.Logger{
+		Out:          logger.Out,
+		Hooks:        logger.Hooks,
+		Formatter:    logger.Formatter,
+		ReportCaller: lvl > logrus.InfoLevel,
+		Level:        lvl,
+		ExitFunc:     logger.ExitFunc,
+	}
+	if f, ok := logger.Formatter.(*outputFormatter); ok {
+		if logrus.Level(atomic.LoadUint32(&f.level)) == lvl {
+			return l, nil
+		}
+		cloneF := &outputFormatter{Formatter: f.Formatter, level: uint32(lvl), dbLevel: f.dbLevel}
+		clone.Formatter, clone.Level = cloneF, cloneF.loggerLevel()
+	} else if lvl == logger.GetLevel() {
+		return l, nil
+	}
+	return clone.WithFields(fields), nil
+}
+
 // PgxLogger is the struct used to log using pgx postgres driver
 type PgxLogger struct {
 	l LoggerIface
@@ -65,14 +164,19 @@ func (pgxlogger *PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg str
 	if logger == FallbackLogger { //switch from standard to specified
 		logger = pgxlogger.l
 	}
+	_, statement := data["sql"]
 	if data != nil {
-		logger = logger.WithFields(data)
+		logger = logger.WithFields(statementFields(data))
 	}
 	switch level {
 	case pgx.LogLevelTrace:
 		logger.WithField("PGX_LOG_LEVEL", level).Debug(msg)
 	case pgx.LogLevelDebug, pgx.LogLevelInfo: //pgx is way too chatty on INFO level
-		logger.Debug(msg)
+		if tl, ok := logger.(interface{ Trace(...interface{}) }); ok && statement {
+			tl.Trace(msg)
+		} else {
+			logger.Debug(msg)
+		}
 	case pgx.LogLevelWarn:
 		logger.Warn(msg)
 	case pgx.LogLevelError:
@@ -82,6 +186,23 @@ func (pgxlogger *PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg str
 	}
 }
 
+// statementFields returns pgx log data with arguments of the statement formatted as the string, so the redactor
+// masks secrets passed as parameters, and the execution time renamed to duration
+func statementFields(data map[string]interface{}) logrus.Fields {
+	fields := make(logrus.Fields, len(data))
+	for key, value := range data {
+		switch key {
+		case "args":
+			fields[key] = fmt.Sprint(value)
+		case "time":
+			fields["duration"] = value
+		default:
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
 // WithLogger returns a new context with the provided logger. Use in
 // combination with logger.WithField(s) for great effect
 func WithLogger(ctx context.Context, logger LoggerIface) context.Context {
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/getsentry/sentry-go"
+	"github.com/sirupsen/logrus"
+)
+
+// sentryTags lists record fields sent as searchable event tags, other fields are sent as extra data
+var sentryTags = map[string]bool{"chain": true, "task": true, "run_id": true, "txid": true}
+
+// SentryHook reports error records, e.g. chain failures, to Sentry with the chain and task context
+type SentryHook struct {
+	client *sentry.Client
+}
+
+// NewSentryHook returns the hook reporting errors to the Sentry project specified by DSN.
+// Events are reported with the client name as the server name and the version as the release
+func NewSentryHook(opts config.SentryOpts, clientName, version string) (*SentryHook, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         opts.DSN,
+		SampleRate:  opts.SampleRate,
+		Environment: opts.Environment,
+		ServerName:  clientName,
+		Release:     version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SentryHook{client: client}, nil
+}
+
+// Fire sends the log record to Sentry in the background
+func (hook *SentryHook) Fire(entry *logrus.Entry) error {
+	event := sentry.NewEvent()
+	event.Level = sentry.LevelError
+	if entry.Level < logrus.ErrorLevel {
+		event.Level = sentry.LevelFatal
+	}
+	event.Message = entry.Message
+	event.Timestamp = entry.Time
+	event.Logger = appName
+	for key, value := range entry.Data {
+		switch err, isErr := value.(error); {
+		case key == logrus.ErrorKey && isErr:
+			event.SetException(err, 10)
+		case sentryTags[key]:
+			event.Tags[key] = fmt.Sprint(value)
+		case isErr:
+			event.Extra[key] = err.Error()
+		default:
+			event.Extra[key] = value
+		}
+	}
+	hook.client.CaptureEvent(event, nil, nil)
+	return nil
+}
+
+// Levels returns the logging levels reported to Sentry
+func (hook *SentryHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Flush waits until pending events are sent or the timeout is reached
+func (hook *SentryHook) Flush(timeout time.Duration) bool {
+	return hook.client.Flush(timeout)
+}
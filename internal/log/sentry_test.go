@@ -0,0 +1,69 @@
+package log
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/getsentry/sentry-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentryTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *sentryTransport) Configure(sentry.ClientOptions) {}
+
+func (t *sentryTransport) Flush(time.Duration) bool { return true }
+
+func (t *sentryTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func TestNewSentryHook(t *testing.T) {
+	_, err := NewSentryHook(config.SentryOpts{DSN: "https://key@sentry.example.com/42", SampleRate: 1}, "worker", "v5")
+	assert.NoError(t, err)
+	_, err = NewSentryHook(config.SentryOpts{DSN: "foo", SampleRate: 1}, "worker", "v5")
+	assert.Error(t, err)
+}
+
+func TestSentryHook(t *testing.T) {
+	transport := &sentryTransport{}
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:        "https://key@sentry.example.com/42",
+		Transport:  transport,
+		ServerName: "worker",
+	})
+	require.NoError(t, err)
+	hook := &SentryHook{client: client}
+
+	entry := newEntry(logrus.ErrorLevel, "Task execution failed", logrus.Fields{
+		"chain":   42,
+		"task":    24,
+		"run_id":  "run",
+		"command": "SELECT 1",
+		"error":   errors.New("division by zero"),
+	})
+	require.NoError(t, hook.Fire(entry))
+	assert.True(t, hook.Flush(time.Second))
+
+	require.Len(t, transport.events, 1)
+	event := transport.events[0]
+	assert.Equal(t, sentry.LevelError, event.Level)
+	assert.Equal(t, "Task execution failed", event.Message)
+	assert.Equal(t, "worker", event.ServerName)
+	assert.Equal(t, map[string]string{"chain": "42", "task": "24", "run_id": "run"}, event.Tags)
+	assert.Equal(t, "SELECT 1", event.Extra["command"])
+	require.Len(t, event.Exception, 1)
+	assert.Equal(t, "division by zero", event.Exception[0].Value)
+
+	assert.NotContains(t, hook.Levels(), logrus.InfoLevel, "only errors must be reported")
+}
@@ -0,0 +1,206 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	retry "github.com/sethvargo/go-retry"
+	"github.com/sirupsen/logrus"
+)
+
+// ShipperHook sends log records to the central log store, e.g. Loki or Elasticsearch, in batches.
+// Records are dropped if the store cannot keep up, failed batches are retried with backoff
+type ShipperHook struct {
+	batchSize     int           // send this number of entries at once
+	flushInterval time.Duration // wait this amount of time before sending an incomplete batch
+	maxRetries    uint64        // retry the failed batch this number of times before dropping it
+	retryDelay    time.Duration // initial delay between retries, doubled after every attempt
+	client        *http.Client
+	input         chan logrus.Entry
+	lastError     chan error
+	newRequest    func(batch []logrus.Entry) (*http.Request, error)
+}
+
+func newShipperHook(newRequest func(batch []logrus.Entry) (*http.Request, error)) *ShipperHook {
+	batchSize := 500
+	hook := &ShipperHook{
+		batchSize:     batchSize,
+		flushInterval: time.Second,
+		maxRetries:    5,
+		retryDelay:    500 * time.Millisecond,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		input:         make(chan logrus.Entry, batchSize*2),
+		lastError:     make(chan error, 1),
+		newRequest:    newRequest,
+	}
+	go hook.poll()
+	return hook
+}
+
+// Fire adds logrus log message to the internal queue for sending
+func (hook *ShipperHook) Fire(entry *logrus.Entry) error {
+	select {
+	case hook.input <- *entry:
+		// entry sent
+	default:
+		// entry dropped due to a huge load, check stdout or file for detailed log
+	}
+	select {
+	case err := <-hook.lastError:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Levels returns the available logging levels
+func (hook *ShipperHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// poll collects incoming records and sends them when the batch is full or the flush interval passed
+func (hook *ShipperHook) poll() {
+	batch := make([]logrus.Entry, 0, hook.batchSize)
+	tick := time.NewTicker(hook.flushInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case entry := <-hook.input:
+			if batch = append(batch, entry); len(batch) < hook.batchSize {
+				continue
+			}
+		case <-tick.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := hook.send(batch); err != nil {
+			select {
+			case hook.lastError <- err:
+				//error sent to the logger
+			default:
+				//there is unprocessed error already
+			}
+		}
+		batch = batch[:0]
+	}
+}
+
+// send posts the batch to the store, network failures and server errors are retried
+func (hook *ShipperHook) send(batch []logrus.Entry) error {
+	backoff := retry.WithMaxRetries(hook.maxRetries, retry.NewExponential(hook.retryDelay))
+	return retry.Do(context.Background(), backoff, func(ctx context.Context) error {
+		req, err := hook.newRequest(batch)
+		if err != nil {
+			return err
+		}
+		resp, err := hook.client.Do(req.WithContext(ctx))
+		if err != nil {
+			return retry.RetryableError(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode < 300 {
+			return checkBulkResponse(body)
+		}
+		err = fmt.Errorf("cannot ship logs to %s: %s %s", req.URL.Host, resp.Status, bytes.TrimSpace(body))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return retry.RetryableError(err)
+		}
+		return err
+	})
+}
+
+// checkBulkResponse returns an error if Elasticsearch bulk API rejected some of the records
+func checkBulkResponse(body []byte) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Errors {
+		return fmt.Errorf("some log records are rejected by Elasticsearch: %s", body)
+	}
+	return nil
+}
+
+var jsonFormatter = &logrus.JSONFormatter{
+	TimestampFormat: time.RFC3339Nano,
+	FieldMap:        logrus.FieldMap{logrus.FieldKeyTime: "@timestamp"},
+}
+
+// NewLokiHook returns the hook pushing log records to the Loki server, e.g. http://localhost:3100.
+// Records are stored as JSON lines in the streams labeled with the application name, level and static labels
+func NewLokiHook(addr string, labels map[string]string) *ShipperHook {
+	url := strings.TrimSuffix(addr, "/") + "/loki/api/v1/push"
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	return newShipperHook(func(batch []logrus.Entry) (*http.Request, error) {
+		streams := make(map[logrus.Level]*stream)
+		for i := range batch {
+			s, ok := streams[batch[i].Level]
+			if !ok {
+				s = &stream{Stream: map[string]string{"app": appName, "level": batch[i].Level.String()}}
+				for key, value := range labels {
+					s.Stream[key] = value
+				}
+				streams[batch[i].Level] = s
+			}
+			line, err := jsonFormatter.Format(&batch[i])
+			if err != nil {
+				return nil, err
+			}
+			s.Values = append(s.Values, [2]string{
+				strconv.FormatInt(batch[i].Time.UnixNano(), 10),
+				string(bytes.TrimSpace(line)),
+			})
+		}
+		payload := struct {
+			Streams []*stream `json:"streams"`
+		}{}
+		for _, s := range streams {
+			payload.Streams = append(payload.Streams, s)
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	})
+}
+
+// NewElasticsearchHook returns the hook indexing log records with the Elasticsearch bulk API, e.g. http://localhost:9200.
+// Records are stored in daily indices named after the prefix, e.g. pg_timetable-2006.01.02
+func NewElasticsearchHook(addr string, index string) *ShipperHook {
+	url := strings.TrimSuffix(addr, "/") + "/_bulk"
+	if index == "" {
+		index = appName
+	}
+	return newShipperHook(func(batch []logrus.Entry) (*http.Request, error) {
+		body := &bytes.Buffer{}
+		for i := range batch {
+			doc, err := jsonFormatter.Format(&batch[i])
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(body, `{"index":{"_index":%q}}`+"\n", index+"-"+batch[i].Time.UTC().Format("2006.01.02"))
+			body.Write(doc)
+		}
+		req, err := http.NewRequest(http.MethodPost, url, body)
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-ndjson")
+		}
+		return req, err
+	})
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLokiHook(t *testing.T) {
+	received := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/loki/api/v1/push", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	hook := NewLokiHook(srv.URL+"/", map[string]string{"env": "prod"})
+	require.NoError(t, hook.Fire(newEntry(logrus.InfoLevel, "Starting chain", logrus.Fields{"chain": 42})))
+
+	var payload struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	select {
+	case body := <-received:
+		require.NoError(t, json.Unmarshal(body, &payload))
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch is not sent")
+	}
+	require.Len(t, payload.Streams, 1)
+	assert.Equal(t, map[string]string{"app": "pg_timetable", "level": "info", "env": "prod"}, payload.Streams[0].Stream)
+	require.Len(t, payload.Streams[0].Values, 1)
+	assert.Equal(t, "1664627400000000000", payload.Streams[0].Values[0][0])
+	assert.JSONEq(t, `{"@timestamp":"2022-10-01T12:30:00Z","level":"info","msg":"Starting chain","chain":42}`,
+		payload.Streams[0].Values[0][1])
+}
+
+func TestElasticsearchHook(t *testing.T) {
+	var attempts int32
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/_bulk", r.URL.Path)
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+		_, _ = w.Write([]byte(`{"errors":false}`))
+	}))
+	defer srv.Close()
+
+	hook := NewElasticsearchHook(srv.URL, "")
+	hook.retryDelay = 10 * time.Millisecond
+	require.NoError(t, hook.Fire(newEntry(logrus.ErrorLevel, "Chain failed", nil)))
+	select {
+	case body := <-received:
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		require.Len(t, lines, 2)
+		assert.JSONEq(t, `{"index":{"_index":"pg_timetable-2022.10.01"}}`, lines[0])
+		assert.JSONEq(t, `{"@timestamp":"2022-10-01T12:30:00Z","level":"error","msg":"Chain failed"}`, lines[1])
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch is not sent")
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&attempts), "failed batch must be retried")
+}
+
+func TestShipperHookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"errors":true}`))
+	}))
+	defer srv.Close()
+
+	hook := NewElasticsearchHook(srv.URL, "logs")
+	require.NoError(t, hook.Fire(newEntry(logrus.InfoLevel, "Starting chain", nil)))
+	assert.Eventually(t, func() bool {
+		return hook.Fire(newEntry(logrus.InfoLevel, "Starting chain", nil)) != nil
+	}, 5*time.Second, 100*time.Millisecond, "rejected records must be reported")
+}
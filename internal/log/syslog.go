@@ -0,0 +1,138 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	appName        = "pg_timetable"
+	facilityDaemon = 3
+	dialTimeout    = 5 * time.Second
+)
+
+// severity returns the syslog severity of the logrus level
+func severity(level logrus.Level) int {
+	switch level {
+	case logrus.PanicLevel:
+		return 0 // emergency
+	case logrus.FatalLevel:
+		return 2 // critical
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7 // debug
+	}
+}
+
+// formatFields returns the message followed by the record fields in key=value form sorted by key
+func formatFields(entry *logrus.Entry) string {
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b := bytes.NewBufferString(entry.Message)
+	for _, key := range keys {
+		value := fmt.Sprint(entry.Data[key])
+		if strings.ContainsAny(value, " \"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(b, " %s=%s", key, value)
+	}
+	return b.String()
+}
+
+// SyslogHook sends log records to the syslog server in RFC 5424 format
+type SyslogHook struct {
+	network  string
+	address  string
+	hostname string
+	conn     net.Conn
+	mu       sync.Mutex
+}
+
+// NewSyslogHook returns the hook sending log records to the server specified by the URL, e.g.
+// udp://localhost:514, tcp://localhost:601 or unix:///dev/log. The connection is established on the first record
+func NewSyslogHook(addr string) (*SyslogHook, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	hook := &SyslogHook{network: u.Scheme, address: u.Host}
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "unix", "unixgram":
+		hook.address = u.Path
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %q", u.Scheme)
+	}
+	if hook.address == "" {
+		return nil, fmt.Errorf("syslog address is not specified: %q", addr)
+	}
+	if hook.hostname, err = os.Hostname(); err != nil || hook.hostname == "" {
+		hook.hostname = "-"
+	}
+	return hook, nil
+}
+
+// format returns the RFC 5424 message, e.g. `<30>1 2006-01-02T15:04:05.000000Z host pg_timetable 42 - - message`
+func (hook *SyslogHook) format(entry *logrus.Entry) []byte {
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		facilityDaemon*8+severity(entry.Level),
+		entry.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		hook.hostname,
+		appName,
+		os.Getpid(),
+		formatFields(entry)))
+}
+
+func (hook *SyslogHook) write(msg []byte) (err error) {
+	if hook.conn == nil {
+		network := hook.network
+		if network == "unix" {
+			network = "unixgram" // local syslog daemons listen on datagram sockets
+		}
+		if hook.conn, err = net.DialTimeout(network, hook.address, dialTimeout); err != nil {
+			return err
+		}
+	}
+	if hook.network == "tcp" { // octet counting framing, RFC 6587
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_, err = hook.conn.Write(msg)
+	return err
+}
+
+// Fire sends the log record to the syslog server, the connection is re-established once if broken
+func (hook *SyslogHook) Fire(entry *logrus.Entry) error {
+	msg := hook.format(entry)
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	err := hook.write(msg)
+	if err != nil && hook.conn != nil {
+		hook.conn.Close()
+		hook.conn = nil
+		err = hook.write(msg)
+	}
+	return err
+}
+
+// Levels returns the available logging levels
+func (hook *SyslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSyslogHook(t *testing.T) {
+	for _, addr := range []string{"udp://localhost:514", "tcp://localhost:601", "unix:///dev/log"} {
+		_, err := NewSyslogHook(addr)
+		assert.NoError(t, err, addr)
+	}
+	for _, addr := range []string{"localhost:514", "http://localhost", "udp://", "unix://", ":foo"} {
+		_, err := NewSyslogHook(addr)
+		assert.Error(t, err, addr)
+	}
+}
+
+func newEntry(level logrus.Level, msg string, fields logrus.Fields) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.New()).WithFields(fields)
+	entry.Level, entry.Message = level, msg
+	entry.Time = time.Date(2022, 10, 1, 12, 30, 0, 0, time.UTC)
+	return entry
+}
+
+func TestSyslogHookUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	hook, err := NewSyslogHook("udp://" + conn.LocalAddr().String())
+	require.NoError(t, err)
+
+	require.NoError(t, hook.Fire(newEntry(logrus.ErrorLevel, "Chain failed", logrus.Fields{"chain": 42, "sql": "SELECT 1"})))
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	expected := fmt.Sprintf(`<27>1 2022-10-01T12:30:00.000000Z %s pg_timetable %d - - Chain failed chain=42 sql="SELECT 1"`,
+		hook.hostname, os.Getpid())
+	assert.Equal(t, expected, string(buf[:n]))
+}
+
+func TestSyslogHookTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	hook, err := NewSyslogHook("tcp://" + ln.Addr().String())
+	require.NoError(t, err)
+
+	require.NoError(t, hook.Fire(newEntry(logrus.InfoLevel, "Starting chain", nil)))
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('-')
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^\d+ <30>1 `), line, "message must be framed with its length")
+	conn.Close()
+
+	// the broken connection is re-established
+	require.Eventually(t, func() bool {
+		return hook.Fire(newEntry(logrus.InfoLevel, "Starting chain", nil)) == nil
+	}, 2*time.Second, 50*time.Millisecond)
+}
+
+func TestSeverity(t *testing.T) {
+	assert.Equal(t, 0, severity(logrus.PanicLevel))
+	assert.Equal(t, 4, severity(logrus.WarnLevel))
+	assert.Equal(t, 7, severity(logrus.TraceLevel))
+}
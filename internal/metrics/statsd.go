@@ -0,0 +1,155 @@
+// Package metrics sends chain and task execution metrics to the StatsD server,
+// optionally tagged in the DogStatsD format
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+)
+
+// statsd sends metrics over UDP, delivery is not guaranteed and send errors are ignored
+type statsd struct {
+	conn   net.Conn
+	prefix string
+	tags   []string // static tags added to every metric, nil if tags are disabled
+}
+
+// sink is the client used by the package functions, metrics are not sent if nil
+var sink *statsd
+
+// Init sets up the StatsD client used to report chain and task metrics and returns the function
+// closing the connection on exit. Metrics are disabled if the server address is not specified
+func Init(opts config.StatsdOpts, clientName string) (shutdown func() error, err error) {
+	sink = nil
+	if opts.Address == "" {
+		return func() error { return nil }, nil
+	}
+	conn, err := net.Dial("udp", opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	s := &statsd{conn: conn, prefix: opts.Prefix}
+	if s.prefix != "" && !strings.HasSuffix(s.prefix, ".") {
+		s.prefix += "."
+	}
+	if opts.Tags {
+		s.tags = []string{tag("client", clientName)}
+	}
+	sink = s
+	return conn.Close, nil
+}
+
+// tag returns the DogStatsD tag, characters separating tags and metric fields are replaced in the value
+func tag(name, value string) string {
+	return name + ":" + strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", " ").Replace(value)
+}
+
+// send writes the counter and the timer of the execution in one datagram
+func (s *statsd) send(name string, success bool, duration time.Duration, tags ...string) {
+	status := "success"
+	if !success {
+		status = "failure"
+	}
+	suffix := ""
+	if s.tags != nil {
+		tags = append(append(tags, s.tags...), tag("status", status))
+		suffix = "|#" + strings.Join(tags, ",")
+	}
+	msg := fmt.Sprintf("%[1]s%[2]s.runs:1|c%[3]s\n%[1]s%[2]s.%[4]s:1|c%[3]s\n%[1]s%[2]s.duration:%[5]d|ms%[3]s",
+		s.prefix, name, suffix, status, duration.Milliseconds())
+	_, _ = s.conn.Write([]byte(msg))
+}
+
+// ChainFinished reports the chain execution with its result and duration
+func ChainFinished(chainName string, success bool, duration time.Duration) {
+	if sink != nil {
+		sink.send("chain", success, duration, tag("chain", chainName))
+	}
+}
+
+// TaskFinished reports the task execution with its result and duration
+func TaskFinished(chainName string, taskID int, kind string, success bool, duration time.Duration) {
+	if sink != nil {
+		sink.send("task", success, duration, tag("chain", chainName), tag("task", strconv.Itoa(taskID)), tag("kind", kind))
+	}
+}
+
+// ChainDurationAnomaly reports the chain run deviating from the usual duration
+func ChainDurationAnomaly(chainName string) {
+	if sink == nil {
+		return
+	}
+	suffix := ""
+	if sink.tags != nil {
+		suffix = "|#" + strings.Join(append([]string{tag("chain", chainName)}, sink.tags...), ",")
+	}
+	_, _ = fmt.Fprintf(sink.conn, "%schain.duration_anomaly:1|c%s", sink.prefix, suffix)
+}
+
+// ChainDropped reports the scheduled chain run dropped because the execution queue is full
+func ChainDropped(chainName string) {
+	if sink == nil {
+		return
+	}
+	suffix := ""
+	if sink.tags != nil {
+		suffix = "|#" + strings.Join(append([]string{tag("chain", chainName)}, sink.tags...), ",")
+	}
+	_, _ = fmt.Fprintf(sink.conn, "%schain.dropped:1|c%s", sink.prefix, suffix)
+}
+
+// ChainDisabled reports the chain disabled after consecutive failed runs
+func ChainDisabled(chainName string) {
+	if sink == nil {
+		return
+	}
+	suffix := ""
+	if sink.tags != nil {
+		suffix = "|#" + strings.Join(append([]string{tag("chain", chainName)}, sink.tags...), ",")
+	}
+	_, _ = fmt.Fprintf(sink.conn, "%schain.disabled:1|c%s", sink.prefix, suffix)
+}
+
+// Enabled returns true if metrics are sent, so callers may skip collecting them otherwise
+func Enabled() bool {
+	return sink != nil
+}
+
+var nonMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// ChainFreshness reports the number of seconds passed since the last successful run of the chain.
+// Without tags the chain name is appended to the metric name, e.g. chain.freshness.my_chain
+func ChainFreshness(chainName string, seconds int64) {
+	if sink == nil {
+		return
+	}
+	if sink.tags == nil {
+		_, _ = fmt.Fprintf(sink.conn, "%schain.freshness.%s:%d|g", sink.prefix, nonMetricChars.ReplaceAllString(chainName, "_"), seconds)
+		return
+	}
+	tags := append([]string{tag("chain", chainName)}, sink.tags...)
+	_, _ = fmt.Fprintf(sink.conn, "%schain.freshness:%d|g|#%s", sink.prefix, seconds, strings.Join(tags, ","))
+}
+
+// ChainDrift reports the number of chains differing from the definition files during the last reconciliation
+// and whether the reconciliation succeeded, i.e. files were read and changes were applied
+func ChainDrift(chains int, success bool) {
+	if sink == nil {
+		return
+	}
+	status := "success"
+	if !success {
+		status = "failure"
+	}
+	suffix := ""
+	if sink.tags != nil {
+		suffix = "|#" + strings.Join(sink.tags, ",")
+	}
+	_, _ = fmt.Fprintf(sink.conn, "%[1]sgitops.drift:%[2]d|g%[3]s\n%[1]sgitops.%[4]s:1|c%[3]s", sink.prefix, chains, suffix, status)
+}
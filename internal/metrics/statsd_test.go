@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listen(t *testing.T) (net.PacketConn, func() string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return conn, func() string {
+		buf := make([]byte, 1024)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+}
+
+func TestInit(t *testing.T) {
+	shutdown, err := Init(config.StatsdOpts{}, "worker")
+	require.NoError(t, err)
+	assert.Nil(t, sink, "metrics disabled without address")
+	assert.NoError(t, shutdown())
+	assert.NotPanics(t, func() { ChainFinished("foo", true, time.Second) })
+
+	_, err = Init(config.StatsdOpts{Address: "localhost"}, "worker")
+	assert.Error(t, err, "port is missing")
+}
+
+func TestStatsd(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	shutdown, err := Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Prefix: "pgtt"}, "worker")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, shutdown()) }()
+
+	ChainFinished("foo", true, 1500*time.Millisecond)
+	assert.Equal(t, "pgtt.chain.runs:1|c\npgtt.chain.success:1|c\npgtt.chain.duration:1500|ms", read())
+}
+
+func TestDogStatsd(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	shutdown, err := Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Tags: true}, "worker")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, shutdown()) }()
+
+	TaskFinished("foo,bar|baz", 42, "SQL", false, 20*time.Millisecond)
+	tags := "|#chain:foo_bar_baz,task:42,kind:SQL,client:worker,status:failure"
+	assert.Equal(t, "task.runs:1|c"+tags+"\ntask.failure:1|c"+tags+"\ntask.duration:20|ms"+tags, read())
+}
+
+func TestChainFreshness(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	shutdown, err := Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Prefix: "pgtt"}, "worker")
+	require.NoError(t, err)
+	assert.True(t, Enabled())
+	ChainFreshness("daily backup", 90)
+	assert.Equal(t, "pgtt.chain.freshness.daily_backup:90|g", read())
+	assert.NoError(t, shutdown())
+
+	shutdown, err = Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Tags: true}, "worker")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, shutdown()) }()
+	ChainFreshness("daily backup", 90)
+	assert.Equal(t, "chain.freshness:90|g|#chain:daily backup,client:worker", read())
+}
+
+func TestChainDurationAnomaly(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	shutdown, err := Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Tags: true}, "worker")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, shutdown()) }()
+	ChainDurationAnomaly("backup")
+	assert.Equal(t, "chain.duration_anomaly:1|c|#chain:backup,client:worker", read())
+}
+
+func TestChainDropped(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	shutdown, err := Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Prefix: "pgtt"}, "worker")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, shutdown()) }()
+	ChainDropped("backup")
+	assert.Equal(t, "pgtt.chain.dropped:1|c", read())
+}
+
+func TestChainDisabled(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	shutdown, err := Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Tags: true}, "worker")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, shutdown()) }()
+	ChainDisabled("backup")
+	assert.Equal(t, "chain.disabled:1|c|#chain:backup,client:worker", read())
+}
+
+func TestChainDrift(t *testing.T) {
+	conn, read := listen(t)
+	defer conn.Close()
+	shutdown, err := Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Prefix: "pgtt"}, "worker")
+	require.NoError(t, err)
+	ChainDrift(2, true)
+	assert.Equal(t, "pgtt.gitops.drift:2|g\npgtt.gitops.success:1|c", read())
+	assert.NoError(t, shutdown())
+
+	shutdown, err = Init(config.StatsdOpts{Address: conn.LocalAddr().String(), Tags: true}, "worker")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, shutdown()) }()
+	ChainDrift(0, false)
+	assert.Equal(t, "gitops.drift:0|g|#client:worker\ngitops.failure:1|c|#client:worker", read())
+}
@@ -0,0 +1,201 @@
+// Package pgagent converts jobs of pgAgent, the scheduler of pgAdmin, stored in the pgagent schema
+// to equivalent chains, easing the migration of jobs to pg_timetable
+package pgagent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// shell runs batch steps, the same as pgAgent does on Unix
+const shell = "/bin/sh"
+
+// nonName matches characters not allowed in generated chain names
+var nonName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Job is the job of the pgagent.pga_job table with its steps and schedules
+type Job struct {
+	ID        int    `db:"jobid"`
+	Name      string `db:"jobname"`
+	Enabled   bool   `db:"jobenabled"`
+	HostAgent string `db:"jobhostagent"`
+	Steps     []Step
+	Schedules []Schedule
+}
+
+// Step is the step of the job stored in the pgagent.pga_jobstep table
+type Step struct {
+	JobID   int    `db:"jstjobid"`
+	Name    string `db:"jstname"`
+	Enabled bool   `db:"jstenabled"`
+	Kind    string `db:"jstkind"` // s for SQL, b for batch
+	Code    string `db:"jstcode"`
+	ConnStr string `db:"jstconnstr"`
+	DBName  string `db:"jstdbname"`
+	OnError string `db:"jstonerror"` // f to fail, s to succeed, i to ignore
+}
+
+// Schedule is the schedule of the job stored in the pgagent.pga_schedule table. Arrays list moments the job runs at,
+// no moment set means any
+type Schedule struct {
+	JobID     int        `db:"jscjobid"`
+	Name      string     `db:"jscname"`
+	Enabled   bool       `db:"jscenabled"`
+	Start     time.Time  `db:"jscstart"`
+	End       *time.Time `db:"jscend"`
+	Minutes   []bool     `db:"jscminutes"`
+	Hours     []bool     `db:"jschours"`
+	WeekDays  []bool     `db:"jscweekdays"`  // Sunday first
+	MonthDays []bool     `db:"jscmonthdays"` // the last element stands for the last day of the month
+	Months    []bool     `db:"jscmonths"`
+}
+
+// Load returns jobs of the pgagent schema with steps in the execution order, i.e. by name
+func Load(ctx context.Context, db pgxscan.Querier) (jobs []Job, err error) {
+	const (
+		sqlSelectJobs = `SELECT jobid, jobname, jobenabled, COALESCE(jobhostagent, '') AS jobhostagent
+FROM pgagent.pga_job ORDER BY jobid`
+		sqlSelectSteps = `SELECT jstjobid, jstname, jstenabled, jstkind, jstcode, COALESCE(jstconnstr, '') AS jstconnstr,
+COALESCE(jstdbname, '') AS jstdbname, jstonerror
+FROM pgagent.pga_jobstep ORDER BY jstjobid, jstname, jstid`
+		sqlSelectSchedules = `SELECT jscjobid, jscname, jscenabled, jscstart, jscend,
+jscminutes, jschours, jscweekdays, jscmonthdays, jscmonths
+FROM pgagent.pga_schedule ORDER BY jscjobid, jscid`
+	)
+	var (
+		steps     []Step
+		schedules []Schedule
+	)
+	if err = pgxscan.Select(ctx, db, &jobs, sqlSelectJobs); err != nil {
+		return
+	}
+	if err = pgxscan.Select(ctx, db, &steps, sqlSelectSteps); err != nil {
+		return
+	}
+	if err = pgxscan.Select(ctx, db, &schedules, sqlSelectSchedules); err != nil {
+		return
+	}
+	index := make(map[int]*Job, len(jobs))
+	for i := range jobs {
+		index[jobs[i].ID] = &jobs[i]
+	}
+	for _, step := range steps {
+		if job, ok := index[step.JobID]; ok {
+			job.Steps = append(job.Steps, step)
+		}
+	}
+	for _, schedule := range schedules {
+		if job, ok := index[schedule.JobID]; ok {
+			job.Schedules = append(job.Schedules, schedule)
+		}
+	}
+	return
+}
+
+// Convert returns chain definitions equivalent to jobs. Every schedule of the job results in the chain named
+// <prefix>_<job name>, followed by the number of the schedule if the job has several ones. Jobs without schedules
+// result in chains started on demand only. Disabled jobs and schedules result in chains which are not live.
+// Parts of jobs which cannot be converted exactly are reported as warnings
+func Convert(jobs []Job, prefix string) (defs []pgengine.ChainDefinition, warnings []string) {
+	names := make(map[string]int)
+	for _, job := range jobs {
+		warn := func(format string, args ...interface{}) {
+			warnings = append(warnings, fmt.Sprintf("job %q: ", job.Name)+fmt.Sprintf(format, args...))
+		}
+		if job.HostAgent != "" {
+			warn("host agent %q ignored, chains are run by any client", job.HostAgent)
+		}
+		var tasks []pgengine.TaskDefinition
+		for _, step := range job.Steps {
+			if !step.Enabled {
+				warn("disabled step %q skipped", step.Name)
+				continue
+			}
+			task := pgengine.TaskDefinition{
+				Name:          step.Name,
+				Kind:          "SQL",
+				Command:       step.Code,
+				ConnectString: step.ConnStr,
+				IgnoreError:   step.OnError != "f",
+			}
+			if step.Kind == "b" {
+				task.Kind, task.Command = "PROGRAM", shell
+				task.Parameters = []interface{}{[]string{"-c", step.Code}}
+			} else if step.ConnStr == "" && step.DBName != "" {
+				task.ConnectString = "dbname=" + step.DBName
+				warn("step %q runs in database %q, check the connection string of the task", step.Name, step.DBName)
+			}
+			tasks = append(tasks, task)
+		}
+		if len(tasks) == 0 {
+			warn("no enabled steps, skipped")
+			continue
+		}
+		base := strings.Trim(nonName.ReplaceAllString(strings.ToLower(job.Name), "_"), "_")
+		if base == "" {
+			base = strconv.Itoa(job.ID)
+		}
+		base = strings.Trim(prefix+"_"+base, "_")
+		schedules := job.Schedules
+		if len(schedules) == 0 {
+			schedules = []Schedule{{Enabled: true}}
+		}
+		for i, schedule := range schedules {
+			name := base
+			if len(job.Schedules) > 1 {
+				name = fmt.Sprintf("%s_%d", base, i+1)
+			}
+			if names[name]++; names[name] > 1 {
+				name = fmt.Sprintf("%s_%d", name, names[name])
+			}
+			def := pgengine.ChainDefinition{
+				Name:     name,
+				Live:     job.Enabled && schedule.Enabled,
+				Schedule: cronSchedule(schedule),
+				Tasks:    tasks,
+			}
+			if len(job.Schedules) == 0 {
+				def.Schedule = ""
+			}
+			if len(schedule.MonthDays) > 31 && schedule.MonthDays[31] {
+				warn("schedule %q: the last day of the month is not supported", schedule.Name)
+			}
+			if schedule.End != nil {
+				warn("schedule %q: the end %s is ignored", schedule.Name, schedule.End.Format(time.RFC3339))
+			}
+			defs = append(defs, def)
+		}
+	}
+	return
+}
+
+// cronSchedule returns the cron-style expression equivalent to the schedule
+func cronSchedule(s Schedule) string {
+	monthDays := s.MonthDays
+	if len(monthDays) > 31 {
+		monthDays = monthDays[:31]
+	}
+	return strings.Join([]string{cronField(s.Minutes, 0), cronField(s.Hours, 0), cronField(monthDays, 1),
+		cronField(s.Months, 1), cronField(s.WeekDays, 0)}, " ")
+}
+
+// cronField returns the list of values set, starting from the first one, or * if none or all of them are set
+func cronField(set []bool, first int) string {
+	var values []string
+	for i, on := range set {
+		if on {
+			values = append(values, strconv.Itoa(first+i))
+		}
+	}
+	if len(values) == 0 || len(values) == len(set) {
+		return "*"
+	}
+	return strings.Join(values, ",")
+}
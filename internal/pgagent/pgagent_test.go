@@ -0,0 +1,74 @@
+package pgagent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+// moments returns the schedule array of size n with the elements specified set
+func moments(n int, set ...int) []bool {
+	a := make([]bool, n)
+	for _, i := range set {
+		a[i] = true
+	}
+	return a
+}
+
+func TestConvert(t *testing.T) {
+	end := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	jobs := []Job{
+		{
+			ID: 1, Name: "Nightly Vacuum", Enabled: true,
+			Steps: []Step{
+				{Name: "vacuum", Enabled: true, Kind: "s", Code: "VACUUM ANALYZE", DBName: "sales", OnError: "f"},
+				{Name: "disabled", Enabled: false, Kind: "s", Code: "SELECT 1"},
+				{Name: "dump", Enabled: true, Kind: "b", Code: "pg_dump sales > /tmp/sales.sql", OnError: "i"},
+			},
+			Schedules: []Schedule{
+				{Name: "night", Enabled: true, Minutes: moments(60, 30), Hours: moments(24, 2),
+					WeekDays: moments(7, 1, 2, 3, 4, 5), MonthDays: moments(32), Months: moments(12)},
+				{Name: "month end", Enabled: false, Minutes: moments(60, 0), Hours: moments(24),
+					WeekDays: moments(7), MonthDays: moments(32, 0, 14, 31), Months: moments(12, 0, 11), End: &end},
+			},
+		},
+		{
+			ID: 2, Name: "on demand", Enabled: false, HostAgent: "db1",
+			Steps: []Step{{Name: "report", Enabled: true, Kind: "s", Code: "CALL report()", ConnStr: "host=db2", OnError: "f"}},
+		},
+		{ID: 3, Name: "empty", Enabled: true, Steps: []Step{{Name: "off", Enabled: false}}},
+		{ID: 4, Name: "on-demand", Enabled: true, Steps: []Step{{Name: "noop", Enabled: true, Kind: "s", Code: "SELECT 1", OnError: "s"}}},
+	}
+	defs, warnings := Convert(jobs, "pgagent")
+	assert.Equal(t, []string{
+		`job "Nightly Vacuum": step "vacuum" runs in database "sales", check the connection string of the task`,
+		`job "Nightly Vacuum": disabled step "disabled" skipped`,
+		`job "Nightly Vacuum": schedule "month end": the last day of the month is not supported`,
+		`job "Nightly Vacuum": schedule "month end": the end 2030-01-01T00:00:00Z is ignored`,
+		`job "on demand": host agent "db1" ignored, chains are run by any client`,
+		`job "empty": disabled step "off" skipped`,
+		`job "empty": no enabled steps, skipped`,
+	}, warnings)
+	tasks := []pgengine.TaskDefinition{
+		{Name: "vacuum", Kind: "SQL", Command: "VACUUM ANALYZE", ConnectString: "dbname=sales"},
+		{Name: "dump", Kind: "PROGRAM", Command: "/bin/sh", IgnoreError: true,
+			Parameters: []interface{}{[]string{"-c", "pg_dump sales > /tmp/sales.sql"}}},
+	}
+	assert.Equal(t, []pgengine.ChainDefinition{
+		{Name: "pgagent_nightly_vacuum_1", Live: true, Schedule: "30 2 * * 1,2,3,4,5", Tasks: tasks},
+		{Name: "pgagent_nightly_vacuum_2", Live: false, Schedule: "0 * 1,15 1,12 *", Tasks: tasks},
+		{Name: "pgagent_on_demand", Live: false,
+			Tasks: []pgengine.TaskDefinition{{Name: "report", Kind: "SQL", Command: "CALL report()", ConnectString: "host=db2"}}},
+		{Name: "pgagent_on_demand_2", Live: true,
+			Tasks: []pgengine.TaskDefinition{{Name: "noop", Kind: "SQL", Command: "SELECT 1", IgnoreError: true}}},
+	}, defs)
+}
+
+func TestCronField(t *testing.T) {
+	assert.Equal(t, "*", cronField(nil, 0))
+	assert.Equal(t, "*", cronField(moments(7, 0, 1, 2, 3, 4, 5, 6), 0))
+	assert.Equal(t, "0,6", cronField(moments(7, 0, 6), 0))
+	assert.Equal(t, "1,31", cronField(moments(31, 0, 30), 1))
+}
@@ -0,0 +1,136 @@
+// Package pgcron converts jobs of the pg_cron extension stored in the cron.job table to equivalent SQL chains,
+// easing the migration of jobs to pg_timetable
+package pgcron
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+)
+
+// macros are the nonstandard schedules supported by pg_cron
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+	"@reboot":   "@reboot",
+}
+
+var (
+	// seconds matches the interval schedule of pg_cron, e.g. "30 seconds"
+	seconds = regexp.MustCompile(`^([1-9][0-9]?) seconds?$`)
+	// nonName matches characters not allowed in generated chain names
+	nonName = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Job is the job of the cron.job table
+type Job struct {
+	ID       int64  `db:"jobid"`
+	Name     string `db:"jobname"`
+	Schedule string `db:"schedule"`
+	Command  string `db:"command"`
+	NodeName string `db:"nodename"`
+	NodePort int    `db:"nodeport"`
+	Database string `db:"database"`
+	Username string `db:"username"`
+	Active   bool   `db:"active"`
+}
+
+// Target is the database and the user chains are executed with by the scheduler
+type Target struct {
+	Database string
+	User     string
+}
+
+// Load returns jobs of the cron.job table
+func Load(ctx context.Context, db pgxscan.Querier) (jobs []Job, err error) {
+	const sqlSelectJobs = `SELECT jobid, COALESCE(jobname, '') AS jobname, schedule, command, nodename, nodeport,
+database, username, active FROM cron.job ORDER BY jobid`
+	err = pgxscan.Select(ctx, db, &jobs, sqlSelectJobs)
+	return
+}
+
+// Execer executes statements, e.g. pgx.Conn or pgxpool.Pool
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Disable deactivates jobs specified by IDs, so they are not run by pg_cron any more
+func Disable(ctx context.Context, db Execer, ids []int64) error {
+	const sqlDisableJobs = `SELECT cron.alter_job(jobid, active := false) FROM unnest($1::bigint[]) AS jobid`
+	_, err := db.Exec(ctx, sqlDisableJobs, ids)
+	return err
+}
+
+// Convert returns chain definitions equivalent to jobs and IDs of jobs converted. Chains are named <prefix>_<job name>,
+// or <prefix>_<job ID> for unnamed jobs, and have the single SQL task with the command of the job. The task connects
+// to the database of the job if it differs from the target one, and runs as the user of the job if it differs from
+// the target one. Inactive jobs result in chains which are not live. Jobs with schedules which cannot be converted
+// are skipped and reported as warnings
+func Convert(jobs []Job, prefix string, target Target) (defs []pgengine.ChainDefinition, ids []int64, warnings []string) {
+	names := make(map[string]int)
+	for _, job := range jobs {
+		schedule, err := convertSchedule(job.Schedule)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("job %d: %s, skipped", job.ID, err))
+			continue
+		}
+		base := strings.Trim(nonName.ReplaceAllString(strings.ToLower(job.Name), "_"), "_")
+		if base == "" {
+			base = strconv.FormatInt(job.ID, 10)
+		}
+		name := strings.Trim(prefix+"_"+base, "_")
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, names[name])
+		}
+		task := pgengine.TaskDefinition{Kind: "SQL", Command: job.Command}
+		if job.NodeName != "" && job.NodeName != "localhost" {
+			task.ConnectString = fmt.Sprintf("host=%s port=%d dbname=%s", job.NodeName, job.NodePort, job.Database)
+		} else if job.Database != target.Database {
+			task.ConnectString = "dbname=" + job.Database
+		}
+		if job.Username != target.User {
+			task.RunAs = job.Username
+		}
+		defs = append(defs, pgengine.ChainDefinition{
+			Name:     name,
+			Schedule: schedule,
+			Live:     job.Active,
+			Tasks:    []pgengine.TaskDefinition{task},
+		})
+		ids = append(ids, job.ID)
+	}
+	return
+}
+
+// convertSchedule returns the schedule of the chain equivalent to the pg_cron schedule
+func convertSchedule(schedule string) (string, error) {
+	schedule = strings.Join(strings.Fields(strings.ToLower(schedule)), " ")
+	if m := seconds.FindStringSubmatch(schedule); m != nil {
+		return "@every " + m[1] + " seconds", nil
+	}
+	if strings.HasPrefix(schedule, "@") {
+		if macro, ok := macros[schedule]; ok {
+			return macro, nil
+		}
+		return "", fmt.Errorf("unknown schedule %s", schedule)
+	}
+	if strings.Contains(schedule, "$") {
+		return "", fmt.Errorf("the last day of the month in %q is not supported", schedule)
+	}
+	if _, err := cron.Parse(schedule); err != nil {
+		return "", fmt.Errorf("invalid schedule %q: %w", schedule, err)
+	}
+	return schedule, nil
+}
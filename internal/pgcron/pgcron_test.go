@@ -0,0 +1,37 @@
+package pgcron
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	jobs := []Job{
+		{ID: 1, Name: "Nightly Vacuum", Schedule: "30 2 * * 1-5", Command: "VACUUM ANALYZE", NodeName: "localhost",
+			NodePort: 5432, Database: "timetable", Username: "scheduler", Active: true},
+		{ID: 2, Schedule: "@daily", Command: "CALL purge()", NodeName: "localhost", NodePort: 5432,
+			Database: "sales", Username: "postgres", Active: false},
+		{ID: 3, Name: "nightly-vacuum", Schedule: "10  seconds", Command: "SELECT 1", NodeName: "db2",
+			NodePort: 5433, Database: "sales", Username: "scheduler", Active: true},
+		{ID: 4, Schedule: "0 12 $ * *", Command: "SELECT 1", Active: true},
+		{ID: 5, Schedule: "61 * * * *", Command: "SELECT 1", Active: true},
+		{ID: 6, Schedule: "@often", Command: "SELECT 1", Active: true},
+	}
+	defs, ids, warnings := Convert(jobs, "pgcron", Target{Database: "timetable", User: "scheduler"})
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	if assert.Len(t, warnings, 3) {
+		assert.Equal(t, `job 4: the last day of the month in "0 12 $ * *" is not supported, skipped`, warnings[0])
+		assert.Contains(t, warnings[1], `job 5: invalid schedule "61 * * * *"`)
+		assert.Equal(t, `job 6: unknown schedule @often, skipped`, warnings[2])
+	}
+	assert.Equal(t, []pgengine.ChainDefinition{
+		{Name: "pgcron_nightly_vacuum", Schedule: "30 2 * * 1-5", Live: true,
+			Tasks: []pgengine.TaskDefinition{{Kind: "SQL", Command: "VACUUM ANALYZE"}}},
+		{Name: "pgcron_2", Schedule: "0 0 * * *", Live: false,
+			Tasks: []pgengine.TaskDefinition{{Kind: "SQL", Command: "CALL purge()", ConnectString: "dbname=sales", RunAs: "postgres"}}},
+		{Name: "pgcron_nightly_vacuum_2", Schedule: "@every 10 seconds", Live: true,
+			Tasks: []pgengine.TaskDefinition{{Kind: "SQL", Command: "SELECT 1", ConnectString: "host=db2 port=5433 dbname=sales"}}},
+	}, defs)
+}
@@ -2,9 +2,14 @@ package pgengine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
 	"github.com/georgysavva/scany/pgxscan"
 )
 
@@ -27,14 +32,31 @@ func (pge *PgEngine) IsAlive() bool {
 	return pge.ConfigDb != nil && pge.ConfigDb.Ping(context.Background()) == nil
 }
 
-// LogChainElementExecution will log current chain element execution status including retcode
+// ClockSkew returns the difference between the server and the client clocks, positive if the server clock is ahead.
+// The round trip is compensated assuming the request and the response take the same time
+func (pge *PgEngine) ClockSkew(ctx context.Context) (time.Duration, error) {
+	var serverTime time.Time
+	sent := time.Now()
+	if err := pge.ConfigDb.QueryRow(ctx, "SELECT clock_timestamp()").Scan(&serverTime); err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	return serverTime.Sub(sent.Add(received.Sub(sent) / 2)), nil
+}
+
+// LogChainElementExecution will log current chain element execution status including retcode.
+// The output is shortened according to the output limit and policy
 func (pge *PgEngine) LogChainElementExecution(ctx context.Context, task *ChainTask, retCode int, output string) {
-	_, err := pge.ConfigDb.Exec(ctx, `INSERT INTO timetable.execution_log (
-chain_id, task_id, command, kind, last_run, finished, returncode, pid, output, client_name, txid) 
-VALUES ($1, $2, $3, $4, clock_timestamp() - $5 :: interval, clock_timestamp(), $6, $7, NULLIF($8, ''), $9, $10)`,
+	output, compressed, err := ShortenOutput(log.Redact(strings.TrimSpace(output)), pge.Resource.OutputLimit<<10, pge.Resource.OutputPolicy)
+	if err != nil {
+		pge.l.WithError(err).Error("Failed to compress task output")
+	}
+	_, err = pge.ConfigDb.Exec(ctx, `INSERT INTO timetable.execution_log (
+chain_id, task_id, command, kind, last_run, finished, returncode, pid, output, client_name, txid, output_gzip) 
+VALUES ($1, $2, $3, $4, clock_timestamp() - $5 :: interval, clock_timestamp(), $6, $7, NULLIF($8, ''), $9, $10, $11)`,
 		task.ChainID, task.TaskID, task.Script, task.Kind,
 		fmt.Sprintf("%f seconds", float64(task.Duration)/1000000),
-		retCode, pge.Getpid(), strings.TrimSpace(output), pge.ClientName, task.Txid)
+		retCode, pge.Getpid(), output, pge.ClientName, task.Txid, compressed)
 	if err != nil {
 		pge.l.WithError(err).Error("Failed to log chain element execution status")
 	}
@@ -64,37 +86,237 @@ func (pge *PgEngine) RemoveChainRunStatus(ctx context.Context, chainID int) {
 	}
 }
 
-// Select live chains with proper client_name value
-const sqlSelectLiveChains = `SELECT chain_id, chain_name, self_destruct, exclusive_execution, COALESCE(timeout, 0) as timeout, COALESCE(max_instances, 16) as max_instances
-FROM timetable.chain WHERE live AND (client_name = $1 or client_name IS NULL)`
+// UpdateChainStatus stores the time of the successful chain run used to track the chain freshness,
+// and the time the run started at used as the previous run of the next one
+func (pge *PgEngine) UpdateChainStatus(ctx context.Context, chainID int, startedAt time.Time) {
+	const sqlUpdateChainStatus = `INSERT INTO timetable.chain_status (chain_id, last_success, client_name, last_started) 
+VALUES ($1, now(), $2, $3) 
+ON CONFLICT (chain_id) DO UPDATE SET last_success = EXCLUDED.last_success, client_name = EXCLUDED.client_name, 
+	last_started = EXCLUDED.last_started`
+	_, err := pge.ConfigDb.Exec(ctx, sqlUpdateChainStatus, chainID, pge.ClientName, startedAt)
+	if err != nil {
+		pge.l.WithError(err).Error("Cannot save the time of the successful chain run")
+	}
+}
+
+// SelectPreviousRun returns the time the last successful run of the chain started at, nil if the chain never succeeded
+func (pge *PgEngine) SelectPreviousRun(ctx context.Context, chainID int) (startedAt *time.Time, err error) {
+	const sqlSelectPreviousRun = `SELECT max(last_started) FROM timetable.chain_status WHERE chain_id = $1`
+	err = pge.ConfigDb.QueryRow(ctx, sqlSelectPreviousRun, chainID).Scan(&startedAt)
+	return
+}
+
+// Select chains matching the client tags: tagged with any of them, or untagged if the client has no tags
+const sqlTagsMatch = `(tags && $2 OR cardinality($2::text[]) = 0 AND COALESCE(cardinality(tags), 0) = 0)`
+
+// Select chains requiring a subset of the client labels, chains without required labels match any client
+const sqlLabelsMatch = `COALESCE(required_labels, '{}') <@ $3::jsonb`
+
+// Select live chains with proper client_name, tags and required_labels values
+const sqlSelectLiveChains = `SELECT chain_id, chain_name, self_destruct, exclusive_execution, COALESCE(timeout, 0) as timeout, COALESCE(max_instances, 16) as max_instances, COALESCE(log_level, '') as log_level, COALESCE(log_sampling, 1) as log_sampling, COALESCE(read_only, FALSE) as read_only, owner
+FROM timetable.chain WHERE live AND (client_name = $1 or client_name IS NULL) AND ` + sqlTagsMatch + ` AND ` + sqlLabelsMatch
+
+// clientLabels returns labels of the client, they are validated on startup
+func (pge *PgEngine) clientLabels() map[string]string {
+	labels, _ := pge.Labels()
+	return labels
+}
 
 // SelectRebootChains returns a list of chains should be executed after reboot
 func (pge *PgEngine) SelectRebootChains(ctx context.Context, dest interface{}) error {
 	const sqlSelectRebootChains = sqlSelectLiveChains + ` AND run_at = '@reboot'`
-	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectRebootChains, pge.ClientName)
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectRebootChains, pge.ClientName, pge.Tags(), pge.clientLabels())
 }
 
-// SelectChains returns a list of chains should be executed at the current moment
-func (pge *PgEngine) SelectChains(ctx context.Context, dest interface{}) error {
-	const sqlSelectChains = sqlSelectLiveChains + ` AND NOT COALESCE(starts_with(run_at, '@'), FALSE) AND timetable.is_cron_in_time(run_at, now())`
-	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectChains, pge.ClientName)
+// SelectChains returns a list of chains should be executed at the current moment or in any of the previous
+// minutes, e.g. minutes = 2 means the current and the previous minute are checked. With the load balancing
+// chains without client name are returned only if they are assigned to this client
+func (pge *PgEngine) SelectChains(ctx context.Context, dest interface{}, minutes int) error {
+	const sqlSelectChains = sqlSelectLiveChains + ` AND NOT COALESCE(starts_with(run_at, '@'), FALSE) AND EXISTS(
+SELECT 1 FROM generate_series(0, $4 - 1) AS m WHERE timetable.is_cron_in_time(run_at, now() - m * interval '1 minute'))
+AND (NOT $5 OR client_name IS NOT NULL OR COALESCE(timetable.balanced_client(chain_id), $1) = $1)`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectChains, pge.ClientName, pge.Tags(), pge.clientLabels(), minutes, pge.Resource.LoadBalancing)
+}
+
+// SelectScheduledChains returns live cron-style chains regardless of their schedule together with the schedule
+// and the time zone of the database session, so due chains are checked in memory
+func (pge *PgEngine) SelectScheduledChains(ctx context.Context, dest interface{}) error {
+	const sqlSelectScheduledChains = `SELECT chain_id, chain_name, self_destruct, exclusive_execution, COALESCE(timeout, 0) as timeout, COALESCE(max_instances, 16) as max_instances, COALESCE(log_level, '') as log_level, COALESCE(log_sampling, 1) as log_sampling, COALESCE(read_only, FALSE) as read_only, owner,
+COALESCE(run_at, '') as run_at, current_setting('TimeZone') as time_zone
+FROM timetable.chain WHERE live AND (client_name = $1 or client_name IS NULL) AND ` + sqlTagsMatch + ` AND ` + sqlLabelsMatch + ` AND NOT COALESCE(starts_with(run_at, '@'), FALSE)`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectScheduledChains, pge.ClientName, pge.Tags(), pge.clientLabels())
+}
+
+// ChainChanges returns the number of change notifications of chains, tasks and parameters received so far
+func (pge *PgEngine) ChainChanges() int64 {
+	return atomic.LoadInt64(&pge.chainChanges)
+}
+
+// UpdateHeartbeat reports the number of free workers used to balance chains without client name between clients.
+// The report is considered by other clients during the valid period only
+func (pge *PgEngine) UpdateHeartbeat(ctx context.Context, freeSlots int, valid time.Duration) {
+	const sqlUpdateHeartbeat = `INSERT INTO timetable.client_heartbeat AS h (client_name, free_slots, reported_at, valid_seconds)
+VALUES ($1, $2, now(), $3)
+ON CONFLICT (client_name) DO UPDATE SET
+	prev_free_slots = CASE WHEN h.reported_at < date_trunc('minute', now()) THEN h.free_slots ELSE h.prev_free_slots END,
+	prev_reported_at = CASE WHEN h.reported_at < date_trunc('minute', now()) THEN h.reported_at ELSE h.prev_reported_at END,
+	free_slots = EXCLUDED.free_slots, reported_at = EXCLUDED.reported_at, valid_seconds = EXCLUDED.valid_seconds`
+	_, err := pge.ConfigDb.Exec(ctx, sqlUpdateHeartbeat, pge.ClientName, freeSlots, int(valid.Seconds()))
+	if err != nil {
+		pge.l.WithError(err).Error("Cannot report free workers to the heartbeat table")
+	}
 }
 
 // SelectIntervalChains returns list of interval chains to be executed
 func (pge *PgEngine) SelectIntervalChains(ctx context.Context, dest interface{}) error {
 	const sqlSelectIntervalChains = `SELECT
 chain_id, chain_name, self_destruct, exclusive_execution, 
-COALESCE(timeout, 0) as timeout, COALESCE(max_instances, 16) as max_instances,
+COALESCE(timeout, 0) as timeout, COALESCE(max_instances, 16) as max_instances, COALESCE(log_level, '') as log_level, COALESCE(log_sampling, 1) as log_sampling, COALESCE(read_only, FALSE) as read_only, owner,
 EXTRACT(EPOCH FROM (substr(run_at, 7) :: interval)) :: int4 as interval_seconds,
 starts_with(run_at, '@after') as repeat_after
-FROM timetable.chain WHERE live AND (client_name = $1 or client_name IS NULL) AND substr(run_at, 1, 6) IN ('@every', '@after')`
-	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectIntervalChains, pge.ClientName)
+FROM timetable.chain WHERE live AND (client_name = $1 or client_name IS NULL) AND ` + sqlTagsMatch + ` AND ` + sqlLabelsMatch + ` AND substr(run_at, 1, 6) IN ('@every', '@after')`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectIntervalChains, pge.ClientName, pge.Tags(), pge.clientLabels())
 }
 
 // SelectChain returns the chain with the specified ID
 func (pge *PgEngine) SelectChain(ctx context.Context, dest interface{}, chainID int) error {
 	// we accept not only live chains here because we want to run them in debug mode
-	const sqlSelectSingleChain = `SELECT chain_id, chain_name, self_destruct, exclusive_execution, COALESCE(timeout, 0) as timeout, COALESCE(max_instances, 16) as max_instances
+	const sqlSelectSingleChain = `SELECT chain_id, chain_name, self_destruct, exclusive_execution, COALESCE(timeout, 0) as timeout, COALESCE(max_instances, 16) as max_instances, COALESCE(log_level, '') as log_level, COALESCE(log_sampling, 1) as log_sampling, COALESCE(read_only, FALSE) as read_only, owner
 FROM timetable.chain WHERE (client_name = $1 OR client_name IS NULL) AND chain_id = $2`
 	return pgxscan.Get(ctx, pge.ConfigDb, dest, sqlSelectSingleChain, pge.ClientName, chainID)
 }
+
+// ChainInfo describes the chain configuration stored in the timetable.chain
+type ChainInfo struct {
+	ChainID            int    `db:"chain_id" json:"chain_id"`
+	ChainName          string `db:"chain_name" json:"chain_name"`
+	RunAt              string `db:"run_at" json:"run_at"`
+	MaxInstances       int    `db:"max_instances" json:"max_instances"`
+	Timeout            int    `db:"timeout" json:"timeout"`
+	Live               bool   `db:"live" json:"live"`
+	SelfDestruct       bool   `db:"self_destruct" json:"self_destruct"`
+	ExclusiveExecution bool   `db:"exclusive_execution" json:"exclusive_execution"`
+	ClientName         string `db:"client_name" json:"client_name"`
+}
+
+// SelectChainList returns all chains available to this client ordered by ID
+func (pge *PgEngine) SelectChainList(ctx context.Context, dest interface{}, liveOnly bool) error {
+	const sqlSelectChainList = `SELECT chain_id, chain_name, COALESCE(run_at, '') AS run_at, 
+COALESCE(max_instances, 0) AS max_instances, COALESCE(timeout, 0) AS timeout, COALESCE(live, FALSE) AS live, 
+COALESCE(self_destruct, FALSE) AS self_destruct, COALESCE(exclusive_execution, FALSE) AS exclusive_execution, 
+COALESCE(client_name, '') AS client_name
+FROM timetable.chain WHERE (client_name = $1 OR client_name IS NULL) AND (live OR NOT $2)
+ORDER BY chain_id`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectChainList, pge.ClientName, liveOnly)
+}
+
+// SelectChainByName returns the chain with the specified name
+func (pge *PgEngine) SelectChainByName(ctx context.Context, dest interface{}, chainName string) error {
+	const sqlSelectChainByName = `SELECT chain_id, chain_name, self_destruct, exclusive_execution, COALESCE(timeout, 0) as timeout, COALESCE(max_instances, 16) as max_instances, COALESCE(log_level, '') as log_level, COALESCE(log_sampling, 1) as log_sampling, COALESCE(read_only, FALSE) as read_only, owner
+FROM timetable.chain WHERE live AND (client_name = $1 OR client_name IS NULL) AND chain_name = $2`
+	return pgxscan.Get(ctx, pge.ConfigDb, dest, sqlSelectChainByName, pge.ClientName, chainName)
+}
+
+// SelectChainNames returns names of all chains starting with the prefix ordered by name, e.g. for the shell completion
+func (pge *PgEngine) SelectChainNames(ctx context.Context, prefix string) (names []string, err error) {
+	const sqlSelectChainNames = `SELECT chain_name FROM timetable.chain WHERE starts_with(chain_name, $1) ORDER BY chain_name`
+	err = pgxscan.Select(ctx, pge.ConfigDb, &names, sqlSelectChainNames, prefix)
+	return
+}
+
+// SelectChainID returns the ID of the chain specified by the name or the ID
+func (pge *PgEngine) SelectChainID(ctx context.Context, nameOrID string) (chainID int, err error) {
+	const sqlSelectChainID = `SELECT chain_id FROM timetable.chain WHERE chain_name = $1 OR chain_id::text = $1`
+	err = pge.ConfigDb.QueryRow(ctx, sqlSelectChainID, nameOrID).Scan(&chainID)
+	return
+}
+
+// ListenChannel returns the NOTIFY channel the client listens on
+func (pge *PgEngine) ListenChannel() string {
+	if pge.NotifyChannel == "" {
+		return pge.ClientName
+	}
+	return strings.ReplaceAll(pge.NotifyChannel, "{client}", pge.ClientName)
+}
+
+// ClientChannel returns the NOTIFY channel the client listens on in addition to ListenChannel,
+// timetable.notify_chain_start() sends START notifications of chains bound to the client there
+func (pge *PgEngine) ClientChannel() string {
+	return "pgtt_" + pge.ClientName
+}
+
+// RegisterNotifyChannel stores the channel the client listens on, so notifications sent by
+// timetable.notify_chain_start() and other functions are delivered to it
+func (pge *PgEngine) RegisterNotifyChannel(ctx context.Context) (err error) {
+	if channel := pge.ListenChannel(); channel != pge.ClientName {
+		_, err = pge.ConfigDb.Exec(ctx, `INSERT INTO timetable.notify_channel (client_name, channel) VALUES ($1, $2)
+ON CONFLICT (client_name) DO UPDATE SET channel = EXCLUDED.channel`, pge.ClientName, channel)
+	} else {
+		_, err = pge.ConfigDb.Exec(ctx, "DELETE FROM timetable.notify_channel WHERE client_name = $1", pge.ClientName)
+	}
+	return
+}
+
+// NotifyChainStart sends the notification to the client to start the chain
+func (pge *PgEngine) NotifyChainStart(ctx context.Context, chainID int) error {
+	_, err := pge.ConfigDb.Exec(ctx, "SELECT timetable.notify_chain_start($1, $2)", chainID, pge.ClientName)
+	return err
+}
+
+// NotifyChainStop sends the notification to the client to stop the chain
+func (pge *PgEngine) NotifyChainStop(ctx context.Context, chainID int) error {
+	_, err := pge.ConfigDb.Exec(ctx, "SELECT timetable.notify_chain_stop($1, $2)", chainID, pge.ClientName)
+	return err
+}
+
+// HandoffChain reassigns the chain to the client and notifies active clients, so the previous owner finishes
+// the running instance and the new one picks up the next run
+func (pge *PgEngine) HandoffChain(ctx context.Context, chainID int, clientName string) error {
+	_, err := pge.ConfigDb.Exec(ctx, "SELECT timetable.handoff_chain($1, $2)", chainID, clientName)
+	return err
+}
+
+// ChainSelector selects chains by tags and the name pattern, chains must match both if specified
+type ChainSelector struct {
+	Tags    []string // chains tagged with any of these tags
+	Pattern string   // name pattern, * matches any sequence of characters and ? any single character
+}
+
+// ErrNoChainSelector is returned if neither tags nor the name pattern is specified
+var ErrNoChainSelector = errors.New("tag or name pattern is required")
+
+// likePattern converts the name pattern to the LIKE pattern
+var likePattern = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%", "?", "_")
+
+// SetChainsLive sets the live flag of all chains matching the selector in a single statement, e.g. to pause chains
+// before the maintenance window, and returns names of chains changed
+func (pge *PgEngine) SetChainsLive(ctx context.Context, live bool, selector ChainSelector) (names []string, err error) {
+	const sqlSetChainsLive = `UPDATE timetable.chain SET live = $1
+WHERE live IS DISTINCT FROM $1 AND (cardinality($2 :: text[]) = 0 OR tags && $2) AND ($3 = '' OR chain_name LIKE $3)
+RETURNING chain_name`
+	if len(selector.Tags) == 0 && selector.Pattern == "" {
+		return nil, ErrNoChainSelector
+	}
+	tags := selector.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	err = pgxscan.Select(ctx, pge.ConfigDb, &names, sqlSetChainsLive, live, tags, likePattern.Replace(selector.Pattern))
+	sort.Strings(names)
+	return
+}
+
+// DisableChain turns the live flag of the chain off and returns false if the chain is not live already
+func (pge *PgEngine) DisableChain(ctx context.Context, chainID int) (bool, error) {
+	const sqlDisableChain = `UPDATE timetable.chain SET live = FALSE WHERE chain_id = $1 AND live`
+	tag, err := pge.ConfigDb.Exec(ctx, sqlDisableChain, chainID)
+	return tag.RowsAffected() > 0, err
+}
+
+// SelectChainSchedule returns the schedule of the chain and the time zone of the database session
+// used to evaluate it
+func (pge *PgEngine) SelectChainSchedule(ctx context.Context, chainID int) (runAt string, timeZone string, err error) {
+	const sqlSelectChainSchedule = `SELECT COALESCE(run_at, ''), current_setting('TimeZone') FROM timetable.chain WHERE chain_id = $1`
+	err = pge.ConfigDb.QueryRow(ctx, sqlSelectChainSchedule, chainID).Scan(&runAt, &timeZone)
+	return
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
 	"github.com/pashagolub/pgxmock"
@@ -60,13 +61,53 @@ func TestRemoveChainRunStatus(t *testing.T) {
 	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
 }
 
-func TestSelectChains(t *testing.T) {
+func TestUpdateChainStatus(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	pge.ClientName = "test_client"
+	defer mockPool.Close()
+
+	startedAt := time.Now()
+	mockPool.ExpectExec("INSERT INTO timetable\\.chain_status").
+		WithArgs(42, pge.ClientName, startedAt).
+		WillReturnError(errors.New("error"))
+	pge.UpdateChainStatus(context.Background(), 42, startedAt)
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestSelectPreviousRun(t *testing.T) {
 	initmockdb(t)
 	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
 	defer mockPool.Close()
+	ctx := context.Background()
+
+	startedAt := time.Now()
+	mockPool.ExpectQuery("SELECT max\\(last_started\\) FROM timetable\\.chain_status").WithArgs(42).
+		WillReturnRows(pgxmock.NewRows([]string{"max"}).AddRow(&startedAt))
+	prev, err := pge.SelectPreviousRun(ctx, 42)
+	assert.NoError(t, err)
+	assert.Equal(t, &startedAt, prev)
+
+	mockPool.ExpectQuery("SELECT max\\(last_started\\) FROM timetable\\.chain_status").WithArgs(42).
+		WillReturnError(errors.New("error"))
+	_, err = pge.SelectPreviousRun(ctx, 42)
+	assert.Error(t, err)
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestSelectChains(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test", "--chain-tags=etl,reports", "--client-labels=os=linux")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("SELECT.+chain_id.+tags.+required_labels").
+		WithArgs(pge.ClientName, []string{"etl", "reports"}, map[string]string{"os": "linux"}, 2, false).WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectChains(context.Background(), &[]struct{}{}, 2))
 
 	mockPool.ExpectExec("SELECT.+chain_id").WillReturnError(errors.New("error"))
-	assert.Error(t, pge.SelectChains(context.Background(), struct{}{}))
+	assert.Error(t, pge.SelectChains(context.Background(), struct{}{}, 1))
 
 	mockPool.ExpectExec("SELECT.+chain_id").WillReturnError(errors.New("error"))
 	assert.Error(t, pge.SelectRebootChains(context.Background(), struct{}{}))
@@ -75,6 +116,22 @@ func TestSelectChains(t *testing.T) {
 	assert.Error(t, pge.SelectIntervalChains(context.Background(), struct{}{}))
 }
 
+func TestUpdateHeartbeat(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test", "--load-balancing")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("SELECT.+balanced_client").WithArgs(pge.ClientName, []string{}, map[string]string{}, 1, true).WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectChains(context.Background(), &[]struct{}{}, 1))
+
+	mockPool.ExpectExec("INSERT INTO timetable.client_heartbeat").WithArgs(pge.ClientName, 12, 120).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	pge.UpdateHeartbeat(context.Background(), 12, 2*time.Minute)
+	mockPool.ExpectExec("INSERT INTO timetable.client_heartbeat").WillReturnError(errors.New("error"))
+	pge.UpdateHeartbeat(context.Background(), 0, time.Minute)
+	assert.NoError(t, mockPool.ExpectationsWereMet())
+}
+
 func TestSelectChain(t *testing.T) {
 	initmockdb(t)
 	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
@@ -82,6 +139,74 @@ func TestSelectChain(t *testing.T) {
 
 	mockPool.ExpectExec("SELECT.+chain_id").WillReturnError(errors.New("error"))
 	assert.Error(t, pge.SelectChain(context.Background(), struct{}{}, 42))
+
+	mockPool.ExpectExec("SELECT.+chain_id").WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectChainByName(context.Background(), struct{}{}, "foo"))
+
+	mockPool.ExpectQuery("SELECT.+chain_id").WithArgs(pge.ClientName, true).WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectChainList(context.Background(), &[]pgengine.ChainInfo{}, true))
+}
+
+func TestSelectChainNames(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("SELECT chain_name").WithArgs("va").WillReturnRows(pgxmock.NewRows([]string{"chain_name"}).AddRow("vacuum"))
+	names, err := pge.SelectChainNames(context.Background(), "va")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vacuum"}, names)
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestChainManagement(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+	ctx := context.Background()
+
+	mockPool.ExpectQuery("SELECT chain_id FROM timetable\\.chain").WithArgs("foo").
+		WillReturnRows(pgxmock.NewRows([]string{"chain_id"}).AddRow(42))
+	id, err := pge.SelectChainID(ctx, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 42, id)
+
+	mockPool.ExpectExec("SELECT timetable\\.notify_chain_start").WithArgs(42, pge.ClientName).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	assert.NoError(t, pge.NotifyChainStart(ctx, 42))
+
+	mockPool.ExpectExec("SELECT timetable\\.notify_chain_stop").WithArgs(42, pge.ClientName).
+		WillReturnError(errors.New("error"))
+	assert.Error(t, pge.NotifyChainStop(ctx, 42))
+
+	mockPool.ExpectExec("SELECT timetable\\.handoff_chain").WithArgs(42, "client02").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	assert.NoError(t, pge.HandoffChain(ctx, 42, "client02"))
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestNotifyChannel(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	pge.ClientName = "pgengine_unit_test"
+	defer mockPool.Close()
+	ctx := context.Background()
+
+	assert.Equal(t, "pgengine_unit_test", pge.ListenChannel())
+	assert.Equal(t, "pgtt_pgengine_unit_test", pge.ClientChannel())
+	mockPool.ExpectExec("DELETE FROM timetable\\.notify_channel").WithArgs("pgengine_unit_test").
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	assert.NoError(t, pge.RegisterNotifyChannel(ctx))
+
+	pge.NotifyChannel = "pgtt_chain_{client}"
+	assert.Equal(t, "pgtt_chain_pgengine_unit_test", pge.ListenChannel())
+	mockPool.ExpectExec("INSERT INTO timetable\\.notify_channel").WithArgs("pgengine_unit_test", "pgtt_chain_pgengine_unit_test").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	assert.NoError(t, pge.RegisterNotifyChannel(ctx))
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
 }
 
 func TestIsAlive(t *testing.T) {
@@ -92,6 +217,22 @@ func TestIsAlive(t *testing.T) {
 	assert.True(t, pge.IsAlive())
 }
 
+func TestClockSkew(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("SELECT clock_timestamp").
+		WillReturnRows(pgxmock.NewRows([]string{"clock_timestamp"}).AddRow(time.Now().Add(time.Hour)))
+	skew, err := pge.ClockSkew(context.Background())
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, skew, float64(time.Second))
+
+	mockPool.ExpectQuery("SELECT clock_timestamp").WillReturnError(errors.New("error"))
+	_, err = pge.ClockSkew(context.Background())
+	assert.Error(t, err)
+}
+
 func TestLogChainElementExecution(t *testing.T) {
 	initmockdb(t)
 	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
@@ -104,3 +245,84 @@ func TestLogChainElementExecution(t *testing.T) {
 
 	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
 }
+
+func TestSelectChainSchedule(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("SELECT.+run_at").WithArgs(42).
+		WillReturnRows(pgxmock.NewRows([]string{"run_at", "current_setting"}).AddRow("* * * * *", "UTC"))
+	runAt, tz, err := pge.SelectChainSchedule(context.Background(), 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "* * * * *", runAt)
+	assert.Equal(t, "UTC", tz)
+}
+
+func TestSelectSchedule(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("SELECT.+timetable\\.next_run").WillReturnError(errors.New("error"))
+	_, err := pge.SelectSchedule(context.Background())
+	assert.Error(t, err)
+}
+
+func TestSelectChainStats(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("WITH runs AS").WillReturnError(errors.New("error"))
+	_, err := pge.SelectChainStats(context.Background(), time.Now())
+	assert.Error(t, err)
+}
+
+func TestSelectChainOverview(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("SELECT.+LEFT JOIN LATERAL").WillReturnError(errors.New("error"))
+	_, err := pge.SelectChainOverview(context.Background(), pgengine.ChainOverviewFilter{Client: "foo", Tags: []string{"etl"}})
+	assert.Error(t, err)
+}
+
+func TestSetChainsLive(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+	ctx := context.Background()
+
+	_, err := pge.SetChainsLive(ctx, false, pgengine.ChainSelector{})
+	assert.ErrorIs(t, err, pgengine.ErrNoChainSelector)
+
+	mockPool.ExpectQuery("UPDATE timetable\\.chain SET live").WithArgs(false, []string{}, `etl\_%`).
+		WillReturnRows(pgxmock.NewRows([]string{"chain_name"}).AddRow("etl_load").AddRow("etl_clean"))
+	names, err := pge.SetChainsLive(ctx, false, pgengine.ChainSelector{Pattern: "etl_*"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"etl_clean", "etl_load"}, names)
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestDisableChain(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+	ctx := context.Background()
+
+	mockPool.ExpectExec("UPDATE timetable\\.chain SET live = FALSE").WithArgs(42).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	disabled, err := pge.DisableChain(ctx, 42)
+	assert.NoError(t, err)
+	assert.True(t, disabled)
+
+	mockPool.ExpectExec("UPDATE timetable\\.chain SET live = FALSE").WithArgs(42).
+		WillReturnError(errors.New("error"))
+	_, err = pge.DisableChain(ctx, 42)
+	assert.Error(t, err)
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
@@ -0,0 +1,74 @@
+package pgengine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SelectArchiveDays returns days having execution log entries older than the specified number of days, the oldest first
+func (pge *PgEngine) SelectArchiveDays(ctx context.Context, days int) (res []time.Time, err error) {
+	const sqlSelectArchiveDays = `SELECT DISTINCT last_run :: date FROM timetable.execution_log 
+WHERE last_run < current_date - $1 :: integer ORDER BY 1`
+	rows, err := pge.ConfigDb.Query(ctx, sqlSelectArchiveDays, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day time.Time
+		if err = rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		res = append(res, day)
+	}
+	return res, rows.Err()
+}
+
+// ArchiveExecutionLog moves execution log entries of the day into the timetable.execution_log_archive table,
+// or into the file of the directory if dir is specified, as gzip compressed CSV. Entries are deleted in the same
+// transaction, so they are never lost, but may be exported twice if the commit fails after the file is written.
+// Returns the number of entries archived, 0 if another client is archiving at the moment
+func (pge *PgEngine) ArchiveExecutionLog(ctx context.Context, day time.Time, dir string) (int64, error) {
+	const sqlCopyDay = `COPY (DELETE FROM timetable.execution_log 
+WHERE last_run >= '%[1]s' :: date AND last_run < '%[1]s' :: date + 1 RETURNING *) TO STDOUT WITH (FORMAT csv, HEADER)`
+	tx, err := pge.ConfigDb.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	var locked bool
+	err = tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock(hashtext('pg_timetable_archive'))").Scan(&locked)
+	if err != nil || !locked {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	res, err := tx.Conn().PgConn().CopyTo(ctx, zw, fmt.Sprintf(sqlCopyDay, day.Format("2006-01-02")))
+	if err != nil {
+		return 0, err
+	}
+	if err = zw.Close(); err != nil || res.RowsAffected() == 0 {
+		return 0, err
+	}
+	if dir == "" {
+		_, err = tx.Exec(ctx, `INSERT INTO timetable.execution_log_archive (day, entries, client_name, data) 
+VALUES ($1, $2, $3, $4)`, day, res.RowsAffected(), pge.ClientName, buf.Bytes())
+	} else if err = os.MkdirAll(dir, 0750); err == nil {
+		err = os.WriteFile(archiveFileName(dir, day, time.Now()), buf.Bytes(), 0640)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), tx.Commit(ctx)
+}
+
+// archiveFileName returns the name of the file entries of the day are exported to, the moment of archiving
+// is included, so entries of the same day archived later never overwrite the previous file
+func archiveFileName(dir string, day time.Time, now time.Time) string {
+	return filepath.Join(dir, fmt.Sprintf("execution_log_%s_%d.csv.gz", day.Format("2006-01-02"), now.Unix()))
+}
@@ -0,0 +1,49 @@
+package pgengine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveFileName(t *testing.T) {
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Unix(1791000000, 0)
+	assert.Equal(t, filepath.Join("archive", "execution_log_2026-10-01_1791000000.csv.gz"), archiveFileName("archive", day, now))
+	assert.NotEqual(t, archiveFileName("archive", day, now), archiveFileName("archive", day, now.Add(time.Second)),
+		"later archiving of the same day never overwrites the file")
+}
+
+func TestArchiveExecutionLog(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+	pge := NewDB(mock, "pgengine_unit_test")
+	ctx := context.Background()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT DISTINCT last_run :: date FROM timetable\\.execution_log").WithArgs(30).
+		WillReturnRows(pgxmock.NewRows([]string{"last_run"}).AddRow(day))
+	days, err := pge.SelectArchiveDays(ctx, 30)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{day}, days)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock").
+		WillReturnRows(pgxmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectRollback()
+	entries, err := pge.ArchiveExecutionLog(ctx, day, "")
+	assert.NoError(t, err)
+	assert.Zero(t, entries, "nothing is archived while another client is archiving")
+
+	mock.ExpectBegin().WillReturnError(errors.New("error"))
+	_, err = pge.ArchiveExecutionLog(ctx, day, "")
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
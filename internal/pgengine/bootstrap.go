@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/config"
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/secrets"
 
 	pgconn "github.com/jackc/pgconn"
 	pgx "github.com/jackc/pgx/v4"
@@ -51,12 +55,29 @@ type PgxPoolIface interface {
 
 // PgEngine is responsible for every database-related action
 type PgEngine struct {
+	// generation of credentials increased on each secret rotation, accessed atomically, must be 64-bit aligned
+	credentialsGen int64
+	// number of chain change notifications received, accessed atomically, must be 64-bit aligned
+	chainChanges int64
+
 	l        log.LoggerHookerIface
 	ConfigDb PgxPoolIface
 	config.CmdOptions
 	// NOTIFY messages passed verification are pushed to this channel
 	chainSignalChan chan ChainSignal
 	pid             int32
+	// resolves secret references in the connection options
+	secrets *secrets.Resolver
+	// generation of credentials each pool connection was established with
+	connGen sync.Map
+	// connection holding the leader lock of the high availability group, used by the election goroutine only
+	leaderConn *pgxpool.Conn
+	// 1 if the client name lock is released for the instance taking over, accessed atomically
+	released int32
+	// tasks of recently executed chains
+	tasks taskCache
+	// concurrent tasks of remote database connections
+	remoteQuota connQuota
 }
 
 // Getpid returns the pseudo-random process ID to use for the session identification.
@@ -81,14 +102,23 @@ func New(ctx context.Context, cmdOpts config.CmdOptions, logger log.LoggerHooker
 		ConfigDb:        nil,
 		CmdOptions:      cmdOpts,
 		chainSignalChan: make(chan ChainSignal, 64),
+		secrets:         secrets.NewResolver(ctx, cmdOpts.Secrets, logger),
 	}
+	pge.secrets.OnRotate(pge.rotateConnections)
 	pge.l.WithField("PID", pge.Getpid()).Info("Starting new session... ")
-	connctx, conncancel := context.WithTimeout(ctx, time.Duration(cmdOpts.Connection.Timeout)*time.Second)
+	timeout := time.Duration(cmdOpts.Connection.Timeout) * time.Second
+	connctx, conncancel := context.WithTimeout(ctx, timeout)
+	if cmdOpts.Start.RetryStartup { // retry until cancelled, the timeout is applied to each attempt
+		connctx, conncancel = context.WithCancel(ctx)
+	}
 	defer conncancel()
 
 	config := pge.getPgxConnConfig()
 	if err = retry.Do(connctx, backoff, func(ctx context.Context) error {
-		if pge.ConfigDb, err = pgxpool.ConnectConfig(connctx, config); err != nil {
+		attemptctx, attemptcancel := context.WithTimeout(connctx, timeout)
+		defer attemptcancel()
+		if pge.ConfigDb, err = pgxpool.ConnectConfig(attemptctx, config); err != nil {
+			pge.invalidateSecretsOnAuthError(err)
 			pge.l.Info("Sleeping before reconnecting...")
 			return retry.RetryableError(err)
 		}
@@ -109,6 +139,31 @@ func New(ctx context.Context, cmdOpts config.CmdOptions, logger log.LoggerHooker
 	return pge, nil
 }
 
+// Connect opens the connection to the database used by management commands. Unlike New it neither
+// creates the schema nor starts the session, so it is safe to run along with the working instance
+func Connect(ctx context.Context, cmdOpts config.CmdOptions, logger log.LoggerHookerIface) (*PgEngine, error) {
+	pge := &PgEngine{
+		l:               logger,
+		CmdOptions:      cmdOpts,
+		chainSignalChan: make(chan ChainSignal, 64),
+		secrets:         secrets.NewResolver(ctx, cmdOpts.Secrets, logger),
+	}
+	connConfig := pge.getPgxConnConfig()
+	if connConfig == nil {
+		return nil, errors.New("cannot parse connection string")
+	}
+	connConfig.AfterConnect = nil // do not lock the client name, the working instance may hold it
+	connConfig.MaxConns = 1
+	connctx, conncancel := context.WithTimeout(ctx, time.Duration(cmdOpts.Connection.Timeout)*time.Second)
+	defer conncancel()
+	pool, err := pgxpool.ConnectConfig(connctx, connConfig)
+	if err != nil {
+		return nil, err
+	}
+	pge.ConfigDb = pool
+	return pge, nil
+}
+
 // NewDB creates pgengine instance for already opened database connection, allowing to bypass a parameters based credentials.
 // We assume here all checks for proper schema validation are done beforehannd
 func NewDB(DB PgxPoolIface, args ...string) *PgEngine {
@@ -117,22 +172,30 @@ func NewDB(DB PgxPoolIface, args ...string) *PgEngine {
 		ConfigDb:        DB,
 		CmdOptions:      *config.NewCmdOptions(args...),
 		chainSignalChan: make(chan ChainSignal, 64),
+		secrets:         secrets.NewResolver(context.Background(), config.SecretOpts{}, log.Init(config.LoggingOpts{LogLevel: "error"})),
 	}
 }
 
-// getPgxConnConfig transforms standard connestion string to pgx specific one with
-func (pge *PgEngine) getPgxConnConfig() *pgxpool.Config {
-	var connstr string
+// connString returns the connection string of the scheduler database, user and password referencing
+// the secret store are omitted and set by resolveCredentials
+func (pge *PgEngine) connString() string {
 	if pge.Connection.PgURL != "" {
-		connstr = pge.Connection.PgURL
-	} else {
-		connstr = fmt.Sprintf("host='%s' port='%d' dbname='%s' sslmode='%s' user='%s'",
-			pge.Connection.Host, pge.Connection.Port, pge.Connection.DBName, pge.Connection.SSLMode, pge.Connection.User)
-		if pge.Connection.Password != "" {
-			connstr = connstr + fmt.Sprintf(" password='%s'", pge.Connection.Password)
-		}
+		return pge.Connection.PgURL
+	}
+	connstr := fmt.Sprintf("host='%s' port='%d' dbname='%s' sslmode='%s'",
+		pge.Connection.Host, pge.Connection.Port, pge.Connection.DBName, pge.Connection.SSLMode)
+	if !secrets.IsReference(pge.Connection.User) {
+		connstr = connstr + fmt.Sprintf(" user='%s'", pge.Connection.User)
+	}
+	if pge.Connection.Password != "" && !secrets.IsReference(pge.Connection.Password) {
+		connstr = connstr + fmt.Sprintf(" password='%s'", pge.Connection.Password)
 	}
-	connConfig, err := pgxpool.ParseConfig(connstr)
+	return connstr
+}
+
+// getPgxConnConfig transforms standard connestion string to pgx specific one with
+func (pge *PgEngine) getPgxConnConfig() *pgxpool.Config {
+	connConfig, err := pgxpool.ParseConfig(pge.connString())
 	if err != nil {
 		pge.l.WithError(err).Error("Cannot parse connection string")
 		return nil
@@ -142,18 +205,36 @@ func (pge *PgEngine) getPgxConnConfig() *pgxpool.Config {
 	// separate connection for Scheduler.retrieveIntervalChainsAndRun(),
 	// and another connection for LogHook.send()
 	connConfig.MaxConns = int32(pge.Resource.CronWorkers) + int32(pge.Resource.IntervalWorkers) + 3
+	if pge.HA.Enabled() { // the leader lock holds its connection permanently
+		connConfig.MaxConns++
+	}
 	connConfig.ConnConfig.RuntimeParams["application_name"] = "pg_timetable"
+	if pge.Kerberos.SrvName != "" {
+		connConfig.ConnConfig.KerberosSrvName = pge.Kerberos.SrvName
+	}
 	connConfig.ConnConfig.OnNotice = func(c *pgconn.PgConn, n *pgconn.Notice) {
 		pge.l.WithField("severity", n.Severity).WithField("notice", n.Message).Info("Notice received")
 	}
+	connConfig.BeforeConnect = pge.resolveCredentials
+	connConfig.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		return pge.isCurrentConnection(conn)
+	}
+	connConfig.AfterRelease = pge.isCurrentConnection
 	connConfig.AfterConnect = func(ctx context.Context, pgconn *pgx.Conn) (err error) {
+		pge.connGen.Store(pgconn, atomic.LoadInt64(&pge.credentialsGen))
 		pge.l.WithField("ConnPID", pgconn.PgConn().PID()).
 			WithField("client", pge.ClientName).
 			Debug("Trying to get lock for the session")
+		if atomic.LoadInt32(&pge.released) == 1 {
+			return errors.New("client name lock is released for the instance taking over")
+		}
 		if err = pge.TryLockClientName(ctx, pgconn); err != nil {
 			return err
 		}
-		_, err = pgconn.Exec(ctx, "LISTEN "+quoteIdent(pge.ClientName))
+		if _, err = pgconn.Exec(ctx, "LISTEN "+quoteIdent(pge.ListenChannel())); err != nil || pge.ListenChannel() == pge.ClientChannel() {
+			return err
+		}
+		_, err = pgconn.Exec(ctx, "LISTEN "+quoteIdent(pge.ClientChannel()))
 		return err
 	}
 	if !pge.Start.Debug { //will handle notification in HandleNotifications directly
@@ -168,6 +249,47 @@ func (pge *PgEngine) getPgxConnConfig() *pgxpool.Config {
 	return connConfig
 }
 
+// resolveCredentials sets the user and the password referencing the secret store before each new connection,
+// so rotated credentials are used without restart
+func (pge *PgEngine) resolveCredentials(ctx context.Context, connConfig *pgx.ConnConfig) (err error) {
+	if secrets.IsReference(pge.Connection.User) {
+		if connConfig.User, err = pge.secrets.Resolve(ctx, pge.Connection.User); err != nil {
+			return
+		}
+	}
+	if secrets.IsReference(pge.Connection.Password) {
+		connConfig.Password, err = pge.secrets.Resolve(ctx, pge.Connection.Password)
+	}
+	return
+}
+
+// invalidateSecretsOnAuthError drops cached secrets if the server rejected the credentials,
+// so the next connection attempt uses the values fetched from the secret store again
+func (pge *PgEngine) invalidateSecretsOnAuthError(err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "28") { // invalid_authorization_specification class
+		pge.l.WithError(err).Info("Authentication failed, refreshing secrets")
+		pge.secrets.Invalidate()
+	}
+}
+
+// rotateConnections marks all pool connections as outdated, so they are replaced by the new ones
+// established with the rotated credentials as soon as they are acquired or released
+func (pge *PgEngine) rotateConnections() {
+	atomic.AddInt64(&pge.credentialsGen, 1)
+}
+
+// isCurrentConnection returns false if the connection was established before the last credentials rotation.
+// The pool destroys such connections instead of handing them out
+func (pge *PgEngine) isCurrentConnection(conn *pgx.Conn) bool {
+	gen, ok := pge.connGen.Load(conn)
+	if ok && gen.(int64) != atomic.LoadInt64(&pge.credentialsGen) {
+		pge.connGen.Delete(conn)
+		return false
+	}
+	return true
+}
+
 // AddLogHook adds a new pgx log hook to logrus logger
 func (pge *PgEngine) AddLogHook(ctx context.Context) {
 	pge.l.AddHook(NewHook(ctx, pge, pge.Logging.LogDBLevel))
@@ -196,12 +318,56 @@ func (pge *PgEngine) TryLockClientName(ctx context.Context, conn QueryRowIface)
 			return e
 		} else if !locked {
 			pge.l.Info("Cannot obtain lock for a session")
+			pge.checkStaleLock(ctx, conn)
 			return retry.RetryableError(errors.New("Cannot obtain lock for a session"))
 		}
 		return nil
 	})
 }
 
+// checkStaleLock looks for another client with the same name holding the lock while all its sessions are gone
+// or idle longer than the stale lock timeout and its heartbeat, if reported, is outdated, e.g. after the host
+// of the client crashed and its connections are not closed yet. Sessions of such a client are terminated
+// if stealing is enabled, so the lock is obtained on the next attempt
+func (pge *PgEngine) checkStaleLock(ctx context.Context, conn QueryRowIface) {
+	const (
+		sqlStaleHolder = `SELECT s.client_pid FROM timetable.active_session s
+	LEFT JOIN pg_catalog.pg_stat_activity a ON a.pid = s.server_pid
+	LEFT JOIN timetable.client_heartbeat h ON h.client_name = s.client_name
+WHERE s.client_name = $1 AND s.client_pid <> $2
+GROUP BY s.client_pid
+HAVING bool_and(a.pid IS NULL OR a.state LIKE 'idle%' AND a.state_change < now() - $3 * interval '1 second')
+	AND COALESCE(max(h.reported_at) < now() - $3 * interval '1 second', TRUE)
+LIMIT 1`
+		sqlStealLock = `WITH stale AS (
+	DELETE FROM timetable.active_session WHERE client_name = $1 AND client_pid = $2 RETURNING server_pid
+)
+SELECT count(pg_terminate_backend(server_pid)) FROM stale`
+	)
+	if pge.Start.StaleLock <= 0 {
+		return
+	}
+	var holder, terminated int
+	err := conn.QueryRow(ctx, sqlStaleHolder, pge.ClientName, pge.Getpid(), pge.Start.StaleLock).Scan(&holder)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	l := pge.l.WithField("holder_pid", holder)
+	if err != nil {
+		l.WithError(err).Error("Cannot check if the client name lock is stale")
+		return
+	}
+	if !pge.Start.StealLock {
+		l.Warn("Client name lock seems to be held by the dead client, use --steal-stale-lock to take it over")
+		return
+	}
+	if err = conn.QueryRow(ctx, sqlStealLock, pge.ClientName, holder).Scan(&terminated); err != nil {
+		l.WithError(err).Error("Cannot take over the stale client name lock")
+		return
+	}
+	l.WithField("sessions", terminated).Warn("Stale client name lock taken over")
+}
+
 // ExecuteCustomScripts executes SQL scripts in files
 func (pge *PgEngine) ExecuteCustomScripts(ctx context.Context, filename ...string) error {
 	for _, f := range filename {
@@ -247,14 +413,30 @@ func (pge *PgEngine) ExecuteSchemaScripts(ctx context.Context) error {
 	return nil
 }
 
+// ReleaseSession releases the client name lock, so another instance with the same name may start while running
+// chains are finished. New connections cannot be established after that. The session of the new instance is kept
+// on Finalize
+func (pge *PgEngine) ReleaseSession(ctx context.Context) error {
+	sql := `WITH del_hb AS (DELETE FROM timetable.client_heartbeat WHERE client_name = $1),
+del_st AS (DELETE FROM timetable.client_status WHERE client_name = $1)
+DELETE FROM timetable.active_session WHERE client_name = $1 AND client_pid = $2`
+	atomic.StoreInt32(&pge.released, 1)
+	_, err := pge.ConfigDb.Exec(ctx, sql, pge.ClientName, pge.Getpid())
+	return err
+}
+
 // Finalize closes session
 func (pge *PgEngine) Finalize() {
 	pge.l.Info("Closing session")
-	sql := `WITH del_ch AS (DELETE FROM timetable.active_chain WHERE client_name = $1)
+	if atomic.LoadInt32(&pge.released) == 0 {
+		sql := `WITH del_ch AS (DELETE FROM timetable.active_chain WHERE client_name = $1),
+del_hb AS (DELETE FROM timetable.client_heartbeat WHERE client_name = $1),
+del_st AS (DELETE FROM timetable.client_status WHERE client_name = $1)
 DELETE FROM timetable.active_session WHERE client_name = $1`
-	_, err := pge.ConfigDb.Exec(context.Background(), sql, pge.ClientName)
-	if err != nil {
-		pge.l.WithError(err).Error("Cannot finalize database session")
+		_, err := pge.ConfigDb.Exec(context.Background(), sql, pge.ClientName)
+		if err != nil {
+			pge.l.WithError(err).Error("Cannot finalize database session")
+		}
 	}
 	pge.ConfigDb.Close()
 	pge.ConfigDb = nil
@@ -75,6 +75,17 @@ func TestFinalizeConnection(t *testing.T) {
 	assert.NoError(t, mockPool.ExpectationsWereMet())
 }
 
+func TestReleaseSession(t *testing.T) {
+	initmockdb(t)
+	mockpge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	mockPool.ExpectExec(`DELETE FROM timetable\.active_session`).WithArgs(mockpge.ClientName, mockpge.Getpid()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	assert.NoError(t, mockpge.ReleaseSession(context.Background()))
+	mockPool.ExpectClose()
+	mockpge.Finalize()
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "session of the new instance must be kept")
+}
+
 type mockpgrow struct {
 	results []interface{}
 }
@@ -142,14 +153,39 @@ func TestTryLockClientName(t *testing.T) {
 
 	t.Run("retry locking", func(t *testing.T) {
 		r := &mockpgrow{results: []interface{}{
-			1,     //procoid
-			false, //locked
-			false, //locked
-			false, //locked
+			1,             //procoid
+			false,         //locked
+			pgx.ErrNoRows, //no stale lock holder
+			false,         //locked
+			pgx.ErrNoRows, //no stale lock holder
+			false,         //locked
+			pgx.ErrNoRows, //no stale lock holder
 		}}
 		m := mockpgconn{r}
 		ctx, cancel := context.WithTimeout(context.Background(), pgengine.WaitTime*2)
 		defer cancel()
 		assert.ErrorIs(t, pge.TryLockClientName(ctx, m), ctx.Err())
 	})
+
+	t.Run("stale lock", func(t *testing.T) {
+		r := &mockpgrow{results: []interface{}{
+			1,     //procoid
+			false, //locked
+			42,    //stale lock holder
+			true,  //locked
+		}}
+		m := mockpgconn{r}
+		assert.NoError(t, pge.TryLockClientName(context.Background(), m), "stale lock is reported only")
+
+		pge.Start.StealLock = true
+		r.results = []interface{}{
+			1,     //procoid
+			false, //locked
+			42,    //stale lock holder
+			2,     //sessions terminated
+			true,  //locked
+		}
+		assert.NoError(t, pge.TryLockClientName(context.Background(), m))
+		assert.Empty(t, r.results, "stale lock must be taken over")
+	})
 }
@@ -0,0 +1,419 @@
+package pgengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/secrets"
+	"github.com/cybertec-postgresql/pg_timetable/internal/signature"
+	"github.com/georgysavva/scany/pgxscan"
+	pgx "github.com/jackc/pgx/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidChainDefinition is returned when the imported chain definition cannot be applied
+var ErrInvalidChainDefinition = errors.New("invalid chain definition")
+
+// ChainDefinition is the portable description of the chain with its tasks and parameters
+// suitable for the transfer of job definitions between environments
+type ChainDefinition struct {
+	Name               string           `json:"name" yaml:"name"`
+	Schedule           string           `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	MaxInstances       int              `json:"max_instances,omitempty" yaml:"max_instances,omitempty"`
+	Timeout            int              `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Live               bool             `json:"live" yaml:"live"`
+	SelfDestruct       bool             `json:"self_destruct,omitempty" yaml:"self_destruct,omitempty"`
+	ExclusiveExecution bool             `json:"exclusive_execution,omitempty" yaml:"exclusive_execution,omitempty"`
+	ReadOnly           bool             `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	ClientName         string           `json:"client_name,omitempty" yaml:"client_name,omitempty"`
+	Owner              string           `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Tasks              []TaskDefinition `json:"tasks" yaml:"tasks"`
+}
+
+// TaskDefinition is the portable description of the chain task
+type TaskDefinition struct {
+	Name          string         `json:"name,omitempty" yaml:"name,omitempty"`
+	Kind          string         `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Command       string         `json:"command" yaml:"command"`
+	RunAs         string         `json:"run_as,omitempty" yaml:"run_as,omitempty"`
+	ConnectString string         `json:"database_connection,omitempty" yaml:"database_connection,omitempty"`
+	IgnoreError   bool           `json:"ignore_error,omitempty" yaml:"ignore_error,omitempty"`
+	Autonomous    bool           `json:"autonomous,omitempty" yaml:"autonomous,omitempty"`
+	Timeout       int            `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Sandbox       bool           `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+	Env           []string       `json:"env,omitempty" yaml:"env,omitempty"`
+	Container     *TaskContainer `json:"container,omitempty" yaml:"container,omitempty"`
+	Parameters    []interface{}  `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	// Secrets lists 1-based positions of secret parameters, their values are encrypted:// references
+	Secrets []int `json:"secret_parameters,omitempty" yaml:"secret_parameters,omitempty"`
+}
+
+// HasProgramTasks returns true if any task of the chain executes the program
+func (def ChainDefinition) HasProgramTasks() bool {
+	for _, task := range def.Tasks {
+		if task.Kind == "PROGRAM" {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks if the chain definition contains all mandatory fields
+func (def ChainDefinition) Validate() error {
+	if def.Name == "" {
+		return fmt.Errorf("%w: chain name is required", ErrInvalidChainDefinition)
+	}
+	for i, task := range def.Tasks {
+		if task.Command == "" {
+			return fmt.Errorf("%w: command is required for task #%d", ErrInvalidChainDefinition, i+1)
+		}
+		switch task.Kind {
+		case "", "SQL", "PROGRAM", "BUILTIN":
+		default:
+			return fmt.Errorf("%w: unknown kind %q of task #%d", ErrInvalidChainDefinition, task.Kind, i+1)
+		}
+		if task.Sandbox && task.Kind != "PROGRAM" {
+			return fmt.Errorf("%w: only PROGRAM task #%d can run in the sandbox", ErrInvalidChainDefinition, i+1)
+		}
+		if len(task.Env) > 0 && task.Kind != "PROGRAM" {
+			return fmt.Errorf("%w: only PROGRAM task #%d can have environment variables", ErrInvalidChainDefinition, i+1)
+		}
+		for _, v := range task.Env {
+			if name, _, ok := strings.Cut(v, "="); !ok || name == "" {
+				return fmt.Errorf("%w: environment variable %q of task #%d must be NAME=value", ErrInvalidChainDefinition, v, i+1)
+			}
+		}
+		for _, n := range task.Secrets {
+			if n < 1 || n > len(task.Parameters) {
+				return fmt.Errorf("%w: secret parameter #%d of task #%d doesn't exist", ErrInvalidChainDefinition, n, i+1)
+			}
+			if ref, ok := task.Parameters[n-1].(string); !ok || !secrets.IsReference(ref) {
+				return fmt.Errorf("%w: secret parameter #%d of task #%d must be encrypted", ErrInvalidChainDefinition, n, i+1)
+			}
+		}
+		if task.Container != nil {
+			if task.Kind != "PROGRAM" {
+				return fmt.Errorf("%w: only PROGRAM task #%d can run in the container", ErrInvalidChainDefinition, i+1)
+			}
+			if task.Sandbox {
+				return fmt.Errorf("%w: task #%d cannot run both in the sandbox and in the container", ErrInvalidChainDefinition, i+1)
+			}
+			if task.Container.Image == "" {
+				return fmt.Errorf("%w: container image is required for task #%d", ErrInvalidChainDefinition, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// ExportChain returns the portable definition of the chain with the specified ID
+func (pge *PgEngine) ExportChain(ctx context.Context, chainID int) (def ChainDefinition, err error) {
+	return exportChain(ctx, pge.ConfigDb, chainID)
+}
+
+// exportChain returns the definition of the chain using conn, e.g. the transaction
+func exportChain(ctx context.Context, conn QueryRowIface, chainID int) (def ChainDefinition, err error) {
+	const sqlExportChain = `SELECT json_build_object(
+	'name', c.chain_name,
+	'schedule', c.run_at,
+	'max_instances', c.max_instances,
+	'timeout', COALESCE(c.timeout, 0),
+	'live', COALESCE(c.live, FALSE),
+	'self_destruct', COALESCE(c.self_destruct, FALSE),
+	'exclusive_execution', COALESCE(c.exclusive_execution, FALSE),
+	'read_only', COALESCE(c.read_only, FALSE),
+	'client_name', c.client_name,
+	'owner', c.owner,
+	'tasks', COALESCE((
+		SELECT json_agg(json_build_object(
+			'name', t.task_name,
+			'kind', t.kind,
+			'command', t.command,
+			'run_as', t.run_as,
+			'database_connection', t.database_connection,
+			'ignore_error', t.ignore_error,
+			'autonomous', t.autonomous,
+			'timeout', COALESCE(t.timeout, 0),
+			'sandbox', t.sandbox,
+			'env', t.env,
+			'container', t.container,
+			'parameters', (SELECT json_agg(p.value ORDER BY p.order_id) FROM timetable.parameter p WHERE p.task_id = t.task_id),
+			'secret_parameters', (SELECT json_agg(p.n) FROM (
+				SELECT row_number() OVER (ORDER BY order_id) AS n, secret FROM timetable.parameter WHERE task_id = t.task_id
+			) p WHERE p.secret)
+		) ORDER BY t.task_order)
+		FROM timetable.task t WHERE t.chain_id = c.chain_id
+	), '[]')
+)
+FROM timetable.chain c WHERE c.chain_id = $1`
+	var doc []byte
+	if err = conn.QueryRow(ctx, sqlExportChain, chainID).Scan(&doc); err != nil {
+		return
+	}
+	err = json.Unmarshal(doc, &def)
+	return
+}
+
+// ImportChain creates the chain from the definition or replaces the existing chain with the same name.
+// Importing the same definition several times always results in the same chain configuration
+func (pge *PgEngine) ImportChain(ctx context.Context, def ChainDefinition) (chainID int, err error) {
+	if err = def.Validate(); err != nil {
+		return
+	}
+	tx, err := pge.ConfigDb.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+	if chainID, err = importChain(ctx, tx, def); err != nil {
+		return
+	}
+	err = tx.Commit(ctx)
+	return
+}
+
+// importChain creates or replaces the chain within the transaction
+func importChain(ctx context.Context, tx pgx.Tx, def ChainDefinition) (chainID int, err error) {
+	const (
+		sqlUpsertChain = `INSERT INTO timetable.chain (chain_name, run_at, max_instances, timeout,
+	live, self_destruct, exclusive_execution, client_name, read_only, owner)
+VALUES ($1, NULLIF($2, ''), NULLIF($3, 0), $4, $5, $6, $7, NULLIF($8, ''), $9, COALESCE(NULLIF($10, ''), current_user))
+ON CONFLICT (chain_name) DO UPDATE SET
+	run_at = EXCLUDED.run_at,
+	max_instances = EXCLUDED.max_instances,
+	timeout = EXCLUDED.timeout,
+	live = EXCLUDED.live,
+	self_destruct = EXCLUDED.self_destruct,
+	exclusive_execution = EXCLUDED.exclusive_execution,
+	client_name = EXCLUDED.client_name,
+	read_only = EXCLUDED.read_only,
+	owner = EXCLUDED.owner
+RETURNING chain_id`
+		sqlDeleteTasks = `DELETE FROM timetable.task WHERE chain_id = $1`
+		sqlInsertTask  = `INSERT INTO timetable.task (chain_id, task_order, task_name, kind, command,
+	run_as, database_connection, ignore_error, autonomous, timeout, sandbox, env, container)
+VALUES ($1, $2, NULLIF($3, ''), COALESCE(NULLIF($4, ''), 'SQL') :: timetable.command_kind, $5,
+	NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11, $12, NULLIF($13, 'null') :: jsonb)
+RETURNING task_id`
+		sqlInsertParameter = `INSERT INTO timetable.parameter (task_id, order_id, value, secret) VALUES ($1, $2, $3 :: jsonb, $4)`
+	)
+	if operator := Operator(ctx); operator != "" {
+		if _, err = tx.Exec(ctx, `SELECT set_config('pg_timetable.operator', $1, true)`, operator); err != nil {
+			return
+		}
+	}
+	if err = tx.QueryRow(ctx, sqlUpsertChain, def.Name, def.Schedule, def.MaxInstances, def.Timeout,
+		def.Live, def.SelfDestruct, def.ExclusiveExecution, def.ClientName, def.ReadOnly, def.Owner).Scan(&chainID); err != nil {
+		return
+	}
+	if _, err = tx.Exec(ctx, sqlDeleteTasks, chainID); err != nil {
+		return
+	}
+	for i, task := range def.Tasks {
+		var (
+			taskID    int
+			container []byte
+		)
+		if container, err = json.Marshal(task.Container); err != nil {
+			return
+		}
+		if err = tx.QueryRow(ctx, sqlInsertTask, chainID, (i+1)*10, task.Name, task.Kind, task.Command, task.RunAs,
+			task.ConnectString, task.IgnoreError, task.Autonomous, task.Timeout, task.Sandbox, task.Env, string(container)).Scan(&taskID); err != nil {
+			return
+		}
+		for j, param := range task.Parameters {
+			var value []byte
+			if value, err = json.Marshal(param); err != nil {
+				return
+			}
+			secret := false
+			for _, n := range task.Secrets {
+				secret = secret || n == j+1
+			}
+			if _, err = tx.Exec(ctx, sqlInsertParameter, taskID, j+1, string(value), secret); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// ChainChange is the change of the chain made by ApplyChains
+type ChainChange struct {
+	Name   string
+	Action string // created, updated, unchanged or deleted
+}
+
+// sqlSelectStaleChains selects chains applied before, but missing in the definitions
+const sqlSelectStaleChains = `SELECT chain_id, chain_name FROM timetable.chain
+WHERE applied AND chain_name <> ALL($1) ORDER BY chain_name`
+
+// validateDefinitions checks definitions and returns names of chains, which must be unique
+func validateDefinitions(defs []ChainDefinition) (names []string, err error) {
+	names = make([]string, 0, len(defs))
+	for _, def := range defs {
+		if err = def.Validate(); err != nil {
+			return nil, fmt.Errorf("chain %s: %w", def.Name, err)
+		}
+		for _, name := range names {
+			if name == def.Name {
+				return nil, fmt.Errorf("%w: chain %s is defined more than once", ErrInvalidChainDefinition, def.Name)
+			}
+		}
+		names = append(names, def.Name)
+	}
+	return
+}
+
+// ApplyChains makes chains match the declarative definitions in a single transaction: missing chains are created,
+// changed ones are replaced and unchanged ones are left intact, so applying the same definitions again changes
+// nothing. If prune is set, chains applied before but missing in the definitions are deleted. If dryRun is set,
+// the changes are returned, but not made
+func (pge *PgEngine) ApplyChains(ctx context.Context, defs []ChainDefinition, prune bool, dryRun bool) (changes []ChainChange, err error) {
+	const (
+		sqlMarkApplied = `UPDATE timetable.chain SET applied = TRUE WHERE chain_id = $1 AND NOT applied`
+		sqlDeleteChain = `DELETE FROM timetable.chain WHERE chain_id = $1`
+	)
+	names, err := validateDefinitions(defs)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := pge.ConfigDb.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil || dryRun {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+	for _, def := range defs {
+		var (
+			chainID int
+			current ChainDefinition
+		)
+		change := ChainChange{Name: def.Name, Action: "created"}
+		err = tx.QueryRow(ctx, `SELECT chain_id FROM timetable.chain WHERE chain_name = $1`, def.Name).Scan(&chainID)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+		case err != nil:
+			return
+		default:
+			if current, err = exportChain(ctx, tx, chainID); err != nil {
+				return
+			}
+			change.Action = "updated"
+			if def.equal(current) {
+				change.Action = "unchanged"
+			}
+		}
+		if change.Action != "unchanged" {
+			if chainID, err = importChain(ctx, tx, def); err != nil {
+				return nil, fmt.Errorf("cannot apply chain %s: %w", def.Name, err)
+			}
+		}
+		if _, err = tx.Exec(ctx, sqlMarkApplied, chainID); err != nil {
+			return
+		}
+		changes = append(changes, change)
+	}
+	if prune {
+		var stale []struct {
+			ChainID   int    `db:"chain_id"`
+			ChainName string `db:"chain_name"`
+		}
+		if err = pgxscan.Select(ctx, tx, &stale, sqlSelectStaleChains, names); err != nil {
+			return
+		}
+		for _, chain := range stale {
+			if _, err = tx.Exec(ctx, sqlDeleteChain, chain.ChainID); err != nil {
+				return
+			}
+			changes = append(changes, ChainChange{Name: chain.ChainName, Action: "deleted"})
+		}
+	}
+	if !dryRun {
+		err = tx.Commit(ctx)
+	}
+	return
+}
+
+// equal returns true if the definition describes the same chain as the exported one
+func (def ChainDefinition) equal(exported ChainDefinition) bool {
+	return reflect.DeepEqual(def.normalized(exported), exported.normalized(exported))
+}
+
+// normalized returns the definition with omitted values replaced with defaults of the exported one, and parameters
+// converted to JSON values, e.g. numbers of YAML files become equal to JSON ones
+func (def ChainDefinition) normalized(exported ChainDefinition) (normalized ChainDefinition) {
+	if def.Owner == "" {
+		def.Owner = exported.Owner
+	}
+	def.Tasks = append([]TaskDefinition{}, def.Tasks...)
+	for i := range def.Tasks {
+		if def.Tasks[i].Kind == "" {
+			def.Tasks[i].Kind = "SQL"
+		}
+	}
+	data, _ := json.Marshal(def)
+	_ = json.Unmarshal(data, &normalized)
+	return
+}
+
+// ReadChainDefinitions returns chain definitions of the YAML or JSON file. The file may contain several documents
+// separated with --- as well as lists of definitions. The file must be signed if verifier is not nil
+func ReadChainDefinitions(file string, verifier *signature.Verifier) (defs []ChainDefinition, err error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if verifier != nil {
+		if err = verifier.Verify(data, readSignature(file)); err != nil {
+			return nil, err
+		}
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data)) // JSON is valid YAML as well
+	for {
+		var doc yaml.Node
+		if err = dec.Decode(&doc); errors.Is(err, io.EOF) {
+			return defs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.SequenceNode {
+			var list []ChainDefinition
+			if err = doc.Decode(&list); err != nil {
+				return nil, err
+			}
+			defs = append(defs, list...)
+			continue
+		}
+		var def ChainDefinition
+		if err = doc.Decode(&def); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+}
+
+// readSignature returns the detached signature of the file stored next to it by minisign or GnuPG,
+// i.e. <file>.minisig, <file>.asc or <file>.sig, or nil if none found
+func readSignature(file string) []byte {
+	for _, ext := range []string{".minisig", ".asc", ".sig"} {
+		if sig, err := os.ReadFile(file + ext); err == nil {
+			return sig
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package pgengine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	pgx "github.com/jackc/pgx/v4"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainDefinitionValidate(t *testing.T) {
+	assert.ErrorIs(t, pgengine.ChainDefinition{}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Kind: "SQL"}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Kind: "FOO", Command: "bar"}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1", Sandbox: true}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1", Env: []string{"LANG=C"}}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Kind: "PROGRAM", Command: "echo", Env: []string{"LANG"}}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1", Container: &pgengine.TaskContainer{Image: "alpine"}}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Kind: "PROGRAM", Command: "echo", Container: &pgengine.TaskContainer{}}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Kind: "PROGRAM", Command: "echo", Sandbox: true,
+			Container: &pgengine.TaskContainer{Image: "alpine"}}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Command: "SELECT $1", Secrets: []int{2},
+			Parameters: []interface{}{"encrypted://foo"}}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.ErrorIs(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Command: "SELECT $1", Secrets: []int{1},
+			Parameters: []interface{}{[]string{"plain"}}}}}.Validate(), pgengine.ErrInvalidChainDefinition)
+	assert.NoError(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Command: "SELECT $1", Secrets: []int{1},
+			Parameters: []interface{}{"encrypted://foo"}}}}.Validate())
+	assert.NoError(t, pgengine.ChainDefinition{Name: "foo",
+		Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1"}, {Kind: "BUILTIN", Command: "Sleep"},
+			{Kind: "PROGRAM", Command: "echo", Sandbox: true, Env: []string{"LANG=C", "EMPTY="}},
+			{Kind: "PROGRAM", Command: "psql", Container: &pgengine.TaskContainer{Image: "postgres:15", Network: "host"}}}}.Validate())
+}
+
+func TestChainDefinitionHasProgramTasks(t *testing.T) {
+	assert.False(t, pgengine.ChainDefinition{Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1"}, {Kind: "BUILTIN"}}}.HasProgramTasks())
+	assert.True(t, pgengine.ChainDefinition{Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1"}, {Kind: "PROGRAM"}}}.HasProgramTasks())
+}
+
+func TestExportChain(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	mockPool.ExpectQuery("SELECT json_build_object").
+		WithArgs(42).
+		WillReturnRows(pgxmock.NewRows([]string{"json_build_object"}).
+			AddRow([]byte(`{"name": "foo", "schedule": "* * * * *", "live": true,
+			"tasks": [{"kind": "SQL", "command": "SELECT $1", "parameters": [[42]]}]}`)))
+	def, err := pge.ExportChain(context.Background(), 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", def.Name)
+	assert.Equal(t, "* * * * *", def.Schedule)
+	assert.Len(t, def.Tasks, 1)
+	assert.Len(t, def.Tasks[0].Parameters, 1)
+
+	mockPool.ExpectQuery("SELECT json_build_object").WillReturnError(errors.New("error"))
+	_, err = pge.ExportChain(context.Background(), 42)
+	assert.Error(t, err)
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestImportChain(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+	ctx := context.Background()
+	def := pgengine.ChainDefinition{
+		Name:     "foo",
+		Schedule: "@every 1 minute",
+		Tasks:    []pgengine.TaskDefinition{{Command: "SELECT $1", Parameters: []interface{}{[]int{42}}}},
+	}
+
+	t.Run("Check ImportChain for invalid definition", func(t *testing.T) {
+		_, err := pge.ImportChain(ctx, pgengine.ChainDefinition{})
+		assert.ErrorIs(t, err, pgengine.ErrInvalidChainDefinition)
+	})
+
+	t.Run("Check ImportChain if everything fine", func(t *testing.T) {
+		mockPool.ExpectBegin()
+		mockPool.ExpectQuery("INSERT INTO timetable\\.chain").
+			WillReturnRows(pgxmock.NewRows([]string{"chain_id"}).AddRow(42))
+		mockPool.ExpectExec("DELETE FROM timetable\\.task").WithArgs(42).
+			WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		mockPool.ExpectQuery("INSERT INTO timetable\\.task").
+			WillReturnRows(pgxmock.NewRows([]string{"task_id"}).AddRow(24))
+		mockPool.ExpectExec("INSERT INTO timetable\\.parameter").WithArgs(24, 1, "[42]", false).
+			WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		mockPool.ExpectCommit()
+		id, err := pge.ImportChain(ctx, def)
+		assert.NoError(t, err)
+		assert.Equal(t, 42, id)
+	})
+
+	t.Run("Check ImportChain if sql fails", func(t *testing.T) {
+		mockPool.ExpectBegin()
+		mockPool.ExpectQuery("INSERT INTO timetable\\.chain").WillReturnError(errors.New("error"))
+		mockPool.ExpectRollback()
+		_, err := pge.ImportChain(ctx, def)
+		assert.Error(t, err)
+	})
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestApplyChains(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+	ctx := context.Background()
+	defs := []pgengine.ChainDefinition{
+		{Name: "foo", Schedule: "* * * * *", Live: true,
+			Tasks: []pgengine.TaskDefinition{{Command: "SELECT $1", Parameters: []interface{}{[]int{42}}}}},
+		{Name: "bar", Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1"}}},
+	}
+
+	_, err := pge.ApplyChains(ctx, append(defs, defs[0]), false, false)
+	assert.ErrorIs(t, err, pgengine.ErrInvalidChainDefinition, "duplicate chain names")
+
+	mockPool.ExpectBegin()
+	// foo exists and is unchanged
+	mockPool.ExpectQuery("SELECT chain_id FROM timetable\\.chain").WithArgs("foo").
+		WillReturnRows(pgxmock.NewRows([]string{"chain_id"}).AddRow(42))
+	mockPool.ExpectQuery("SELECT json_build_object").WithArgs(42).
+		WillReturnRows(pgxmock.NewRows([]string{"json_build_object"}).
+			AddRow([]byte(`{"name": "foo", "schedule": "* * * * *", "live": true, "owner": "scheduler",
+			"tasks": [{"kind": "SQL", "command": "SELECT $1", "parameters": [[42]]}]}`)))
+	mockPool.ExpectExec("UPDATE timetable\\.chain SET applied").WithArgs(42).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	// bar is created
+	mockPool.ExpectQuery("SELECT chain_id FROM timetable\\.chain").WithArgs("bar").WillReturnError(pgx.ErrNoRows)
+	mockPool.ExpectQuery("INSERT INTO timetable\\.chain").WillReturnRows(pgxmock.NewRows([]string{"chain_id"}).AddRow(43))
+	mockPool.ExpectExec("DELETE FROM timetable\\.task").WithArgs(43).WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mockPool.ExpectQuery("INSERT INTO timetable\\.task").WillReturnRows(pgxmock.NewRows([]string{"task_id"}).AddRow(24))
+	mockPool.ExpectExec("UPDATE timetable\\.chain SET applied").WithArgs(43).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	// baz was applied before, but is not defined anymore
+	mockPool.ExpectQuery("SELECT chain_id, chain_name FROM timetable\\.chain").WithArgs([]string{"foo", "bar"}).
+		WillReturnRows(pgxmock.NewRows([]string{"chain_id", "chain_name"}).AddRow(44, "baz"))
+	mockPool.ExpectExec("DELETE FROM timetable\\.chain").WithArgs(44).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mockPool.ExpectRollback()
+	changes, err := pge.ApplyChains(ctx, defs, true, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []pgengine.ChainChange{{Name: "foo", Action: "unchanged"}, {Name: "bar", Action: "created"},
+		{Name: "baz", Action: "deleted"}}, changes)
+
+	mockPool.ExpectBegin()
+	mockPool.ExpectQuery("SELECT chain_id FROM timetable\\.chain").WithArgs("foo").WillReturnError(errors.New("error"))
+	mockPool.ExpectRollback()
+	_, err = pge.ApplyChains(ctx, defs, false, false)
+	assert.Error(t, err)
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestDiffChains(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+	ctx := context.Background()
+	defs := []pgengine.ChainDefinition{{Name: "bar", Tasks: []pgengine.TaskDefinition{{Command: "SELECT 1"}}}}
+
+	mockPool.ExpectQuery("SELECT chain_id FROM timetable\\.chain").WithArgs("bar").WillReturnError(pgx.ErrNoRows)
+	mockPool.ExpectQuery("SELECT chain_id, chain_name FROM timetable\\.chain").WithArgs([]string{"bar"}).
+		WillReturnRows(pgxmock.NewRows([]string{"chain_id", "chain_name"}))
+	diffs, err := pge.DiffChains(ctx, defs, true)
+	assert.NoError(t, err)
+	if assert.Len(t, diffs, 1) {
+		assert.Equal(t, "created", diffs[0].Action)
+		assert.Contains(t, diffs[0].Diff, "+ name: bar\n")
+	}
+
+	mockPool.ExpectQuery("SELECT chain_id FROM timetable\\.chain").WithArgs("bar").WillReturnError(errors.New("error"))
+	_, err = pge.DiffChains(ctx, defs, false)
+	assert.Error(t, err)
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
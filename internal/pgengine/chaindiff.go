@@ -0,0 +1,146 @@
+package pgengine
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/georgysavva/scany/pgxscan"
+	pgx "github.com/jackc/pgx/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// diffContext is the number of unchanged lines shown around changed ones
+const diffContext = 2
+
+// ChainDiff describes the change ApplyChains would make to the chain
+type ChainDiff struct {
+	Name   string
+	Action string // created, updated, unchanged or deleted
+	Diff   string // lines of YAML definitions prefixed with - if removed and + if added, empty if unchanged
+}
+
+// DiffChains compares the declarative definitions with chains of the database without changing anything and
+// returns changes ApplyChains would make with the same arguments along with differences of definitions
+func (pge *PgEngine) DiffChains(ctx context.Context, defs []ChainDefinition, prune bool) (diffs []ChainDiff, err error) {
+	names, err := validateDefinitions(defs)
+	if err != nil {
+		return nil, err
+	}
+	for _, def := range defs {
+		var (
+			chainID int
+			current ChainDefinition
+		)
+		diff := ChainDiff{Name: def.Name, Action: "created"}
+		err = pge.ConfigDb.QueryRow(ctx, `SELECT chain_id FROM timetable.chain WHERE chain_name = $1`, def.Name).Scan(&chainID)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			err = nil
+			diff.Diff = lineDiff("", definitionYAML(def.normalized(def)))
+		case err != nil:
+			return
+		default:
+			if current, err = exportChain(ctx, pge.ConfigDb, chainID); err != nil {
+				return
+			}
+			diff.Action = "updated"
+			if def.equal(current) {
+				diff.Action = "unchanged"
+			} else {
+				diff.Diff = lineDiff(definitionYAML(current.normalized(current)), definitionYAML(def.normalized(current)))
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	if !prune {
+		return
+	}
+	var stale []struct {
+		ChainID   int    `db:"chain_id"`
+		ChainName string `db:"chain_name"`
+	}
+	if err = pgxscan.Select(ctx, pge.ConfigDb, &stale, sqlSelectStaleChains, names); err != nil {
+		return
+	}
+	for _, chain := range stale {
+		current, err := exportChain(ctx, pge.ConfigDb, chain.ChainID)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, ChainDiff{Name: chain.ChainName, Action: "deleted",
+			Diff: lineDiff(definitionYAML(current.normalized(current)), "")})
+	}
+	return
+}
+
+// definitionYAML returns the definition encoded as YAML
+func definitionYAML(def ChainDefinition) string {
+	data, _ := yaml.Marshal(def)
+	return string(data)
+}
+
+// lineDiff returns lines of a removed in b prefixed with "- ", lines of b missing in a prefixed with "+ " and
+// unchanged lines around them prefixed with "  ", skipped unchanged lines are replaced with "  ..."
+func lineDiff(a, b string) string {
+	split := func(s string) []string {
+		if s = strings.TrimSuffix(s, "\n"); s == "" {
+			return nil
+		}
+		return strings.Split(s, "\n")
+	}
+	x, y := split(a), split(b)
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []string
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, "  "+x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+x[i])
+			i++
+		default:
+			lines = append(lines, "+ "+y[j])
+			j++
+		}
+	}
+	// keep unchanged lines close to changed ones only
+	var out strings.Builder
+	skipped := false
+	for i, line := range lines {
+		near := false
+		for k := i - diffContext; k <= i+diffContext && !near; k++ {
+			near = k >= 0 && k < len(lines) && !strings.HasPrefix(lines[k], "  ")
+		}
+		if !near {
+			skipped = true
+			continue
+		}
+		if skipped {
+			out.WriteString("  ...\n")
+			skipped = false
+		}
+		out.WriteString(line + "\n")
+	}
+	if skipped {
+		out.WriteString("  ...\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,33 @@
+package pgengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineDiff(t *testing.T) {
+	assert.Equal(t, "", lineDiff("", ""))
+	assert.Equal(t, "+ a\n+ b\n", lineDiff("", "a\nb\n"))
+	assert.Equal(t, "- a\n", lineDiff("a\n", ""))
+	assert.Equal(t, `  ...
+  c
+  d
+- e
++ E
+  f
+  g
+  ...
+  j
+  k
++ l
+`, lineDiff("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n", "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\nl\n"))
+}
+
+func TestDefinitionNormalized(t *testing.T) {
+	exported := ChainDefinition{Name: "foo", Owner: "scheduler", Tasks: []TaskDefinition{{Kind: "SQL", Command: "SELECT 1"}}}
+	def := ChainDefinition{Name: "foo", Tasks: []TaskDefinition{{Command: "SELECT 1"}}}
+	assert.Equal(t, exported, def.normalized(exported))
+	assert.True(t, def.equal(exported))
+	assert.Empty(t, def.Tasks[0].Kind, "the definition itself is not changed")
+}
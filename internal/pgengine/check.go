@@ -0,0 +1,261 @@
+package pgengine
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CheckResult describes the outcome of a single configuration check
+type CheckResult struct {
+	Check    string
+	Problems []string // empty if the check passed
+}
+
+// CheckConfig validates the configuration against the database: the connection, the schema version,
+// schedules of all chains, binaries of PROGRAM tasks and disabled BUILTIN tasks. The database is accessed the same way
+// as by Connect, so it is safe to run along with the working instance
+func CheckConfig(ctx context.Context, cmdOpts config.CmdOptions, logger log.LoggerHookerIface) (results []CheckResult) {
+	return runChecks(ctx, cmdOpts, logger, false)
+}
+
+// Doctor runs checks of CheckConfig along with checks of the database features the scheduler relies on: privileges
+// on objects of the timetable schema, advisory locks and the LISTEN/NOTIFY round trip
+func Doctor(ctx context.Context, cmdOpts config.CmdOptions, logger log.LoggerHookerIface) (results []CheckResult) {
+	return runChecks(ctx, cmdOpts, logger, true)
+}
+
+func runChecks(ctx context.Context, cmdOpts config.CmdOptions, logger log.LoggerHookerIface, doctor bool) (results []CheckResult) {
+	connResult := CheckResult{Check: "Database connection"}
+	pge, err := Connect(ctx, cmdOpts, logger)
+	if err != nil {
+		connResult.Problems = append(connResult.Problems, err.Error())
+		return append(results, connResult)
+	}
+	defer pge.ConfigDb.Close()
+	results = append(results, connResult)
+
+	schemaResult := pge.checkSchemaVersion(ctx)
+	results = append(results, schemaResult)
+	if len(schemaResult.Problems) > 0 {
+		return
+	}
+	if doctor {
+		results = append(results, pge.checkPrivileges(ctx), pge.checkAdvisoryLocks(ctx), pge.checkNotifications(ctx))
+	}
+	results = append(results, pge.checkChainSchedules(ctx))
+	if !cmdOpts.NoProgramTasks {
+		results = append(results, pge.checkProgramTasks(ctx))
+	}
+	if cmdOpts.DisableBuiltins != "" {
+		results = append(results, pge.checkBuiltinTasks(ctx))
+	}
+	return
+}
+
+func (pge *PgEngine) checkSchemaVersion(ctx context.Context) (res CheckResult) {
+	res.Check = "Schema version"
+	var exists bool
+	if err := pge.ConfigDb.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM pg_namespace WHERE nspname = 'timetable')").Scan(&exists); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	if !exists {
+		res.Problems = append(res.Problems, "schema timetable does not exist, use --init option to create it")
+		return
+	}
+	upgrade, err := pge.CheckNeedMigrateDb(ctx)
+	if err != nil {
+		res.Problems = append(res.Problems, err.Error())
+	} else if upgrade {
+		res.Problems = append(res.Problems, "database schema is outdated, use --upgrade option")
+	}
+	return
+}
+
+func (pge *PgEngine) checkChainSchedules(ctx context.Context) (res CheckResult) {
+	res.Check = "Chain schedules"
+	rows, err := pge.ConfigDb.Query(ctx, "SELECT chain_name, run_at FROM timetable.chain WHERE run_at IS NOT NULL ORDER BY chain_id")
+	if err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, runAt string
+		if err = rows.Scan(&name, &runAt); err != nil {
+			res.Problems = append(res.Problems, err.Error())
+			return
+		}
+		if strings.HasPrefix(runAt, "@") {
+			continue // @reboot, @every and @after values are validated by the timetable.cron domain
+		}
+		schedule, err := cron.Parse(runAt)
+		if err != nil {
+			res.Problems = append(res.Problems, fmt.Sprintf("chain %q: invalid schedule %q: %s", name, runAt, err))
+		} else if len(schedule.NextN(time.Now(), 1)) == 0 {
+			res.Problems = append(res.Problems, fmt.Sprintf("chain %q: schedule %q never fires", name, runAt))
+		}
+	}
+	if err = rows.Err(); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+	}
+	return
+}
+
+func (pge *PgEngine) checkProgramTasks(ctx context.Context) (res CheckResult) {
+	res.Check = "Program tasks"
+	rows, err := pge.ConfigDb.Query(ctx, `SELECT c.chain_name, t.command FROM timetable.task t JOIN timetable.chain c USING (chain_id)
+WHERE t.kind = 'PROGRAM' AND (c.client_name IS NULL OR c.client_name = $1) ORDER BY c.chain_id, t.task_order`, pge.ClientName)
+	if err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, command string
+		if err = rows.Scan(&name, &command); err != nil {
+			res.Problems = append(res.Problems, err.Error())
+			return
+		}
+		if _, err := exec.LookPath(command); err != nil {
+			res.Problems = append(res.Problems, fmt.Sprintf("chain %q: program %q not found", name, command))
+		}
+	}
+	if err = rows.Err(); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+	}
+	return
+}
+
+func (pge *PgEngine) checkBuiltinTasks(ctx context.Context) (res CheckResult) {
+	res.Check = "Builtin tasks"
+	rows, err := pge.ConfigDb.Query(ctx, `SELECT c.chain_name, t.command FROM timetable.task t JOIN timetable.chain c USING (chain_id)
+WHERE t.kind = 'BUILTIN' AND (c.client_name IS NULL OR c.client_name = $1) ORDER BY c.chain_id, t.task_order`, pge.ClientName)
+	if err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, command string
+		if err = rows.Scan(&name, &command); err != nil {
+			res.Problems = append(res.Problems, err.Error())
+			return
+		}
+		if pge.BuiltinDisabled(command) {
+			res.Problems = append(res.Problems, fmt.Sprintf("chain %q: builtin %q is disabled", name, command))
+		}
+	}
+	if err = rows.Err(); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+	}
+	return
+}
+
+func (pge *PgEngine) checkPrivileges(ctx context.Context) (res CheckResult) {
+	res.Check = "Privileges"
+	const sqlMissingPrivileges = `SELECT 'missing USAGE privilege on schema timetable'
+WHERE NOT has_schema_privilege('timetable', 'USAGE')
+UNION ALL
+SELECT format('missing %s privilege on table %s', p.privilege, c.oid::regclass)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace, unnest(ARRAY['SELECT', 'INSERT', 'UPDATE', 'DELETE']) AS p(privilege)
+WHERE n.nspname = 'timetable' AND c.relkind IN ('r', 'p') AND NOT has_table_privilege(c.oid, p.privilege)
+UNION ALL
+SELECT format('missing USAGE privilege on sequence %s', c.oid::regclass)
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = 'timetable' AND c.relkind = 'S' AND NOT has_sequence_privilege(c.oid, 'USAGE')
+UNION ALL
+SELECT format('missing EXECUTE privilege on function %s', p.oid::regprocedure)
+FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = 'timetable' AND NOT has_function_privilege(p.oid, 'EXECUTE')`
+	rows, err := pge.ConfigDb.Query(ctx, sqlMissingPrivileges)
+	if err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var problem string
+		if err = rows.Scan(&problem); err != nil {
+			res.Problems = append(res.Problems, err.Error())
+			return
+		}
+		res.Problems = append(res.Problems, problem)
+	}
+	if err = rows.Err(); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+	}
+	return
+}
+
+// checkAdvisoryLocks takes and releases the session advisory lock in a single statement, advisory locks are used
+// by the leader election of the high availability group and by exclusive chains
+func (pge *PgEngine) checkAdvisoryLocks(ctx context.Context) (res CheckResult) {
+	res.Check = "Advisory locks"
+	const sqlLockUnlock = `SELECT CASE WHEN pg_try_advisory_lock(hashtext('pg_timetable_doctor'), pg_backend_pid())
+	THEN pg_advisory_unlock(hashtext('pg_timetable_doctor'), pg_backend_pid()) ELSE false END`
+	var ok bool
+	if err := pge.ConfigDb.QueryRow(ctx, sqlLockUnlock).Scan(&ok); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+	} else if !ok {
+		res.Problems = append(res.Problems, "cannot obtain the advisory lock")
+	}
+	return
+}
+
+// notifyTimeout limits how long the notification sent by checkNotifications is waited for
+const notifyTimeout = 5 * time.Second
+
+// checkNotifications sends the notification to the channel listened by the same session and waits for it.
+// The round trip fails, e.g. if the connection goes through the pooler in the transaction mode, so chains
+// cannot be started or stopped asynchronously
+func (pge *PgEngine) checkNotifications(ctx context.Context) (res CheckResult) {
+	res.Check = "LISTEN/NOTIFY"
+	connConfig := pge.getPgxConnConfig()
+	if connConfig == nil {
+		res.Problems = append(res.Problems, "cannot parse connection string")
+		return
+	}
+	connConfig.AfterConnect, connConfig.MaxConns = nil, 1
+	connConfig.ConnConfig.OnNotification = nil // received by WaitForNotification instead of being handled as chain signals
+	pool, err := pgxpool.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	defer pool.Close()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	defer conn.Release()
+	channel := fmt.Sprintf("pg_timetable_doctor_%d", conn.Conn().PgConn().PID())
+	payload := time.Now().Format(time.RFC3339Nano)
+	if _, err = conn.Exec(ctx, "LISTEN "+quoteIdent(channel)); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	if _, err = conn.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		return
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	n, err := conn.Conn().WaitForNotification(waitCtx)
+	switch {
+	case err != nil:
+		res.Problems = append(res.Problems, fmt.Sprintf("notification not received in %s: %s", notifyTimeout, err))
+	case n.Channel != channel || n.Payload != payload:
+		res.Problems = append(res.Problems, fmt.Sprintf("unexpected notification received on channel %q", n.Channel))
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package pgengine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigChecks(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := &PgEngine{l: log.Init(config.LoggingOpts{LogLevel: "error"}), ConfigDb: mock}
+	pge.ClientName = "check_unit_test"
+	ctx := context.Background()
+
+	t.Run("Check schema version if schema is absent", func(t *testing.T) {
+		mock.ExpectQuery("SELECT EXISTS").WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+		res := pge.checkSchemaVersion(ctx)
+		assert.Len(t, res.Problems, 1)
+	})
+
+	t.Run("Check chain schedules", func(t *testing.T) {
+		mock.ExpectQuery("SELECT chain_name, run_at FROM timetable\\.chain").
+			WillReturnRows(pgxmock.NewRows([]string{"chain_name", "run_at"}).
+				AddRow("fine", "* * * * *").
+				AddRow("reboot", "@reboot").
+				AddRow("invalid", "61 * * * *").
+				AddRow("never", "0 0 30 2 *"))
+		res := pge.checkChainSchedules(ctx)
+		assert.Len(t, res.Problems, 2)
+
+		mock.ExpectQuery("SELECT chain_name, run_at FROM timetable\\.chain").WillReturnError(errors.New("error"))
+		res = pge.checkChainSchedules(ctx)
+		assert.Len(t, res.Problems, 1)
+	})
+
+	t.Run("Check program tasks", func(t *testing.T) {
+		mock.ExpectQuery("SELECT c\\.chain_name, t\\.command").WithArgs("check_unit_test").
+			WillReturnRows(pgxmock.NewRows([]string{"chain_name", "command"}).
+				AddRow("fine", "go").
+				AddRow("absent", "surely_absent_program_binary"))
+		res := pge.checkProgramTasks(ctx)
+		assert.Len(t, res.Problems, 1)
+	})
+
+	t.Run("Check builtin tasks", func(t *testing.T) {
+		pge.DisableBuiltins = "Shutdown"
+		mock.ExpectQuery("SELECT c\\.chain_name, t\\.command").WithArgs("check_unit_test").
+			WillReturnRows(pgxmock.NewRows([]string{"chain_name", "command"}).
+				AddRow("fine", "NoOp").
+				AddRow("disabled", "Shutdown"))
+		res := pge.checkBuiltinTasks(ctx)
+		assert.Len(t, res.Problems, 1)
+	})
+
+	t.Run("Check privileges", func(t *testing.T) {
+		mock.ExpectQuery("SELECT 'missing USAGE privilege on schema timetable'").
+			WillReturnRows(pgxmock.NewRows([]string{"format"}).
+				AddRow("missing DELETE privilege on table timetable.active_chain"))
+		res := pge.checkPrivileges(ctx)
+		assert.Equal(t, []string{"missing DELETE privilege on table timetable.active_chain"}, res.Problems)
+	})
+
+	t.Run("Check advisory locks", func(t *testing.T) {
+		mock.ExpectQuery("SELECT CASE WHEN pg_try_advisory_lock").
+			WillReturnRows(pgxmock.NewRows([]string{"case"}).AddRow(true))
+		res := pge.checkAdvisoryLocks(ctx)
+		assert.Empty(t, res.Problems)
+
+		mock.ExpectQuery("SELECT CASE WHEN pg_try_advisory_lock").
+			WillReturnRows(pgxmock.NewRows([]string{"case"}).AddRow(false))
+		res = pge.checkAdvisoryLocks(ctx)
+		assert.Len(t, res.Problems, 1)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
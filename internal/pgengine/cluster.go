@@ -0,0 +1,66 @@
+package pgengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// Version is the application version reported in the cluster status, set on startup
+var Version = "devel"
+
+// ClientStatus describes the connected client as shown by the timetable.cluster_status view, clients
+// not reporting the status, e.g. of older versions, have nil values
+type ClientStatus struct {
+	ClientName   string     `db:"client_name" json:"client_name"`
+	Version      *string    `db:"version" json:"version"`
+	StartedAt    time.Time  `db:"started_at" json:"started_at"`
+	Connections  int        `db:"connections" json:"connections"`
+	ReportedAt   *time.Time `db:"reported_at" json:"reported_at"`
+	Healthy      bool       `db:"healthy" json:"healthy"`
+	ActiveChains int        `db:"active_chains" json:"active_chains"`
+	QueuedChains *int       `db:"queued_chains" json:"queued_chains"`
+	Leader       *bool      `db:"leader" json:"leader"`
+	Maintenance  *bool      `db:"maintenance" json:"maintenance"`
+}
+
+// UpdateClientStatus reports the version and the number of chains waiting for workers to the cluster status.
+// The status is considered healthy for the valid duration
+func (pge *PgEngine) UpdateClientStatus(ctx context.Context, queued int, leader bool, maintenance bool, valid time.Duration) {
+	const sqlUpdateClientStatus = `INSERT INTO timetable.client_status AS s
+(client_name, version, reported_at, valid_seconds, queued_chains, leader, maintenance)
+VALUES ($1, $2, now(), $3, $4, $5, $6)
+ON CONFLICT (client_name) DO UPDATE SET
+	version = EXCLUDED.version, reported_at = EXCLUDED.reported_at, valid_seconds = EXCLUDED.valid_seconds,
+	queued_chains = EXCLUDED.queued_chains, leader = EXCLUDED.leader, maintenance = EXCLUDED.maintenance`
+	_, err := pge.ConfigDb.Exec(ctx, sqlUpdateClientStatus, pge.ClientName, Version, int(valid.Seconds()), queued, leader, maintenance)
+	if err != nil {
+		pge.l.WithError(err).Error("Cannot report the client status")
+	}
+}
+
+// SelectClusterStatus returns the status of all connected clients
+func (pge *PgEngine) SelectClusterStatus(ctx context.Context, dest interface{}) error {
+	const sqlSelectClusterStatus = `SELECT client_name, version, started_at, connections, reported_at, healthy,
+active_chains, queued_chains, leader, maintenance
+FROM timetable.cluster_status ORDER BY client_name`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectClusterStatus)
+}
+
+// RunningChain describes the chain being executed by the client
+type RunningChain struct {
+	ChainID    int       `db:"chain_id" json:"chain_id"`
+	ChainName  string    `db:"chain_name" json:"chain_name"`
+	ClientName string    `db:"client_name" json:"client_name"`
+	StartedAt  time.Time `db:"started_at" json:"started_at"`
+}
+
+// SelectRunningChains returns chains being executed by all clients, the longest running first
+func (pge *PgEngine) SelectRunningChains(ctx context.Context, dest interface{}) error {
+	const sqlSelectRunningChains = `SELECT ac.chain_id, COALESCE(c.chain_name, '') AS chain_name, ac.client_name,
+COALESCE(ac.started_at, now()) AS started_at
+FROM timetable.active_chain ac LEFT JOIN timetable.chain c USING (chain_id)
+ORDER BY ac.started_at, ac.chain_id`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectRunningChains)
+}
@@ -0,0 +1,34 @@
+package pgengine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterStatus(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+	ctx := context.Background()
+
+	mockPool.ExpectExec("INSERT INTO timetable.client_status").
+		WithArgs(pge.ClientName, pgengine.Version, 120, 3, true, false).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	pge.UpdateClientStatus(ctx, 3, true, false, 2*time.Minute)
+
+	mockPool.ExpectExec("INSERT INTO timetable.client_status").WillReturnError(errors.New("error"))
+	pge.UpdateClientStatus(ctx, 0, true, false, time.Minute) // errors are logged only
+
+	mockPool.ExpectQuery("SELECT.+FROM timetable.cluster_status").WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectClusterStatus(ctx, &[]pgengine.ClientStatus{}))
+
+	mockPool.ExpectQuery("SELECT.+FROM timetable.active_chain").WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectRunningChains(ctx, &[]pgengine.RunningChain{}))
+	assert.NoError(t, mockPool.ExpectationsWereMet())
+}
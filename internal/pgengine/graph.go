@@ -0,0 +1,66 @@
+package pgengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// dotString escapes the value as the quoted DOT string, line breaks are kept as centered lines of labels
+var dotString = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteChainDOT outputs the graph of the chain in the Graphviz DOT language: the schedule node followed by tasks
+// in the execution order. Tasks ignoring errors are drawn dashed, since the chain goes on if they fail
+func WriteChainDOT(w io.Writer, def ChainDefinition) error {
+	var b strings.Builder
+	schedule := def.Schedule
+	if schedule == "" {
+		schedule = "on demand"
+	}
+	fmt.Fprintf(&b, "digraph \"%s\" {\n", dotString.Replace(def.Name))
+	b.WriteString("\trankdir=LR;\n\tnode [shape=box, style=rounded, fontname=\"Helvetica\"];\n")
+	fmt.Fprintf(&b, "\tschedule [shape=ellipse, style=filled, label=\"%s\\n%s\"];\n",
+		dotString.Replace(def.Name), dotString.Replace(schedule))
+	prev := "schedule"
+	for i, task := range def.Tasks {
+		kind := task.Kind
+		if kind == "" {
+			kind = "SQL"
+		}
+		label := fmt.Sprintf("%d. %s", i+1, kind)
+		if task.Name != "" {
+			label += " " + task.Name
+		}
+		label += "\n" + shortCommand(task.Command)
+		if task.Container != nil {
+			label += "\nin " + task.Container.Image
+		}
+		style := "rounded"
+		if task.IgnoreError {
+			style, label = "\"rounded,dashed\"", label+"\nerrors ignored"
+		}
+		node := fmt.Sprintf("task%d", i+1)
+		fmt.Fprintf(&b, "\t%s [style=%s, label=\"%s\"];\n", node, style, dotString.Replace(label))
+		fmt.Fprintf(&b, "\t%s -> %s;\n", prev, node)
+		prev = node
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// RenderSVG converts the graph in the DOT language to SVG with the dot program of Graphviz,
+// the error wraps exec.ErrNotFound if Graphviz is not installed
+func RenderSVG(ctx context.Context, dot []byte) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "dot", "-Tsvg")
+	cmd.Stdin, cmd.Stderr = bytes.NewReader(dot), &stderr
+	out, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, err
+}
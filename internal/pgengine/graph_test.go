@@ -0,0 +1,35 @@
+package pgengine_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteChainDOT(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, pgengine.WriteChainDOT(&b, pgengine.ChainDefinition{
+		Name:     `nightly "backup"`,
+		Schedule: "0 3 * * *",
+		Tasks: []pgengine.TaskDefinition{
+			{Command: "VACUUM\n  ANALYZE", IgnoreError: true},
+			{Name: "dump", Kind: "PROGRAM", Command: "pg_dump", Container: &pgengine.TaskContainer{Image: "postgres:15"}},
+		},
+	}))
+	assert.Equal(t, `digraph "nightly \"backup\"" {
+	rankdir=LR;
+	node [shape=box, style=rounded, fontname="Helvetica"];
+	schedule [shape=ellipse, style=filled, label="nightly \"backup\"\n0 3 * * *"];
+	task1 [style="rounded,dashed", label="1. SQL\nVACUUM ANALYZE\nerrors ignored"];
+	schedule -> task1;
+	task2 [style=rounded, label="2. PROGRAM dump\npg_dump\nin postgres:15"];
+	task1 -> task2;
+}
+`, b.String())
+
+	b.Reset()
+	assert.NoError(t, pgengine.WriteChainDOT(&b, pgengine.ChainDefinition{Name: "manual"}))
+	assert.Contains(t, b.String(), `label="manual\non demand"`)
+}
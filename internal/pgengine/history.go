@@ -0,0 +1,215 @@
+package pgengine
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// ExecutionLogFilter specifies the filter applied to the execution history
+type ExecutionLogFilter struct {
+	ChainID int       // return only runs of this chain, 0 means any
+	Status  string    // allowed: "success", "failure" or empty for any
+	Since   time.Time // return only runs started at or after this moment, zero means any
+	Limit   int       // maximum number of entries to return
+	Offset  int       // number of entries to skip
+}
+
+// ExecutionLogEntry describes the single task execution stored in the timetable.execution_log
+type ExecutionLogEntry struct {
+	ChainID    int        `db:"chain_id" json:"chain_id"`
+	TaskID     int        `db:"task_id" json:"task_id"`
+	Txid       int        `db:"txid" json:"txid"`
+	LastRun    time.Time  `db:"last_run" json:"last_run"`
+	Finished   *time.Time `db:"finished" json:"finished"`
+	Pid        int        `db:"pid" json:"pid"`
+	Returncode int        `db:"returncode" json:"returncode"`
+	Kind       string     `db:"kind" json:"kind"`
+	Command    string     `db:"command" json:"command"`
+	Output     string     `db:"output" json:"output"`
+	ClientName string     `db:"client_name" json:"client_name"`
+}
+
+// SelectExecutionLog returns the execution history entries matching the filter, the most recent first
+func (pge *PgEngine) SelectExecutionLog(ctx context.Context, dest interface{}, filter ExecutionLogFilter) error {
+	const sqlSelectExecutionLog = `SELECT chain_id, task_id, txid, last_run, finished, 
+COALESCE(pid, 0) AS pid, COALESCE(returncode, 0) AS returncode, COALESCE(kind :: text, '') AS kind, 
+COALESCE(command, '') AS command, COALESCE(output, '') AS output, client_name
+FROM timetable.execution_log 
+WHERE ($1 = 0 OR chain_id = $1)
+	AND ($2 = '' OR $2 = 'success' AND returncode = 0 OR $2 = 'failure' AND returncode <> 0)
+	AND ($3 :: timestamptz IS NULL OR last_run >= $3)
+ORDER BY last_run DESC
+LIMIT $4 OFFSET $5`
+	var since *time.Time
+	if !filter.Since.IsZero() {
+		since = &filter.Since
+	}
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectExecutionLog,
+		filter.ChainID, filter.Status, since, filter.Limit, filter.Offset)
+}
+
+type operatorKey struct{}
+
+// WithOperator returns the context carrying the identity of the operator, e.g. the REST API client,
+// recorded in the timetable.audit for changes made within the context
+func WithOperator(ctx context.Context, operator string) context.Context {
+	return context.WithValue(ctx, operatorKey{}, operator)
+}
+
+// Operator returns the identity of the operator stored in the context or empty string
+func Operator(ctx context.Context) string {
+	operator, _ := ctx.Value(operatorKey{}).(string)
+	return operator
+}
+
+// AuditFilter specifies the filter applied to the configuration changes log
+type AuditFilter struct {
+	ChainID int       // return only changes of this chain, 0 means any
+	Since   time.Time // return only changes made at or after this moment, zero means any
+	Limit   int       // maximum number of entries to return
+	Offset  int       // number of entries to skip
+}
+
+// AuditEntry describes the single change of chain, task or parameter stored in the timetable.audit
+type AuditEntry struct {
+	AuditID    int64           `db:"audit_id" json:"audit_id"`
+	ChangedAt  time.Time       `db:"changed_at" json:"changed_at"`
+	ChangedBy  string          `db:"changed_by" json:"changed_by"`
+	ClientName string          `db:"client_name" json:"client_name"`
+	TableName  string          `db:"table_name" json:"table_name"`
+	Operation  string          `db:"operation" json:"operation"`
+	ChainID    int             `db:"chain_id" json:"chain_id"`
+	TaskID     int             `db:"task_id" json:"task_id"`
+	OldData    json.RawMessage `db:"old_data" json:"old_data"`
+	NewData    json.RawMessage `db:"new_data" json:"new_data"`
+	Operator   string          `db:"operator" json:"operator"`
+}
+
+// SelectAuditLog returns the configuration changes matching the filter, the most recent first
+func (pge *PgEngine) SelectAuditLog(ctx context.Context, dest interface{}, filter AuditFilter) error {
+	const sqlSelectAuditLog = `SELECT audit_id, changed_at, changed_by, COALESCE(client_name, '') AS client_name, 
+table_name, operation, COALESCE(chain_id, 0) AS chain_id, COALESCE(task_id, 0) AS task_id, 
+COALESCE(old_data, 'null') AS old_data, COALESCE(new_data, 'null') AS new_data, COALESCE(operator, '') AS operator
+FROM timetable.audit 
+WHERE ($1 = 0 OR chain_id = $1)
+	AND ($2 :: timestamptz IS NULL OR changed_at >= $2)
+ORDER BY changed_at DESC, audit_id DESC
+LIMIT $3 OFFSET $4`
+	var since *time.Time
+	if !filter.Since.IsZero() {
+		since = &filter.Since
+	}
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectAuditLog,
+		filter.ChainID, since, filter.Limit, filter.Offset)
+}
+
+// APICall describes the single manual operation performed through the REST or gRPC API stored in the timetable.api_audit
+type APICall struct {
+	APIAuditID int64     `db:"api_audit_id" json:"api_audit_id"`
+	CalledAt   time.Time `db:"called_at" json:"called_at"`
+	ClientName string    `db:"client_name" json:"client_name"`
+	API        string    `db:"api" json:"api"`             // REST or gRPC
+	Operation  string    `db:"operation" json:"operation"` // e.g. POST /maintenance/on
+	ChainID    int       `db:"chain_id" json:"chain_id"`
+	Operator   string    `db:"operator" json:"operator"`
+	RemoteAddr string    `db:"remote_addr" json:"remote_addr"`
+	Status     string    `db:"status" json:"status"`
+}
+
+// APICallFilter specifies the filter applied to the API audit trail
+type APICallFilter struct {
+	ChainID  int       // return only operations on this chain, 0 means any
+	Operator string    // return only operations of this operator, empty means any
+	Since    time.Time // return only operations performed at or after this moment, zero means any
+	Limit    int       // maximum number of entries to return
+	Offset   int       // number of entries to skip
+}
+
+// LogAPICall stores the manual operation performed through the API served by this client in the timetable.api_audit
+func (pge *PgEngine) LogAPICall(ctx context.Context, call APICall) {
+	_, err := pge.ConfigDb.Exec(ctx, `INSERT INTO timetable.api_audit (
+client_name, api, operation, chain_id, operator, remote_addr, status) 
+VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''), NULLIF($6, ''), $7)`,
+		pge.ClientName, call.API, call.Operation, call.ChainID, call.Operator, call.RemoteAddr, call.Status)
+	if err != nil {
+		pge.l.WithError(err).Error("Failed to log API call")
+	}
+}
+
+// SelectAPICalls returns the manual operations performed through the API matching the filter, the most recent first
+func (pge *PgEngine) SelectAPICalls(ctx context.Context, dest interface{}, filter APICallFilter) error {
+	const sqlSelectAPICalls = `SELECT api_audit_id, called_at, client_name, api, operation, 
+COALESCE(chain_id, 0) AS chain_id, COALESCE(operator, '') AS operator, COALESCE(remote_addr, '') AS remote_addr, status
+FROM timetable.api_audit 
+WHERE ($1 = 0 OR chain_id = $1)
+	AND ($2 = '' OR operator = $2)
+	AND ($3 :: timestamptz IS NULL OR called_at >= $3)
+ORDER BY called_at DESC, api_audit_id DESC
+LIMIT $4 OFFSET $5`
+	var since *time.Time
+	if !filter.Since.IsZero() {
+		since = &filter.Since
+	}
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectAPICalls,
+		filter.ChainID, filter.Operator, since, filter.Limit, filter.Offset)
+}
+
+// ChainFreshness describes the time passed since the last successful run of the chain
+type ChainFreshness struct {
+	ChainID             int        `db:"chain_id" json:"chain_id"`
+	ChainName           string     `db:"chain_name" json:"chain_name"`
+	LastSuccess         *time.Time `db:"last_success" json:"last_success"`
+	SecondsSinceSuccess *int64     `db:"seconds_since_success" json:"seconds_since_success"`
+}
+
+// SelectChainFreshness returns the freshness of every chain, chains never succeeded have nil values
+func (pge *PgEngine) SelectChainFreshness(ctx context.Context, dest interface{}) error {
+	const sqlSelectChainFreshness = `SELECT chain_id, chain_name, last_success, seconds_since_success 
+FROM timetable.chain_freshness ORDER BY chain_id`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectChainFreshness)
+}
+
+// ChainFailures summarizes the failures of the chain executed by the client within the digest period
+type ChainFailures struct {
+	ChainID     int       `db:"chain_id" json:"chain_id"`
+	ChainName   string    `db:"chain_name" json:"chain_name"`
+	Failures    int       `db:"failures" json:"failures"`
+	LastFailure time.Time `db:"last_failure" json:"last_failure"`
+	LastOutput  string    `db:"last_output" json:"last_output"`
+}
+
+// SelectChainFailures returns the chains having failed tasks since the specified moment, the most failing first.
+// Failures are counted per chain run, only runs of the current client are taken into account
+func (pge *PgEngine) SelectChainFailures(ctx context.Context, dest interface{}, since time.Time) error {
+	const sqlSelectChainFailures = `SELECT e.chain_id, COALESCE(c.chain_name, '') AS chain_name, 
+count(DISTINCT e.txid) AS failures, max(e.finished) AS last_failure, 
+COALESCE((array_agg(e.output ORDER BY e.finished DESC))[1], '') AS last_output
+FROM timetable.execution_log e LEFT JOIN timetable.chain c USING (chain_id)
+WHERE e.returncode <> 0 AND e.finished >= $1 AND e.client_name = $2
+GROUP BY e.chain_id, c.chain_name
+ORDER BY failures DESC, e.chain_id`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectChainFailures, since, pge.ClientName)
+}
+
+// FailedTask describes the failed task execution of any client
+type FailedTask struct {
+	ChainID    int       `db:"chain_id" json:"chain_id"`
+	ChainName  string    `db:"chain_name" json:"chain_name"`
+	ClientName string    `db:"client_name" json:"client_name"`
+	Finished   time.Time `db:"finished" json:"finished"`
+	ReturnCode int       `db:"returncode" json:"returncode"`
+	Output     string    `db:"output" json:"output"`
+}
+
+// SelectRecentFailures returns the latest failed task executions of all clients since the specified moment
+func (pge *PgEngine) SelectRecentFailures(ctx context.Context, dest interface{}, since time.Time, limit int) error {
+	const sqlSelectRecentFailures = `SELECT e.chain_id, COALESCE(c.chain_name, '') AS chain_name, e.client_name,
+e.finished, e.returncode, COALESCE(e.output, '') AS output
+FROM timetable.execution_log e LEFT JOIN timetable.chain c USING (chain_id)
+WHERE e.returncode <> 0 AND e.finished >= $1
+ORDER BY e.finished DESC LIMIT $2`
+	return pgxscan.Select(ctx, pge.ConfigDb, dest, sqlSelectRecentFailures, since, limit)
+}
@@ -0,0 +1,97 @@
+package pgengine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectExecutionLog(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	var entries []pgengine.ExecutionLogEntry
+	since := time.Now()
+	mockPool.ExpectQuery("SELECT.+FROM timetable\\.execution_log").
+		WithArgs(42, "failure", &since, 10, 20).
+		WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectExecutionLog(context.Background(), &entries,
+		pgengine.ExecutionLogFilter{ChainID: 42, Status: "failure", Since: since, Limit: 10, Offset: 20}))
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestSelectAuditLog(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	var entries []pgengine.AuditEntry
+	mockPool.ExpectQuery("SELECT.+FROM timetable\\.audit").
+		WithArgs(42, (*time.Time)(nil), 10, 0).
+		WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectAuditLog(context.Background(), &entries,
+		pgengine.AuditFilter{ChainID: 42, Limit: 10}))
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestAPICalls(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	pge.ClientName = "pgengine_unit_test"
+	defer mockPool.Close()
+
+	mockPool.ExpectExec("INSERT INTO timetable\\.api_audit").
+		WithArgs("pgengine_unit_test", "REST", "POST /maintenance/on", 0, "ops", "127.0.0.1", "200").
+		WillReturnError(errors.New("error"))
+	pge.LogAPICall(context.Background(), pgengine.APICall{API: "REST", Operation: "POST /maintenance/on",
+		Operator: "ops", RemoteAddr: "127.0.0.1", Status: "200"})
+
+	var calls []pgengine.APICall
+	mockPool.ExpectQuery("SELECT.+FROM timetable\\.api_audit").
+		WithArgs(42, "ops", (*time.Time)(nil), 10, 0).
+		WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectAPICalls(context.Background(), &calls,
+		pgengine.APICallFilter{ChainID: 42, Operator: "ops", Limit: 10}))
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestSelectChainFreshness(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	defer mockPool.Close()
+
+	var freshness []pgengine.ChainFreshness
+	mockPool.ExpectQuery("SELECT.+FROM timetable\\.chain_freshness").WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectChainFreshness(context.Background(), &freshness))
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestSelectChainFailures(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	pge.ClientName = "test_client"
+	defer mockPool.Close()
+
+	var failures []pgengine.ChainFailures
+	since := time.Now().Add(-24 * time.Hour)
+	mockPool.ExpectQuery("SELECT.+FROM timetable\\.execution_log").
+		WithArgs(since, pge.ClientName).
+		WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectChainFailures(context.Background(), &failures, since))
+
+	mockPool.ExpectQuery("SELECT.+FROM timetable\\.execution_log").
+		WithArgs(since, 10).
+		WillReturnError(errors.New("error"))
+	assert.Error(t, pge.SelectRecentFailures(context.Background(), &[]pgengine.FailedTask{}, since, 10))
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
@@ -0,0 +1,95 @@
+package pgengine
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// leaderLockSQL takes the session advisory lock of the high availability group. The server releases the lock
+// as soon as the session of the leader ends, e.g. the leader crashed or lost the network
+const leaderLockSQL = "SELECT pg_try_advisory_lock(hashtext('pg_timetable_leader'), hashtext($1))"
+
+// TryLockLeader obtains the leader lock of the high availability group on the connection.
+// Returns false if another instance of the group holds the lock
+func (pge *PgEngine) TryLockLeader(ctx context.Context, conn QueryRowIface) (locked bool, err error) {
+	err = conn.QueryRow(ctx, leaderLockSQL, pge.HA.Group).Scan(&locked)
+	return
+}
+
+// TryBecomeLeader tries to obtain the leader lock of the high availability group on the dedicated connection.
+// The connection is held until ResignLeader is called, so the instance stays the leader while the connection is alive
+func (pge *PgEngine) TryBecomeLeader(ctx context.Context) (bool, error) {
+	if pge.leaderConn != nil {
+		return true, nil
+	}
+	conn, err := pge.ConfigDb.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	locked, err := pge.TryLockLeader(ctx, conn)
+	if err != nil || !locked {
+		conn.Release()
+		return false, err
+	}
+	pge.leaderConn = conn
+	pge.l.WithField("group", pge.HA.Group).Info("Leader lock obtained")
+	return true, nil
+}
+
+// CheckLeader returns error if the connection holding the leader lock is lost, the lock is released by the server then
+func (pge *PgEngine) CheckLeader(ctx context.Context) error {
+	if pge.leaderConn == nil {
+		return errors.New("leader lock is not held")
+	}
+	return pge.leaderConn.Ping(ctx)
+}
+
+// ResignLeader releases the leader lock closing the connection holding it
+func (pge *PgEngine) ResignLeader(ctx context.Context) {
+	if pge.leaderConn == nil {
+		return
+	}
+	// closing the session releases the lock, the closed connection is destroyed by the pool on release
+	_ = pge.leaderConn.Conn().Close(ctx)
+	pge.leaderConn.Release()
+	pge.leaderConn = nil
+}
+
+// IsPrimaryActive returns true if the session of the client with the same name is alive, i.e. the primary instance
+// holds the client name lock obtained by timetable.try_lock_client_name()
+func (pge *PgEngine) IsPrimaryActive(ctx context.Context) (active bool, err error) {
+	const sqlPrimaryActive = `SELECT EXISTS(SELECT 1 FROM timetable.active_session s
+JOIN pg_catalog.pg_stat_activity a ON a.pid = s.server_pid AND a.application_name = 'pg_timetable'
+WHERE s.client_name = $1)`
+	err = pge.ConfigDb.QueryRow(ctx, sqlPrimaryActive, pge.ClientName).Scan(&active)
+	return
+}
+
+// NotifyDrain asks the primary instance with the same client name to finish running chains and stop,
+// so this instance takes over
+func (pge *PgEngine) NotifyDrain(ctx context.Context) error {
+	_, err := pge.ConfigDb.Exec(ctx, "SELECT timetable.notify_drain($1)", pge.ClientName)
+	return err
+}
+
+// WaitPrimaryStopped blocks until the primary instance with the same client name stops or loses the connection,
+// so the standby instance may take over. The primary is checked every `--ha-check-interval` seconds
+func (pge *PgEngine) WaitPrimaryStopped(ctx context.Context) error {
+	pge.l.WithField("client", pge.ClientName).Info("Standing by until the primary client stops")
+	for {
+		active, err := pge.IsPrimaryActive(ctx)
+		switch {
+		case err != nil:
+			pge.l.WithError(err).Error("Cannot check the primary client session")
+		case !active:
+			pge.l.Info("Primary client stopped, taking over")
+			return nil
+		}
+		select {
+		case <-time.After(time.Duration(pge.HA.CheckInterval) * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
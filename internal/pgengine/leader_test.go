@@ -0,0 +1,64 @@
+package pgengine_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTryLockLeader(t *testing.T) {
+	pge := pgengine.NewDB(nil, "pgengine_unit_test", "--ha-group=prod")
+	ctx := context.Background()
+
+	locked, err := pge.TryLockLeader(ctx, mockpgconn{&mockpgrow{results: []interface{}{errors.New("locking error")}}})
+	assert.Error(t, err)
+	assert.False(t, locked)
+
+	locked, err = pge.TryLockLeader(ctx, mockpgconn{&mockpgrow{results: []interface{}{false}}})
+	assert.NoError(t, err)
+	assert.False(t, locked, "another instance is the leader")
+
+	locked, err = pge.TryLockLeader(ctx, mockpgconn{&mockpgrow{results: []interface{}{true}}})
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	assert.Error(t, pge.CheckLeader(ctx), "leader lock is not held")
+	pge.ResignLeader(ctx) // no-op without the lock
+}
+
+func TestWaitPrimaryStopped(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test", "--standby", "--ha-check-interval=1")
+	defer mockPool.Close()
+	ctx := context.Background()
+
+	mockPool.ExpectQuery("SELECT EXISTS.+active_session").WithArgs(pge.ClientName).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mockPool.ExpectQuery("SELECT EXISTS.+active_session").WithArgs(pge.ClientName).
+		WillReturnError(errors.New("connection lost"))
+	mockPool.ExpectQuery("SELECT EXISTS.+active_session").WithArgs(pge.ClientName).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	assert.NoError(t, pge.WaitPrimaryStopped(ctx), "errors are retried until the primary stops")
+
+	mockPool.ExpectQuery("SELECT EXISTS.+active_session").WithArgs(pge.ClientName).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, pge.WaitPrimaryStopped(cancelled), context.Canceled)
+	assert.NoError(t, mockPool.ExpectationsWereMet())
+}
+
+func TestNotifyDrain(t *testing.T) {
+	initmockdb(t)
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test", "--takeover")
+	defer mockPool.Close()
+
+	mockPool.ExpectExec("SELECT timetable\\.notify_drain").WithArgs(pge.ClientName).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	assert.NoError(t, pge.NotifyDrain(context.Background()))
+	assert.NoError(t, mockPool.ExpectationsWereMet())
+}
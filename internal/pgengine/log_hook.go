@@ -9,6 +9,10 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// SampledOutField marks log records of chain runs excluded by log sampling,
+// such records are not stored in the database unless they report errors
+const SampledOutField = "sampled_out"
+
 // LogHook is the implementation of the logrus hook for pgx
 type LogHook struct {
 	cacheLimit      int           // hold this number of entries before flush to database
@@ -47,6 +51,9 @@ func (hook *LogHook) Fire(entry *logrus.Entry) error {
 	if hook.ctx.Err() != nil {
 		return nil
 	}
+	if sampledOut, _ := entry.Data[SampledOutField].(bool); sampledOut && entry.Level > logrus.ErrorLevel {
+		return nil
+	}
 	select {
 	case hook.input <- *entry:
 		// entry sent
@@ -64,8 +71,15 @@ func (hook *LogHook) Fire(entry *logrus.Entry) error {
 // Levels returns the available logging levels
 func (hook *LogHook) Levels() []logrus.Level {
 	switch hook.level {
-	case "debug":
-		return logrus.AllLevels
+	case "debug": // statements logged on the trace level are never stored, otherwise storing would log itself
+		return []logrus.Level{
+			logrus.PanicLevel,
+			logrus.FatalLevel,
+			logrus.ErrorLevel,
+			logrus.WarnLevel,
+			logrus.InfoLevel,
+			logrus.DebugLevel,
+		}
 	case "info":
 		return []logrus.Level{
 			logrus.PanicLevel,
@@ -74,6 +88,13 @@ func (hook *LogHook) Levels() []logrus.Level {
 			logrus.WarnLevel,
 			logrus.InfoLevel,
 		}
+	case "warn":
+		return []logrus.Level{
+			logrus.PanicLevel,
+			logrus.FatalLevel,
+			logrus.ErrorLevel,
+			logrus.WarnLevel,
+		}
 	default:
 		return []logrus.Level{
 			logrus.PanicLevel,
@@ -50,7 +50,8 @@ func TestCancelledContext(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 	h := NewHook(ctx, &PgEngine{}, "debug")
-	assert.Equal(t, h.Levels(), logrus.AllLevels)
+	assert.Contains(t, h.Levels(), logrus.DebugLevel)
+	assert.NotContains(t, h.Levels(), logrus.TraceLevel, "statements must never be stored")
 	assert.NoError(t, h.Fire(&logrus.Entry{}))
 }
 
@@ -63,3 +64,11 @@ func TestFireError(t *testing.T) {
 	<-time.After(time.Second)
 	assert.Equal(t, err, h.Fire(&logrus.Entry{}))
 }
+
+func TestSampledOut(t *testing.T) {
+	h := &LogHook{ctx: context.Background(), input: make(chan logrus.Entry, 2), level: "debug", highLoadTimeout: time.Second}
+	assert.NoError(t, h.Fire(&logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{SampledOutField: true}}))
+	assert.Empty(t, h.input, "records of sampled out runs must be skipped")
+	assert.NoError(t, h.Fire(&logrus.Entry{Level: logrus.ErrorLevel, Data: logrus.Fields{SampledOutField: true}}))
+	assert.Len(t, h.input, 1, "errors must be stored")
+}
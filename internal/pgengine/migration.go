@@ -114,6 +114,162 @@ var Migrations func() migrator.Option = func() migrator.Option {
 				return ExecuteMigrationScript(ctx, tx, "00436.sql")
 			},
 		},
+		&migrator.Migration{
+			Name: "01354 Add timetable.notify_maintenance function",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01354.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01366 Add timetable.notify_channel table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01366.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01377 Add log_level column to timetable.chain",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01377.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01378 Add timetable.audit table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01378.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01383 Add output_gzip column to timetable.execution_log",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01383.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01384 Add log_sampling column to timetable.chain",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01384.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01387 Add timetable.chain_freshness view",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01387.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01393 Add tags column to timetable.chain",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01393.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01395 Add timetable.client_heartbeat table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01395.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01398 Add timetable.handoff_chain() function",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01398.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01399 Add required_labels column to timetable.chain",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01399.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01400 Add timetable.notify_drain() function",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01400.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01401 Add timetable.cluster_status view",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01401.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01404 Add sandbox column to timetable.task",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01404.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01406 Add read_only column to timetable.chain",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01406.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01407 Add operator column to timetable.audit",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01407.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01409 Add env column to timetable.task",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01409.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01411 Add container column to timetable.task",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01411.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01412 Add timetable.api_audit table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01412.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01413 Add owner column and tenant policies to timetable.chain",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01413.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01414 Add secret column to timetable.parameter",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01414.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01417 Add applied column to timetable.chain",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01417.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01438 Add timetable.notify_chain_change trigger function",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01438.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01446 Add timetable.execution_log_archive table",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01446.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01447 Start chains by their clients with timetable.notify_chain_start()",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01447.sql")
+			},
+		},
+		&migrator.Migration{
+			Name: "01448 Add last_started column to timetable.chain_status",
+			Func: func(ctx context.Context, tx pgx.Tx) error {
+				return ExecuteMigrationScript(ctx, tx, "01448.sql")
+			},
+		},
 		// adding new migration here, update "timetable"."migration" in "sql/ddl.sql"
 		// and "dbapi" variable in main.go!
 
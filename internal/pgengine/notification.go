@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pgconn "github.com/jackc/pgconn"
@@ -16,7 +17,7 @@ var NotifyTTL int64 = 60
 // ChainSignal used to hold asynchronous notifications from PostgreSQL server
 type ChainSignal struct {
 	ConfigID int    // chain configuration ifentifier
-	Command  string // allowed: START, STOP
+	Command  string // allowed: START, STOP, HANDOFF, DRAIN, MAINTENANCE_ON, MAINTENANCE_OFF, CHANGED
 	Ts       int64  // timestamp NOTIFY sent
 }
 
@@ -47,6 +48,12 @@ func (pge *PgEngine) NotificationHandler(c *pgconn.PgConn, n *pgconn.Notificatio
 	var signal ChainSignal
 	var err error
 	if err = json.Unmarshal([]byte(n.Payload), &signal); err == nil {
+		if signal.Command == "CHANGED" { // invalidation is idempotent, so it's not deduplicated
+			l.WithField("signal", signal).Debug("Chain definition changed")
+			pge.tasks.invalidate(signal.ConfigID)
+			atomic.AddInt64(&pge.chainChanges, 1)
+			return
+		}
 		mutex.Lock()
 		if _, ok := notifications[signal]; ok {
 			l.WithField("handled", notifications).Debug("Notification already handled")
@@ -62,6 +69,20 @@ func (pge *PgEngine) NotificationHandler(c *pgconn.PgConn, n *pgconn.Notificatio
 				pge.chainSignalChan <- signal
 				return
 			}
+		case "HANDOFF":
+			if signal.ConfigID > 0 {
+				l.WithField("signal", signal).Info("Chain reassignment received")
+				pge.chainSignalChan <- signal
+				return
+			}
+		case "DRAIN":
+			l.WithField("signal", signal).Info("Drain requested by the instance taking over")
+			pge.chainSignalChan <- signal
+			return
+		case "MAINTENANCE_ON", "MAINTENANCE_OFF":
+			l.WithField("signal", signal).Info("Maintenance mode change requested")
+			pge.chainSignalChan <- signal
+			return
 		}
 		err = fmt.Errorf("Unknown command: %s", signal.Command)
 	}
@@ -0,0 +1,56 @@
+package pgengine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"unicode/utf8"
+)
+
+// outputCut is the marker stored in place of the removed part of the task output
+const outputCut = "\n... %d bytes truncated ...\n"
+
+// headCut returns the index not greater than n not splitting UTF-8 characters of s
+func headCut(s string, n int) int {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// tailCut returns the index not less than n not splitting UTF-8 characters of s
+func tailCut(s string, n int) int {
+	for n < len(s) && !utf8.RuneStart(s[n]) {
+		n++
+	}
+	return n
+}
+
+// ShortenOutput applies the output limit in bytes to the task output stored in the execution log.
+// Depending on the policy the head, the tail or both ends of the output are kept. With the "gzip" policy
+// the tail is kept and the whole output is returned compressed as well. Limit 0 means no limit
+func ShortenOutput(output string, limit int, policy string) (short string, compressed []byte, err error) {
+	if limit <= 0 || len(output) <= limit {
+		return output, nil, nil
+	}
+	switch policy {
+	case "head":
+		n := headCut(output, limit)
+		return output[:n] + fmt.Sprintf(outputCut, len(output)-n), nil, nil
+	case "head-tail":
+		head, tail := headCut(output, limit/2), tailCut(output, len(output)-limit/2)
+		return output[:head] + fmt.Sprintf(outputCut, tail-head) + output[tail:], nil, nil
+	case "gzip":
+		b := &bytes.Buffer{}
+		w := gzip.NewWriter(b)
+		if _, err = w.Write([]byte(output)); err == nil {
+			err = w.Close()
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		compressed = b.Bytes()
+	}
+	n := tailCut(output, len(output)-limit)
+	return fmt.Sprintf(outputCut, n) + output[n:], compressed, nil
+}
@@ -0,0 +1,44 @@
+package pgengine_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortenOutput(t *testing.T) {
+	output := "0123456789abcdefghij"
+	for _, policy := range []string{"head", "tail", "head-tail", "gzip"} {
+		short, compressed, err := pgengine.ShortenOutput(output, 0, policy)
+		assert.NoError(t, err)
+		assert.Equal(t, output, short, "no limit")
+		assert.Nil(t, compressed)
+		short, _, _ = pgengine.ShortenOutput(output, len(output), policy)
+		assert.Equal(t, output, short, "output within limit")
+	}
+
+	short, _, _ := pgengine.ShortenOutput(output, 4, "head")
+	assert.Equal(t, "0123\n... 16 bytes truncated ...\n", short)
+	short, _, _ = pgengine.ShortenOutput(output, 4, "tail")
+	assert.Equal(t, "\n... 16 bytes truncated ...\nghij", short)
+	short, _, _ = pgengine.ShortenOutput(output, 4, "head-tail")
+	assert.Equal(t, "01\n... 16 bytes truncated ...\nij", short)
+	short, _, _ = pgengine.ShortenOutput("привет", 3, "head")
+	assert.Equal(t, "п\n... 10 bytes truncated ...\n", short, "UTF-8 characters must not be split")
+	short, _, _ = pgengine.ShortenOutput("привет", 3, "tail")
+	assert.Equal(t, "\n... 10 bytes truncated ...\nт", short, "UTF-8 characters must not be split")
+
+	short, compressed, err := pgengine.ShortenOutput(output, 4, "gzip")
+	require.NoError(t, err)
+	assert.Equal(t, "\n... 16 bytes truncated ...\nghij", short)
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	whole, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, output, string(whole))
+}
@@ -68,7 +68,7 @@ func TestInitAndTestConfigDBConnection(t *testing.T) {
 
 	t.Run("Check timetable tables", func(t *testing.T) {
 		var oid int
-		tableNames := []string{"task", "chain", "parameter", "log", "execution_log", "active_session", "active_chain"}
+		tableNames := []string{"task", "chain", "parameter", "log", "execution_log", "active_session", "active_chain", "notify_channel"}
 		for _, tableName := range tableNames {
 			err := pge.ConfigDb.QueryRow(ctx, fmt.Sprintf("SELECT COALESCE(to_regclass('timetable.%s'), 0) :: int", tableName)).Scan(&oid)
 			assert.NoError(t, err, fmt.Sprintf("Query for %s existence failed", tableName))
@@ -82,7 +82,8 @@ func TestInitAndTestConfigDBConnection(t *testing.T) {
 			"validate_json_schema(jsonb, jsonb, jsonb)",
 			"add_task(timetable.command_kind, TEXT, BIGINT, DOUBLE PRECISION)",
 			"add_job(TEXT, timetable.cron, TEXT, JSONB, timetable.command_kind, TEXT, INTEGER, BOOLEAN, BOOLEAN, BOOLEAN, BOOLEAN)",
-			"is_cron_in_time(timetable.cron, timestamptz)"}
+			"is_cron_in_time(timetable.cron, timestamptz)",
+			"get_notify_channel(TEXT)"}
 		for _, funcName := range funcNames {
 			err := pge.ConfigDb.QueryRow(ctx, fmt.Sprintf("SELECT COALESCE(to_regprocedure('timetable.%s'), 0) :: int", funcName)).Scan(&oid)
 			assert.NoError(t, err, fmt.Sprintf("Query for %s existence failed", funcName))
@@ -140,7 +141,7 @@ func TestSchedulerFunctions(t *testing.T) {
 
 	t.Run("Check GetChainElements funсtion", func(t *testing.T) {
 		var chains []pgengine.ChainTask
-		tx, txid, err := pge.StartTransaction(ctx, 0)
+		tx, txid, err := pge.StartTransaction(ctx, 0, false)
 		assert.NoError(t, err, "Should start transaction")
 		assert.Greater(t, txid, 0, "Should return transaction id")
 		assert.True(t, pge.GetChainElements(ctx, tx, &chains, 0), "Should no error in clean database")
@@ -150,14 +151,10 @@ func TestSchedulerFunctions(t *testing.T) {
 
 	t.Run("Check GetChainParamValues funсtion", func(t *testing.T) {
 		var paramVals []string
-		tx, txid, err := pge.StartTransaction(ctx, 0)
-		assert.NoError(t, err, "Should start transaction")
-		assert.Greater(t, txid, 0, "Should return transaction id")
-		assert.True(t, pge.GetChainParamValues(ctx, tx, &paramVals, &pgengine.ChainTask{
+		assert.True(t, pge.GetChainParamValues(ctx, &paramVals, &pgengine.ChainTask{
 			TaskID:  0,
-			ChainID: 0}), "Should no error in clean database")
-		assert.Empty(t, paramVals, "Should be empty in clean database")
-		pge.CommitTransaction(ctx, tx)
+			ChainID: 0}), "Should no error for task without parameters")
+		assert.Empty(t, paramVals, "Should be empty for task without parameters")
 	})
 
 	t.Run("Check InsertChainRunStatus funсtion", func(t *testing.T) {
@@ -168,7 +165,7 @@ func TestSchedulerFunctions(t *testing.T) {
 	})
 
 	t.Run("Check ExecuteSQLCommand function", func(t *testing.T) {
-		tx, txid, err := pge.StartTransaction(ctx, 0)
+		tx, txid, err := pge.StartTransaction(ctx, 0, false)
 		assert.NoError(t, err, "Should start transaction")
 		assert.Greater(t, txid, 0, "Should return transaction id")
 		f := func(sql string, params []string) error {
@@ -0,0 +1,44 @@
+package pgengine
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+)
+
+// connQuota limits the number of tasks executed concurrently on the same remote database connection
+type connQuota struct {
+	sync.Mutex
+	slots map[string]chan struct{} // occupied slots by connection string
+}
+
+// acquire waits for the free slot of the connection and returns the function releasing it.
+// Zero or negative limit means unlimited
+func (q *connQuota) acquire(ctx context.Context, connStr string, limit int) (release func(), err error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	q.Lock()
+	if q.slots == nil {
+		q.slots = make(map[string]chan struct{})
+	}
+	slots, ok := q.slots[connStr]
+	if !ok || cap(slots) != limit { // the limit was changed by the configuration reload
+		slots = make(chan struct{}, limit)
+		q.slots[connStr] = slots
+	}
+	q.Unlock()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+	}
+	log.GetLogger(ctx).WithField("limit", limit).Info("Waiting for the free slot of the remote database connection")
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,36 @@
+package pgengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnQuota(t *testing.T) {
+	var q connQuota
+	ctx := context.Background()
+	release, err := q.acquire(ctx, "dbname=foo", 0)
+	assert.NoError(t, err)
+	release()
+
+	release1, err := q.acquire(ctx, "dbname=foo", 1)
+	assert.NoError(t, err)
+	release2, err := q.acquire(ctx, "dbname=bar", 1)
+	assert.NoError(t, err, "other connections have own slots")
+	release2()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = q.acquire(timeoutCtx, "dbname=foo", 1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the task waits for the free slot")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release1()
+	}()
+	release, err = q.acquire(ctx, "dbname=foo", 1)
+	assert.NoError(t, err, "the released slot is taken")
+	release()
+}
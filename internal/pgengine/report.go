@@ -0,0 +1,266 @@
+package pgengine
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/georgysavva/scany/pgxscan"
+)
+
+// ScheduleTask describes the task of the chain in the schedule report
+type ScheduleTask struct {
+	Kind    string `json:"kind"`
+	Command string `json:"command"`
+}
+
+// ScheduleEntry describes the live chain in the schedule report
+type ScheduleEntry struct {
+	ChainID    int            `db:"chain_id" json:"chain_id"`
+	ChainName  string         `db:"chain_name" json:"chain_name"`
+	Schedule   string         `db:"run_at" json:"schedule"`
+	ClientName string         `db:"client_name" json:"client_name,omitempty"`
+	NextRun    *time.Time     `db:"next_run" json:"next_run,omitempty"`
+	Tasks      []ScheduleTask `db:"tasks" json:"tasks"`
+}
+
+// SelectSchedule returns live chains of all clients with their tasks ordered by name for the schedule report.
+// The next run is returned only for cron-style schedules
+func (pge *PgEngine) SelectSchedule(ctx context.Context) (entries []ScheduleEntry, err error) {
+	const sqlSelectSchedule = `SELECT chain_id, chain_name, COALESCE(run_at, '') AS run_at,
+COALESCE(client_name, '') AS client_name,
+CASE WHEN run_at !~ '^@' THEN timetable.next_run(run_at) END AS next_run,
+COALESCE((SELECT json_agg(json_build_object('kind', t.kind, 'command', t.command) ORDER BY t.task_order)
+	FROM timetable.task t WHERE t.chain_id = c.chain_id), '[]') AS tasks
+FROM timetable.chain c WHERE live
+ORDER BY chain_name`
+	err = pgxscan.Select(ctx, pge.ConfigDb, &entries, sqlSelectSchedule)
+	return
+}
+
+// ChainOverviewFilter selects chains of the chain list command
+type ChainOverviewFilter struct {
+	Client   string   // chains available to the client, i.e. assigned to it or to any client
+	LiveOnly bool     // live chains only
+	Tags     []string // chains tagged with any of these tags, all chains if empty
+}
+
+// ChainOverview describes the chain with the state of its last run for the chain list command
+type ChainOverview struct {
+	ChainID    int        `db:"chain_id" json:"chain_id"`
+	ChainName  string     `db:"chain_name" json:"chain_name"`
+	Schedule   string     `db:"run_at" json:"schedule"`
+	Live       bool       `db:"live" json:"live"`
+	ClientName string     `db:"client_name" json:"client_name,omitempty"`
+	Tags       []string   `db:"tags" json:"tags,omitempty"`
+	LastRun    *time.Time `db:"last_run" json:"last_run,omitempty"`
+	LastStatus string     `db:"last_status" json:"last_status,omitempty"` // succeeded or failed, empty if never run
+	NextRun    *time.Time `db:"next_run" json:"next_run,omitempty"`
+}
+
+// SelectChainOverview returns chains matching the filter ordered by ID. The last run is the set of tasks executed
+// in the latest transaction of the chain and failed if any of them failed. The next run is returned only for live
+// chains with cron-style schedules
+func (pge *PgEngine) SelectChainOverview(ctx context.Context, filter ChainOverviewFilter) (chains []ChainOverview, err error) {
+	const sqlSelectChainOverview = `SELECT c.chain_id, c.chain_name, COALESCE(c.run_at, '') AS run_at,
+COALESCE(c.live, FALSE) AS live, COALESCE(c.client_name, '') AS client_name, COALESCE(c.tags, '{}') AS tags,
+r.last_run, COALESCE(CASE WHEN r.succeeded THEN 'succeeded' WHEN NOT r.succeeded THEN 'failed' END, '') AS last_status,
+CASE WHEN c.live AND c.run_at !~ '^@' THEN timetable.next_run(c.run_at) END AS next_run
+FROM timetable.chain c
+LEFT JOIN LATERAL (
+	SELECT max(e.finished) AS last_run, bool_and(e.returncode = 0) AS succeeded
+	FROM timetable.execution_log e
+	WHERE e.chain_id = c.chain_id AND e.txid = (
+		SELECT txid FROM timetable.execution_log WHERE chain_id = c.chain_id ORDER BY last_run DESC LIMIT 1)
+) r ON TRUE
+WHERE (c.client_name = $1 OR c.client_name IS NULL) AND (c.live OR NOT $2)
+	AND (cardinality($3 :: text[]) = 0 OR c.tags && $3)
+ORDER BY c.chain_id`
+	tags := filter.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	err = pgxscan.Select(ctx, pge.ConfigDb, &chains, sqlSelectChainOverview, filter.Client, filter.LiveOnly, tags)
+	return
+}
+
+// maxReportCommand is the maximum length of the task command in the crontab-like report
+const maxReportCommand = 60
+
+// WriteScheduleReport outputs entries as the crontab-like table, one chain per line with its schedule first.
+// Commands of tasks are shortened to a single line, the JSON output should be used for the full details
+func WriteScheduleReport(w io.Writer, entries []ScheduleEntry, generated time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "# pg_timetable schedule of %d live chains generated at %s\n", len(entries), generated.Format(time.RFC3339))
+	fmt.Fprintln(tw, "# SCHEDULE\tCHAIN\tCLIENT\tNEXT RUN\tTASKS")
+	for _, e := range entries {
+		client, nextRun := e.ClientName, "-"
+		if client == "" {
+			client = "*"
+		}
+		if e.NextRun != nil {
+			nextRun = e.NextRun.Format("2006-01-02 15:04")
+		}
+		tasks := make([]string, 0, len(e.Tasks))
+		for _, task := range e.Tasks {
+			tasks = append(tasks, task.Kind+" "+shortCommand(task.Command))
+		}
+		schedule := e.Schedule
+		if schedule == "" {
+			schedule = "-" // started only on demand, e.g. with notify_chain_start()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", schedule, e.ChainName, client, nextRun, strings.Join(tasks, " -> "))
+	}
+	return tw.Flush()
+}
+
+// shortCommand returns the command collapsed to a single line and truncated to maxReportCommand characters
+func shortCommand(command string) string {
+	runes := []rune(strings.Join(strings.Fields(command), " "))
+	if len(runes) > maxReportCommand {
+		return string(runes[:maxReportCommand-3]) + "..."
+	}
+	return string(runes)
+}
+
+// ChainStats summarizes runs of the chain stored in the timetable.execution_log, durations are in seconds
+type ChainStats struct {
+	ChainID           int        `db:"chain_id" json:"chain_id"`
+	ChainName         string     `db:"chain_name" json:"chain_name"`
+	Runs              int        `db:"runs" json:"runs"`
+	Failures          int        `db:"failures" json:"failures"`
+	SuccessRate       float64    `db:"success_rate" json:"success_rate"`
+	P50Duration       *float64   `db:"p50_duration" json:"p50_duration"`
+	P95Duration       *float64   `db:"p95_duration" json:"p95_duration"`
+	LastFailure       *time.Time `db:"last_failure" json:"last_failure"`
+	LastFailureOutput string     `db:"last_failure_output" json:"last_failure_output"`
+}
+
+// SelectChainStats returns statistics of chain runs of all clients started at or after since, zero means the whole
+// history, ordered by chain name. The run is the set of tasks executed in the same transaction and failed if any
+// of them failed, the duration of the run is measured from the start of the first task to the end of the last one
+func (pge *PgEngine) SelectChainStats(ctx context.Context, since time.Time) (stats []ChainStats, err error) {
+	const sqlSelectChainStats = `WITH runs AS (
+	SELECT COALESCE(chain_id, 0) AS chain_id, txid, bool_and(returncode = 0) AS success,
+		extract(epoch FROM max(finished) - min(last_run)) AS duration, max(finished) AS finished,
+		(array_agg(output ORDER BY finished DESC) FILTER (WHERE returncode <> 0))[1] AS failure_output
+	FROM timetable.execution_log
+	WHERE $1 :: timestamptz IS NULL OR last_run >= $1
+	GROUP BY chain_id, txid
+)
+SELECT r.chain_id, COALESCE(c.chain_name, '') AS chain_name, count(*) AS runs,
+	count(*) FILTER (WHERE NOT r.success) AS failures,
+	(count(*) FILTER (WHERE r.success)) :: float8 / count(*) AS success_rate,
+	percentile_cont(0.5) WITHIN GROUP (ORDER BY r.duration) AS p50_duration,
+	percentile_cont(0.95) WITHIN GROUP (ORDER BY r.duration) AS p95_duration,
+	max(r.finished) FILTER (WHERE NOT r.success) AS last_failure,
+	COALESCE((array_agg(r.failure_output ORDER BY r.finished DESC) FILTER (WHERE NOT r.success))[1], '') AS last_failure_output
+FROM runs r LEFT JOIN timetable.chain c USING (chain_id)
+GROUP BY r.chain_id, c.chain_name
+ORDER BY chain_name, r.chain_id`
+	var from *time.Time
+	if !since.IsZero() {
+		from = &since
+	}
+	err = pgxscan.Select(ctx, pge.ConfigDb, &stats, sqlSelectChainStats, from)
+	return
+}
+
+// WriteChainStatsCSV outputs statistics as CSV with the header line, absent durations and failures are empty
+func WriteChainStatsCSV(w io.Writer, stats []ChainStats) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"chain_id", "chain_name", "runs", "failures", "success_rate",
+		"p50_duration", "p95_duration", "last_failure", "last_failure_output"})
+	for _, s := range stats {
+		lastFailure := ""
+		if s.LastFailure != nil {
+			lastFailure = s.LastFailure.Format(time.RFC3339)
+		}
+		_ = cw.Write([]string{strconv.Itoa(s.ChainID), s.ChainName, strconv.Itoa(s.Runs), strconv.Itoa(s.Failures),
+			strconv.FormatFloat(s.SuccessRate, 'f', 4, 64), formatSeconds(s.P50Duration), formatSeconds(s.P95Duration),
+			lastFailure, s.LastFailureOutput})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatSeconds(seconds *float64) string {
+	if seconds == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*seconds, 'f', 3, 64)
+}
+
+// icalTime is the format of UTC date-times in iCalendar files
+const icalTime = "20060102T150405Z"
+
+// icalText escapes the value of the text property of the iCalendar file
+var icalText = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// WriteScheduleICal outputs the next count runs of every entry with the cron-style schedule as events of the
+// iCalendar file, so schedules may be viewed in calendar applications. Events last the duration of the chain,
+// e.g. the median one, rounded up to minutes, one minute if unknown. Interval, @reboot and on demand chains have
+// no fixed fire times, so they are omitted
+func WriteScheduleICal(w io.Writer, entries []ScheduleEntry, durations map[int]time.Duration, count int, now time.Time) error {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		// lines longer than 75 octets are folded with the leading space of continuation lines
+		s := fmt.Sprintf(format, args...)
+		for limit := 75; len(s) > limit; limit = 74 {
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(s[cut]) {
+				cut--
+			}
+			b.WriteString(s[:cut] + "\r\n ")
+			s = s[cut:]
+		}
+		b.WriteString(s + "\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//CYBERTEC PostgreSQL International GmbH//pg_timetable//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:pg_timetable")
+	for _, e := range entries {
+		if e.Schedule == "" || strings.HasPrefix(e.Schedule, "@") {
+			continue
+		}
+		schedule, err := cron.Parse(e.Schedule)
+		if err != nil {
+			continue // reported by the --check-config option
+		}
+		duration := durations[e.ChainID].Truncate(time.Minute)
+		if duration < durations[e.ChainID] || duration == 0 {
+			duration += time.Minute
+		}
+		client := e.ClientName
+		if client == "" {
+			client = "any client"
+		}
+		tasks := make([]string, 0, len(e.Tasks))
+		for _, task := range e.Tasks {
+			tasks = append(tasks, task.Kind+" "+shortCommand(task.Command))
+		}
+		description := fmt.Sprintf("Schedule: %s\nClient: %s\nTasks:\n%s", e.Schedule, client, strings.Join(tasks, "\n"))
+		for _, start := range schedule.NextN(now, count) {
+			line("BEGIN:VEVENT")
+			line("UID:chain-%d-%d@pg_timetable", e.ChainID, start.Unix())
+			line("DTSTAMP:%s", now.UTC().Format(icalTime))
+			line("DTSTART:%s", start.UTC().Format(icalTime))
+			line("DTEND:%s", start.Add(duration).UTC().Format(icalTime))
+			line("SUMMARY:%s", icalText.Replace(e.ChainName))
+			line("DESCRIPTION:%s", icalText.Replace(description))
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,101 @@
+package pgengine_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteScheduleReport(t *testing.T) {
+	next := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	var b bytes.Buffer
+	assert.NoError(t, pgengine.WriteScheduleReport(&b, []pgengine.ScheduleEntry{
+		{ChainID: 1, ChainName: "vacuum", Schedule: "0 3 * * *", NextRun: &next,
+			Tasks: []pgengine.ScheduleTask{{Kind: "SQL", Command: "VACUUM\n  ANALYZE"}}},
+		{ChainID: 2, ChainName: "backup", Schedule: "@every 6 hours", ClientName: "worker01",
+			Tasks: []pgengine.ScheduleTask{{Kind: "PROGRAM", Command: "pg_dump"}, {Kind: "SQL", Command: strings.Repeat("x", 100)}}},
+		{ChainID: 3, ChainName: "manual"},
+	}, next.Add(-time.Hour)))
+	assert.Equal(t, `# pg_timetable schedule of 3 live chains generated at 2026-10-17T02:00:00Z
+# SCHEDULE      CHAIN   CLIENT    NEXT RUN          TASKS
+0 3 * * *       vacuum  *         2026-10-17 03:00  SQL VACUUM ANALYZE
+@every 6 hours  backup  worker01  -                 PROGRAM pg_dump -> SQL `+strings.Repeat("x", 57)+`...
+-               manual  *         -                 
+`, b.String())
+}
+
+func TestWriteChainStatsCSV(t *testing.T) {
+	failed := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	p50, p95 := 1.5, 12.25
+	var b bytes.Buffer
+	assert.NoError(t, pgengine.WriteChainStatsCSV(&b, []pgengine.ChainStats{
+		{ChainID: 1, ChainName: "backup", Runs: 4, Failures: 1, SuccessRate: 0.75, P50Duration: &p50, P95Duration: &p95,
+			LastFailure: &failed, LastFailureOutput: "pg_dump: error: connection failed,\nretrying"},
+		{ChainID: 2, ChainName: "vacuum", Runs: 2, SuccessRate: 1},
+	}))
+	assert.Equal(t, `chain_id,chain_name,runs,failures,success_rate,p50_duration,p95_duration,last_failure,last_failure_output
+1,backup,4,1,0.7500,1.500,12.250,2026-10-17T03:00:00Z,"pg_dump: error: connection failed,
+retrying"
+2,vacuum,2,0,1.0000,,,,
+`, b.String())
+}
+
+func TestWriteScheduleICal(t *testing.T) {
+	now := time.Date(2026, 10, 17, 2, 30, 0, 0, time.UTC)
+	var b bytes.Buffer
+	assert.NoError(t, pgengine.WriteScheduleICal(&b, []pgengine.ScheduleEntry{
+		{ChainID: 1, ChainName: "vacuum; nightly", Schedule: "0 3 * * *",
+			Tasks: []pgengine.ScheduleTask{{Kind: "SQL", Command: "VACUUM ANALYZE"}}},
+		{ChainID: 2, ChainName: "backup", Schedule: "@every 6 hours"},
+		{ChainID: 3, ChainName: "manual"},
+		{ChainID: 4, ChainName: "report", Schedule: "*/30 * * * *", ClientName: "worker01",
+			Tasks: []pgengine.ScheduleTask{{Kind: "PROGRAM", Command: strings.Repeat("x", 50)}}},
+	}, map[int]time.Duration{1: 90 * time.Second}, 2, now))
+	assert.Equal(t, strings.ReplaceAll(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//CYBERTEC PostgreSQL International GmbH//pg_timetable//EN
+CALSCALE:GREGORIAN
+X-WR-CALNAME:pg_timetable
+BEGIN:VEVENT
+UID:chain-1-1792206000@pg_timetable
+DTSTAMP:20261017T023000Z
+DTSTART:20261017T030000Z
+DTEND:20261017T030200Z
+SUMMARY:vacuum\; nightly
+DESCRIPTION:Schedule: 0 3 * * *\nClient: any client\nTasks:\nSQL VACUUM ANA
+ LYZE
+END:VEVENT
+BEGIN:VEVENT
+UID:chain-1-1792292400@pg_timetable
+DTSTAMP:20261017T023000Z
+DTSTART:20261018T030000Z
+DTEND:20261018T030200Z
+SUMMARY:vacuum\; nightly
+DESCRIPTION:Schedule: 0 3 * * *\nClient: any client\nTasks:\nSQL VACUUM ANA
+ LYZE
+END:VEVENT
+BEGIN:VEVENT
+UID:chain-4-1792206000@pg_timetable
+DTSTAMP:20261017T023000Z
+DTSTART:20261017T030000Z
+DTEND:20261017T030100Z
+SUMMARY:report
+DESCRIPTION:Schedule: */30 * * * *\nClient: worker01\nTasks:\nPROGRAM xxxxx
+ xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx
+END:VEVENT
+BEGIN:VEVENT
+UID:chain-4-1792207800@pg_timetable
+DTSTAMP:20261017T023000Z
+DTSTART:20261017T033000Z
+DTEND:20261017T033100Z
+SUMMARY:report
+DESCRIPTION:Schedule: */30 * * * *\nClient: worker01\nTasks:\nPROGRAM xxxxx
+ xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n"), b.String())
+}
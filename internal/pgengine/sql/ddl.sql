@@ -21,7 +21,33 @@ VALUES
     (5, '00381 Rewrite active chain handling'),
     (6, '00394 Add started_at column to active_session and active_chain tables'),
     (7, '00417 Rename LOG database log level to INFO'),
-    (8, '00436 Add txid column to timetable.execution_log');
+    (8, '00436 Add txid column to timetable.execution_log'),
+    (9, '01354 Add timetable.notify_maintenance function'),
+    (10, '01366 Add timetable.notify_channel table'),
+    (11, '01377 Add log_level column to timetable.chain'),
+    (12, '01378 Add timetable.audit table'),
+    (13, '01383 Add output_gzip column to timetable.execution_log'),
+    (14, '01384 Add log_sampling column to timetable.chain'),
+    (15, '01387 Add timetable.chain_freshness view'),
+    (16, '01393 Add tags column to timetable.chain'),
+    (17, '01395 Add timetable.client_heartbeat table'),
+    (18, '01398 Add timetable.handoff_chain() function'),
+    (19, '01399 Add required_labels column to timetable.chain'),
+    (20, '01400 Add timetable.notify_drain() function'),
+    (21, '01401 Add timetable.cluster_status view'),
+    (22, '01404 Add sandbox column to timetable.task'),
+    (23, '01406 Add read_only column to timetable.chain'),
+    (24, '01407 Add operator column to timetable.audit'),
+    (25, '01409 Add env column to timetable.task'),
+    (26, '01411 Add container column to timetable.task'),
+    (27, '01412 Add timetable.api_audit table'),
+    (28, '01413 Add owner column and tenant policies to timetable.chain'),
+    (29, '01414 Add secret column to timetable.parameter'),
+    (30, '01417 Add applied column to timetable.chain'),
+    (31, '01438 Add timetable.notify_chain_change trigger function'),
+    (32, '01446 Add timetable.execution_log_archive table'),
+    (33, '01447 Start chains by their clients with timetable.notify_chain_start()'),
+    (34, '01448 Add last_started column to timetable.chain_status');
 
 CREATE DOMAIN timetable.cron AS TEXT CHECK(
     substr(VALUE, 1, 6) IN ('@every', '@after') AND (substr(VALUE, 7) :: INTERVAL) IS NOT NULL
@@ -40,7 +66,14 @@ CREATE TABLE timetable.chain (
     live                BOOLEAN     DEFAULT FALSE,
     self_destruct       BOOLEAN     DEFAULT FALSE,
     exclusive_execution BOOLEAN     DEFAULT FALSE,
-    client_name         TEXT
+    client_name         TEXT,
+    log_level           TEXT        CHECK (log_level IN ('debug', 'info', 'error')),
+    log_sampling        INTEGER     CHECK (log_sampling > 0),
+    tags                TEXT[],
+    required_labels     JSONB       CHECK (jsonb_typeof(required_labels) = 'object'),
+    read_only           BOOLEAN     DEFAULT FALSE,
+    owner               TEXT        NOT NULL DEFAULT current_user,
+    applied             BOOLEAN     NOT NULL DEFAULT FALSE
 );
 
 COMMENT ON TABLE timetable.chain IS
@@ -59,6 +92,20 @@ COMMENT ON COLUMN timetable.chain.exclusive_execution IS
     'All parallel chains should be paused while executing this chain';
 COMMENT ON COLUMN timetable.chain.client_name IS
     'Only client with this name is allowed to run this chain, set to NULL to allow any client';    
+COMMENT ON COLUMN timetable.chain.log_level IS
+    'Log level used for this chain instead of the client one, set to NULL to use the client log level';
+COMMENT ON COLUMN timetable.chain.log_sampling IS
+    'Log only every Nth successful run of the chain, failed runs are always logged, set to NULL to log every run';
+COMMENT ON COLUMN timetable.chain.tags IS
+    'Only clients selecting any of these tags with the --chain-tags option run this chain, set to NULL to run it on clients without tags';
+COMMENT ON COLUMN timetable.chain.required_labels IS
+    'Only clients having all these labels with the --client-labels option run this chain, e.g. {"os": "linux"}, set to NULL to run it on any client';
+COMMENT ON COLUMN timetable.chain.read_only IS
+    'Execute tasks of the chain in read-only transactions, so they cannot modify data';
+COMMENT ON COLUMN timetable.chain.owner IS
+    'Role owning the chain, the multi-tenant scheduler executes tasks of the chain as this role';
+COMMENT ON COLUMN timetable.chain.applied IS
+    'Chain is managed by the chain apply command, and is deleted by it with --prune if missing in the definition files';
 
 CREATE TYPE timetable.command_kind AS ENUM ('SQL', 'PROGRAM', 'BUILTIN');
 
@@ -73,7 +120,10 @@ CREATE TABLE timetable.task (
     database_connection TEXT,
     ignore_error        BOOLEAN                 NOT NULL DEFAULT FALSE,
     autonomous          BOOLEAN                 NOT NULL DEFAULT FALSE,
-    timeout             INTEGER                 DEFAULT 0
+    timeout             INTEGER                 DEFAULT 0,
+    sandbox             BOOLEAN                 NOT NULL DEFAULT FALSE,
+    env                 TEXT[],
+    container           JSONB
 );          
 
 COMMENT ON TABLE timetable.task IS
@@ -92,6 +142,12 @@ COMMENT ON COLUMN timetable.task.command IS
     'Contains either an SQL command, or command string to be executed';
 COMMENT ON COLUMN timetable.task.timeout IS
     'Abort any task within a chain that takes more than the specified number of milliseconds';
+COMMENT ON COLUMN timetable.task.sandbox IS
+    'Run PROGRAM command in the sandbox: empty temporary working directory, cleared environment and, on Linux, isolated namespaces';
+COMMENT ON COLUMN timetable.task.env IS
+    'Extra environment variables of PROGRAM command as NAME=value, added to the variables inherited from the scheduler';
+COMMENT ON COLUMN timetable.task.container IS
+    'Run PROGRAM command in the container: {"image": ..., "mounts": [...], "network": ..., "options": [...]}';
 
 -- parameter passing for a chain task
 CREATE TABLE timetable.parameter(
@@ -99,11 +155,14 @@ CREATE TABLE timetable.parameter(
                         ON UPDATE CASCADE ON DELETE CASCADE,
     order_id    INTEGER CHECK (order_id > 0),
     value       JSONB,
+    secret      BOOLEAN NOT NULL DEFAULT FALSE,
     PRIMARY KEY (task_id, order_id)
 );
 
 COMMENT ON TABLE timetable.parameter IS
     'Stores parameters passed as arguments to a chain task';
+COMMENT ON COLUMN timetable.parameter.secret IS
+    'Value is the encrypted:// reference decrypted at execution time, the decrypted value is masked in logs and task output';
 
 CREATE UNLOGGED TABLE timetable.active_session(
     client_pid  BIGINT  NOT NULL,
@@ -115,6 +174,14 @@ CREATE UNLOGGED TABLE timetable.active_session(
 COMMENT ON TABLE timetable.active_session IS
     'Stores information about active sessions';
 
+CREATE TABLE timetable.notify_channel (
+    client_name TEXT PRIMARY KEY,
+    channel     TEXT NOT NULL
+);
+
+COMMENT ON TABLE timetable.notify_channel IS
+    'Stores NOTIFY channels of clients listening on other channels than their names';
+
 CREATE TYPE timetable.log_type AS ENUM ('DEBUG', 'NOTICE', 'INFO', 'ERROR', 'PANIC', 'USER');
 
 CREATE OR REPLACE FUNCTION timetable.get_client_name(integer) RETURNS TEXT AS
@@ -147,11 +214,108 @@ CREATE TABLE timetable.execution_log (
     kind        timetable.command_kind,
     command     TEXT,
     output      TEXT,
-    client_name TEXT        NOT NULL
+    client_name TEXT        NOT NULL,
+    output_gzip BYTEA
 );
 
 COMMENT ON TABLE timetable.execution_log IS
     'Stores log entries of executed tasks and chains';
+COMMENT ON COLUMN timetable.execution_log.output_gzip IS
+    'Whole task output compressed with gzip if it exceeds the --output-limit and --output-policy=gzip is used';
+
+CREATE TABLE timetable.execution_log_archive (
+    archive_id  BIGSERIAL   PRIMARY KEY,
+    day         DATE        NOT NULL,
+    entries     INTEGER     NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    client_name TEXT        NOT NULL,
+    data        BYTEA       NOT NULL
+);
+
+CREATE INDEX ON timetable.execution_log_archive (day);
+
+COMMENT ON TABLE timetable.execution_log_archive IS
+    'Stores execution log entries older than --archive-days, entries of the day are kept as gzip compressed CSV';
+
+-- has_chain_access() returns true if the current user is a member of the role owning the chain
+CREATE OR REPLACE FUNCTION timetable.has_chain_access(chain_owner TEXT) RETURNS BOOLEAN AS $$
+    SELECT EXISTS(SELECT 1 FROM pg_catalog.pg_roles r WHERE r.rolname = chain_owner AND pg_has_role(r.oid, 'MEMBER'))
+$$ LANGUAGE SQL STABLE;
+
+-- policies restricting tenants to their own chains are in effect if row level security is enabled on the tables
+CREATE POLICY chain_tenant ON timetable.chain
+    USING (timetable.has_chain_access(owner));
+
+CREATE POLICY task_tenant ON timetable.task
+    USING (EXISTS(SELECT 1 FROM timetable.chain c WHERE c.chain_id = task.chain_id));
+
+CREATE POLICY parameter_tenant ON timetable.parameter
+    USING (EXISTS(SELECT 1 FROM timetable.task t WHERE t.task_id = parameter.task_id));
+
+CREATE POLICY execution_log_tenant ON timetable.execution_log
+    USING (EXISTS(SELECT 1 FROM timetable.chain c WHERE c.chain_id = execution_log.chain_id));
+
+CREATE TABLE timetable.chain_status (
+    chain_id        BIGINT      PRIMARY KEY REFERENCES timetable.chain(chain_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    last_success    TIMESTAMPTZ NOT NULL,
+    client_name     TEXT        NOT NULL,
+    last_started    TIMESTAMPTZ
+);
+
+COMMENT ON TABLE timetable.chain_status IS
+    'Stores the time of the last successful run of every chain';
+COMMENT ON COLUMN timetable.chain_status.last_started IS
+    'Start time of the last successful run, substituted for the $$previous_run_ts$$ macro of the next run';
+
+CREATE VIEW timetable.chain_freshness AS
+SELECT
+    c.chain_id,
+    c.chain_name,
+    s.last_success,
+    s.client_name,
+    EXTRACT(EPOCH FROM now() - s.last_success)::bigint AS seconds_since_success
+FROM timetable.chain c LEFT JOIN timetable.chain_status s USING (chain_id);
+
+COMMENT ON VIEW timetable.chain_freshness IS
+    'Shows seconds since the last successful run of every chain, NULL if the chain never succeeded';
+
+CREATE UNLOGGED TABLE timetable.client_heartbeat (
+    client_name         TEXT        PRIMARY KEY,
+    free_slots          INTEGER     NOT NULL,
+    reported_at         TIMESTAMPTZ NOT NULL,
+    prev_free_slots     INTEGER,
+    prev_reported_at    TIMESTAMPTZ,
+    valid_seconds       INTEGER     NOT NULL
+);
+
+COMMENT ON TABLE timetable.client_heartbeat IS
+    'Stores the number of free workers reported by clients with the --load-balancing option';
+
+CREATE VIEW timetable.client_capacity AS
+SELECT client_name, free_slots FROM (
+    SELECT
+        client_name,
+        CASE WHEN reported_at < date_trunc('minute', now()) THEN free_slots ELSE prev_free_slots END AS free_slots,
+        CASE WHEN reported_at < date_trunc('minute', now()) THEN reported_at ELSE prev_reported_at END AS reported_at,
+        valid_seconds
+    FROM timetable.client_heartbeat
+) h
+WHERE reported_at + valid_seconds * interval '1 second' > date_trunc('minute', now());
+
+COMMENT ON VIEW timetable.client_capacity IS
+    'Shows free workers of live clients as reported before the current minute, so all clients see the same values';
+
+CREATE OR REPLACE FUNCTION timetable.balanced_client(chain_id BIGINT) RETURNS TEXT AS $$
+    SELECT client_name
+    FROM timetable.client_capacity
+    WHERE free_slots > 0
+    -- weighted rendezvous hashing: the chain goes to the client with the highest score, scores grow with free slots
+    ORDER BY free_slots / -ln((hashtext(client_name || ':' || chain_id)::float8 + 2147483649) / 4294967297) DESC, client_name
+    LIMIT 1
+$$ LANGUAGE SQL STABLE;
+
+COMMENT ON FUNCTION timetable.balanced_client IS
+    'Return the client the chain without client name is assigned to according to free workers, NULL if all clients are busy';
 
 CREATE UNLOGGED TABLE timetable.active_chain(
     chain_id    BIGINT  NOT NULL,
@@ -162,6 +326,156 @@ CREATE UNLOGGED TABLE timetable.active_chain(
 COMMENT ON TABLE timetable.active_chain IS
     'Stores information about active chains within session';
 
+CREATE UNLOGGED TABLE timetable.client_status (
+    client_name         TEXT        PRIMARY KEY,
+    version             TEXT        NOT NULL,
+    reported_at         TIMESTAMPTZ NOT NULL,
+    valid_seconds       INTEGER     NOT NULL,
+    queued_chains       INTEGER     NOT NULL,
+    leader              BOOLEAN     NOT NULL,
+    maintenance         BOOLEAN     NOT NULL
+);
+
+COMMENT ON TABLE timetable.client_status IS
+    'Stores the version and the queue depth reported by clients every main loop iteration';
+
+CREATE VIEW timetable.cluster_status AS
+SELECT
+    s.client_name,
+    st.version,
+    s.started_at,
+    s.connections,
+    st.reported_at,
+    COALESCE(st.reported_at + st.valid_seconds * interval '1 second' > now(), FALSE) AS healthy,
+    (SELECT count(*) FROM timetable.active_chain ac WHERE ac.client_name = s.client_name) AS active_chains,
+    st.queued_chains,
+    st.leader,
+    st.maintenance
+FROM (
+    SELECT client_name, min(started_at) AS started_at, count(*) AS connections
+    FROM timetable.active_session
+    WHERE server_pid IN (SELECT pid FROM pg_catalog.pg_stat_activity WHERE application_name = 'pg_timetable')
+    GROUP BY client_name
+) s
+LEFT JOIN timetable.client_status st ON st.client_name = s.client_name;
+
+COMMENT ON VIEW timetable.cluster_status IS
+    'Shows connected clients with their versions, running chains and queue depths, healthy clients reported the status recently';
+
+CREATE TABLE timetable.audit (
+    audit_id    BIGSERIAL   PRIMARY KEY,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    changed_by  TEXT        NOT NULL DEFAULT session_user,
+    client_name TEXT        DEFAULT timetable.get_client_name(pg_backend_pid()),
+    table_name  TEXT        NOT NULL,
+    operation   TEXT        NOT NULL,
+    chain_id    BIGINT,
+    task_id     BIGINT,
+    old_data    JSONB,
+    new_data    JSONB,
+    operator    TEXT        DEFAULT NULLIF(current_setting('pg_timetable.operator', true), '')
+);
+
+CREATE INDEX ON timetable.audit (chain_id, changed_at);
+
+COMMENT ON TABLE timetable.audit IS
+    'Stores changes of chains, tasks and parameters';
+COMMENT ON COLUMN timetable.audit.changed_by IS
+    'Database user made the change';
+COMMENT ON COLUMN timetable.audit.client_name IS
+    'Name of the pg_timetable client made the change, e.g. importing the chain with REST API, NULL for other sessions';
+COMMENT ON COLUMN timetable.audit.operator IS
+    'Identity of the client certificate of the REST or gRPC API request made the change, NULL for other changes';
+
+-- audit_change() stores the changed row of chain, task or parameter table in the timetable.audit
+CREATE OR REPLACE FUNCTION timetable.audit_change() RETURNS trigger AS $$
+DECLARE
+    old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+    new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+    changed_row JSONB := COALESCE(new_row, old_row);
+BEGIN
+    IF old_row = new_row THEN
+        RETURN NULL;
+    END IF;
+    INSERT INTO timetable.audit (table_name, operation, chain_id, task_id, old_data, new_data)
+    VALUES (TG_TABLE_NAME, TG_OP,
+        COALESCE((changed_row->>'chain_id')::bigint,
+            (SELECT chain_id FROM timetable.task WHERE task_id = (changed_row->>'task_id')::bigint)),
+        (changed_row->>'task_id')::bigint,
+        old_row,
+        new_row);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_chain AFTER INSERT OR UPDATE OR DELETE ON timetable.chain
+    FOR EACH ROW EXECUTE PROCEDURE timetable.audit_change();
+
+CREATE TRIGGER audit_task AFTER INSERT OR UPDATE OR DELETE ON timetable.task
+    FOR EACH ROW EXECUTE PROCEDURE timetable.audit_change();
+
+CREATE TRIGGER audit_parameter AFTER INSERT OR UPDATE OR DELETE ON timetable.parameter
+    FOR EACH ROW EXECUTE PROCEDURE timetable.audit_change();
+
+-- notify_chain_change() notifies active workers about the changed chain, task or parameter,
+-- so they invalidate cached tasks of the chain and reload scheduled chains
+CREATE OR REPLACE FUNCTION timetable.notify_chain_change() RETURNS trigger AS $$
+DECLARE
+    old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+    new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+BEGIN
+    IF old_row = new_row THEN
+        RETURN NULL;
+    END IF;
+    PERFORM pg_notify(
+        timetable.get_notify_channel(s.client_name),
+        format('{"ConfigID": %s, "Command": "CHANGED", "Ts": %s}',
+            c.chain_id,
+            EXTRACT(epoch FROM transaction_timestamp())::bigint)
+        )
+    FROM (SELECT DISTINCT client_name FROM timetable.active_session) s,
+        (SELECT DISTINCT COALESCE((r->>'chain_id')::bigint,
+            (SELECT chain_id FROM timetable.task WHERE task_id = (r->>'task_id')::bigint)) AS chain_id
+        FROM unnest(ARRAY[old_row, new_row]) r) c
+    WHERE c.chain_id IS NOT NULL;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_chain_change AFTER INSERT OR UPDATE OR DELETE ON timetable.chain
+    FOR EACH ROW EXECUTE PROCEDURE timetable.notify_chain_change();
+
+CREATE TRIGGER notify_chain_change AFTER INSERT OR UPDATE OR DELETE ON timetable.task
+    FOR EACH ROW EXECUTE PROCEDURE timetable.notify_chain_change();
+
+CREATE TRIGGER notify_chain_change AFTER INSERT OR UPDATE OR DELETE ON timetable.parameter
+    FOR EACH ROW EXECUTE PROCEDURE timetable.notify_chain_change();
+
+CREATE TABLE timetable.api_audit (
+    api_audit_id BIGSERIAL   PRIMARY KEY,
+    called_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    client_name  TEXT        NOT NULL,
+    api          TEXT        NOT NULL,
+    operation    TEXT        NOT NULL,
+    chain_id     BIGINT,
+    operator     TEXT,
+    remote_addr  TEXT,
+    status       TEXT        NOT NULL
+);
+
+CREATE INDEX ON timetable.api_audit (called_at);
+
+COMMENT ON TABLE timetable.api_audit IS
+    'Stores manual operations, e.g. starting and stopping chains or maintenance mode switches, performed through the REST or gRPC API';
+COMMENT ON COLUMN timetable.api_audit.client_name IS
+    'Name of the pg_timetable client served the request';
+COMMENT ON COLUMN timetable.api_audit.operation IS
+    'HTTP method and URI of the REST API request or the full method name of the gRPC call';
+COMMENT ON COLUMN timetable.api_audit.operator IS
+    'Identity of the client certificate of the request, NULL if client certificates are not used';
+COMMENT ON COLUMN timetable.api_audit.status IS
+    'HTTP status code of the REST API response or the gRPC status code';
+
 CREATE OR REPLACE FUNCTION timetable.try_lock_client_name(worker_pid BIGINT, worker_name TEXT)
 RETURNS bool AS
 $CODE$
@@ -47,20 +47,36 @@ $$ LANGUAGE SQL;
 
 COMMENT ON FUNCTION timetable.add_job IS 'Add one-task chain (aka job) to the system';
 
--- notify_chain_start() will send notification to the worker to start the chain
+-- get_notify_channel() returns the channel the worker listens on for notifications
+CREATE OR REPLACE FUNCTION timetable.get_notify_channel(worker_name TEXT) RETURNS TEXT AS $$
+    SELECT COALESCE((SELECT channel FROM timetable.notify_channel WHERE client_name = worker_name), worker_name)
+$$ LANGUAGE SQL STABLE;
+
+COMMENT ON FUNCTION timetable.get_notify_channel IS 'Return the channel the worker listens on for notifications';
+
+-- notify_chain_start() will send notification to the worker to start the chain, the chain is started by the client
+-- it is bound to if the worker is omitted, the notification is sent to the pgtt_<client_name> channel of the client then
 CREATE OR REPLACE FUNCTION timetable.notify_chain_start(
     chain_id BIGINT, 
-    worker_name TEXT
+    worker_name TEXT DEFAULT NULL
 ) RETURNS void AS $$
-    SELECT pg_notify(
-        worker_name, 
+DECLARE
+    v_channel TEXT := CASE WHEN worker_name IS NOT NULL THEN timetable.get_notify_channel(worker_name)
+        ELSE 'pgtt_' || (SELECT c.client_name FROM timetable.chain c WHERE c.chain_id = notify_chain_start.chain_id) END;
+BEGIN
+    IF v_channel IS NULL THEN
+        RAISE EXCEPTION 'chain % is not bound to a client, the worker name must be specified', chain_id;
+    END IF;
+    PERFORM pg_notify(
+        v_channel,
         format('{"ConfigID": %s, "Command": "START", "Ts": %s}', 
         chain_id, 
         EXTRACT(epoch FROM clock_timestamp())::bigint)
-    )
-$$ LANGUAGE SQL;
+    );
+END;
+$$ LANGUAGE plpgsql;
 
-COMMENT ON FUNCTION timetable.notify_chain_start IS 'Send notification to the worker to start the chain';
+COMMENT ON FUNCTION timetable.notify_chain_start IS 'Send notification to the worker or to the client of the chain to start it';
 
 -- notify_chain_stop() will send notification to the worker to stop the chain
 CREATE OR REPLACE FUNCTION timetable.notify_chain_stop(
@@ -68,7 +84,7 @@ CREATE OR REPLACE FUNCTION timetable.notify_chain_stop(
     worker_name TEXT
 ) RETURNS void AS  $$ 
     SELECT pg_notify(
-        worker_name, 
+        timetable.get_notify_channel(worker_name),
         format('{"ConfigID": %s, "Command": "STOP", "Ts": %s}', 
             chain_id, 
             EXTRACT(epoch FROM clock_timestamp())::bigint)
@@ -77,6 +93,59 @@ $$ LANGUAGE SQL;
 
 COMMENT ON FUNCTION timetable.notify_chain_stop IS 'Send notification to the worker to stop the chain';
 
+-- notify_maintenance() will send notification to the worker to switch the maintenance mode
+CREATE OR REPLACE FUNCTION timetable.notify_maintenance(
+    enabled BOOLEAN,
+    worker_name TEXT
+) RETURNS void AS $$
+    SELECT pg_notify(
+        timetable.get_notify_channel(worker_name),
+        format('{"ConfigID": 0, "Command": "%s", "Ts": %s}',
+            CASE WHEN enabled THEN 'MAINTENANCE_ON' ELSE 'MAINTENANCE_OFF' END,
+            EXTRACT(epoch FROM clock_timestamp())::bigint)
+        )
+$$ LANGUAGE SQL;
+
+COMMENT ON FUNCTION timetable.notify_maintenance IS 'Send notification to the worker to turn the maintenance mode on or off';
+
+-- notify_drain() will send notification to the worker to finish running chains and stop, so another instance
+-- with the same name takes over
+CREATE OR REPLACE FUNCTION timetable.notify_drain(
+    worker_name TEXT
+) RETURNS void AS $$
+    SELECT pg_notify(
+        timetable.get_notify_channel(worker_name),
+        format('{"ConfigID": 0, "Command": "DRAIN", "Ts": %s}',
+            EXTRACT(epoch FROM clock_timestamp())::bigint)
+        )
+$$ LANGUAGE SQL;
+
+COMMENT ON FUNCTION timetable.notify_drain IS 'Send notification to the worker to finish running chains and stop';
+
+-- handoff_chain() will reassign the chain to the worker and notify active workers, so the previous owner
+-- stops rescheduling the chain after the running instance is finished and the new owner picks it up
+CREATE OR REPLACE FUNCTION timetable.handoff_chain(
+    chain_id BIGINT,
+    worker_name TEXT
+) RETURNS void AS $$
+BEGIN
+    UPDATE timetable.chain SET client_name = worker_name WHERE chain.chain_id = handoff_chain.chain_id;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'chain % does not exist', chain_id;
+    END IF;
+    PERFORM pg_notify(
+        timetable.get_notify_channel(w.client_name),
+        format('{"ConfigID": %s, "Command": "HANDOFF", "Ts": %s}',
+            chain_id,
+            EXTRACT(epoch FROM clock_timestamp())::bigint)
+        )
+    FROM (SELECT client_name FROM timetable.active_session UNION SELECT worker_name) w
+    WHERE w.client_name IS NOT NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON FUNCTION timetable.handoff_chain IS 'Reassign the chain to the worker, the running instance is finished by the previous owner';
+
 -- move_task_up() will switch the order of the task execution with a previous task within the chain
 CREATE OR REPLACE FUNCTION timetable.move_task_up(IN task_id BIGINT) RETURNS boolean AS $$
 	WITH current_task (ct_chain_id, ct_id, ct_order) AS (
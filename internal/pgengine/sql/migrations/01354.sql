@@ -0,0 +1,14 @@
+-- notify_maintenance() will send notification to the worker to switch the maintenance mode
+CREATE OR REPLACE FUNCTION timetable.notify_maintenance(
+    enabled BOOLEAN,
+    worker_name TEXT
+) RETURNS void AS $$
+    SELECT pg_notify(
+        worker_name,
+        format('{"ConfigID": 0, "Command": "%s", "Ts": %s}',
+            CASE WHEN enabled THEN 'MAINTENANCE_ON' ELSE 'MAINTENANCE_OFF' END,
+            EXTRACT(epoch FROM clock_timestamp())::bigint)
+        )
+$$ LANGUAGE SQL;
+
+COMMENT ON FUNCTION timetable.notify_maintenance IS 'Send notification to the worker to turn the maintenance mode on or off';
@@ -0,0 +1,59 @@
+CREATE TABLE timetable.notify_channel (
+    client_name TEXT PRIMARY KEY,
+    channel     TEXT NOT NULL
+);
+
+COMMENT ON TABLE timetable.notify_channel IS
+    'Stores NOTIFY channels of clients listening on other channels than their names';
+
+-- get_notify_channel() returns the channel the worker listens on for notifications
+CREATE OR REPLACE FUNCTION timetable.get_notify_channel(worker_name TEXT) RETURNS TEXT AS $$
+    SELECT COALESCE((SELECT channel FROM timetable.notify_channel WHERE client_name = worker_name), worker_name)
+$$ LANGUAGE SQL STABLE;
+
+COMMENT ON FUNCTION timetable.get_notify_channel IS 'Return the channel the worker listens on for notifications';
+
+-- notify_chain_start() will send notification to the worker to start the chain
+CREATE OR REPLACE FUNCTION timetable.notify_chain_start(
+    chain_id BIGINT, 
+    worker_name TEXT
+) RETURNS void AS $$
+    SELECT pg_notify(
+        timetable.get_notify_channel(worker_name),
+        format('{"ConfigID": %s, "Command": "START", "Ts": %s}', 
+        chain_id, 
+        EXTRACT(epoch FROM clock_timestamp())::bigint)
+    )
+$$ LANGUAGE SQL;
+
+COMMENT ON FUNCTION timetable.notify_chain_start IS 'Send notification to the worker to start the chain';
+
+-- notify_chain_stop() will send notification to the worker to stop the chain
+CREATE OR REPLACE FUNCTION timetable.notify_chain_stop(
+    chain_id BIGINT, 
+    worker_name TEXT
+) RETURNS void AS  $$ 
+    SELECT pg_notify(
+        timetable.get_notify_channel(worker_name),
+        format('{"ConfigID": %s, "Command": "STOP", "Ts": %s}', 
+            chain_id, 
+            EXTRACT(epoch FROM clock_timestamp())::bigint)
+        )
+$$ LANGUAGE SQL;
+
+COMMENT ON FUNCTION timetable.notify_chain_stop IS 'Send notification to the worker to stop the chain';
+
+-- notify_maintenance() will send notification to the worker to switch the maintenance mode
+CREATE OR REPLACE FUNCTION timetable.notify_maintenance(
+    enabled BOOLEAN,
+    worker_name TEXT
+) RETURNS void AS $$
+    SELECT pg_notify(
+        timetable.get_notify_channel(worker_name),
+        format('{"ConfigID": 0, "Command": "%s", "Ts": %s}',
+            CASE WHEN enabled THEN 'MAINTENANCE_ON' ELSE 'MAINTENANCE_OFF' END,
+            EXTRACT(epoch FROM clock_timestamp())::bigint)
+        )
+$$ LANGUAGE SQL;
+
+COMMENT ON FUNCTION timetable.notify_maintenance IS 'Send notification to the worker to turn the maintenance mode on or off';
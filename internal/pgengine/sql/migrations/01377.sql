@@ -0,0 +1,5 @@
+ALTER TABLE timetable.chain
+    ADD COLUMN log_level TEXT CHECK (log_level IN ('debug', 'info', 'error'));
+
+COMMENT ON COLUMN timetable.chain.log_level IS
+    'Log level used for this chain instead of the client one, set to NULL to use the client log level';
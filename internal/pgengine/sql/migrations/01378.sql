@@ -0,0 +1,51 @@
+CREATE TABLE timetable.audit (
+    audit_id    BIGSERIAL   PRIMARY KEY,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    changed_by  TEXT        NOT NULL DEFAULT session_user,
+    client_name TEXT        DEFAULT timetable.get_client_name(pg_backend_pid()),
+    table_name  TEXT        NOT NULL,
+    operation   TEXT        NOT NULL,
+    chain_id    BIGINT,
+    task_id     BIGINT,
+    old_data    JSONB,
+    new_data    JSONB
+);
+
+CREATE INDEX ON timetable.audit (chain_id, changed_at);
+
+COMMENT ON TABLE timetable.audit IS
+    'Stores changes of chains, tasks and parameters';
+COMMENT ON COLUMN timetable.audit.changed_by IS
+    'Database user made the change';
+COMMENT ON COLUMN timetable.audit.client_name IS
+    'Name of the pg_timetable client made the change, e.g. importing the chain with REST API, NULL for other sessions';
+
+-- audit_change() stores the changed row of chain, task or parameter table in the timetable.audit
+CREATE OR REPLACE FUNCTION timetable.audit_change() RETURNS trigger AS $$
+DECLARE
+    old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+    new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+    changed_row JSONB := COALESCE(new_row, old_row);
+BEGIN
+    IF old_row = new_row THEN
+        RETURN NULL;
+    END IF;
+    INSERT INTO timetable.audit (table_name, operation, chain_id, task_id, old_data, new_data)
+    VALUES (TG_TABLE_NAME, TG_OP,
+        COALESCE((changed_row->>'chain_id')::bigint,
+            (SELECT chain_id FROM timetable.task WHERE task_id = (changed_row->>'task_id')::bigint)),
+        (changed_row->>'task_id')::bigint,
+        old_row,
+        new_row);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_chain AFTER INSERT OR UPDATE OR DELETE ON timetable.chain
+    FOR EACH ROW EXECUTE PROCEDURE timetable.audit_change();
+
+CREATE TRIGGER audit_task AFTER INSERT OR UPDATE OR DELETE ON timetable.task
+    FOR EACH ROW EXECUTE PROCEDURE timetable.audit_change();
+
+CREATE TRIGGER audit_parameter AFTER INSERT OR UPDATE OR DELETE ON timetable.parameter
+    FOR EACH ROW EXECUTE PROCEDURE timetable.audit_change();
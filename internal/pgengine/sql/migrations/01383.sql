@@ -0,0 +1,5 @@
+ALTER TABLE timetable.execution_log
+    ADD COLUMN output_gzip BYTEA;
+
+COMMENT ON COLUMN timetable.execution_log.output_gzip IS
+    'Whole task output compressed with gzip if it exceeds the --output-limit and --output-policy=gzip is used';
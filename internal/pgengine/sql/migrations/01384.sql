@@ -0,0 +1,5 @@
+ALTER TABLE timetable.chain
+    ADD COLUMN log_sampling INTEGER CHECK (log_sampling > 0);
+
+COMMENT ON COLUMN timetable.chain.log_sampling IS
+    'Log only every Nth successful run of the chain, failed runs are always logged, set to NULL to log every run';
@@ -0,0 +1,20 @@
+CREATE TABLE timetable.chain_status (
+    chain_id        BIGINT      PRIMARY KEY REFERENCES timetable.chain(chain_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    last_success    TIMESTAMPTZ NOT NULL,
+    client_name     TEXT        NOT NULL
+);
+
+COMMENT ON TABLE timetable.chain_status IS
+    'Stores the time of the last successful run of every chain';
+
+CREATE VIEW timetable.chain_freshness AS
+SELECT
+    c.chain_id,
+    c.chain_name,
+    s.last_success,
+    s.client_name,
+    EXTRACT(EPOCH FROM now() - s.last_success)::bigint AS seconds_since_success
+FROM timetable.chain c LEFT JOIN timetable.chain_status s USING (chain_id);
+
+COMMENT ON VIEW timetable.chain_freshness IS
+    'Shows seconds since the last successful run of every chain, NULL if the chain never succeeded';
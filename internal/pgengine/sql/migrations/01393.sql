@@ -0,0 +1,5 @@
+ALTER TABLE timetable.chain
+    ADD COLUMN tags TEXT[];
+
+COMMENT ON COLUMN timetable.chain.tags IS
+    'Only clients selecting any of these tags with the --chain-tags option run this chain, set to NULL to run it on clients without tags';
@@ -0,0 +1,37 @@
+CREATE UNLOGGED TABLE timetable.client_heartbeat (
+    client_name         TEXT        PRIMARY KEY,
+    free_slots          INTEGER     NOT NULL,
+    reported_at         TIMESTAMPTZ NOT NULL,
+    prev_free_slots     INTEGER,
+    prev_reported_at    TIMESTAMPTZ,
+    valid_seconds       INTEGER     NOT NULL
+);
+
+COMMENT ON TABLE timetable.client_heartbeat IS
+    'Stores the number of free workers reported by clients with the --load-balancing option';
+
+CREATE VIEW timetable.client_capacity AS
+SELECT client_name, free_slots FROM (
+    SELECT
+        client_name,
+        CASE WHEN reported_at < date_trunc('minute', now()) THEN free_slots ELSE prev_free_slots END AS free_slots,
+        CASE WHEN reported_at < date_trunc('minute', now()) THEN reported_at ELSE prev_reported_at END AS reported_at,
+        valid_seconds
+    FROM timetable.client_heartbeat
+) h
+WHERE reported_at + valid_seconds * interval '1 second' > date_trunc('minute', now());
+
+COMMENT ON VIEW timetable.client_capacity IS
+    'Shows free workers of live clients as reported before the current minute, so all clients see the same values';
+
+CREATE OR REPLACE FUNCTION timetable.balanced_client(chain_id BIGINT) RETURNS TEXT AS $$
+    SELECT client_name
+    FROM timetable.client_capacity
+    WHERE free_slots > 0
+    -- weighted rendezvous hashing: the chain goes to the client with the highest score, scores grow with free slots
+    ORDER BY free_slots / -ln((hashtext(client_name || ':' || chain_id)::float8 + 2147483649) / 4294967297) DESC, client_name
+    LIMIT 1
+$$ LANGUAGE SQL STABLE;
+
+COMMENT ON FUNCTION timetable.balanced_client IS
+    'Return the client the chain without client name is assigned to according to free workers, NULL if all clients are busy';
@@ -0,0 +1,23 @@
+-- handoff_chain() will reassign the chain to the worker and notify active workers, so the previous owner
+-- stops rescheduling the chain after the running instance is finished and the new owner picks it up
+CREATE OR REPLACE FUNCTION timetable.handoff_chain(
+    chain_id BIGINT,
+    worker_name TEXT
+) RETURNS void AS $$
+BEGIN
+    UPDATE timetable.chain SET client_name = worker_name WHERE chain.chain_id = handoff_chain.chain_id;
+    IF NOT FOUND THEN
+        RAISE EXCEPTION 'chain % does not exist', chain_id;
+    END IF;
+    PERFORM pg_notify(
+        timetable.get_notify_channel(w.client_name),
+        format('{"ConfigID": %s, "Command": "HANDOFF", "Ts": %s}',
+            chain_id,
+            EXTRACT(epoch FROM clock_timestamp())::bigint)
+        )
+    FROM (SELECT client_name FROM timetable.active_session UNION SELECT worker_name) w
+    WHERE w.client_name IS NOT NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON FUNCTION timetable.handoff_chain IS 'Reassign the chain to the worker, the running instance is finished by the previous owner';
@@ -0,0 +1,5 @@
+ALTER TABLE timetable.chain
+    ADD COLUMN required_labels JSONB CHECK (jsonb_typeof(required_labels) = 'object');
+
+COMMENT ON COLUMN timetable.chain.required_labels IS
+    'Only clients having all these labels with the --client-labels option run this chain, e.g. {"os": "linux"}, set to NULL to run it on any client';
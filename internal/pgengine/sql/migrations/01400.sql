@@ -0,0 +1,13 @@
+-- notify_drain() will send notification to the worker to finish running chains and stop, so another instance
+-- with the same name takes over
+CREATE OR REPLACE FUNCTION timetable.notify_drain(
+    worker_name TEXT
+) RETURNS void AS $$
+    SELECT pg_notify(
+        timetable.get_notify_channel(worker_name),
+        format('{"ConfigID": 0, "Command": "DRAIN", "Ts": %s}',
+            EXTRACT(epoch FROM clock_timestamp())::bigint)
+        )
+$$ LANGUAGE SQL;
+
+COMMENT ON FUNCTION timetable.notify_drain IS 'Send notification to the worker to finish running chains and stop';
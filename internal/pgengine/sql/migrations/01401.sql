@@ -0,0 +1,35 @@
+CREATE UNLOGGED TABLE timetable.client_status (
+    client_name         TEXT        PRIMARY KEY,
+    version             TEXT        NOT NULL,
+    reported_at         TIMESTAMPTZ NOT NULL,
+    valid_seconds       INTEGER     NOT NULL,
+    queued_chains       INTEGER     NOT NULL,
+    leader              BOOLEAN     NOT NULL,
+    maintenance         BOOLEAN     NOT NULL
+);
+
+COMMENT ON TABLE timetable.client_status IS
+    'Stores the version and the queue depth reported by clients every main loop iteration';
+
+CREATE VIEW timetable.cluster_status AS
+SELECT
+    s.client_name,
+    st.version,
+    s.started_at,
+    s.connections,
+    st.reported_at,
+    COALESCE(st.reported_at + st.valid_seconds * interval '1 second' > now(), FALSE) AS healthy,
+    (SELECT count(*) FROM timetable.active_chain ac WHERE ac.client_name = s.client_name) AS active_chains,
+    st.queued_chains,
+    st.leader,
+    st.maintenance
+FROM (
+    SELECT client_name, min(started_at) AS started_at, count(*) AS connections
+    FROM timetable.active_session
+    WHERE server_pid IN (SELECT pid FROM pg_catalog.pg_stat_activity WHERE application_name = 'pg_timetable')
+    GROUP BY client_name
+) s
+LEFT JOIN timetable.client_status st ON st.client_name = s.client_name;
+
+COMMENT ON VIEW timetable.cluster_status IS
+    'Shows connected clients with their versions, running chains and queue depths, healthy clients reported the status recently';
@@ -0,0 +1,5 @@
+ALTER TABLE timetable.task
+    ADD COLUMN sandbox BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN timetable.task.sandbox IS
+    'Run PROGRAM command in the sandbox: empty temporary working directory, cleared environment and, on Linux, isolated namespaces';
@@ -0,0 +1,5 @@
+ALTER TABLE timetable.chain
+    ADD COLUMN read_only BOOLEAN DEFAULT FALSE;
+
+COMMENT ON COLUMN timetable.chain.read_only IS
+    'Execute tasks of the chain in read-only transactions, so they cannot modify data';
@@ -0,0 +1,5 @@
+ALTER TABLE timetable.audit
+    ADD COLUMN operator TEXT DEFAULT NULLIF(current_setting('pg_timetable.operator', true), '');
+
+COMMENT ON COLUMN timetable.audit.operator IS
+    'Identity of the client certificate of the REST or gRPC API request made the change, NULL for other changes';
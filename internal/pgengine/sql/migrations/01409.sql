@@ -0,0 +1,5 @@
+ALTER TABLE timetable.task
+    ADD COLUMN env TEXT[];
+
+COMMENT ON COLUMN timetable.task.env IS
+    'Extra environment variables of PROGRAM command as NAME=value, added to the variables inherited from the scheduler';
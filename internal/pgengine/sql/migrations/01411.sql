@@ -0,0 +1,5 @@
+ALTER TABLE timetable.task
+    ADD COLUMN container JSONB;
+
+COMMENT ON COLUMN timetable.task.container IS
+    'Run PROGRAM command in the container: {"image": ..., "mounts": [...], "network": ..., "options": [...]}';
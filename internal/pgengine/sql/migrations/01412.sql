@@ -0,0 +1,24 @@
+CREATE TABLE timetable.api_audit (
+    api_audit_id BIGSERIAL   PRIMARY KEY,
+    called_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    client_name  TEXT        NOT NULL,
+    api          TEXT        NOT NULL,
+    operation    TEXT        NOT NULL,
+    chain_id     BIGINT,
+    operator     TEXT,
+    remote_addr  TEXT,
+    status       TEXT        NOT NULL
+);
+
+CREATE INDEX ON timetable.api_audit (called_at);
+
+COMMENT ON TABLE timetable.api_audit IS
+    'Stores manual operations, e.g. starting and stopping chains or maintenance mode switches, performed through the REST or gRPC API';
+COMMENT ON COLUMN timetable.api_audit.client_name IS
+    'Name of the pg_timetable client served the request';
+COMMENT ON COLUMN timetable.api_audit.operation IS
+    'HTTP method and URI of the REST API request or the full method name of the gRPC call';
+COMMENT ON COLUMN timetable.api_audit.operator IS
+    'Identity of the client certificate of the request, NULL if client certificates are not used';
+COMMENT ON COLUMN timetable.api_audit.status IS
+    'HTTP status code of the REST API response or the gRPC status code';
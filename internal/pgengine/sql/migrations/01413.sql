@@ -0,0 +1,23 @@
+ALTER TABLE timetable.chain
+    ADD COLUMN owner TEXT NOT NULL DEFAULT current_user;
+
+COMMENT ON COLUMN timetable.chain.owner IS
+    'Role owning the chain, the multi-tenant scheduler executes tasks of the chain as this role';
+
+-- has_chain_access() returns true if the current user is a member of the role owning the chain
+CREATE OR REPLACE FUNCTION timetable.has_chain_access(chain_owner TEXT) RETURNS BOOLEAN AS $$
+    SELECT EXISTS(SELECT 1 FROM pg_catalog.pg_roles r WHERE r.rolname = chain_owner AND pg_has_role(r.oid, 'MEMBER'))
+$$ LANGUAGE SQL STABLE;
+
+-- policies restricting tenants to their own chains are in effect if row level security is enabled on the tables
+CREATE POLICY chain_tenant ON timetable.chain
+    USING (timetable.has_chain_access(owner));
+
+CREATE POLICY task_tenant ON timetable.task
+    USING (EXISTS(SELECT 1 FROM timetable.chain c WHERE c.chain_id = task.chain_id));
+
+CREATE POLICY parameter_tenant ON timetable.parameter
+    USING (EXISTS(SELECT 1 FROM timetable.task t WHERE t.task_id = parameter.task_id));
+
+CREATE POLICY execution_log_tenant ON timetable.execution_log
+    USING (EXISTS(SELECT 1 FROM timetable.chain c WHERE c.chain_id = execution_log.chain_id));
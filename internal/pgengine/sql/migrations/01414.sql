@@ -0,0 +1,5 @@
+ALTER TABLE timetable.parameter
+    ADD COLUMN secret BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN timetable.parameter.secret IS
+    'Value is the encrypted:// reference decrypted at execution time, the decrypted value is masked in logs and task output';
@@ -0,0 +1,5 @@
+ALTER TABLE timetable.chain
+    ADD COLUMN applied BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN timetable.chain.applied IS
+    'Chain is managed by the chain apply command, and is deleted by it with --prune if missing in the definition files';
@@ -0,0 +1,33 @@
+-- notify_chain_change() notifies active workers about the changed chain, task or parameter,
+-- so they invalidate cached tasks of the chain and reload scheduled chains
+CREATE OR REPLACE FUNCTION timetable.notify_chain_change() RETURNS trigger AS $$
+DECLARE
+    old_row JSONB := CASE WHEN TG_OP <> 'INSERT' THEN to_jsonb(OLD) END;
+    new_row JSONB := CASE WHEN TG_OP <> 'DELETE' THEN to_jsonb(NEW) END;
+BEGIN
+    IF old_row = new_row THEN
+        RETURN NULL;
+    END IF;
+    PERFORM pg_notify(
+        timetable.get_notify_channel(s.client_name),
+        format('{"ConfigID": %s, "Command": "CHANGED", "Ts": %s}',
+            c.chain_id,
+            EXTRACT(epoch FROM transaction_timestamp())::bigint)
+        )
+    FROM (SELECT DISTINCT client_name FROM timetable.active_session) s,
+        (SELECT DISTINCT COALESCE((r->>'chain_id')::bigint,
+            (SELECT chain_id FROM timetable.task WHERE task_id = (r->>'task_id')::bigint)) AS chain_id
+        FROM unnest(ARRAY[old_row, new_row]) r) c
+    WHERE c.chain_id IS NOT NULL;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notify_chain_change AFTER INSERT OR UPDATE OR DELETE ON timetable.chain
+    FOR EACH ROW EXECUTE PROCEDURE timetable.notify_chain_change();
+
+CREATE TRIGGER notify_chain_change AFTER INSERT OR UPDATE OR DELETE ON timetable.task
+    FOR EACH ROW EXECUTE PROCEDURE timetable.notify_chain_change();
+
+CREATE TRIGGER notify_chain_change AFTER INSERT OR UPDATE OR DELETE ON timetable.parameter
+    FOR EACH ROW EXECUTE PROCEDURE timetable.notify_chain_change();
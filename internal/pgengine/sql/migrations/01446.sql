@@ -0,0 +1,13 @@
+CREATE TABLE timetable.execution_log_archive (
+    archive_id  BIGSERIAL   PRIMARY KEY,
+    day         DATE        NOT NULL,
+    entries     INTEGER     NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    client_name TEXT        NOT NULL,
+    data        BYTEA       NOT NULL
+);
+
+CREATE INDEX ON timetable.execution_log_archive (day);
+
+COMMENT ON TABLE timetable.execution_log_archive IS
+    'Stores execution log entries older than --archive-days, entries of the day are kept as gzip compressed CSV';
@@ -0,0 +1,23 @@
+-- notify_chain_start() will send notification to the worker to start the chain, the chain is started by the client
+-- it is bound to if the worker is omitted, the notification is sent to the pgtt_<client_name> channel of the client then
+CREATE OR REPLACE FUNCTION timetable.notify_chain_start(
+    chain_id BIGINT, 
+    worker_name TEXT DEFAULT NULL
+) RETURNS void AS $$
+DECLARE
+    v_channel TEXT := CASE WHEN worker_name IS NOT NULL THEN timetable.get_notify_channel(worker_name)
+        ELSE 'pgtt_' || (SELECT c.client_name FROM timetable.chain c WHERE c.chain_id = notify_chain_start.chain_id) END;
+BEGIN
+    IF v_channel IS NULL THEN
+        RAISE EXCEPTION 'chain % is not bound to a client, the worker name must be specified', chain_id;
+    END IF;
+    PERFORM pg_notify(
+        v_channel,
+        format('{"ConfigID": %s, "Command": "START", "Ts": %s}', 
+        chain_id, 
+        EXTRACT(epoch FROM clock_timestamp())::bigint)
+    );
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON FUNCTION timetable.notify_chain_start IS 'Send notification to the worker or to the client of the chain to start it';
@@ -0,0 +1,5 @@
+ALTER TABLE timetable.chain_status
+    ADD COLUMN last_started TIMESTAMPTZ;
+
+COMMENT ON COLUMN timetable.chain_status.last_started IS
+    'Start time of the last successful run, substituted for the $$previous_run_ts$$ macro of the next run';
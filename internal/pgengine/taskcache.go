@@ -0,0 +1,58 @@
+package pgengine
+
+import (
+	"sync"
+	"time"
+)
+
+// taskCache keeps tasks of chains fetched by GetChainElements, so frequently executed chains don't select
+// the same metadata each time. Entries are invalidated by the CHANGED notification sent by the
+// timetable.notify_chain_change trigger and expire after the TTL in case a notification is lost
+type taskCache struct {
+	sync.Mutex
+	entries map[int]taskCacheEntry
+	gen     uint64 // increased on each invalidation, so tasks fetched before it are not cached
+}
+
+type taskCacheEntry struct {
+	tasks   []ChainTask
+	fetched time.Time
+}
+
+// get returns cached tasks of the chain if they are not older than ttl, and the generation of the cache
+// to pass to put after tasks are fetched
+func (c *taskCache) get(chainID int, ttl time.Duration) (tasks []ChainTask, gen uint64, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[chainID]
+	if ok && time.Since(entry.fetched) > ttl {
+		delete(c.entries, chainID)
+		ok = false
+	}
+	return entry.tasks, c.gen, ok
+}
+
+// put caches tasks of the chain unless the cache was invalidated since gen was returned by get
+func (c *taskCache) put(chainID int, gen uint64, tasks []ChainTask) {
+	c.Lock()
+	defer c.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[int]taskCacheEntry)
+	}
+	c.entries[chainID] = taskCacheEntry{tasks: tasks, fetched: time.Now()}
+}
+
+// invalidate removes cached tasks of the chain, or of all chains if chainID is 0
+func (c *taskCache) invalidate(chainID int) {
+	c.Lock()
+	defer c.Unlock()
+	c.gen++
+	if chainID == 0 {
+		c.entries = nil
+		return
+	}
+	delete(c.entries, chainID)
+}
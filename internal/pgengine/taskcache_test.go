@@ -0,0 +1,34 @@
+package pgengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskCache(t *testing.T) {
+	var c taskCache
+	_, gen, ok := c.get(1, time.Minute)
+	assert.False(t, ok)
+	c.put(1, gen, []ChainTask{{TaskID: 10}})
+	c.put(2, gen, []ChainTask{{TaskID: 20}})
+	tasks, _, ok := c.get(1, time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, []ChainTask{{TaskID: 10}}, tasks)
+	_, _, ok = c.get(1, 0)
+	assert.False(t, ok, "expired tasks must be fetched again")
+
+	c.invalidate(2)
+	_, _, ok = c.get(2, time.Minute)
+	assert.False(t, ok)
+	c.put(2, gen, []ChainTask{{TaskID: 20}})
+	_, _, ok = c.get(2, time.Minute)
+	assert.False(t, ok, "tasks fetched before the invalidation must not be cached")
+
+	_, gen, _ = c.get(3, time.Minute)
+	c.put(3, gen, []ChainTask{{TaskID: 30}})
+	c.invalidate(0)
+	_, _, ok = c.get(3, time.Minute)
+	assert.False(t, ok, "all chains must be invalidated")
+}
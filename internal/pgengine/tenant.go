@@ -0,0 +1,44 @@
+package pgengine
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/secrets"
+	pgx "github.com/jackc/pgx/v4"
+)
+
+// tenantConnConfig returns the configuration of the connection to the scheduler database logging in as the role.
+// The scheduler password is not used, the server authenticates the role itself, e.g. with the password found
+// in the password file, the client certificate or GSSAPI
+func (pge *PgEngine) tenantConnConfig(role string) (*pgx.ConnConfig, error) {
+	connstr := pge.connString()
+	if u, err := url.Parse(connstr); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		u.User = url.User(role)
+		q := u.Query()
+		q.Del("user")
+		q.Del("password")
+		u.RawQuery = q.Encode()
+		connstr = u.String()
+	} else {
+		connstr += fmt.Sprintf(" user='%s' password=''", strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(role))
+	}
+	connConfig, err := pgx.ParseConfig(connstr)
+	if err != nil {
+		return nil, err
+	}
+	if pge.Kerberos.SrvName != "" {
+		connConfig.KerberosSrvName = pge.Kerberos.SrvName
+	}
+	return connConfig, nil
+}
+
+// checkTenantSecret returns an error if the chain owned by the tenant references the secret store other than
+// encrypted values. Other stores are read with the scheduler credentials and would expose secrets of everyone
+func checkTenantSecret(owner string, ref string) error {
+	if owner == "" || !secrets.IsReference(ref) || strings.HasPrefix(ref, "encrypted://") {
+		return nil
+	}
+	return fmt.Errorf("chain owned by %s may reference only encrypted:// secrets", owner)
+}
@@ -0,0 +1,45 @@
+package pgengine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantConnConfig(t *testing.T) {
+	passfile := filepath.Join(t.TempDir(), "pgpass")
+	assert.NoError(t, os.WriteFile(passfile, []byte("*:*:*:team_a:tenant_secret\n"), 0600))
+	t.Setenv("PGPASSFILE", passfile)
+	t.Setenv("PGPASSWORD", "")
+
+	pge := &PgEngine{CmdOptions: config.CmdOptions{Connection: config.ConnectionOpts{
+		Host: "db", Port: 5433, DBName: "timetable", User: "scheduler", Password: "scheduler_secret", SSLMode: "disable"}}}
+	c, err := pge.tenantConnConfig("team_a")
+	assert.NoError(t, err)
+	assert.Equal(t, "team_a", c.User)
+	assert.Equal(t, "tenant_secret", c.Password, "tenant password is looked up in the password file")
+	assert.Equal(t, "db", c.Host)
+	assert.EqualValues(t, 5433, c.Port)
+	assert.Equal(t, "timetable", c.Database)
+
+	pge.Connection.PgURL = "postgres://scheduler:scheduler_secret@db/timetable?sslmode=disable&password=scheduler_secret"
+	c, err = pge.tenantConnConfig("team_a")
+	assert.NoError(t, err)
+	assert.Equal(t, "team_a", c.User)
+	assert.Equal(t, "tenant_secret", c.Password, "scheduler password is never used by tenants")
+
+	c, err = pge.tenantConnConfig("team_b")
+	assert.NoError(t, err)
+	assert.Empty(t, c.Password)
+}
+
+func TestCheckTenantSecret(t *testing.T) {
+	assert.NoError(t, checkTenantSecret("", "vault://database/creds/backup#password"), "chains without owner are not restricted")
+	assert.NoError(t, checkTenantSecret("team_a", "encrypted://c2VjcmV0"))
+	assert.NoError(t, checkTenantSecret("team_a", "host=db user=team_a"), "plain values are not references")
+	assert.Error(t, checkTenantSecret("team_a", "vault://database/creds/backup#password"))
+	assert.Error(t, checkTenantSecret("team_a", "file:///run/secrets/scheduler#password"))
+}
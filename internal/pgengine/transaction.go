@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/secrets"
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgtype"
@@ -27,17 +28,49 @@ type ChainTask struct {
 	Autonomous    bool           `db:"autonomous"`
 	ConnectString pgtype.Varchar `db:"database_connection"`
 	Timeout       int            `db:"timeout"` // in milliseconds
+	Sandbox       bool           `db:"sandbox"`
+	Env           []string       `db:"env"` // extra environment variables of the PROGRAM task as NAME=value
+	Container     *TaskContainer `db:"container"`
+	Params        []TaskParam    `db:"params"` // parameters fetched together with the task, never nil
 	StartedAt     time.Time
 	Duration      int64 // in microseconds
 	Txid          int
+	RunID         string // identifier of the chain execution
+	ReadOnly      bool   // the chain is read-only, so the task must not modify data
+	Owner         string // role the task of the multi-tenant chain is executed as, empty if not multi-tenant
 }
 
-// StartTransaction returns transaction object, transaction id and error
-func (pge *PgEngine) StartTransaction(ctx context.Context, chainID int) (tx pgx.Tx, txid int, err error) {
+// TaskParam is the parameter value of the task as stored in the timetable.parameter table
+type TaskParam struct {
+	Value  string `db:"value" json:"value"`
+	Secret bool   `db:"secret" json:"secret"`
+}
+
+// TaskContainer describes the container the PROGRAM task is executed in
+type TaskContainer struct {
+	Image   string   `json:"image" yaml:"image"`
+	Mounts  []string `json:"mounts,omitempty" yaml:"mounts,omitempty"`   // volumes as host_path:container_path[:ro]
+	Network string   `json:"network,omitempty" yaml:"network,omitempty"` // e.g. none, host or the name of the network
+	Options []string `json:"options,omitempty" yaml:"options,omitempty"` // extra options of the run command, e.g. --user=1000
+}
+
+// StartTransaction returns transaction object, transaction id and error.
+// The transaction of the read-only chain cannot modify data. The transaction is rolled back on error
+func (pge *PgEngine) StartTransaction(ctx context.Context, chainID int, readOnly bool) (tx pgx.Tx, txid int, err error) {
 	tx, err = pge.ConfigDb.Begin(ctx)
 	if err != nil {
 		return
 	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+	if readOnly {
+		if err = setReadOnly(ctx, tx); err != nil {
+			return
+		}
+	}
 	err = pgxscan.Get(ctx, tx, &txid, "SELECT txid_current()")
 	if err != nil {
 		return
@@ -46,6 +79,36 @@ func (pge *PgEngine) StartTransaction(ctx context.Context, chainID int) (tx pgx.
 	return
 }
 
+// setReadOnly makes the transaction read-only and sets default_transaction_read_only, so tasks cannot modify data
+func setReadOnly(ctx context.Context, tx executor) error {
+	_, err := tx.Exec(ctx, "SET TRANSACTION READ ONLY")
+	if err == nil {
+		_, err = tx.Exec(ctx, "SET LOCAL default_transaction_read_only = on")
+	}
+	return err
+}
+
+// SetChainRunID makes the identifier of the chain execution available to its tasks
+// via the pg_timetable.run_id setting for the rest of the transaction
+func (pge *PgEngine) SetChainRunID(ctx context.Context, tx pgx.Tx, runID string) error {
+	_, err := tx.Exec(ctx, `SELECT set_config('pg_timetable.run_id', $1, true)`, runID)
+	return err
+}
+
+// TryLockExclusiveChain obtains the transaction level advisory lock keyed by the chain, so the exclusive chain
+// is not executed by several clients at the same time. The lock is released at the end of the chain transaction
+func (pge *PgEngine) TryLockExclusiveChain(ctx context.Context, tx pgx.Tx, chainID int) (locked bool, err error) {
+	err = tx.QueryRow(ctx, "SELECT pg_try_advisory_xact_lock(hashtext('pg_timetable_exclusive'), $1)", chainID).Scan(&locked)
+	return
+}
+
+// SetChainPayload makes the payload passed to the chain available to its tasks
+// via the pg_timetable.chain_payload setting for the rest of the transaction
+func (pge *PgEngine) SetChainPayload(ctx context.Context, tx pgx.Tx, payload string) error {
+	_, err := tx.Exec(ctx, `SELECT set_config('pg_timetable.chain_payload', $1, true)`, payload)
+	return err
+}
+
 // CommitTransaction commits transaction and log error in the case of error
 func (pge *PgEngine) CommitTransaction(ctx context.Context, tx pgx.Tx) {
 	err := tx.Commit(ctx)
@@ -82,29 +145,125 @@ func (pge *PgEngine) MustRollbackToSavepoint(ctx context.Context, tx pgx.Tx, sav
 	}
 }
 
-// GetChainElements returns all elements for a given chain
+// GetChainElements returns all elements for a given chain. Tasks are cached for the chain cache TTL
+// if chainTasks is *[]ChainTask
 func (pge *PgEngine) GetChainElements(ctx context.Context, tx pgx.Tx, chainTasks interface{}, chainID int) bool {
-	const sqlSelectChainTasks = `SELECT task_id, command, kind, run_as, ignore_error, autonomous, database_connection, timeout
+	// parameters are aggregated into the task row, so the chain metadata is fetched in a single round-trip
+	const sqlSelectChainTasks = `SELECT task_id, command, kind, run_as, ignore_error, autonomous, database_connection, timeout, sandbox, env, container,
+COALESCE((SELECT json_agg(json_build_object('value', p.value::text, 'secret', COALESCE(p.secret, FALSE)) ORDER BY p.order_id)
+	FROM timetable.parameter p WHERE p.task_id = task.task_id AND p.value IS NOT NULL), '[]') AS params
 FROM timetable.task WHERE chain_id = $1 ORDER BY task_order ASC`
+	tasks, cached := chainTasks.(*[]ChainTask)
+	ttl := time.Duration(pge.Resource.ChainCacheTTL) * time.Second
+	var gen uint64
+	if cached && ttl > 0 {
+		var hit []ChainTask
+		if hit, gen, cached = pge.tasks.get(chainID, ttl); cached {
+			*tasks = append((*tasks)[:0], hit...)
+			return true
+		}
+	}
 	err := pgxscan.Select(ctx, tx, chainTasks, sqlSelectChainTasks, chainID)
 	if err != nil {
 		log.GetLogger(ctx).WithError(err).Error("Failed to retrieve chain elements")
 		return false
 	}
+	if tasks != nil {
+		for i := range *tasks {
+			if (*tasks)[i].Params == nil {
+				(*tasks)[i].Params = []TaskParam{}
+			}
+		}
+	}
+	if tasks != nil && ttl > 0 {
+		pge.tasks.put(chainID, gen, append([]ChainTask(nil), *tasks...))
+	}
 	return true
 }
 
-// GetChainParamValues returns parameter values to pass for task being executed
-func (pge *PgEngine) GetChainParamValues(ctx context.Context, tx pgx.Tx, paramValues interface{}, task *ChainTask) bool {
-	const sqlGetParamValues = `SELECT value FROM timetable.parameter WHERE task_id = $1 AND value IS NOT NULL ORDER BY order_id ASC`
-	err := pgxscan.Select(ctx, tx, paramValues, sqlGetParamValues, task.TaskID)
-	if err != nil {
-		log.GetLogger(ctx).WithError(err).Error("cannot fetch parameters values for chain: ", err)
-		return false
+// GetChainParamValues returns parameter values to pass for task being executed. Parameters are fetched
+// together with the task by GetChainElements
+func (pge *PgEngine) GetChainParamValues(ctx context.Context, paramValues *[]string, task *ChainTask) bool {
+	for _, param := range task.Params {
+		if param.Secret {
+			var err error
+			if param.Value, err = pge.decryptParameter(ctx, task.Owner, param.Value); err != nil {
+				log.GetLogger(ctx).WithError(err).Error("cannot decrypt secret parameter")
+				return false
+			}
+		}
+		*paramValues = append(*paramValues, param.Value)
 	}
 	return true
 }
 
+// decryptParameter returns the value of the secret parameter stored as JSON string with the encrypted://
+// or another secret store reference. The value and its strings are masked in the log and task output from now on
+func (pge *PgEngine) decryptParameter(ctx context.Context, owner string, value string) (string, error) {
+	var ref string
+	if err := json.Unmarshal([]byte(value), &ref); err != nil || !secrets.IsReference(ref) {
+		return "", errors.New("secret parameter value must be the secret reference, e.g. encrypted://...")
+	}
+	if err := checkTenantSecret(owner, ref); err != nil {
+		return "", err
+	}
+	value, err := pge.secrets.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	log.AddSecrets(append(jsonStrings(value), value)...)
+	return value, nil
+}
+
+// ResolveTaskEnv returns NAME=value environment variables of the task with secret://<scheme>/<path>#<key> values
+// resolved, so credentials are not stored in the task definition. Resolved values are masked in the log and task output.
+// Tasks of chains owned by tenants may reference only encrypted values
+func (pge *PgEngine) ResolveTaskEnv(ctx context.Context, owner string, env []string) ([]string, error) {
+	resolved := make([]string, 0, len(env))
+	for _, v := range env {
+		name, value, _ := strings.Cut(v, "=")
+		if ref, ok := secrets.TaskReference(value); ok {
+			if !secrets.IsReference(ref) {
+				return nil, fmt.Errorf("unknown secret store of environment variable %s", name)
+			}
+			if err := checkTenantSecret(owner, ref); err != nil {
+				return nil, err
+			}
+			var err error
+			if value, err = pge.secrets.Resolve(ctx, ref); err != nil {
+				return nil, fmt.Errorf("cannot resolve environment variable %s: %w", name, err)
+			}
+			log.AddSecrets(value)
+		}
+		resolved = append(resolved, name+"="+value)
+	}
+	return resolved, nil
+}
+
+// jsonStrings returns all string values of the JSON document, e.g. arguments of the program
+func jsonStrings(doc string) (values []string) {
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			values = append(values, v)
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	var v interface{}
+	if json.Unmarshal([]byte(doc), &v) == nil {
+		walk(v)
+	}
+	return
+}
+
 type executor interface {
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (commandTag pgconn.CommandTag, err error)
 }
@@ -114,6 +273,7 @@ func (pge *PgEngine) ExecuteSQLTask(ctx context.Context, tx pgx.Tx, task *ChainT
 	var execTx pgx.Tx
 	var remoteDb PgxConnIface
 	var executor executor
+	var connConfig *pgx.ConnConfig
 
 	execTx = tx
 	if task.Autonomous {
@@ -122,9 +282,34 @@ func (pge *PgEngine) ExecuteSQLTask(ctx context.Context, tx pgx.Tx, task *ChainT
 		executor = tx
 	}
 
+	switch {
+	case task.ConnectString.Status != pgtype.Null:
+		if err = checkTenantSecret(task.Owner, task.ConnectString.String); err != nil {
+			return
+		}
+		if connConfig, err = pge.remoteConnConfig(ctx, task.ConnectString.String); err != nil {
+			return
+		}
+		// the task of the multi-tenant chain must not use credentials of other roles
+		if task.Owner != "" && connConfig.User != task.Owner {
+			return "", fmt.Errorf("database connection of the multi-tenant chain must log in as the chain owner %s", task.Owner)
+		}
+		var release func()
+		if release, err = pge.remoteQuota.acquire(ctx, task.ConnectString.String, pge.Resource.RemoteConnLimit); err != nil {
+			return
+		}
+		defer release()
+	case task.Owner != "":
+		// The task of the multi-tenant chain runs in the session of the chain owner, outside of the chain transaction,
+		// so neither RESET ROLE nor SET ROLE can switch it to the scheduler role
+		if connConfig, err = pge.tenantConnConfig(task.Owner); err != nil {
+			return
+		}
+	}
+
 	//Connect to Remote DB
-	if task.ConnectString.Status != pgtype.Null {
-		remoteDb, execTx, err = pge.GetRemoteDBTransaction(ctx, task.ConnectString.String)
+	if connConfig != nil {
+		remoteDb, execTx, err = pge.connectTransaction(ctx, connConfig)
 		if err != nil {
 			return
 		}
@@ -138,6 +323,49 @@ func (pge *PgEngine) ExecuteSQLTask(ctx context.Context, tx pgx.Tx, task *ChainT
 		defer pge.FinalizeRemoteDBConnection(ctx, remoteDb)
 	}
 
+	// The chain transaction is read-only already, other transactions of the read-only chain are made read-only here
+	if task.ReadOnly {
+		switch {
+		case remoteDb != nil && task.Autonomous:
+			_, err = remoteDb.Exec(ctx, "SET default_transaction_read_only = on")
+		case remoteDb != nil:
+			err = setReadOnly(ctx, execTx)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	// Autonomous tasks of read-only chains run in their own transaction, so it can be made read-only
+	if task.Autonomous && remoteDb == nil && task.ReadOnly {
+		var autonomousTx pgx.Tx
+		if autonomousTx, err = pge.ConfigDb.Begin(ctx); err != nil {
+			return
+		}
+		defer func() {
+			if err != nil {
+				pge.RollbackTransaction(ctx, autonomousTx)
+			} else {
+				pge.CommitTransaction(ctx, autonomousTx)
+			}
+		}()
+		executor = autonomousTx
+		if err = setReadOnly(ctx, autonomousTx); err != nil {
+			return
+		}
+	}
+
+	// the chain owner must be a member of the role the task runs as, otherwise SET ROLE fails
+	if !task.Autonomous && task.Owner != "" && task.RunAs.Status != pgtype.Null {
+		var member bool
+		if err = execTx.QueryRow(ctx, "SELECT pg_has_role($1, $2, 'MEMBER')", task.Owner, task.RunAs.String).Scan(&member); err != nil {
+			return
+		}
+		if !member {
+			return "", fmt.Errorf("chain owner %s is not a member of role %s", task.Owner, task.RunAs.String)
+		}
+	}
+
 	if !task.Autonomous {
 		pge.SetRole(ctx, execTx, task.RunAs)
 		if task.IgnoreError {
@@ -145,7 +373,7 @@ func (pge *PgEngine) ExecuteSQLTask(ctx context.Context, tx pgx.Tx, task *ChainT
 		}
 	}
 
-	pge.SetCurrentTaskContext(ctx, execTx, task.TaskID)
+	pge.SetCurrentTaskContext(ctx, execTx, task.TaskID, task.RunID)
 	out, err = pge.ExecuteSQLCommand(ctx, executor, task.Script, paramValues)
 
 	if err != nil && task.IgnoreError && !task.Autonomous {
@@ -158,7 +386,7 @@ func (pge *PgEngine) ExecuteSQLTask(ctx context.Context, tx pgx.Tx, task *ChainT
 	}
 
 	// Commit changes on remote server
-	if task.ConnectString.Status != pgtype.Null && !task.Autonomous {
+	if remoteDb != nil && !task.Autonomous {
 		pge.CommitTransaction(ctx, execTx)
 	}
 
@@ -192,13 +420,27 @@ func (pge *PgEngine) ExecuteSQLCommand(ctx context.Context, executor executor, c
 
 //GetRemoteDBTransaction create a remote db connection and returns transaction object
 func (pge *PgEngine) GetRemoteDBTransaction(ctx context.Context, connectionString string) (PgxConnIface, pgx.Tx, error) {
+	connConfig, err := pge.remoteConnConfig(ctx, connectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pge.connectTransaction(ctx, connConfig)
+}
+
+// remoteConnConfig returns the configuration of the remote connection with secret references resolved
+func (pge *PgEngine) remoteConnConfig(ctx context.Context, connectionString string) (*pgx.ConnConfig, error) {
 	if strings.TrimSpace(connectionString) == "" {
-		return nil, nil, errors.New("Connection string is blank")
+		return nil, errors.New("Connection string is blank")
 	}
-	connConfig, err := pgx.ParseConfig(connectionString)
+	connectionString, err := pge.secrets.Resolve(ctx, connectionString)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	return pgx.ParseConfig(connectionString)
+}
+
+// connectTransaction opens the connection and starts the transaction there
+func (pge *PgEngine) connectTransaction(ctx context.Context, connConfig *pgx.ConnConfig) (PgxConnIface, pgx.Tx, error) {
 	connConfig.Logger = log.NewPgxLogger(pge.l)
 	if pge.Verbose() {
 		connConfig.LogLevel = pgx.LogLevelDebug
@@ -208,6 +450,7 @@ func (pge *PgEngine) GetRemoteDBTransaction(ctx context.Context, connectionStrin
 	l := log.GetLogger(ctx)
 	remoteDb, err := pgx.ConnectConfig(ctx, connConfig)
 	if err != nil {
+		pge.invalidateSecretsOnAuthError(err)
 		l.WithError(err).Error("Failed to establish remote connection")
 		return nil, nil, err
 	}
@@ -254,11 +497,13 @@ func (pge *PgEngine) ResetRole(ctx context.Context, tx pgx.Tx) {
 	}
 }
 
-// SetCurrentTaskContext - set the working transaction "pg_timetable.current_task_id" run-time parameter
-func (pge *PgEngine) SetCurrentTaskContext(ctx context.Context, tx pgx.Tx, taskID int) {
+// SetCurrentTaskContext - set the working transaction "pg_timetable.current_task_id" and "pg_timetable.run_id"
+// run-time parameters, so remote databases receive the chain execution identifier as well
+func (pge *PgEngine) SetCurrentTaskContext(ctx context.Context, tx pgx.Tx, taskID int, runID string) {
 	l := log.GetLogger(ctx)
 	l.Debug("Setting current task context to ", taskID)
-	_, err := tx.Exec(ctx, "SELECT set_config('pg_timetable.current_task_id', $1, true)", strconv.Itoa(taskID))
+	_, err := tx.Exec(ctx, "SELECT set_config('pg_timetable.current_task_id', $1, true), set_config('pg_timetable.run_id', $2, true)",
+		strconv.Itoa(taskID), runID)
 	if err != nil {
 		l.WithError(err).Error("Failed to set current task context", err)
 	}
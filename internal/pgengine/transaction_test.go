@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
 	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
 	"github.com/jackc/pgtype"
 	"github.com/pashagolub/pgxmock"
@@ -55,6 +58,27 @@ func TestMustTransaction(t *testing.T) {
 	assert.NoError(t, err)
 	pge.MustRollbackToSavepoint(ctx, tx, "foo")
 
+	mockPool.ExpectBegin()
+	mockPool.ExpectExec("SELECT set_config").WithArgs("payload").WillReturnError(errors.New("error"))
+	tx, err = mockPool.Begin(context.Background())
+	assert.NoError(t, err)
+	assert.Error(t, pge.SetChainPayload(ctx, tx, "payload"))
+
+	mockPool.ExpectBegin()
+	mockPool.ExpectExec("SELECT set_config").WithArgs("run").WillReturnError(errors.New("error"))
+	tx, err = mockPool.Begin(context.Background())
+	assert.NoError(t, err)
+	assert.Error(t, pge.SetChainRunID(ctx, tx, "run"))
+
+	mockPool.ExpectBegin()
+	mockPool.ExpectQuery("SELECT pg_try_advisory_xact_lock").WithArgs(42).
+		WillReturnRows(pgxmock.NewRows([]string{"locked"}).AddRow(false))
+	tx, err = mockPool.Begin(context.Background())
+	assert.NoError(t, err)
+	locked, err := pge.TryLockExclusiveChain(ctx, tx, 42)
+	assert.NoError(t, err)
+	assert.False(t, locked, "exclusive chain is running on another client")
+
 	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
 }
 
@@ -99,6 +123,75 @@ func TestExecuteSQLTask(t *testing.T) {
 	}
 }
 
+func TestReadOnlyChain(t *testing.T) {
+	initmockdb(t)
+	defer mockPool.Close()
+	ctx := context.Background()
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+
+	mockPool.ExpectBegin()
+	mockPool.ExpectExec("SET TRANSACTION READ ONLY").WillReturnResult(pgxmock.NewResult("SET", 0))
+	mockPool.ExpectExec("SET LOCAL default_transaction_read_only").WillReturnResult(pgxmock.NewResult("SET", 0))
+	mockPool.ExpectQuery("SELECT txid_current").WillReturnRows(pgxmock.NewRows([]string{"txid"}).AddRow(42))
+	mockPool.ExpectExec("SELECT set_config").WithArgs("24").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	tx, txid, err := pge.StartTransaction(ctx, 24, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, txid)
+
+	// autonomous task runs in its own read-only transaction
+	mockPool.ExpectBegin()
+	mockPool.ExpectExec("SET TRANSACTION READ ONLY").WillReturnResult(pgxmock.NewResult("SET", 0))
+	mockPool.ExpectExec("SET LOCAL default_transaction_read_only").WillReturnResult(pgxmock.NewResult("SET", 0))
+	mockPool.ExpectExec("SELECT set_config").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mockPool.ExpectExec("SELECT 1").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mockPool.ExpectCommit()
+	_, err = pge.ExecuteSQLTask(ctx, tx, &pgengine.ChainTask{Script: "SELECT 1", Autonomous: true, ReadOnly: true,
+		ConnectString: pgtype.Varchar{Status: pgtype.Null}}, nil)
+	assert.NoError(t, err)
+
+	mockPool.ExpectBegin()
+	mockPool.ExpectExec("SET TRANSACTION READ ONLY").WillReturnError(errors.New("error"))
+	mockPool.ExpectRollback()
+	_, _, err = pge.StartTransaction(ctx, 24, true)
+	assert.Error(t, err)
+
+	mockPool.ExpectBegin()
+	mockPool.ExpectQuery("SELECT txid_current").WillReturnError(errors.New("error"))
+	mockPool.ExpectRollback()
+	_, _, err = pge.StartTransaction(ctx, 24, false)
+	assert.Error(t, err, "transaction is rolled back, so the connection returns to the pool")
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "there were unfulfilled expectations")
+}
+
+func TestMultiTenantTask(t *testing.T) {
+	initmockdb(t)
+	defer mockPool.Close()
+	ctx := context.Background()
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+
+	mockPool.ExpectBegin()
+	tx, err := mockPool.Begin(ctx)
+	assert.NoError(t, err)
+
+	// remote tasks of the multi-tenant chain must log in as the chain owner
+	_, err = pge.ExecuteSQLTask(ctx, tx, &pgengine.ChainTask{Script: "SELECT 1", Owner: "tenant",
+		ConnectString: pgtype.Varchar{String: "host=db user=scheduler", Status: pgtype.Present}}, nil)
+	assert.ErrorContains(t, err, "must log in as the chain owner tenant")
+
+	// secret stores are read with the scheduler credentials, so tenants cannot reference them
+	_, err = pge.ExecuteSQLTask(ctx, tx, &pgengine.ChainTask{Script: "SELECT 1", Owner: "tenant",
+		ConnectString: pgtype.Varchar{String: "vault://database/creds/scheduler#connstr", Status: pgtype.Present}}, nil)
+	assert.ErrorContains(t, err, "only encrypted:// secrets")
+	var paramValues []string
+	assert.False(t, pge.GetChainParamValues(ctx, &paramValues, &pgengine.ChainTask{Owner: "tenant",
+		Params: []pgengine.TaskParam{{Value: `"vault://database/creds/scheduler#password"`, Secret: true}}}))
+	_, err = pge.ResolveTaskEnv(ctx, "tenant", []string{"PGPASSWORD=secret://vault/database/creds/scheduler#password"})
+	assert.ErrorContains(t, err, "only encrypted:// secrets")
+
+	assert.NoError(t, mockPool.ExpectationsWereMet(), "tasks of tenants must not touch the scheduler session")
+}
+
 func TestExpectedCloseError(t *testing.T) {
 	initmockdb(t)
 	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
@@ -168,17 +261,55 @@ func TestGetChainElements(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, pge.GetChainElements(ctx, tx, &[]string{}, 0))
 
+	// tasks without parameters get the empty list, so parameters are never selected per task
 	mockPool.ExpectBegin()
-	mockPool.ExpectQuery("SELECT").WillReturnError(errors.New("error"))
+	mockPool.ExpectQuery("SELECT task_id").WithArgs(1).WillReturnRows(pgxmock.NewRows([]string{"task_id", "params"}).
+		AddRow(1, []pgengine.TaskParam{{Value: `"foo"`}}).AddRow(2, nil))
 	tx, err = mockPool.Begin(ctx)
 	assert.NoError(t, err)
-	assert.False(t, pge.GetChainParamValues(ctx, tx, &[]string{}, &pgengine.ChainTask{}))
+	tasks := []pgengine.ChainTask{}
+	assert.True(t, pge.GetChainElements(ctx, tx, &tasks, 1))
+	assert.Len(t, tasks, 2)
+	assert.Equal(t, []pgengine.TaskParam{{Value: `"foo"`}}, tasks[0].Params)
+	assert.NotNil(t, tasks[1].Params)
 
-	mockPool.ExpectBegin()
-	mockPool.ExpectQuery("SELECT").WithArgs(0).WillReturnRows(pgxmock.NewRows([]string{"s"}).AddRow("foo"))
-	tx, err = mockPool.Begin(ctx)
+	values := []string{}
+	assert.True(t, pge.GetChainParamValues(ctx, &values, &pgengine.ChainTask{
+		Params: []pgengine.TaskParam{{Value: `"foo"`}, {Value: `"bar"`}}}))
+	assert.Equal(t, []string{`"foo"`, `"bar"`}, values)
+	assert.True(t, pge.GetChainParamValues(ctx, &values, &pgengine.ChainTask{Params: []pgengine.TaskParam{}}))
+	assert.Len(t, values, 2)
+
+	// secret parameter is resolved and masked in the log from now on
+	secret := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(secret, []byte(`["s3cr3t-param"]`), 0600))
+	values = []string{}
+	assert.True(t, pge.GetChainParamValues(ctx, &values, &pgengine.ChainTask{
+		Params: []pgengine.TaskParam{{Value: `"file://` + secret + `"`, Secret: true}}}))
+	assert.Equal(t, []string{`["s3cr3t-param"]`}, values)
+	assert.Equal(t, "--password=*****", log.Redact("--password=s3cr3t-param"))
+
+	assert.False(t, pge.GetChainParamValues(ctx, &[]string{}, &pgengine.ChainTask{
+		Params: []pgengine.TaskParam{{Value: `["plain"]`, Secret: true}}}), "secret value must be encrypted")
+}
+
+func TestResolveTaskEnv(t *testing.T) {
+	initmockdb(t)
+	defer mockPool.Close()
+	ctx := context.Background()
+	pge := pgengine.NewDB(mockPool, "pgengine_unit_test")
+	secret := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(secret, []byte("s3cr3t-env\n"), 0600))
+
+	env, err := pge.ResolveTaskEnv(ctx, "", []string{"LANG=C", "DATA=file:///data", "PGPASSWORD=secret://file" + secret})
 	assert.NoError(t, err)
-	assert.True(t, pge.GetChainParamValues(ctx, tx, &[]string{}, &pgengine.ChainTask{}))
+	assert.Equal(t, []string{"LANG=C", "DATA=file:///data", "PGPASSWORD=s3cr3t-env"}, env)
+	assert.Equal(t, "password is *****", log.Redact("password is s3cr3t-env"))
+
+	_, err = pge.ResolveTaskEnv(ctx, "", []string{"PGPASSWORD=secret://vault/database/creds/backup#password"})
+	assert.ErrorContains(t, err, "PGPASSWORD", "vault is not configured")
+	_, err = pge.ResolveTaskEnv(ctx, "", []string{"PGPASSWORD=secret://foo/bar"})
+	assert.ErrorContains(t, err, "unknown secret store")
 }
 
 func TestSetRole(t *testing.T) {
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/metrics"
+)
+
+const (
+	durationWindow     = 20          // the number of recent successful runs the median duration is calculated of
+	minDurationSamples = 5           // the number of runs required before anomalies are detected
+	minAnomalyDuration = time.Second // shorter runs are never reported, since they vary a lot
+)
+
+// durationHistory is the ring buffer of recent chain run durations
+type durationHistory struct {
+	samples []time.Duration
+	next    int
+}
+
+func (h *durationHistory) add(d time.Duration) {
+	if len(h.samples) < durationWindow {
+		h.samples = append(h.samples, d)
+		return
+	}
+	h.samples[h.next] = d
+	h.next = (h.next + 1) % durationWindow
+}
+
+func (h *durationHistory) median() time.Duration {
+	sorted := append([]time.Duration(nil), h.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if n := len(sorted); n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
+
+// checkDurationAnomaly warns if the successful chain run took the anomaly factor times longer or shorter
+// than the median of recent runs, then adds the duration to the chain history
+func (sch *Scheduler) checkDurationAnomaly(l log.LoggerIface, chain Chain, duration time.Duration) {
+	factor := sch.Config().Resource.DurationAnomaly
+	if factor <= 1 {
+		return
+	}
+	sch.chainDurationMutex.Lock()
+	defer sch.chainDurationMutex.Unlock()
+	history, ok := sch.chainDurations[chain.ChainID]
+	if !ok {
+		history = &durationHistory{}
+		sch.chainDurations[chain.ChainID] = history
+	}
+	defer history.add(duration)
+	if len(history.samples) < minDurationSamples {
+		return
+	}
+	median := history.median()
+	if duration < minAnomalyDuration && median < minAnomalyDuration {
+		return
+	}
+	if float64(duration) > factor*float64(median) || factor*float64(duration) < float64(median) {
+		l.WithField("duration", duration.Round(time.Millisecond).String()).
+			WithField("median", median.Round(time.Millisecond).String()).
+			Warn("Chain duration deviates from the usual one")
+		metrics.ChainDurationAnomaly(chain.ChainName)
+	}
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationHistory(t *testing.T) {
+	h := &durationHistory{}
+	for i := 1; i <= durationWindow+2; i++ {
+		h.add(time.Duration(i) * time.Second)
+	}
+	assert.Len(t, h.samples, durationWindow, "only recent runs are kept")
+	assert.Equal(t, 12500*time.Millisecond, h.median())
+	h = &durationHistory{samples: []time.Duration{3, 1, 2}}
+	assert.Equal(t, time.Duration(2), h.median())
+}
+
+func TestCheckDurationAnomaly(t *testing.T) {
+	l := log.Init(config.LoggingOpts{LogLevel: "info"})
+	var buf bytes.Buffer
+	l.(*logrus.Logger).Out = &buf
+	pge := &pgengine.PgEngine{CmdOptions: *config.NewCmdOptions("-c", "scheduler_unit_test")}
+	sch := &Scheduler{l: l, pgengine: pge, chainDurations: make(map[int]*durationHistory)}
+	chain := Chain{ChainID: 1, ChainName: "backup"}
+
+	sch.checkDurationAnomaly(l, chain, time.Minute)
+	assert.Empty(t, sch.chainDurations, "check is disabled by default")
+
+	pge.Resource.DurationAnomaly = 3
+	for i := 0; i < minDurationSamples; i++ {
+		sch.checkDurationAnomaly(l, chain, 10*time.Second)
+	}
+	assert.Empty(t, buf.String(), "not enough samples to detect anomaly")
+	sch.checkDurationAnomaly(l, chain, 20*time.Second)
+	assert.Empty(t, buf.String(), "deviation is within the factor")
+	sch.checkDurationAnomaly(l, chain, 40*time.Second)
+	assert.Contains(t, buf.String(), "Chain duration deviates from the usual one")
+	buf.Reset()
+	sch.checkDurationAnomaly(l, chain, time.Second)
+	assert.Contains(t, buf.String(), "[median:10s]", "too short run is reported as well")
+
+	buf.Reset()
+	chain.ChainID = 2
+	for i := 0; i < minDurationSamples; i++ {
+		sch.checkDurationAnomaly(l, chain, 10*time.Millisecond)
+	}
+	sch.checkDurationAnomaly(l, chain, 500*time.Millisecond)
+	assert.Empty(t, buf.String(), "short runs are not reported")
+}
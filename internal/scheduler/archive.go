@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// runArchiver moves execution log entries older than `--archive-days` to the archive every interval
+// until ctx is cancelled
+func (sch *Scheduler) runArchiver(ctx context.Context) {
+	for {
+		if sch.IsLeader() { // followers of the group do not compete archiving the same entries
+			sch.archiveExecutionLog(ctx)
+		}
+		select {
+		case <-time.After(time.Duration(sch.Config().Archive.Interval) * time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// archiveExecutionLog archives old execution log entries day by day, so every transaction holds entries
+// of a single day only. Archiving stops on the first error and is retried on the next run
+func (sch *Scheduler) archiveExecutionLog(ctx context.Context) {
+	opts := sch.Config().Archive
+	days, err := sch.pgengine.SelectArchiveDays(ctx, opts.Days)
+	if err != nil {
+		sch.l.WithError(err).Error("Cannot select execution log entries to archive")
+		return
+	}
+	for _, day := range days {
+		l := sch.l.WithField("day", day.Format("2006-01-02"))
+		entries, err := sch.pgengine.ArchiveExecutionLog(ctx, day, opts.Dir)
+		if err != nil {
+			l.WithError(err).Error("Cannot archive execution log entries")
+			return
+		}
+		if entries > 0 {
+			l.WithField("entries", entries).Info("Execution log entries archived")
+		}
+	}
+}
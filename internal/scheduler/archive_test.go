@@ -0,0 +1,36 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveExecutionLog(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+	l := log.Init(config.LoggingOpts{LogLevel: "error"})
+	sch := New(pgengine.NewDB(mock, "scheduler_unit_test", "--archive-days=30"), l)
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT DISTINCT last_run :: date FROM timetable\\.execution_log").WithArgs(30).
+		WillReturnError(errors.New("error"))
+	sch.archiveExecutionLog(ctx)
+
+	mock.ExpectQuery("SELECT DISTINCT last_run :: date FROM timetable\\.execution_log").WithArgs(30).
+		WillReturnRows(pgxmock.NewRows([]string{"last_run"}).
+			AddRow(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(time.Date(2026, 9, 2, 0, 0, 0, 0, time.UTC)))
+	mock.ExpectBegin().WillReturnError(errors.New("error"))
+	sch.archiveExecutionLog(ctx) // the next day is not archived after the failure
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
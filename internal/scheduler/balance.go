@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// freeCronSlots returns the number of chain workers neither busy nor having chains queued for them
+func (sch *Scheduler) freeCronSlots() int {
+	sch.workersMutex.Lock()
+	workers := len(sch.cronWorkers)
+	sch.workersMutex.Unlock()
+	return Max(0, workers-int(atomic.LoadInt32(&sch.busyCronWorkers))-len(sch.chainsChan))
+}
+
+// reportCapacity stores the number of free chain workers in the heartbeat table, so scheduled chains
+// without client name are assigned to clients proportionally to their free workers
+func (sch *Scheduler) reportCapacity(ctx context.Context) {
+	sch.pgengine.UpdateHeartbeat(ctx, sch.freeCronSlots(), 2*sch.cronInterval())
+}
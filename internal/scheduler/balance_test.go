@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeCronSlots(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test", "--load-balancing")
+	pge.ClientName = "scheduler_unit_test"
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	assert.Equal(t, 0, sch.freeCronSlots(), "workers are not started")
+
+	sch.cronWorkers = make([]chan struct{}, 4)
+	sch.busyCronWorkers = 1
+	sch.chainsChan <- Chain{ChainID: 42}
+	assert.Equal(t, 2, sch.freeCronSlots(), "busy workers and queued chains are not free")
+	sch.busyCronWorkers = 5
+	assert.Equal(t, 0, sch.freeCronSlots())
+
+	sch.busyCronWorkers = 0
+	mock.ExpectExec("INSERT INTO timetable.client_heartbeat").WithArgs("scheduler_unit_test", 3, 120).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	sch.reportCapacity(context.Background())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,12 +2,21 @@ package scheduler
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/metrics"
 	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/cybertec-postgresql/pg_timetable/internal/tracing"
 	pgx "github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Chain structure used to represent tasks chains
@@ -18,16 +27,121 @@ type Chain struct {
 	ExclusiveExecution bool   `db:"exclusive_execution"`
 	MaxInstances       int    `db:"max_instances"`
 	Timeout            int    `db:"timeout"`
+	LogLevel           string `db:"log_level"`    // overrides the client log level if specified
+	LogSampling        int    `db:"log_sampling"` // log only every Nth successful run
+	ReadOnly           bool   `db:"read_only"`    // tasks must not modify data
+	Owner              string `db:"owner"`        // role owning the chain
+	Payload            string `db:"-"`            // optional data passed to the chain when started on demand
+	RunID              string `db:"-"`            // unique identifier of the chain execution used for correlation
+	SampledOut         bool   `db:"-"`            // the successful run is not logged to the database
 }
 
-// SendChain sends chain to the channel for workers
+// ActiveChain describes the chain being executed at the moment
+type ActiveChain struct {
+	ChainID   int       `json:"chain_id"`
+	ChainName string    `json:"chain_name"`
+	RunID     string    `json:"run_id"`
+	StartedAt time.Time `json:"started_at"`
+	TaskID    int       `json:"task_id"` // the task being executed at the moment
+	Txid      int       `json:"txid"`
+	cancel    context.CancelFunc
+}
+
+type runIDKey struct{}
+
+// newRunID returns the random identifier of the chain execution in the UUID format
+func newRunID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// withRunID returns the context carrying the identifier of the chain execution
+func withRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// RunID returns the identifier of the chain execution stored in the context or empty string
+func RunID(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDKey{}).(string)
+	return runID
+}
+
+// sampleChainRun returns true if the successful run of the chain should not be logged
+// to the database, i.e. it's not the Nth one for chains with log sampling
+func (sch *Scheduler) sampleChainRun(chain Chain) bool {
+	if chain.LogSampling <= 1 {
+		return false
+	}
+	sch.chainRunCountMutex.Lock()
+	defer sch.chainRunCountMutex.Unlock()
+	count := sch.chainRunCounts[chain.ChainID]
+	sch.chainRunCounts[chain.ChainID] = count + 1
+	return count%chain.LogSampling != 0
+}
+
+// executionLogEntry is the task execution log entry postponed until the chain run result is known
+type executionLogEntry struct {
+	task    pgengine.ChainTask
+	retCode int
+	output  string
+}
+
+type executionLogKey struct{}
+
+// logTaskExecution stores the task execution log entry. Successful tasks of sampled out chain runs
+// are postponed and stored only if the chain fails eventually
+func (sch *Scheduler) logTaskExecution(ctx context.Context, task *pgengine.ChainTask, retCode int, output string) {
+	if results, ok := ctx.Value(taskResultsKey{}).(*[]TaskResult); ok {
+		*results = append(*results, TaskResult{task.TaskID, task.Kind, task.Script, retCode,
+			time.Duration(task.Duration) * time.Microsecond, output})
+	}
+	if postponed, ok := ctx.Value(executionLogKey{}).(*[]executionLogEntry); ok && retCode == 0 {
+		*postponed = append(*postponed, executionLogEntry{*task, retCode, output})
+		return
+	}
+	sch.pgengine.LogChainElementExecution(context.Background(), task, retCode, output)
+}
+
+// SendChain sends chain to the channel for workers. If the channel is full, the chain waits for the place
+// up to the queue timeout, replaces the oldest queued chain or is dropped depending on the queue policy
 func (sch *Scheduler) SendChain(c Chain) {
 	select {
 	case sch.chainsChan <- c:
 		sch.l.WithField("chain", c.ChainID).Debug("Sent chain to the execution channel")
+		return
 	default:
-		sch.l.WithField("chain", c.ChainID).Error("Failed to send chain to the execution channel")
 	}
+	switch sch.Config().Resource.QueuePolicy {
+	case "block":
+		select {
+		case sch.chainsChan <- c:
+			sch.l.WithField("chain", c.ChainID).Debug("Sent chain to the execution channel")
+			return
+		case <-time.After(time.Duration(sch.Config().Resource.QueueTimeout) * time.Second):
+		}
+	case "drop-oldest":
+		for {
+			select {
+			case sch.chainsChan <- c:
+				sch.l.WithField("chain", c.ChainID).Debug("Sent chain to the execution channel")
+				return
+			case old := <-sch.chainsChan:
+				sch.dropChain(old)
+			}
+		}
+	}
+	sch.dropChain(c)
+}
+
+// dropChain reports the scheduled chain not executed because the execution channel is full
+func (sch *Scheduler) dropChain(c Chain) {
+	atomic.AddInt64(&sch.droppedChains, 1)
+	metrics.ChainDropped(c.ChainName)
+	sch.l.WithField("chain", c.ChainID).WithField("policy", sch.Config().Resource.QueuePolicy).
+		Error("Execution channel is full, chain run dropped")
 }
 
 // Lock locks the chain in exclusive or non-exclusive mode
@@ -49,13 +163,22 @@ func (sch *Scheduler) Unlock(exclusiveExecution bool) {
 }
 
 func (sch *Scheduler) retrieveAsyncChainsAndRun(ctx context.Context) {
+	starts := newDebouncer(time.Duration(sch.Config().Resource.StartDebounce) * time.Millisecond)
 	for {
 		chainSignal := sch.pgengine.WaitForChainSignal(ctx)
-		if chainSignal.ConfigID == 0 {
+		if chainSignal.Command == "" {
 			return
 		}
+		if sch.isDraining() && chainSignal.Command != "STOP" {
+			sch.l.WithField("signal", chainSignal).Info("Ignoring notification, the instance taking over handles it")
+			continue
+		}
 		switch chainSignal.Command {
 		case "START":
+			if !starts.allow(chainSignal.ConfigID, time.Now()) {
+				sch.l.WithField("chain", chainSignal.ConfigID).Debug("Ignoring repeated START notification")
+				continue
+			}
 			var c Chain
 			err := sch.pgengine.SelectChain(ctx, &c, chainSignal.ConfigID)
 			if err != nil {
@@ -64,14 +187,19 @@ func (sch *Scheduler) retrieveAsyncChainsAndRun(ctx context.Context) {
 				sch.SendChain(c)
 			}
 		case "STOP":
-			if cancel, ok := sch.activeChains[chainSignal.ConfigID]; ok {
-				cancel()
-			}
+			sch.StopChain(chainSignal.ConfigID)
+		case "HANDOFF":
+			sch.handOffChain(ctx, chainSignal.ConfigID)
+		case "DRAIN":
+			sch.requestDrain()
+		case "MAINTENANCE_ON", "MAINTENANCE_OFF":
+			sch.SetMaintenance(chainSignal.Command == "MAINTENANCE_ON")
 		}
 	}
 }
 
-func (sch *Scheduler) retrieveChainsAndRun(ctx context.Context, reboot bool) {
+// retrieveChainsAndRun sends to workers @reboot chains or chains scheduled for the specified number of last minutes
+func (sch *Scheduler) retrieveChainsAndRun(ctx context.Context, reboot bool, minutes int) {
 	var err error
 	msg := "Retrieve scheduled chains to run"
 	if reboot {
@@ -80,8 +208,10 @@ func (sch *Scheduler) retrieveChainsAndRun(ctx context.Context, reboot bool) {
 	headChains := []Chain{}
 	if reboot {
 		err = sch.pgengine.SelectRebootChains(ctx, &headChains)
+	} else if sch.usePlan() {
+		headChains, err = sch.dueChains(ctx, time.Now(), minutes)
 	} else {
-		err = sch.pgengine.SelectChains(ctx, &headChains)
+		err = sch.pgengine.SelectChains(ctx, &headChains, minutes)
 	}
 	if err != nil {
 		sch.l.WithError(err).Error("Could not query pending tasks")
@@ -92,19 +222,102 @@ func (sch *Scheduler) retrieveChainsAndRun(ctx context.Context, reboot bool) {
 	// now we can loop through the chains
 	for _, c := range headChains {
 		// if the number of chains pulled for execution is high, try to spread execution to avoid spikes
-		if headChainsCount > sch.Config().Resource.CronWorkers*refetchTimeout {
-			time.Sleep(time.Duration(refetchTimeout*1000/headChainsCount) * time.Millisecond)
+		if interval := sch.cronInterval(); headChainsCount > sch.Config().Resource.CronWorkers*int(interval/time.Second) {
+			time.Sleep(interval / time.Duration(headChainsCount))
 		}
 		sch.SendChain(c)
 	}
 }
 
-func (sch *Scheduler) addActiveChain(id int, cancel context.CancelFunc) {
+// SetChainLogLevel overrides the log level of the chain until the restart, empty level restores the default one
+func (sch *Scheduler) SetChainLogLevel(chainID int, level string) error {
+	if level != "" {
+		if _, err := log.WithLevel(sch.l, level); err != nil {
+			return err
+		}
+	}
+	sch.chainLogLevelMutex.Lock()
+	defer sch.chainLogLevelMutex.Unlock()
+	if level == "" {
+		delete(sch.chainLogLevels, chainID)
+	} else {
+		sch.chainLogLevels[chainID] = level
+	}
+	sch.l.WithField("chain", chainID).WithField("log-level", level).Info("Chain log level changed")
+	return nil
+}
+
+// chainLogger returns the logger for the chain using the log level set by SetChainLogLevel,
+// or specified in the timetable.chain.log_level column, or the client one
+func (sch *Scheduler) chainLogger(chain Chain) log.LoggerIface {
+	l := sch.l.WithField("chain", chain.ChainID)
+	if chain.RunID != "" {
+		l = l.WithField("run_id", chain.RunID)
+	}
+	if chain.SampledOut {
+		l = l.WithField(pgengine.SampledOutField, true)
+	}
+	sch.chainLogLevelMutex.Lock()
+	level, ok := sch.chainLogLevels[chain.ChainID]
+	sch.chainLogLevelMutex.Unlock()
+	if !ok {
+		level = chain.LogLevel
+	}
+	if level == "" {
+		return l
+	}
+	chainL, err := log.WithLevel(l, level)
+	if err != nil {
+		l.WithError(err).Error("Cannot set chain log level")
+		return l
+	}
+	return chainL
+}
+
+func (sch *Scheduler) addActiveChain(chain Chain, cancel context.CancelFunc) {
 	sch.activeChainMutex.Lock()
-	sch.activeChains[id] = cancel
+	sch.activeChains[chain.ChainID] = &ActiveChain{
+		ChainID:   chain.ChainID,
+		ChainName: chain.ChainName,
+		RunID:     chain.RunID,
+		StartedAt: time.Now(),
+		cancel:    cancel,
+	}
 	sch.activeChainMutex.Unlock()
 }
 
+// updateActiveChain stores the transaction and the task being executed for the running chain
+func (sch *Scheduler) updateActiveChain(id int, txid int, taskID int) {
+	sch.activeChainMutex.Lock()
+	if ac, ok := sch.activeChains[id]; ok {
+		ac.Txid = txid
+		ac.TaskID = taskID
+	}
+	sch.activeChainMutex.Unlock()
+}
+
+// GetActiveChains returns the list of chains being executed at the moment
+func (sch *Scheduler) GetActiveChains() []ActiveChain {
+	sch.activeChainMutex.Lock()
+	defer sch.activeChainMutex.Unlock()
+	chains := make([]ActiveChain, 0, len(sch.activeChains))
+	for _, ac := range sch.activeChains {
+		chains = append(chains, *ac)
+	}
+	return chains
+}
+
+// StopChain aborts the chain being executed. It returns false if the chain is not running
+func (sch *Scheduler) StopChain(id int) bool {
+	sch.activeChainMutex.Lock()
+	defer sch.activeChainMutex.Unlock()
+	ac, ok := sch.activeChains[id]
+	if ok {
+		ac.cancel()
+	}
+	return ok
+}
+
 func (sch *Scheduler) deleteActiveChain(id int) {
 	sch.activeChainMutex.Lock()
 	delete(sch.activeChains, id)
@@ -112,43 +325,62 @@ func (sch *Scheduler) deleteActiveChain(id int) {
 }
 
 func (sch *Scheduler) terminateChains() {
-	for id, cancel := range sch.activeChains {
+	sch.activeChainMutex.Lock()
+	for id, ac := range sch.activeChains {
 		sch.l.WithField("chain", id).Debug("Terminating chain...")
-		cancel()
+		ac.cancel()
 	}
+	sch.activeChainMutex.Unlock()
 	for {
 		time.Sleep(1 * time.Second) // give some time to terminate chains gracefully
-		if len(sch.activeChains) == 0 {
+		sch.activeChainMutex.Lock()
+		count := len(sch.activeChains)
+		sch.activeChainMutex.Unlock()
+		if count == 0 {
 			return
 		}
-		sch.l.Debugf("Still active chains running: %d", len(sch.activeChains))
+		sch.l.Debugf("Still active chains running: %d", count)
 	}
 }
 
-func (sch *Scheduler) chainWorker(ctx context.Context, chains <-chan Chain) {
+// chainWorker executes chains received from the channel until the context is cancelled or the quit channel is closed
+func (sch *Scheduler) chainWorker(ctx context.Context, quit <-chan struct{}, chains <-chan Chain) {
 	for {
 		select {
 		case <-ctx.Done(): //check context with high priority
 			return
+		case <-quit:
+			return
 		default:
 			select {
 			case chain := <-chains:
-				chainL := sch.l.WithField("chain", chain.ChainID)
-				chainContext := log.WithLogger(ctx, chainL)
+				chain.RunID = newRunID()
+				chainL := sch.chainLogger(chain)
+				if sch.skipInMaintenance(chain.ExclusiveExecution) {
+					chainL.Info("Skipping chain in maintenance mode")
+					continue
+				}
 				if !sch.pgengine.InsertChainRunStatus(ctx, chain.ChainID, chain.MaxInstances) {
 					chainL.Info("Cannot proceed. Sleeping")
 					continue
 				}
+				// only started runs are sampled, so skipped ones don't shift the logged Nth run
+				chain.SampledOut = sch.sampleChainRun(chain)
+				chainL = sch.chainLogger(chain)
 				chainL.Info("Starting chain")
+				atomic.AddInt32(&sch.busyCronWorkers, 1)
 				sch.Lock(chain.ExclusiveExecution)
-				chainContext, cancel := context.WithCancel(chainContext)
-				sch.addActiveChain(chain.ChainID, cancel)
+				chainContext, cancel := context.WithCancel(log.WithLogger(ctx, chainL))
+				sch.addActiveChain(chain, cancel)
 				sch.executeChain(chainContext, chain)
 				sch.deleteActiveChain(chain.ChainID)
 				cancel()
 				sch.Unlock(chain.ExclusiveExecution)
+				atomic.AddInt32(&sch.busyCronWorkers, -1)
 			case <-ctx.Done():
 				return
+			case <-quit:
+				return
 			}
 
 		}
@@ -163,8 +395,8 @@ func getTimeoutContext(ctx context.Context, t1 int, t2 int) (context.Context, co
 	return ctx, nil
 }
 
-/* execute a chain of tasks */
-func (sch *Scheduler) executeChain(ctx context.Context, chain Chain) {
+/* execute a chain of tasks, returns true if the chain succeeded */
+func (sch *Scheduler) executeChain(ctx context.Context, chain Chain) (succeeded bool) {
 	var ChainTasks []pgengine.ChainTask
 	var bctx context.Context
 	var cancel context.CancelFunc
@@ -175,17 +407,79 @@ func (sch *Scheduler) executeChain(ctx context.Context, chain Chain) {
 		defer cancel()
 	}
 
-	chainL := sch.l.WithField("chain", chain.ChainID)
+	if chain.RunID == "" {
+		chain.RunID = newRunID()
+	}
+	ctx = withRunID(ctx, chain.RunID)
+
+	startedAt := time.Now()
+	defer func() { metrics.ChainFinished(chain.ChainName, succeeded, time.Since(startedAt)) }()
+	ctx = withMacros(ctx, sch.newRunMacros(ctx, chain, startedAt))
+
+	var postponed []executionLogEntry
+	if chain.SampledOut {
+		ctx = context.WithValue(ctx, executionLogKey{}, &postponed)
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "chain "+chain.ChainName, trace.WithAttributes(
+		attribute.Int("chain.id", chain.ChainID),
+		attribute.String("chain.name", chain.ChainName),
+		attribute.String("run.id", chain.RunID),
+	))
+	defer span.End()
+
+	chainL := sch.chainLogger(chain).WithFields(tracing.LogFields(ctx))
 
-	tx, txid, err := sch.pgengine.StartTransaction(ctx, chain.ChainID)
+	tx, txid, err := sch.pgengine.StartTransaction(ctx, chain.ChainID, chain.ReadOnly)
 	if err != nil {
 		chainL.WithError(err).Error("Cannot start transaction")
+		span.SetStatus(codes.Error, "Cannot start transaction")
+		sch.countChainFailures(log.WithLogger(context.Background(), chainL), chainL, chain, false)
 		return
 	}
 	chainL = chainL.WithField("txid", txid)
+	span.SetAttributes(attribute.Int("txid", txid))
+	sch.updateActiveChain(chain.ChainID, txid, 0)
+
+	if chain.ExclusiveExecution {
+		if locked, err := sch.pgengine.TryLockExclusiveChain(ctx, tx, chain.ChainID); err != nil || !locked {
+			if err != nil {
+				chainL.WithError(err).Error("Cannot lock exclusive chain")
+				span.SetStatus(codes.Error, "Cannot lock exclusive chain")
+				sch.countChainFailures(log.WithLogger(context.Background(), chainL), chainL, chain, false)
+			} else {
+				chainL.Info("Exclusive chain is running on another client, skipping")
+			}
+			sch.pgengine.RemoveChainRunStatus(ctx, chain.ChainID)
+			sch.pgengine.RollbackTransaction(ctx, tx)
+			return
+		}
+	}
+
+	if err = sch.pgengine.SetChainRunID(ctx, tx, chain.RunID); err != nil {
+		chainL.WithError(err).Error("Cannot pass run ID to the chain")
+		span.SetStatus(codes.Error, "Cannot pass run ID to the chain")
+		sch.pgengine.RemoveChainRunStatus(ctx, chain.ChainID)
+		sch.pgengine.RollbackTransaction(ctx, tx)
+		sch.countChainFailures(log.WithLogger(context.Background(), chainL), chainL, chain, false)
+		return
+	}
+
+	if chain.Payload != "" {
+		if err = sch.pgengine.SetChainPayload(ctx, tx, chain.Payload); err != nil {
+			chainL.WithError(err).Error("Cannot pass payload to the chain")
+			span.SetStatus(codes.Error, "Cannot pass payload to the chain")
+			sch.pgengine.RemoveChainRunStatus(ctx, chain.ChainID)
+			sch.pgengine.RollbackTransaction(ctx, tx)
+			sch.countChainFailures(log.WithLogger(context.Background(), chainL), chainL, chain, false)
+			return
+		}
+	}
 
 	if !sch.pgengine.GetChainElements(ctx, tx, &ChainTasks, chain.ChainID) {
+		span.SetStatus(codes.Error, "Cannot retrieve chain tasks")
 		sch.pgengine.RollbackTransaction(ctx, tx)
+		sch.countChainFailures(log.WithLogger(context.Background(), chainL), chainL, chain, false)
 		return
 	}
 
@@ -193,18 +487,30 @@ func (sch *Scheduler) executeChain(ctx context.Context, chain Chain) {
 	for _, task := range ChainTasks {
 		task.ChainID = chain.ChainID
 		task.Txid = txid
+		task.RunID = chain.RunID
+		task.ReadOnly = chain.ReadOnly
+		if sch.Config().MultiTenant {
+			task.Owner = chain.Owner
+		}
 		l := chainL.WithField("task", task.TaskID)
 		l.Info("Starting task")
+		sch.updateActiveChain(chain.ChainID, txid, task.TaskID)
 		ctx = log.WithLogger(ctx, l)
 		retCode := sch.executeСhainElement(ctx, tx, &task)
+		metrics.TaskFinished(chain.ChainName, task.TaskID, task.Kind, retCode == 0, time.Duration(task.Duration)*time.Microsecond)
 
 		// we use background context here because current one (ctx) might be cancelled
 		bctx = log.WithLogger(context.Background(), l)
 		if retCode != 0 {
 			if !task.IgnoreError {
+				for _, e := range postponed { // failed runs are always logged
+					sch.pgengine.LogChainElementExecution(bctx, &e.task, e.retCode, e.output)
+				}
 				chainL.Error("Chain failed")
+				span.SetStatus(codes.Error, "Chain failed")
 				sch.pgengine.RemoveChainRunStatus(bctx, chain.ChainID)
 				sch.pgengine.RollbackTransaction(bctx, tx)
+				sch.countChainFailures(log.WithLogger(context.Background(), chainL), chainL, chain, false)
 				return
 			}
 			l.Info("Ignoring task failure")
@@ -212,11 +518,16 @@ func (sch *Scheduler) executeChain(ctx context.Context, chain Chain) {
 	}
 	bctx = log.WithLogger(context.Background(), chainL)
 	sch.pgengine.CommitTransaction(bctx, tx)
+	succeeded = true
 	chainL.Info("Chain executed successfully")
+	sch.checkDurationAnomaly(chainL, chain, time.Since(startedAt))
+	sch.countChainFailures(bctx, chainL, chain, true)
 	sch.pgengine.RemoveChainRunStatus(bctx, chain.ChainID)
+	sch.pgengine.UpdateChainStatus(bctx, chain.ChainID, startedAt)
 	if chain.SelfDestruct {
 		sch.pgengine.DeleteChainConfig(bctx, chain.ChainID)
 	}
+	return
 }
 
 func (sch *Scheduler) executeСhainElement(ctx context.Context, tx pgx.Tx, task *pgengine.ChainTask) int {
@@ -228,8 +539,21 @@ func (sch *Scheduler) executeСhainElement(ctx context.Context, tx pgx.Tx, task
 		cancel      context.CancelFunc
 	)
 
+	ctx, span := tracing.Tracer().Start(ctx, "task "+task.Kind, trace.WithAttributes(
+		attribute.Int("task.id", task.TaskID),
+		attribute.String("task.kind", task.Kind),
+		attribute.Int("txid", task.Txid),
+	))
+	defer span.End()
+
 	l := log.GetLogger(ctx)
-	if !sch.pgengine.GetChainParamValues(ctx, tx, &paramValues, task) {
+	if !sch.pgengine.GetChainParamValues(ctx, &paramValues, task) {
+		span.SetStatus(codes.Error, "Cannot retrieve task parameters")
+		return -1
+	}
+	if task.Script, paramValues, err = expandMacros(ctx, task.Script, paramValues); err != nil {
+		l.WithError(err).Error("Cannot substitute runtime macros")
+		span.SetStatus(codes.Error, "Cannot substitute runtime macros")
 		return -1
 	}
 
@@ -245,10 +569,33 @@ func (sch *Scheduler) executeСhainElement(ctx context.Context, tx pgx.Tx, task
 	case "PROGRAM":
 		if sch.pgengine.NoProgramTasks {
 			l.Info("Program task execution skipped")
+			span.SetAttributes(attribute.Int("retcode", -2))
 			return -2
 		}
+		// the sandbox shares the file system with the scheduler, mounts and run options may expose the host
+		if task.Owner != "" && (task.Container == nil || len(task.Container.Mounts) > 0 || len(task.Container.Options) > 0) {
+			err = errors.New("PROGRAM task of the multi-tenant chain must run in the container without mounts and options")
+			break
+		}
+		if task.Sandbox {
+			ctx = withSandbox(ctx)
+		}
+		if len(task.Env) > 0 {
+			var env []string
+			if env, err = sch.pgengine.ResolveTaskEnv(ctx, task.Owner, task.Env); err != nil {
+				break
+			}
+			ctx = withTaskEnv(ctx, env)
+		}
+		if task.Container != nil {
+			ctx = withContainer(ctx, task.Container)
+		}
 		retCode, out, err = sch.ExecuteProgramCommand(ctx, task.Script, paramValues)
 	case "BUILTIN":
+		if task.Owner != "" && !tenantTasks[task.Script] {
+			err = errors.New("builtin task " + task.Script + " is not available to multi-tenant chains")
+			break
+		}
 		out, err = sch.executeTask(ctx, task.Script, paramValues)
 	}
 	task.Duration = time.Since(task.StartedAt).Microseconds()
@@ -259,9 +606,12 @@ func (sch *Scheduler) executeСhainElement(ctx context.Context, tx pgx.Tx, task
 		}
 		out = strings.Join([]string{out, err.Error()}, "\n")
 		l.WithError(err).Error("Task execution failed")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "Task execution failed")
 	} else {
 		l.Info("Task executed successfully")
 	}
-	sch.pgengine.LogChainElementExecution(context.Background(), task, retCode, out)
+	span.SetAttributes(attribute.Int("retcode", retCode), attribute.Int64("duration_us", task.Duration))
+	sch.logTaskExecution(ctx, task, retCode, out)
 	return retCode
 }
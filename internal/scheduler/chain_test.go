@@ -13,6 +13,7 @@ import (
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/pashagolub/pgxmock"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,6 +25,54 @@ func TestSchedulerExclusiveLocking(t *testing.T) {
 	sch.Unlock(false)
 }
 
+func TestChainLogLevel(t *testing.T) {
+	l := log.Init(config.LoggingOpts{LogLevel: "info"})
+	sch := &Scheduler{l: l, chainLogLevels: make(map[int]string)}
+	isDebug := func(l log.LoggerIface) bool {
+		return l.(*logrus.Entry).Logger.IsLevelEnabled(logrus.DebugLevel)
+	}
+	assert.False(t, isDebug(sch.chainLogger(Chain{ChainID: 1})))
+	assert.True(t, isDebug(sch.chainLogger(Chain{ChainID: 1, LogLevel: "debug"})), "chain column level")
+
+	assert.Error(t, sch.SetChainLogLevel(1, "foo"))
+	assert.NoError(t, sch.SetChainLogLevel(1, "debug"))
+	assert.True(t, isDebug(sch.chainLogger(Chain{ChainID: 1})))
+	assert.False(t, isDebug(sch.chainLogger(Chain{ChainID: 2})))
+	assert.NoError(t, sch.SetChainLogLevel(1, "error"))
+	assert.False(t, isDebug(sch.chainLogger(Chain{ChainID: 1, LogLevel: "debug"})), "API level takes precedence")
+	assert.NoError(t, sch.SetChainLogLevel(1, ""))
+	assert.False(t, isDebug(sch.chainLogger(Chain{ChainID: 1})))
+	assert.Equal(t, logrus.InfoLevel, l.(*logrus.Logger).Level, "client log level must not change")
+}
+
+func TestRunID(t *testing.T) {
+	runID := newRunID()
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, runID)
+	assert.NotEqual(t, runID, newRunID(), "run ID must be unique")
+	assert.Equal(t, runID, RunID(withRunID(context.Background(), runID)))
+	assert.Empty(t, RunID(context.Background()))
+
+	sch := &Scheduler{l: log.Init(config.LoggingOpts{LogLevel: "error"}), chainLogLevels: make(map[int]string)}
+	l := sch.chainLogger(Chain{ChainID: 1, RunID: runID})
+	assert.Equal(t, runID, l.(*logrus.Entry).Data["run_id"], "every chain log line must contain run ID")
+}
+
+func TestLogSampling(t *testing.T) {
+	sch := &Scheduler{chainRunCounts: make(map[int]int)}
+	var sampledOut []bool
+	for i := 0; i < 4; i++ {
+		sampledOut = append(sampledOut, sch.sampleChainRun(Chain{ChainID: 1, LogSampling: 3}))
+	}
+	assert.Equal(t, []bool{false, true, true, false}, sampledOut, "every 3rd run must be logged")
+	assert.False(t, sch.sampleChainRun(Chain{ChainID: 2}), "every run is logged by default")
+
+	var postponed []executionLogEntry
+	ctx := context.WithValue(context.Background(), executionLogKey{}, &postponed)
+	sch.logTaskExecution(ctx, &pgengine.ChainTask{TaskID: 42}, 0, "foo")
+	assert.Len(t, postponed, 1, "successful task of sampled out run must be postponed")
+	assert.Equal(t, "foo", postponed[0].output)
+}
+
 func TestAsyncChains(t *testing.T) {
 	mock, err := pgxmock.NewPool(pgxmock.MonitorPingsOption(true))
 	assert.NoError(t, err)
@@ -66,7 +115,7 @@ func TestChainWorker(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
 		cancel()
 		chains <- Chain{}
-		sch.chainWorker(ctx, chains)
+		sch.chainWorker(ctx, nil, chains)
 	})
 
 	t.Run("Check chainWorker if everything fine", func(t *testing.T) {
@@ -78,7 +127,7 @@ func TestChainWorker(t *testing.T) {
 		mock.ExpectExec("INSERT INTO timetable\\.log").WillReturnResult(pgxmock.NewResult("INSERT", 1))
 		mock.ExpectExec("DELETE").WillReturnResult(pgxmock.NewResult("DELETE", 1))
 		chains <- Chain{SelfDestruct: true}
-		sch.chainWorker(ctx, chains)
+		sch.chainWorker(ctx, nil, chains)
 	})
 
 	t.Run("Check chainWorker if cannot proceed with chain execution", func(t *testing.T) {
@@ -88,8 +137,19 @@ func TestChainWorker(t *testing.T) {
 		mock.ExpectExec("INSERT INTO timetable\\.log").WillReturnResult(pgxmock.NewResult("INSERT", 1))
 		mock.ExpectQuery("SELECT count").WillReturnError(errors.New("expected"))
 		mock.ExpectExec("INSERT INTO timetable\\.log").WillReturnResult(pgxmock.NewResult("INSERT", 1))
-		chains <- Chain{}
-		sch.chainWorker(ctx, chains)
+		chains <- Chain{ChainID: 1, LogSampling: 2}
+		sch.chainWorker(ctx, nil, chains)
+		assert.Zero(t, sch.chainRunCounts[1], "runs not started must not be sampled")
+	})
+
+	t.Run("Check chainWorker if in maintenance mode", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		sch.SetMaintenance(true)
+		defer sch.SetMaintenance(false)
+		chains <- Chain{ChainID: 1, LogSampling: 2}
+		sch.chainWorker(ctx, nil, chains)
+		assert.Zero(t, sch.chainRunCounts[1], "runs skipped in maintenance mode must not be sampled")
 	})
 }
 
@@ -115,3 +175,48 @@ func TestExecuteChainElement(t *testing.T) {
 	mock.ExpectQuery("SELECT").WillReturnRows(pgxmock.NewRows([]string{"value"}).AddRow("foo"))
 	sch.executeСhainElement(ctx, mock, &pgengine.ChainTask{Timeout: 1})
 }
+
+func TestExecuteChainElementMultiTenant(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "-c", "scheduler_unit_test", "--password=somestrong")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	var results []TaskResult
+	ctx := context.WithValue(context.Background(), taskResultsKey{}, &results)
+
+	for _, task := range []*pgengine.ChainTask{
+		{Kind: "BUILTIN", Script: "Shutdown"},
+		{Kind: "BUILTIN", Script: "CopyToFile"},
+		{Kind: "PROGRAM", Script: "cat", Sandbox: true},
+		{Kind: "PROGRAM", Script: "cat", Container: &pgengine.TaskContainer{Image: "alpine", Mounts: []string{"/:/host"}}},
+		{Kind: "PROGRAM", Script: "cat", Container: &pgengine.TaskContainer{Image: "alpine", Options: []string{"--privileged"}}},
+	} {
+		task.Owner = "tenant"
+		task.Params = []pgengine.TaskParam{}
+		assert.Equal(t, -1, sch.executeСhainElement(ctx, mock, task), "%s %s must be refused", task.Kind, task.Script)
+	}
+	assert.Contains(t, results[0].Output, "not available to multi-tenant chains")
+	assert.Contains(t, results[2].Output, "must run in the container")
+
+	task := &pgengine.ChainTask{Kind: "BUILTIN", Script: "Log", Owner: "tenant", Params: []pgengine.TaskParam{}}
+	assert.Equal(t, 0, sch.executeСhainElement(ctx, mock, task))
+}
+
+func TestActiveChains(t *testing.T) {
+	sch := &Scheduler{activeChains: make(map[int]*ActiveChain)}
+	cancelled := false
+	sch.addActiveChain(Chain{ChainID: 42, ChainName: "foo", RunID: "run"}, func() { cancelled = true })
+	sch.updateActiveChain(42, 100, 24)
+	sch.updateActiveChain(24, 100, 42) // unknown chain should be ignored
+	chains := sch.GetActiveChains()
+	assert.Len(t, chains, 1)
+	assert.Equal(t, "foo", chains[0].ChainName)
+	assert.Equal(t, "run", chains[0].RunID)
+	assert.Equal(t, 100, chains[0].Txid)
+	assert.Equal(t, 24, chains[0].TaskID)
+	assert.False(t, sch.StopChain(24))
+	assert.True(t, sch.StopChain(42))
+	assert.True(t, cancelled)
+	sch.deleteActiveChain(42)
+	assert.Empty(t, sch.GetActiveChains())
+}
@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+// ListChains returns the chains available to this client
+func (sch *Scheduler) ListChains(ctx context.Context, liveOnly bool) ([]pgengine.ChainInfo, error) {
+	chains := []pgengine.ChainInfo{}
+	err := sch.pgengine.SelectChainList(ctx, &chains, liveOnly)
+	return chains, err
+}
+
+// ExportChain returns the portable definition of the chain
+func (sch *Scheduler) ExportChain(ctx context.Context, chainID int) (pgengine.ChainDefinition, error) {
+	return sch.pgengine.ExportChain(ctx, chainID)
+}
+
+// ImportChain creates or replaces the chain according to the definition and returns its ID
+func (sch *Scheduler) ImportChain(ctx context.Context, def pgengine.ChainDefinition) (int, error) {
+	chainID, err := sch.pgengine.ImportChain(ctx, def)
+	if err == nil {
+		sch.l.WithField("chain", chainID).WithField("name", def.Name).Info("Chain definition imported")
+	}
+	return chainID, err
+}
+
+// SetChainsLive sets the live flag of all chains matching the selector and returns names of chains changed
+func (sch *Scheduler) SetChainsLive(ctx context.Context, live bool, selector pgengine.ChainSelector) ([]string, error) {
+	names, err := sch.pgengine.SetChainsLive(ctx, live, selector)
+	if err == nil {
+		sch.l.WithField("live", live).WithField("chains", names).Info("Live flag of chains changed")
+	}
+	return names, err
+}
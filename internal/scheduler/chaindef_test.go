@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainDefinitions(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT.+FROM timetable\\.chain").WillReturnError(errors.New("error"))
+	_, err = sch.ListChains(ctx, false)
+	assert.Error(t, err)
+
+	mock.ExpectQuery("SELECT json_build_object").WillReturnError(errors.New("error"))
+	_, err = sch.ExportChain(ctx, 42)
+	assert.Error(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO timetable\\.chain").
+		WillReturnRows(pgxmock.NewRows([]string{"chain_id"}).AddRow(42))
+	mock.ExpectExec("DELETE FROM timetable\\.task").WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectCommit()
+	chainID, err := sch.ImportChain(ctx, pgengine.ChainDefinition{Name: "foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, chainID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
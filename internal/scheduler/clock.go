@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// checkClockSkew compares the client clock with the server one and logs if the skew exceeds the maximum,
+// since the skew silently shifts interval chains. Returns false if chains must not be scheduled
+func (sch *Scheduler) checkClockSkew(ctx context.Context) bool {
+	opts := sch.Config().Resource
+	if opts.MaxClockSkew <= 0 {
+		return true
+	}
+	skew, err := sch.pgengine.ClockSkew(ctx)
+	if err != nil {
+		sch.l.WithError(err).Error("Cannot check clock skew")
+		return true
+	}
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs <= time.Duration(opts.MaxClockSkew)*time.Second {
+		if atomic.SwapInt32(&sch.clockSkewed, 0) == 1 {
+			sch.l.WithField("skew", skew.Round(time.Millisecond).String()).Info("Clock skew is within the limit again")
+		}
+		return true
+	}
+	atomic.StoreInt32(&sch.clockSkewed, 1)
+	l := sch.l.WithField("skew", skew.Round(time.Millisecond).String())
+	if opts.ClockSkewAction == "refuse" {
+		l.Error("Clock skew exceeds the limit, chains are not scheduled")
+		return false
+	}
+	l.Warn("Clock skew exceeds the limit, chains may run at wrong time")
+	return true
+}
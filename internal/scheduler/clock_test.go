@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckClockSkew(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	logger := log.Init(config.LoggingOpts{LogLevel: "error"})
+	ctx := context.Background()
+
+	sch := New(pgengine.NewDB(mock, "scheduler_unit_test"), logger)
+	assert.True(t, sch.checkClockSkew(ctx), "check is disabled by default")
+
+	sch = New(pgengine.NewDB(mock, "scheduler_unit_test", "--max-clock-skew=5", "--clock-skew-action=refuse"), logger)
+	mock.ExpectQuery("SELECT clock_timestamp").WillReturnError(errors.New("error"))
+	assert.True(t, sch.checkClockSkew(ctx), "chains are scheduled if the server time is unknown")
+	mock.ExpectQuery("SELECT clock_timestamp").
+		WillReturnRows(pgxmock.NewRows([]string{"clock_timestamp"}).AddRow(time.Now().Add(-time.Minute)))
+	assert.False(t, sch.checkClockSkew(ctx))
+	mock.ExpectQuery("SELECT clock_timestamp").
+		WillReturnRows(pgxmock.NewRows([]string{"clock_timestamp"}).AddRow(time.Now()))
+	assert.True(t, sch.checkClockSkew(ctx))
+
+	sch = New(pgengine.NewDB(mock, "scheduler_unit_test", "--max-clock-skew=5"), logger)
+	mock.ExpectQuery("SELECT clock_timestamp").
+		WillReturnRows(pgxmock.NewRows([]string{"clock_timestamp"}).AddRow(time.Now().Add(time.Minute)))
+	assert.True(t, sch.checkClockSkew(ctx), "only warning is logged by default")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
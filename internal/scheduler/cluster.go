@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+// reportStatus stores the version and the number of chains waiting for workers in the cluster status table
+func (sch *Scheduler) reportStatus(ctx context.Context) {
+	queued := len(sch.chainsChan) + len(sch.ichainsChan)
+	sch.pgengine.UpdateClientStatus(ctx, queued, sch.IsLeader(), sch.InMaintenance(), 2*sch.cronInterval())
+}
+
+// GetClusterStatus returns the status of all clients connected to the database
+func (sch *Scheduler) GetClusterStatus(ctx context.Context) ([]pgengine.ClientStatus, error) {
+	clients := []pgengine.ClientStatus{}
+	err := sch.pgengine.SelectClusterStatus(ctx, &clients)
+	return clients, err
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+// defaultContainerRuntime is used if the container engine CLI is not configured
+const defaultContainerRuntime = "docker"
+
+type containerKey struct{}
+
+// withContainer returns the context requesting the PROGRAM task to run in the container
+func withContainer(ctx context.Context, container *pgengine.TaskContainer) context.Context {
+	return context.WithValue(ctx, containerKey{}, container)
+}
+
+// taskContainer returns the container the PROGRAM task of the context must run in, nil if none
+func taskContainer(ctx context.Context) *pgengine.TaskContainer {
+	container, _ := ctx.Value(containerKey{}).(*pgengine.TaskContainer)
+	return container
+}
+
+// containerName returns the unique name of the container, so it can be removed if the task is cancelled
+func containerName() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "pg_timetable_" + hex.EncodeToString(b)
+}
+
+// containerArgs returns the arguments of the run command executing the command in the new container removed
+// after it exits. Only names of env variables are listed, values are taken from the environment of the engine CLI,
+// so they are not exposed in the process list
+func containerArgs(name string, container pgengine.TaskContainer, memory uint64, env []string, command string, args []string) []string {
+	runArgs := []string{"run", "--rm", "--name", name}
+	if container.Network != "" {
+		runArgs = append(runArgs, "--network", container.Network)
+	}
+	for _, mount := range container.Mounts {
+		runArgs = append(runArgs, "--volume", mount)
+	}
+	if memory > 0 {
+		runArgs = append(runArgs, "--memory", strconv.FormatUint(memory, 10)+"b")
+	}
+	for _, v := range env {
+		envName, _, _ := strings.Cut(v, "=")
+		runArgs = append(runArgs, "--env", envName)
+	}
+	runArgs = append(runArgs, container.Options...)
+	runArgs = append(append(runArgs, container.Image, command), args...)
+	return runArgs
+}
+
+// containerOutput executes the command in the container and returns combined stdout and stderr.
+// The memory limit is applied to the container, other limits are applied to the engine CLI. If the CLI is terminated,
+// e.g. on cancellation, the container is removed, since it is not necessarily stopped along with the CLI
+func (c realCommander) containerOutput(ctx context.Context, container *pgengine.TaskContainer, env []string,
+	command string, args []string) ([]byte, error) {
+	runtime := c.Runtime
+	if runtime == "" {
+		runtime = defaultContainerRuntime
+	}
+	name := containerName()
+	cmd := exec.Command(runtime, containerArgs(name, *container, c.Limits.Memory, env, command, args)...)
+	cmd.Stdin = nil
+	setProcessGroup(cmd)
+	cmd.Env = append(c.environ(), env...)
+	limits := c.Limits
+	limits.Memory = 0
+	out, err := limits.combinedOutput(ctx, cmd, c.KillGrace)
+	if err != nil && (ctx.Err() != nil || cmd.ProcessState == nil || !cmd.ProcessState.Exited()) {
+		_ = exec.Command(runtime, "rm", "--force", name).Run()
+	}
+	return out, err
+}
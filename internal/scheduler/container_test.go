@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerArgs(t *testing.T) {
+	container := pgengine.TaskContainer{
+		Image:   "postgres:15",
+		Mounts:  []string{"/backup:/backup", "/etc/pgpass:/root/.pgpass:ro"},
+		Network: "host",
+		Options: []string{"--user=999"},
+	}
+	assert.Equal(t, []string{"run", "--rm", "--name", "foo", "--network", "host",
+		"--volume", "/backup:/backup", "--volume", "/etc/pgpass:/root/.pgpass:ro", "--memory", "1048576b",
+		"--env", "PGDATABASE", "--env", "PG_TIMETABLE_RUN_ID", "--user=999", "postgres:15", "pg_dump", "-Fc"},
+		containerArgs("foo", container, 1<<20, []string{"PGDATABASE=timetable", "PG_TIMETABLE_RUN_ID=bar"}, "pg_dump", []string{"-Fc"}))
+	assert.Equal(t, []string{"run", "--rm", "--name", "foo", "alpine", "true"},
+		containerArgs("foo", pgengine.TaskContainer{Image: "alpine"}, 0, nil, "true", nil))
+}
+
+func TestContainerOutput(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Linux specific commands are used")
+	}
+	dir := t.TempDir()
+	removed := filepath.Join(dir, "removed")
+	engine := filepath.Join(dir, "engine")
+	assert.NoError(t, os.WriteFile(engine, []byte(`#!/bin/sh
+case "$1" in
+  rm) echo "$3" > `+removed+` ;;
+  *) eval last=\${$#}; [ "$last" = "sleep" ] && sleep 5; echo "$@"; echo $PGTT_TASK_VAR/$PG_TIMETABLE_RUN_ID ;;
+esac
+`), 0755))
+	ctx := context.Background()
+	assert.Nil(t, taskContainer(ctx))
+	ctx = withContainer(withRunID(withTaskEnv(ctx, []string{"PGTT_TASK_VAR=task"}), "bar"), &pgengine.TaskContainer{Image: "alpine"})
+	assert.NotNil(t, taskContainer(ctx))
+
+	c := realCommander{Runtime: engine, Environ: []string{}}
+	out, err := c.CombinedOutput(ctx, "echo", "foo")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^run --rm --name pg_timetable_\w+ --env PGTT_TASK_VAR --env PG_TIMETABLE_RUN_ID alpine echo foo\ntask/bar\n$`, string(out))
+	assert.NoFileExists(t, removed, "container exited by itself is removed by the engine")
+
+	ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	_, err = c.CombinedOutput(ctx, "sleep")
+	assert.Error(t, err)
+	name, err := os.ReadFile(removed)
+	assert.NoError(t, err, "container of the cancelled task must be removed")
+	assert.Contains(t, string(name), "pg_timetable_")
+}
@@ -0,0 +1,31 @@
+package scheduler
+
+import "time"
+
+// debouncer coalesces repeated signals for the same chain arriving within the window after the first one
+type debouncer struct {
+	window time.Duration
+	last   map[int]time.Time // time the signal of the chain was accepted for the last time
+}
+
+func newDebouncer(window time.Duration) *debouncer {
+	return &debouncer{window: window, last: make(map[int]time.Time)}
+}
+
+// allow returns true if the signal for the chain should be processed, i.e. no signal for the chain
+// was accepted within the window before now
+func (d *debouncer) allow(chainID int, now time.Time) bool {
+	if d.window <= 0 {
+		return true
+	}
+	if last, ok := d.last[chainID]; ok && now.Sub(last) < d.window {
+		return false
+	}
+	for id, last := range d.last { // forget expired signals, so the map doesn't grow
+		if now.Sub(last) >= d.window {
+			delete(d.last, id)
+		}
+	}
+	d.last[chainID] = now
+	return true
+}
@@ -0,0 +1,24 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebouncer(t *testing.T) {
+	now := time.Now()
+	d := newDebouncer(time.Second)
+	assert.True(t, d.allow(1, now))
+	assert.False(t, d.allow(1, now.Add(500*time.Millisecond)), "repeated signal is coalesced")
+	assert.True(t, d.allow(2, now.Add(500*time.Millisecond)), "signals of other chains are not affected")
+	assert.True(t, d.allow(1, now.Add(time.Second)), "signal after the window is accepted")
+	assert.Len(t, d.last, 2)
+	assert.True(t, d.allow(3, now.Add(3*time.Second)))
+	assert.Len(t, d.last, 1, "expired signals are forgotten")
+
+	d = newDebouncer(0)
+	assert.True(t, d.allow(1, now))
+	assert.True(t, d.allow(1, now), "debouncing is disabled")
+}
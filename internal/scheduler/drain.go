@@ -0,0 +1,53 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// requestDrain wakes up the main loop to drain chains for the instance taking over, repeated requests are ignored
+func (sch *Scheduler) requestDrain() {
+	if atomic.CompareAndSwapInt32(&sch.draining, 0, 1) {
+		sch.drain <- struct{}{}
+	}
+}
+
+// isDraining returns true if the scheduler finishes running chains for the instance taking over
+func (sch *Scheduler) isDraining() bool {
+	return atomic.LoadInt32(&sch.draining) == 1
+}
+
+// TakeOver marks scheduled chains up to the specified minute as checked by the drained instance,
+// so they are not started again. Must be called before Run
+func (sch *Scheduler) TakeOver(checked time.Time) {
+	sch.lastCronCheck = checked.Truncate(time.Minute)
+}
+
+// drainChains starts chains scheduled for minutes not checked yet, then stops scheduling and releases the client name,
+// so the instance taking over starts with the next minute. Blocks until running chains are finished
+func (sch *Scheduler) drainChains(ctx context.Context) {
+	sch.l.Info("Draining chains for the instance taking over")
+	sch.dropIntervalChains()
+	if minutes := sch.cronMinutesToCheck(time.Now()); minutes > 0 && sch.IsLeader() {
+		sch.retrieveChainsAndRun(ctx, false, minutes)
+	}
+	if err := sch.pgengine.ReleaseSession(ctx); err != nil {
+		sch.l.WithError(err).Error("Cannot release the session for the instance taking over")
+	}
+	for {
+		sch.activeChainMutex.Lock()
+		count := len(sch.activeChains)
+		sch.activeChainMutex.Unlock()
+		if count == 0 && len(sch.chainsChan) == 0 {
+			sch.l.Info("Chains drained")
+			return
+		}
+		sch.l.Debugf("Still chains to drain: %d", count+len(sch.chainsChan))
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
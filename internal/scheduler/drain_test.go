@@ -0,0 +1,30 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	sch := New(pgengine.NewDB(mock, "scheduler_unit_test"), log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	assert.False(t, sch.isDraining())
+	sch.requestDrain()
+	sch.requestDrain()
+	assert.True(t, sch.isDraining())
+	assert.Len(t, sch.drain, 1, "main loop must be woken up once")
+
+	now := time.Date(2022, 1, 1, 12, 30, 45, 0, time.UTC)
+	sch.TakeOver(now)
+	assert.Equal(t, 0, sch.cronMinutesToCheck(now), "minute checked by the drained instance must be skipped")
+	assert.Equal(t, 1, sch.cronMinutesToCheck(now.Add(time.Minute)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+type taskEnvKey struct{}
+
+// withTaskEnv returns the context passing extra NAME=value environment variables to the PROGRAM task
+func withTaskEnv(ctx context.Context, env []string) context.Context {
+	return context.WithValue(ctx, taskEnvKey{}, env)
+}
+
+// taskEnv returns extra environment variables of the PROGRAM task of the context
+func taskEnv(ctx context.Context) []string {
+	env, _ := ctx.Value(taskEnvKey{}).([]string)
+	return env
+}
+
+// filterEnv returns the variables of environ matching any of the comma separated allow patterns, or all if allow
+// is empty, and not matching any of the deny patterns. Patterns are validated on startup
+func filterEnv(environ []string, allow string, deny string) []string {
+	matches := func(name string, patterns string) bool {
+		for _, pattern := range strings.Split(patterns, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern == "" {
+				continue
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	filtered := []string{} // not nil, so the command does not inherit the whole environment
+	for _, v := range environ {
+		name, _, _ := strings.Cut(v, "=")
+		if (strings.TrimSpace(allow) == "" || matches(name, allow)) && !matches(name, deny) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
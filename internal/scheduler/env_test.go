@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "LC_ALL=C", "LC_TIME=C", "PGTT_PGPASSWORD=secret", "AWS_SECRET_ACCESS_KEY=secret"}
+	assert.Equal(t, []string{"PATH=/bin", "LC_ALL=C", "LC_TIME=C", "AWS_SECRET_ACCESS_KEY=secret"},
+		filterEnv(environ, "", "PGTT_*"))
+	assert.Equal(t, []string{"PATH=/bin", "LC_ALL=C", "LC_TIME=C"}, filterEnv(environ, "PATH, LC_*", ""))
+	assert.Equal(t, []string{"PATH=/bin", "LC_TIME=C"}, filterEnv(environ, "PATH,LC_*", "LC_ALL"))
+	assert.Equal(t, environ, filterEnv(environ, "", ""))
+	assert.NotNil(t, filterEnv(environ, "FOO", ""), "empty environment must not be inherited")
+}
+
+func TestTaskEnv(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Linux specific commands are used")
+	}
+	ctx := context.Background()
+	assert.Nil(t, taskEnv(ctx))
+	ctx = withTaskEnv(ctx, []string{"PGTT_TASK_VAR=task", "PGTT_INHERITED=overridden"})
+
+	c := realCommander{Environ: []string{"PGTT_INHERITED=scheduler"}}
+	out, err := c.CombinedOutput(ctx, "/bin/sh", "-c", "echo $PGTT_TASK_VAR/$PGTT_INHERITED")
+	assert.NoError(t, err)
+	assert.Equal(t, "task/overridden\n", string(out))
+
+	t.Setenv("PGTT_SCHEDULER_SECRET", "secret")
+	out, err = realCommander{Environ: []string{}}.CombinedOutput(context.Background(), "/bin/sh", "-c", "echo $PGTT_SCHEDULER_SECRET")
+	assert.NoError(t, err)
+	assert.Equal(t, "\n", string(out), "only the filtered environment is inherited")
+}
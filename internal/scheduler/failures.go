@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/metrics"
+)
+
+// countChainFailures counts consecutive failed runs of the chain and disables the chain reaching the auto-disable
+// limit, so endlessly failing runs don't fill logs. The counter is reset by the successful run and when the chain
+// is disabled, so the re-enabled chain is given the full number of attempts again
+func (sch *Scheduler) countChainFailures(ctx context.Context, l log.LoggerIface, chain Chain, succeeded bool) {
+	limit := sch.Config().Resource.AutoDisable
+	if limit <= 0 {
+		return
+	}
+	sch.chainFailureMutex.Lock()
+	if succeeded {
+		delete(sch.chainFailures, chain.ChainID)
+		sch.chainFailureMutex.Unlock()
+		return
+	}
+	failures := sch.chainFailures[chain.ChainID] + 1
+	if failures < limit {
+		sch.chainFailures[chain.ChainID] = failures
+		sch.chainFailureMutex.Unlock()
+		return
+	}
+	delete(sch.chainFailures, chain.ChainID)
+	sch.chainFailureMutex.Unlock()
+
+	disabled, err := sch.pgengine.DisableChain(ctx, chain.ChainID)
+	if err != nil {
+		l.WithError(err).Error("Cannot disable repeatedly failing chain")
+		return
+	}
+	if disabled {
+		metrics.ChainDisabled(chain.ChainName)
+		l.WithField("failures", failures).Error("Chain disabled after consecutive failures")
+	}
+}
@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountChainFailures(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+	l := log.Init(config.LoggingOpts{LogLevel: "error"})
+	sch := New(pgengine.NewDB(mock, "scheduler_unit_test", "--auto-disable=2"), l)
+	ctx := context.Background()
+	chain := Chain{ChainID: 42, ChainName: "backup"}
+
+	sch.countChainFailures(ctx, l, chain, false)
+	sch.countChainFailures(ctx, l, chain, true)
+	assert.Empty(t, sch.chainFailures, "successful run resets the counter")
+
+	sch.countChainFailures(ctx, l, chain, false)
+	mock.ExpectExec("UPDATE timetable\\.chain SET live = FALSE").WithArgs(42).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	sch.countChainFailures(ctx, l, chain, false)
+	assert.Empty(t, sch.chainFailures, "disabled chain is given the full number of attempts again")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteChainCountsFailures(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+	sch := New(pgengine.NewDB(mock, "scheduler_unit_test", "--auto-disable=2"), log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	mock.ExpectBegin().WillReturnError(errors.New("expected"))
+	assert.False(t, sch.executeChain(context.Background(), Chain{ChainID: 42}))
+	assert.Equal(t, 1, sch.chainFailures[42], "runs failed before tasks are executed must be counted")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/metrics"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/cybertec-postgresql/pg_timetable/internal/signature"
+)
+
+// definitionExts lists extensions of chain definition files read from the GitOps directory
+var definitionExts = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// runGitOps reconciles chains with the definition files every interval until ctx is cancelled
+func (sch *Scheduler) runGitOps(ctx context.Context) {
+	applied := map[string]string{}
+	for {
+		if sch.IsLeader() { // followers of the group do not compete applying the same files
+			applied = sch.reconcileChains(ctx, applied)
+		}
+		select {
+		case <-time.After(time.Duration(sch.Config().GitOps.Interval) * time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reconcileChains pulls the repository if configured and applies the definition files of the directory.
+// applied holds hashes of definitions applied by the previous reconciliation, so changes of chains with unchanged
+// definitions are reported as the drift, e.g. manual changes in the database. Nothing is changed if any file cannot
+// be read, so chains are never pruned because of the broken file. Returns hashes of definitions applied
+func (sch *Scheduler) reconcileChains(ctx context.Context, applied map[string]string) map[string]string {
+	opts := sch.Config().GitOps
+	l := sch.l.WithField("dir", opts.Dir)
+	if opts.Repo > "" {
+		if err := syncRepo(ctx, opts.Repo, opts.Dir); err != nil {
+			l.WithError(err).Error("Cannot pull the repository of chain definitions")
+			metrics.ChainDrift(0, false)
+			return applied
+		}
+	}
+	verifier, err := signature.Load(sch.Config().SigningKeys)
+	if err != nil {
+		l.WithError(err).Error("Cannot load chain signing keys")
+		metrics.ChainDrift(0, false)
+		return applied
+	}
+	defs, err := readDefinitionDir(opts.Dir, verifier)
+	if err != nil {
+		l.WithError(err).Error("Cannot read chain definitions")
+		metrics.ChainDrift(0, false)
+		return applied
+	}
+	changes, err := sch.pgengine.ApplyChains(ctx, defs, opts.Prune, opts.DryRun)
+	if err != nil {
+		l.WithError(err).Error("Cannot apply chain definitions")
+		metrics.ChainDrift(0, false)
+		return applied
+	}
+	hashes := definitionHashes(defs)
+	drift := 0
+	for _, change := range changes {
+		if change.Action == "unchanged" {
+			continue
+		}
+		cl := l.WithField("chain", change.Name).WithField("action", change.Action)
+		switch previous, ok := applied[change.Name]; {
+		case opts.DryRun:
+			drift++
+			cl.Warn("Chain differs from the definition files")
+		case ok && previous == hashes[change.Name]:
+			drift++
+			cl.Warn("Chain was changed in the database, the definition restored")
+		default:
+			cl.Info("Chain definition applied")
+		}
+	}
+	metrics.ChainDrift(drift, true)
+	l.WithField("chains", len(defs)).WithField("drift", drift).Debug("Chains reconciled with the definition files")
+	if opts.DryRun {
+		return applied
+	}
+	return hashes
+}
+
+// definitionHashes returns hashes of definitions by chain names
+func definitionHashes(defs []pgengine.ChainDefinition) map[string]string {
+	hashes := make(map[string]string, len(defs))
+	for _, def := range defs {
+		data, _ := json.Marshal(def)
+		hash := sha256.Sum256(data)
+		hashes[def.Name] = hex.EncodeToString(hash[:])
+	}
+	return hashes
+}
+
+// readDefinitionDir returns chain definitions of YAML and JSON files found in dir and its subdirectories
+// in the lexical order. Hidden files and directories, e.g. .git, are skipped
+func readDefinitionDir(dir string, verifier *signature.Verifier) (defs []pgengine.ChainDefinition, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !definitionExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		fileDefs, err := pgengine.ReadChainDefinitions(path, verifier)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		defs = append(defs, fileDefs...)
+		return nil
+	})
+	return
+}
+
+// syncRepo fetches the latest commit of the branch specified after # in url, the default branch otherwise,
+// into the dir and checks it out. The dir is initialized as the repository if needed, local changes are discarded
+func syncRepo(ctx context.Context, url string, dir string) error {
+	url, branch, _ := strings.Cut(url, "#")
+	if branch == "" {
+		branch = "HEAD"
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet", dir},
+		{"-C", dir, "fetch", "--quiet", "--depth", "1", url, branch},
+		{"-C", dir, "reset", "--quiet", "--hard", "FETCH_HEAD"},
+	} {
+		if out, err := exec.CommandContext(ctx, "git", args...).CombinedOutput(); err != nil {
+			command := args[0]
+			if command == "-C" {
+				command = args[2]
+			}
+			return fmt.Errorf("git %s failed: %w: %s", command, err, bytes.TrimSpace(out))
+		}
+	}
+	return nil
+}
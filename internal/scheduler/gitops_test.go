@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDefinitionDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "reports"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	for name, content := range map[string]string{
+		"jobs.yaml":          "- name: vacuum\n  tasks: [{command: VACUUM}]\n- name: analyze\n  tasks: [{command: ANALYZE}]\n",
+		"reports/daily.json": `{"name": "daily", "tasks": [{"command": "SELECT 1"}]}`,
+		"README.md":          "not a definition",
+		".git/config.yaml":   "name: hidden",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	defs, err := readDefinitionDir(dir, nil)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, def := range defs {
+		names = append(names, def.Name)
+	}
+	assert.Equal(t, []string{"vacuum", "analyze", "daily"}, names)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yml"), []byte("name: [broken"), 0644))
+	_, err = readDefinitionDir(dir, nil)
+	assert.ErrorContains(t, err, "broken.yml", "nothing is applied if any file is broken")
+
+	_, err = readDefinitionDir(filepath.Join(dir, "missing"), nil)
+	assert.Error(t, err)
+}
+
+func TestDefinitionHashes(t *testing.T) {
+	defs := []pgengine.ChainDefinition{{Name: "foo", Schedule: "* * * * *"}, {Name: "bar"}}
+	hashes := definitionHashes(defs)
+	assert.Len(t, hashes, 2)
+	defs[0].Schedule = "0 * * * *"
+	assert.NotEqual(t, hashes["foo"], definitionHashes(defs)["foo"])
+	assert.Equal(t, hashes["bar"], definitionHashes(defs)["bar"])
+}
+
+func TestSyncRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	origin := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", origin, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	git("init", "--quiet")
+	require.NoError(t, os.WriteFile(filepath.Join(origin, "jobs.yaml"), []byte("name: vacuum\n"), 0644))
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	git("branch", "prod")
+
+	ctx := context.Background()
+	clone := filepath.Join(t.TempDir(), "clone")
+	assert.NoError(t, syncRepo(ctx, origin, clone))
+	assert.FileExists(t, filepath.Join(clone, "jobs.yaml"))
+
+	require.NoError(t, os.Remove(filepath.Join(origin, "jobs.yaml")))
+	require.NoError(t, os.WriteFile(filepath.Join(origin, "reports.yaml"), []byte("name: daily\n"), 0644))
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "replace")
+	require.NoError(t, os.WriteFile(filepath.Join(clone, "reports.yaml"), []byte("local change"), 0644))
+	assert.NoError(t, syncRepo(ctx, origin, clone))
+	assert.NoFileExists(t, filepath.Join(clone, "jobs.yaml"))
+	data, err := os.ReadFile(filepath.Join(clone, "reports.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "name: daily\n", string(data), "local changes are discarded")
+
+	assert.NoError(t, syncRepo(ctx, origin+"#prod", clone))
+	assert.FileExists(t, filepath.Join(clone, "jobs.yaml"), "branch is checked out")
+
+	assert.ErrorContains(t, syncRepo(ctx, origin+"#missing", clone), "git fetch failed")
+}
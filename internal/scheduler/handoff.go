@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+)
+
+// handOffChain applies the reassignment of the chain: the interval chain handed off to another client is not
+// rescheduled any more, though its running instance is finished, and the interval chain handed over to this
+// client is scheduled for the next fire without waiting for the main loop. Cron chains are picked up by
+// the new owner at the next scheduled minute
+func (sch *Scheduler) handOffChain(ctx context.Context, chainID int) {
+	ichains := []IntervalChain{}
+	if err := sch.pgengine.SelectIntervalChains(ctx, &ichains); err != nil {
+		sch.l.WithError(err).Error("Could not query pending interval tasks")
+		return
+	}
+	sch.intervalChainMutex.Lock()
+	defer sch.intervalChainMutex.Unlock()
+	for _, ichain := range ichains {
+		if ichain.ChainID != chainID {
+			continue
+		}
+		if (IntervalChain{}) != sch.intervalChains[chainID] || !sch.IsLeader() {
+			return
+		}
+		sch.intervalChains[chainID] = ichain
+		sch.l.WithField("chain", chainID).Info("Interval chain handed over to the client")
+		go func(ichain IntervalChain) {
+			select {
+			case <-time.After(time.Duration(ichain.Interval) * time.Second):
+				if sch.isValid(ichain) {
+					sch.SendIntervalChain(ichain)
+				}
+			case <-ctx.Done():
+			}
+		}(ichain)
+		return
+	}
+	if _, ok := sch.intervalChains[chainID]; ok {
+		delete(sch.intervalChains, chainID)
+		sch.l.WithField("chain", chainID).Info("Interval chain handed off to another client")
+	}
+}
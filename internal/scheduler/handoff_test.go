@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandOffChain(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	columns := []string{"chain_id", "chain_name", "self_destruct", "exclusive_execution", "timeout",
+		"max_instances", "log_level", "log_sampling", "interval_seconds", "repeat_after"}
+
+	mock.ExpectQuery("SELECT.+interval_seconds").
+		WillReturnRows(pgxmock.NewRows(columns).AddRow(42, "foo", false, false, 0, 16, "", 1, 60, false))
+	sch.handOffChain(ctx, 42)
+	assert.Contains(t, sch.intervalChains, 42, "handed over chain must be scheduled")
+	assert.Empty(t, sch.ichainsChan, "handed over chain must wait for the next fire")
+
+	mock.ExpectQuery("SELECT.+interval_seconds").WillReturnRows(pgxmock.NewRows(columns))
+	sch.handOffChain(ctx, 42)
+	assert.NotContains(t, sch.intervalChains, 42, "handed off chain must not be rescheduled")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+// GetRuns returns the execution history entries matching the filter
+func (sch *Scheduler) GetRuns(ctx context.Context, filter pgengine.ExecutionLogFilter) ([]pgengine.ExecutionLogEntry, error) {
+	runs := []pgengine.ExecutionLogEntry{}
+	err := sch.pgengine.SelectExecutionLog(ctx, &runs, filter)
+	return runs, err
+}
+
+// GetAudit returns the configuration changes matching the filter
+func (sch *Scheduler) GetAudit(ctx context.Context, filter pgengine.AuditFilter) ([]pgengine.AuditEntry, error) {
+	changes := []pgengine.AuditEntry{}
+	err := sch.pgengine.SelectAuditLog(ctx, &changes, filter)
+	return changes, err
+}
+
+// LogAPICall stores the manual operation performed through the REST or gRPC API
+func (sch *Scheduler) LogAPICall(ctx context.Context, call pgengine.APICall) {
+	sch.pgengine.LogAPICall(ctx, call)
+}
+
+// GetAPICalls returns the manual operations performed through the API matching the filter
+func (sch *Scheduler) GetAPICalls(ctx context.Context, filter pgengine.APICallFilter) ([]pgengine.APICall, error) {
+	calls := []pgengine.APICall{}
+	err := sch.pgengine.SelectAPICalls(ctx, &calls, filter)
+	return calls, err
+}
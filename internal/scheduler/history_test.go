@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRuns(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	mock.ExpectQuery("SELECT.+FROM timetable\\.execution_log").WillReturnError(errors.New("error"))
+	_, err = sch.GetRuns(context.Background(), pgengine.ExecutionLogFilter{Limit: 10})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAudit(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	mock.ExpectQuery("SELECT.+FROM timetable\\.audit").WillReturnError(errors.New("error"))
+	_, err = sch.GetAudit(context.Background(), pgengine.AuditFilter{ChainID: 42, Limit: 10})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
@@ -39,12 +40,23 @@ func (sch *Scheduler) isValid(ichain IntervalChain) bool {
 	return (IntervalChain{}) != sch.intervalChains[ichain.ChainID]
 }
 
+// dropIntervalChains forgets all interval chains, so they are not rescheduled until retrieved again
+func (sch *Scheduler) dropIntervalChains() {
+	sch.intervalChainMutex.Lock()
+	sch.intervalChains = make(map[int]IntervalChain)
+	sch.intervalLoaded = time.Time{}
+	sch.intervalChainMutex.Unlock()
+}
+
 func (sch *Scheduler) reschedule(ctx context.Context, ichain IntervalChain) {
 	if ichain.SelfDestruct {
 		sch.pgengine.DeleteChainConfig(ctx, ichain.ChainID)
 		return
 	}
 	log.GetLogger(ctx).Debug("Sleeping before next execution of interval chain")
+	if sch.wheel.add(ichain) {
+		return
+	}
 	select {
 	case <-time.After(time.Duration(ichain.Interval) * time.Second):
 		if sch.isValid(ichain) {
@@ -56,6 +68,11 @@ func (sch *Scheduler) reschedule(ctx context.Context, ichain IntervalChain) {
 }
 
 func (sch *Scheduler) retrieveIntervalChainsAndRun(ctx context.Context) {
+	now, changes := time.Now(), sch.pgengine.ChainChanges()
+	if !sch.intervalChainsChanged(now, changes) {
+		atomic.StoreInt64(&sch.heartbeat, now.UnixNano())
+		return
+	}
 	ichains := []IntervalChain{}
 	err := sch.pgengine.SelectIntervalChains(ctx, &ichains)
 	if err != nil {
@@ -63,9 +80,13 @@ func (sch *Scheduler) retrieveIntervalChainsAndRun(ctx context.Context) {
 	} else {
 		sch.l.WithField("count", len(ichains)).Info("Retrieve interval chains to run")
 	}
+	atomic.StoreInt64(&sch.heartbeat, time.Now().UnixNano())
 
 	// delete chains that are not returned from the database
 	sch.intervalChainMutex.Lock()
+	if err == nil {
+		sch.intervalChanges, sch.intervalLoaded = changes, now
+	}
 	for id, ichain := range sch.intervalChains {
 		if !ichain.isListed(ichains) {
 			delete(sch.intervalChains, id)
@@ -74,7 +95,9 @@ func (sch *Scheduler) retrieveIntervalChainsAndRun(ctx context.Context) {
 
 	// update chains from the database and send to working channel new one
 	for _, ichain := range ichains {
-		if (IntervalChain{}) == sch.intervalChains[ichain.ChainID] {
+		isNew := (IntervalChain{}) == sch.intervalChains[ichain.ChainID]
+		ichain = sch.limitInterval(ichain, isNew)
+		if isNew {
 			sch.SendIntervalChain(ichain)
 		}
 		sch.intervalChains[ichain.ChainID] = ichain
@@ -82,23 +105,62 @@ func (sch *Scheduler) retrieveIntervalChainsAndRun(ctx context.Context) {
 	sch.intervalChainMutex.Unlock()
 }
 
-func (sch *Scheduler) intervalChainWorker(ctx context.Context, ichains <-chan IntervalChain) {
+// limitInterval extends the interval of the chain running more often than allowed by the minimum interval.
+// Exclusive chains pause all other chains while running, so they run at most once a minute
+func (sch *Scheduler) limitInterval(ichain IntervalChain, warn bool) IntervalChain {
+	limit := sch.Config().Resource.MinInterval
+	if ichain.ExclusiveExecution && limit < wheelSize {
+		limit = wheelSize
+	}
+	if ichain.Interval < limit {
+		if warn {
+			sch.l.WithField("chain", ichain.ChainID).WithField("interval", ichain.Interval).
+				WithField("min-interval", limit).Warn("Chain interval is too short, the minimum interval is used")
+		}
+		ichain.Interval = limit
+	}
+	return ichain
+}
+
+// intervalChainsChanged returns true if interval chains should be selected from the database, because
+// chains changed since they were selected or the plan refresh period passed
+func (sch *Scheduler) intervalChainsChanged(now time.Time, changes int64) bool {
+	refresh := time.Duration(sch.Config().Resource.PlanRefresh) * time.Second
+	sch.intervalChainMutex.Lock()
+	defer sch.intervalChainMutex.Unlock()
+	return refresh <= 0 || sch.intervalLoaded.IsZero() || changes != sch.intervalChanges || now.Sub(sch.intervalLoaded) >= refresh
+}
+
+// intervalChainWorker executes interval chains received from the channel until the context is cancelled
+// or the quit channel is closed
+func (sch *Scheduler) intervalChainWorker(ctx context.Context, quit <-chan struct{}, ichains <-chan IntervalChain) {
 	for {
 		select {
 		case <-ctx.Done(): //check context with high priority
 			return
+		case <-quit:
+			return
 		default:
 			select {
 			case ichain := <-ichains:
 				if !sch.isValid(ichain) { // chain not in the list of active chains
 					continue
 				}
-				chainL := sch.l.WithField("chain", ichain.ChainID)
+				chain := ichain.Chain
+				chain.RunID = newRunID()
+				chainL := sch.chainLogger(chain)
 				chainContext := log.WithLogger(ctx, chainL)
 				chainL.Info("Starting chain")
 				if !ichain.RepeatAfter {
 					go sch.reschedule(chainContext, ichain)
 				}
+				if sch.skipInMaintenance(ichain.ExclusiveExecution) {
+					chainL.Info("Skipping chain in maintenance mode")
+					if ichain.RepeatAfter {
+						go sch.reschedule(chainContext, ichain)
+					}
+					continue
+				}
 				if !sch.pgengine.InsertChainRunStatus(ctx, ichain.ChainID, ichain.MaxInstances) {
 					chainL.Info("Cannot proceed. Sleeping")
 					if ichain.RepeatAfter {
@@ -106,14 +168,21 @@ func (sch *Scheduler) intervalChainWorker(ctx context.Context, ichains <-chan In
 					}
 					continue
 				}
+				chain.SampledOut = sch.sampleChainRun(chain)
 				sch.Lock(ichain.ExclusiveExecution)
-				sch.executeChain(chainContext, ichain.Chain)
+				runContext, cancel := context.WithCancel(log.WithLogger(ctx, sch.chainLogger(chain)))
+				sch.addActiveChain(chain, cancel)
+				sch.executeChain(runContext, chain)
+				sch.deleteActiveChain(ichain.ChainID)
+				cancel()
 				sch.Unlock(ichain.ExclusiveExecution)
 				if ichain.RepeatAfter {
 					go sch.reschedule(chainContext, ichain)
 				}
 			case <-ctx.Done():
 				return
+			case <-quit:
+				return
 			}
 		}
 	}
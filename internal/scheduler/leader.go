@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// IsLeader returns true if the scheduler executes chains, i.e. the high availability is disabled
+// or the instance is the elected leader of its group
+func (sch *Scheduler) IsLeader() bool {
+	return atomic.LoadInt32(&sch.leader) == 1
+}
+
+// setLeader changes the leadership state. The main loop is woken up on election to start chains immediately,
+// running chains are terminated on the leadership loss, since another instance takes them over
+func (sch *Scheduler) setLeader(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	if atomic.SwapInt32(&sch.leader, v) == v {
+		return
+	}
+	l := sch.l.WithField("group", sch.Config().HA.Group)
+	if on {
+		l.Info("Elected as the leader, executing chains")
+		select {
+		case sch.elected <- struct{}{}:
+		default:
+		}
+		return
+	}
+	l.Warn("Leadership lost, terminating chains")
+	sch.dropIntervalChains()
+	sch.terminateChains()
+}
+
+// campaign tries to become the leader of the group, or checks the leader lock is still held if elected already
+func (sch *Scheduler) campaign(ctx context.Context) {
+	if sch.IsLeader() {
+		if err := sch.pgengine.CheckLeader(ctx); err != nil {
+			sch.l.WithError(err).Error("Leader lock connection lost")
+			sch.pgengine.ResignLeader(ctx)
+			sch.setLeader(false)
+		}
+		return
+	}
+	elected, err := sch.pgengine.TryBecomeLeader(ctx)
+	switch {
+	case err != nil:
+		sch.l.WithError(err).Error("Cannot obtain leader lock")
+	case elected:
+		sch.setLeader(true)
+	default:
+		sch.l.Debug("Another instance is the leader, waiting")
+	}
+}
+
+// runElection campaigns for the leadership every check interval until ctx is cancelled, then resigns
+func (sch *Scheduler) runElection(ctx context.Context) {
+	defer sch.pgengine.ResignLeader(context.Background())
+	for {
+		select {
+		case <-time.After(time.Duration(sch.Config().HA.CheckInterval) * time.Second):
+			sch.campaign(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
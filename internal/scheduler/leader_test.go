@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeadership(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	logger := log.Init(config.LoggingOpts{LogLevel: "error"})
+
+	sch := New(pgengine.NewDB(mock, "scheduler_unit_test"), logger)
+	assert.True(t, sch.IsLeader(), "chains are executed if high availability is disabled")
+
+	sch = New(pgengine.NewDB(mock, "scheduler_unit_test", "--ha-group=prod"), logger)
+	assert.False(t, sch.IsLeader(), "the leader must be elected first")
+
+	sch.setLeader(true)
+	assert.True(t, sch.IsLeader())
+	assert.Len(t, sch.elected, 1, "main loop must be woken up")
+
+	sch.intervalChains[42] = IntervalChain{Chain: Chain{ChainID: 42}, Interval: 10}
+	sch.setLeader(false)
+	assert.False(t, sch.IsLeader())
+	assert.Empty(t, sch.intervalChains, "interval chains must not be rescheduled by the follower")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
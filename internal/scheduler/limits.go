@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ProgramLimits specifies the resources available to the program command, zero value means no limit
+type ProgramLimits struct {
+	CPUTime time.Duration // CPU time consumed by the process
+	Memory  uint64        // memory used by the process in bytes
+	Output  int           // combined stdout and stderr size in bytes
+}
+
+// Errors returned if the program command was aborted because it exceeded the limit
+var (
+	ErrCPULimit    = errors.New("program aborted: CPU time limit exceeded")
+	ErrMemoryLimit = errors.New("program aborted: memory limit exceeded")
+	ErrOutputLimit = errors.New("program aborted: output size limit exceeded")
+)
+
+// limitsCheckInterval specifies how often the resources used by the program are checked
+const limitsCheckInterval = 100 * time.Millisecond
+
+// programLimits returns the limits for PROGRAM tasks specified in the configuration
+func (sch *Scheduler) programLimits() ProgramLimits {
+	res := sch.Config().Resource
+	return ProgramLimits{
+		CPUTime: time.Duration(res.ProgramCPU) * time.Second,
+		Memory:  uint64(res.ProgramMemory) << 20,
+		Output:  res.ProgramOutput << 10,
+	}
+}
+
+// limitedBuffer stores the output of the command and calls exceeded when the output size limit is reached
+type limitedBuffer struct {
+	buf      bytes.Buffer // not embedded, so io.Copy cannot bypass Write with ReadFrom
+	limit    int
+	exceeded func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit > 0 && b.buf.Len()+len(p) > b.limit {
+		b.buf.Write(p[:b.limit-b.buf.Len()])
+		b.exceeded()
+		return len(p), nil // keep the pipe drained until the process is killed
+	}
+	return b.buf.Write(p)
+}
+
+// combinedOutput starts the command, kills it as soon as any limit is exceeded and returns combined stdout and stderr.
+// If ctx is cancelled, the process group of the command is asked to terminate and killed after the grace period
+func (l ProgramLimits) combinedOutput(ctx context.Context, cmd *exec.Cmd, grace time.Duration) ([]byte, error) {
+	var (
+		once      sync.Once
+		violation error
+	)
+	abort := func(err error) {
+		once.Do(func() {
+			violation = err
+			killProcessGroup(cmd.Process)
+		})
+	}
+	out := &limitedBuffer{limit: l.Output, exceeded: func() { abort(ErrOutputLimit) }}
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	guard, err := newResourceGuard(cmd.Process, l)
+	if err != nil {
+		killProcessGroup(cmd.Process)
+		_ = cmd.Wait()
+		return nil, err
+	}
+	defer guard.close()
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(limitsCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				terminateProcessGroup(cmd.Process, grace, done)
+				return
+			case <-ticker.C:
+				if err := guard.check(); err != nil {
+					abort(err)
+					return
+				}
+			}
+		}
+	}()
+	err = cmd.Wait()
+	close(done)
+	<-stopped
+	if err != nil {
+		once.Do(func() { violation = l.violation(guard, cmd.ProcessState) })
+	}
+	if violation != nil {
+		return out.buf.Bytes(), violation
+	}
+	return out.buf.Bytes(), err
+}
+
+// terminateProcessGroup asks the process group to terminate, then kills remaining processes of the group
+// as soon as the process exits or the grace period is over
+func terminateProcessGroup(p *os.Process, grace time.Duration, exited <-chan struct{}) {
+	if grace > 0 && signalProcessGroup(p, false) == nil {
+		select {
+		case <-exited:
+		case <-time.After(grace):
+		}
+	}
+	killProcessGroup(p)
+}
+
+// killProcessGroup kills the process and its process group
+func killProcessGroup(p *os.Process) {
+	_ = signalProcessGroup(p, true)
+	_ = p.Kill()
+}
+
+// violation returns the limit the failed process was terminated for by the operating system, if any
+func (l ProgramLimits) violation(guard *resourceGuard, state *os.ProcessState) error {
+	if state == nil {
+		return nil
+	}
+	if err := guard.violation(state); err != nil {
+		return err
+	}
+	if l.CPUTime > 0 && state.UserTime()+state.SystemTime() >= l.CPUTime {
+		return ErrCPULimit
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// resourceGuard applies the CPU time limit as the process rlimit and watches the resident memory of the process
+type resourceGuard struct {
+	pid    int
+	memory uint64
+}
+
+func newResourceGuard(p *os.Process, l ProgramLimits) (*resourceGuard, error) {
+	if l.CPUTime > 0 {
+		seconds := uint64((l.CPUTime + time.Second - 1) / time.Second) // round up to whole seconds
+		// SIGXCPU is sent at the soft limit, SIGKILL at the hard one if the signal is handled
+		if err := unix.Prlimit(p.Pid, unix.RLIMIT_CPU, &unix.Rlimit{Cur: seconds, Max: seconds + 1}, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &resourceGuard{pid: p.Pid, memory: l.Memory}, nil
+}
+
+// check returns ErrMemoryLimit if the resident memory of the process exceeds the limit
+func (g *resourceGuard) check() error {
+	if g.memory == 0 {
+		return nil
+	}
+	statm, err := os.ReadFile("/proc/" + strconv.Itoa(g.pid) + "/statm")
+	if err != nil { // process already exited
+		return nil
+	}
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return nil
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err == nil && pages*uint64(os.Getpagesize()) > g.memory {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// violation returns ErrCPULimit if the process was terminated by SIGXCPU, the memory limit is enforced by check
+func (g *resourceGuard) violation(state *os.ProcessState) error {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() == syscall.SIGXCPU {
+		return ErrCPULimit
+	}
+	return nil
+}
+
+func (g *resourceGuard) close() {}
@@ -0,0 +1,28 @@
+//go:build !linux && !windows
+
+package scheduler
+
+import (
+	"errors"
+	"os"
+)
+
+// resourceGuard supports only the output size limit on this platform
+type resourceGuard struct{}
+
+func newResourceGuard(p *os.Process, l ProgramLimits) (*resourceGuard, error) {
+	if l.CPUTime > 0 || l.Memory > 0 {
+		return nil, errors.New("CPU time and memory limits for programs are not supported on this platform")
+	}
+	return &resourceGuard{}, nil
+}
+
+func (g *resourceGuard) check() error {
+	return nil
+}
+
+func (g *resourceGuard) violation(state *os.ProcessState) error {
+	return nil
+}
+
+func (g *resourceGuard) close() {}
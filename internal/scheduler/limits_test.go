@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitedBuffer(t *testing.T) {
+	var exceeded bool
+	b := &limitedBuffer{limit: 5, exceeded: func() { exceeded = true }}
+	n, err := b.Write([]byte("foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.False(t, exceeded)
+	n, err = b.Write([]byte("barbaz"))
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n, "output must be drained")
+	assert.True(t, exceeded)
+	assert.Equal(t, "fooba", b.buf.String())
+}
+
+func TestProgramLimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Linux specific commands are used")
+	}
+	ctx := context.Background()
+
+	t.Run("no limits", func(t *testing.T) {
+		out, err := realCommander{}.CombinedOutput(ctx, "echo", "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo\n", string(out))
+	})
+
+	t.Run("run ID", func(t *testing.T) {
+		out, err := realCommander{}.CombinedOutput(withRunID(ctx, "foo"), "sh", "-c", "echo $PG_TIMETABLE_RUN_ID")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo\n", string(out))
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		c := realCommander{Limits: ProgramLimits{CPUTime: time.Second, Memory: 1 << 30, Output: 1024}}
+		out, err := c.CombinedOutput(ctx, "sh", "-c", "echo foo; echo bar >&2; exit 3")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrOutputLimit)
+		assert.Equal(t, "foo\nbar\n", string(out))
+	})
+
+	t.Run("output", func(t *testing.T) {
+		c := realCommander{Limits: ProgramLimits{Output: 10}}
+		out, err := c.CombinedOutput(ctx, "yes")
+		assert.ErrorIs(t, err, ErrOutputLimit)
+		assert.Len(t, out, 10)
+	})
+
+	t.Run("CPU time", func(t *testing.T) {
+		c := realCommander{Limits: ProgramLimits{CPUTime: time.Second}}
+		_, err := c.CombinedOutput(ctx, "sh", "-c", "while :; do :; done")
+		assert.ErrorIs(t, err, ErrCPULimit)
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		c := realCommander{Limits: ProgramLimits{Memory: 16 << 20}}
+		_, err := c.CombinedOutput(ctx, "sh", "-c", `a=$(head -c 100000000 /dev/zero | tr '\0' a); sleep 5`)
+		assert.ErrorIs(t, err, ErrMemoryLimit)
+	})
+	t.Run("process group", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := realCommander{KillGrace: 5 * time.Second}.CombinedOutput(ctx, "sh", "-c", "sleep 30 & wait")
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second, "child processes must be terminated along with the command")
+	})
+
+	t.Run("kill grace", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := realCommander{KillGrace: time.Second}.CombinedOutput(ctx, "sh", "-c", `trap "" TERM; sleep 30 & wait`)
+		assert.Error(t, err)
+		assert.Greater(t, time.Since(start), time.Second, "command ignoring SIGTERM must be killed after the grace period")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
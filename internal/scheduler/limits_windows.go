@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// resourceGuard applies the CPU time and memory limits using the job object the process is assigned to
+type resourceGuard struct {
+	job    windows.Handle
+	memory uint64
+}
+
+func newResourceGuard(p *os.Process, l ProgramLimits) (g *resourceGuard, err error) {
+	g = &resourceGuard{memory: l.Memory}
+	if l.CPUTime == 0 && l.Memory == 0 {
+		return
+	}
+	if g.job, err = windows.CreateJobObject(nil, nil); err != nil {
+		return nil, err
+	}
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	if l.CPUTime > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PROCESS_TIME
+		info.BasicLimitInformation.PerProcessUserTimeLimit = int64(l.CPUTime / 100) // in 100-nanosecond ticks
+	}
+	if l.Memory > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+		info.ProcessMemoryLimit = uintptr(l.Memory)
+	}
+	if _, err = windows.SetInformationJobObject(g.job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		g.close()
+		return nil, err
+	}
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(p.Pid))
+	if err != nil {
+		g.close()
+		return nil, err
+	}
+	defer windows.CloseHandle(process)
+	if err = windows.AssignProcessToJobObject(g.job, process); err != nil {
+		g.close()
+		return nil, err
+	}
+	return
+}
+
+// check returns nil, because the limits are enforced by the job object
+func (g *resourceGuard) check() error {
+	return nil
+}
+
+// violation returns ErrMemoryLimit if the process failed after reaching the memory limit of the job
+func (g *resourceGuard) violation(state *os.ProcessState) error {
+	if g.job == 0 || g.memory == 0 {
+		return nil
+	}
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	if err := windows.QueryInformationJobObject(g.job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil); err != nil {
+		return nil
+	}
+	if uint64(info.PeakProcessMemoryUsed) >= g.memory {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+func (g *resourceGuard) close() {
+	if g.job != 0 {
+		_ = windows.CloseHandle(g.job)
+	}
+}
@@ -0,0 +1,88 @@
+package scheduler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type macrosKey struct{}
+
+// macroTimeFormat is the timestamp format of macro values accepted by PostgreSQL as timestamptz
+const macroTimeFormat = "2006-01-02 15:04:05.999999Z07:00"
+
+// runMacros substitutes runtime macros, e.g. $$client_name$$, in task scripts and parameters with values of the chain
+// run. The previous run is selected on the first use only, so chains without macros do not query it
+type runMacros struct {
+	once     sync.Once
+	pairs    []string
+	previous func() (*time.Time, error)
+	replacer *strings.Replacer
+	err      error
+}
+
+// newRunMacros returns macros of the chain run started at the specified moment
+func (sch *Scheduler) newRunMacros(ctx context.Context, chain Chain, startedAt time.Time) *runMacros {
+	return &runMacros{
+		pairs: []string{
+			"$$client_name$$", sch.pgengine.ClientName,
+			"$$chain_id$$", strconv.Itoa(chain.ChainID),
+			"$$chain_name$$", chain.ChainName,
+			"$$run_id$$", chain.RunID,
+			"$$chain_started$$", formatMacroTime(&startedAt),
+		},
+		previous: func() (*time.Time, error) { return sch.pgengine.SelectPreviousRun(ctx, chain.ChainID) },
+	}
+}
+
+// expand returns the string with macros replaced by their values
+func (m *runMacros) expand(s string) (string, error) {
+	if !strings.Contains(s, "$$") {
+		return s, nil
+	}
+	m.once.Do(func() {
+		var previous *time.Time
+		if previous, m.err = m.previous(); m.err == nil {
+			m.replacer = strings.NewReplacer(append(m.pairs, "$$previous_run_ts$$", formatMacroTime(previous))...)
+		}
+	})
+	if m.err != nil {
+		return s, m.err
+	}
+	return m.replacer.Replace(s), nil
+}
+
+// formatMacroTime returns the timestamp as the macro value, -infinity if the chain never ran before
+func formatMacroTime(t *time.Time) string {
+	if t == nil {
+		return "-infinity"
+	}
+	return t.Format(macroTimeFormat)
+}
+
+// withMacros returns the context carrying runtime macros of the chain run
+func withMacros(ctx context.Context, m *runMacros) context.Context {
+	return context.WithValue(ctx, macrosKey{}, m)
+}
+
+// expandMacros returns the task script and parameter values with runtime macros of the context replaced
+func expandMacros(ctx context.Context, script string, paramValues []string) (string, []string, error) {
+	m, ok := ctx.Value(macrosKey{}).(*runMacros)
+	if !ok {
+		return script, paramValues, nil
+	}
+	script, err := m.expand(script)
+	if err != nil {
+		return script, paramValues, err
+	}
+	values := make([]string, 0, len(paramValues))
+	for _, val := range paramValues {
+		if val, err = m.expand(val); err != nil {
+			return script, paramValues, err
+		}
+		values = append(values, val)
+	}
+	return script, values, nil
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandMacros(t *testing.T) {
+	started := time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC)
+	previous := started.Add(-time.Hour)
+	queried := 0
+	m := &runMacros{
+		pairs: []string{"$$client_name$$", "worker01", "$$chain_started$$", formatMacroTime(&started)},
+		previous: func() (*time.Time, error) {
+			queried++
+			return &previous, nil
+		},
+	}
+	ctx := withMacros(context.Background(), m)
+
+	script, params, err := expandMacros(ctx, "SELECT $1", []string{"[1]"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT $1", script)
+	assert.Equal(t, []string{"[1]"}, params)
+	assert.Zero(t, queried, "previous run is not selected without macros")
+
+	script, params, err = expandMacros(ctx,
+		"DELETE FROM staging WHERE ts >= '$$previous_run_ts$$' AND ts < '$$chain_started$$'",
+		[]string{`["$$client_name$$"]`, "DO $$ BEGIN NULL; END $$"})
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM staging WHERE ts >= '2026-10-16 11:30:00Z' AND ts < '2026-10-16 12:30:00Z'", script)
+	assert.Equal(t, []string{`["worker01"]`, "DO $$ BEGIN NULL; END $$"}, params, "dollar quoting is kept intact")
+	_, _, _ = expandMacros(ctx, "$$previous_run_ts$$", nil)
+	assert.Equal(t, 1, queried, "previous run is selected once per chain run")
+
+	script, _, err = expandMacros(context.Background(), "$$client_name$$", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "$$client_name$$", script, "macros are not substituted outside of the chain run")
+
+	m = &runMacros{previous: func() (*time.Time, error) { return nil, nil }}
+	script, _, err = expandMacros(withMacros(context.Background(), m), "$$previous_run_ts$$", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "-infinity", script, "chain never succeeded before")
+
+	m = &runMacros{previous: func() (*time.Time, error) { return nil, errors.New("error") }}
+	_, _, err = expandMacros(withMacros(context.Background(), m), "$$previous_run_ts$$", nil)
+	assert.Error(t, err, "task is not run with the wrong time window")
+}
@@ -0,0 +1,25 @@
+package scheduler
+
+import "sync/atomic"
+
+// SetMaintenance turns the maintenance mode on or off. While in the maintenance mode
+// the scheduler skips all chains except exclusive ones
+func (sch *Scheduler) SetMaintenance(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	if atomic.SwapInt32(&sch.maintenance, v) != v {
+		sch.l.WithField("maintenance", on).Info("Maintenance mode changed")
+	}
+}
+
+// InMaintenance returns true if the scheduler is in the maintenance mode
+func (sch *Scheduler) InMaintenance() bool {
+	return atomic.LoadInt32(&sch.maintenance) == 1
+}
+
+// skipInMaintenance returns true if the chain should not be executed due to the maintenance mode
+func (sch *Scheduler) skipInMaintenance(exclusiveExecution bool) bool {
+	return !exclusiveExecution && sch.InMaintenance()
+}
@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/jackc/pgconn"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenance(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	assert.False(t, sch.InMaintenance())
+	assert.False(t, sch.skipInMaintenance(false))
+	sch.SetMaintenance(true)
+	assert.True(t, sch.InMaintenance())
+	assert.True(t, sch.skipInMaintenance(false))
+	assert.False(t, sch.skipInMaintenance(true), "exclusive chains are executed in maintenance mode")
+
+	pge.NotificationHandler(&pgconn.PgConn{}, &pgconn.Notification{Payload: `{"Command": "MAINTENANCE_OFF", "Ts": 1}`})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	sch.retrieveAsyncChainsAndRun(ctx)
+	assert.False(t, sch.InMaintenance())
+
+	// non-exclusive chain must be skipped without touching the database
+	sch.SetMaintenance(true)
+	chains := make(chan Chain, 1)
+	chains <- Chain{ChainID: 42}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	sch.chainWorker(ctx, nil, chains)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
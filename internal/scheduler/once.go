@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+// TaskResult describes the task execution of the chain run by RunChainOnce or ExecTask
+type TaskResult struct {
+	TaskID     int           `json:"task_id"`
+	Kind       string        `json:"kind"`
+	Command    string        `json:"command"`
+	ReturnCode int           `json:"returncode"`
+	Duration   time.Duration `json:"duration"`
+	Output     string        `json:"output"`
+}
+
+// ChainResult describes the chain run by RunChainOnce or ExecTask, tasks not executed because of the failure are absent
+type ChainResult struct {
+	ChainID   int           `json:"chain_id"`
+	ChainName string        `json:"chain_name"`
+	RunID     string        `json:"run_id"`
+	Succeeded bool          `json:"succeeded"`
+	Duration  time.Duration `json:"duration"`
+	Tasks     []TaskResult  `json:"tasks"`
+}
+
+type taskResultsKey struct{}
+
+// ErrChainBusy is returned by RunChainOnce if the chain runs the maximum number of instances already
+var ErrChainBusy = errors.New("chain is running the maximum number of instances")
+
+// RunChainOnce executes the chain specified by name or ID once the same way as workers do, i.e. in the transaction
+// and with the execution log, and returns the result when the chain finishes. The chain is not required to be live
+func (sch *Scheduler) RunChainOnce(ctx context.Context, chain string) (res ChainResult, err error) {
+	var c Chain
+	if res.ChainID, err = sch.pgengine.SelectChainID(ctx, chain); err != nil {
+		return
+	}
+	if err = sch.pgengine.SelectChain(ctx, &c, res.ChainID); err != nil {
+		return
+	}
+	if !sch.pgengine.InsertChainRunStatus(ctx, c.ChainID, c.MaxInstances) {
+		return res, ErrChainBusy
+	}
+	c.RunID = newRunID()
+	res.ChainName, res.RunID = c.ChainName, c.RunID
+	chainL := sch.chainLogger(c)
+	chainL.Info("Starting chain once")
+	atomic.AddInt32(&sch.busyCronWorkers, 1)
+	defer atomic.AddInt32(&sch.busyCronWorkers, -1)
+	sch.Lock(c.ExclusiveExecution)
+	defer sch.Unlock(c.ExclusiveExecution)
+	chainContext, cancel := context.WithCancel(log.WithLogger(ctx, chainL))
+	defer cancel()
+	sch.addActiveChain(c, cancel)
+	defer sch.deleteActiveChain(c.ChainID)
+	chainContext = context.WithValue(chainContext, taskResultsKey{}, &res.Tasks)
+	startedAt := time.Now()
+	res.Succeeded = sch.executeChain(chainContext, c)
+	res.Duration = time.Since(startedAt)
+	return
+}
+
+// ExecTask executes the single task through the same pipeline as chain tasks, i.e. in the transaction and with
+// the execution log, and returns the result. The task is stored as the self-destructive chain of this client deleted
+// after the run, so the execution log and the audit of the chain changes remain as the trail of the ad-hoc run
+func (sch *Scheduler) ExecTask(ctx context.Context, task pgengine.TaskDefinition) (res ChainResult, err error) {
+	def := pgengine.ChainDefinition{
+		Name:         "exec_" + newRunID()[:8],
+		MaxInstances: 1,
+		SelfDestruct: true,
+		ClientName:   sch.Config().ClientName,
+		Tasks:        []pgengine.TaskDefinition{task},
+	}
+	chainID, err := sch.pgengine.ImportChain(ctx, def)
+	if err != nil {
+		return
+	}
+	res, err = sch.RunChainOnce(ctx, strconv.Itoa(chainID))
+	if err != nil || !res.Succeeded { // deleted by the successful run otherwise
+		sch.pgengine.DeleteChainConfig(context.Background(), chainID)
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunChainOnce(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "-c", "scheduler_unit_test", "--password=somestrong")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT chain_id FROM timetable\\.chain").WithArgs("foo").WillReturnError(errors.New("no rows"))
+	_, err = sch.RunChainOnce(ctx, "foo")
+	assert.Error(t, err)
+
+	mock.ExpectQuery("SELECT chain_id FROM timetable\\.chain").WithArgs("42").
+		WillReturnRows(pgxmock.NewRows([]string{"chain_id"}).AddRow(42))
+	mock.ExpectQuery("SELECT chain_id, chain_name").WillReturnRows(pgxmock.NewRows(
+		[]string{"chain_id", "chain_name", "self_destruct", "exclusive_execution", "timeout", "max_instances",
+			"log_level", "log_sampling", "read_only", "owner"}).
+		AddRow(42, "foo", false, false, 0, 1, "", 1, false, "scheduler"))
+	mock.ExpectExec("INSERT INTO timetable\\.active_chain").WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	res, err := sch.RunChainOnce(ctx, "42")
+	assert.ErrorIs(t, err, ErrChainBusy)
+	assert.Equal(t, 42, res.ChainID)
+}
+
+func TestExecTask(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "-c", "scheduler_unit_test", "--password=somestrong")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	mock.ExpectBegin().WillReturnError(errors.New("error"))
+	_, err = sch.ExecTask(context.Background(), pgengine.TaskDefinition{Kind: "SQL", Command: "VACUUM"})
+	assert.Error(t, err)
+}
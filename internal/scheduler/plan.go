@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+)
+
+// scheduledChain is the live cron-style chain selected to build the fire plan
+type scheduledChain struct {
+	Chain
+	RunAt    string `db:"run_at"`
+	TimeZone string `db:"time_zone"`
+}
+
+// planEntry is the chain of the fire plan with its parsed schedule, nil schedule matches every minute
+// the same way timetable.is_cron_in_time() does for NULL
+type planEntry struct {
+	chain    Chain
+	schedule *cron.Schedule
+}
+
+// firePlan keeps live cron-style chains in memory, so due chains are found without querying the database.
+// The plan is reloaded when chains change or after the refresh period, in case a notification is lost
+type firePlan struct {
+	sync.Mutex
+	entries []planEntry
+	loc     *time.Location // time zone of the database session schedules are evaluated in
+	changes int64          // the number of chain changes the plan was loaded after
+	loaded  time.Time
+}
+
+// due returns chains of the plan scheduled at any of the specified number of minutes up to now
+func (p *firePlan) due(now time.Time, minutes int) []Chain {
+	chains := []Chain{}
+	now = now.In(p.loc)
+	for _, e := range p.entries {
+		for m := 0; m < minutes; m++ {
+			if e.schedule == nil || e.schedule.Match(now.Add(-time.Duration(m)*time.Minute)) {
+				chains = append(chains, e.chain)
+				break
+			}
+		}
+	}
+	return chains
+}
+
+// usePlan returns true if due chains are found using the fire plan. With the load balancing chains without
+// client name are assigned to clients on each check, so they are selected from the database
+func (sch *Scheduler) usePlan() bool {
+	return sch.Config().Resource.PlanRefresh > 0 && !sch.Config().Resource.LoadBalancing
+}
+
+// dueChains returns chains scheduled at any of the specified number of minutes up to now, reloading the fire plan
+// if any chain changed since it was loaded
+func (sch *Scheduler) dueChains(ctx context.Context, now time.Time, minutes int) ([]Chain, error) {
+	sch.plan.Lock()
+	defer sch.plan.Unlock()
+	changes := sch.pgengine.ChainChanges()
+	refresh := time.Duration(sch.Config().Resource.PlanRefresh) * time.Second
+	if sch.plan.loaded.IsZero() || changes != sch.plan.changes || now.Sub(sch.plan.loaded) >= refresh {
+		chains := []scheduledChain{}
+		if err := sch.pgengine.SelectScheduledChains(ctx, &chains); err != nil {
+			return nil, err
+		}
+		sch.plan.entries = sch.plan.entries[:0]
+		sch.plan.loc = time.UTC
+		if len(chains) > 0 {
+			loc, err := time.LoadLocation(chains[0].TimeZone)
+			if err != nil {
+				sch.l.WithError(err).WithField("timezone", chains[0].TimeZone).Warn("Cannot load database time zone, using UTC")
+			} else {
+				sch.plan.loc = loc
+			}
+		}
+		for _, c := range chains {
+			e := planEntry{chain: c.Chain}
+			if c.RunAt != "" {
+				schedule, err := cron.Parse(c.RunAt)
+				if err != nil {
+					sch.l.WithError(err).WithField("chain", c.ChainID).Error("Cannot parse chain schedule")
+					continue
+				}
+				e.schedule = schedule
+			}
+			sch.plan.entries = append(sch.plan.entries, e)
+		}
+		sch.plan.changes, sch.plan.loaded = changes, now
+		sch.l.WithField("count", len(sch.plan.entries)).Debug("Fire plan of scheduled chains reloaded")
+	}
+	return sch.plan.due(now, minutes), nil
+}
@@ -0,0 +1,42 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirePlanDue(t *testing.T) {
+	hourly, err := cron.Parse("0 * * * *")
+	assert.NoError(t, err)
+	loc, err := time.LoadLocation("Europe/Vienna")
+	assert.NoError(t, err)
+	daily, err := cron.Parse("30 2 * * *")
+	assert.NoError(t, err)
+	p := firePlan{loc: loc, entries: []planEntry{
+		{chain: Chain{ChainID: 1}, schedule: hourly},
+		{chain: Chain{ChainID: 2}, schedule: daily},
+		{chain: Chain{ChainID: 3}},
+	}}
+	now := time.Date(2026, 10, 16, 0, 30, 0, 0, time.UTC) // 02:30 in Vienna
+	assert.Equal(t, []Chain{{ChainID: 2}, {ChainID: 3}}, p.due(now, 1))
+	assert.Equal(t, []Chain{{ChainID: 1}, {ChainID: 2}, {ChainID: 3}}, p.due(now, 31), "missed minutes are checked")
+	assert.Equal(t, []Chain{{ChainID: 3}}, p.due(now.Add(time.Minute), 1))
+}
+
+func TestIntervalChainsChanged(t *testing.T) {
+	sch := New(pgengine.NewDB(nil, "scheduler_unit_test", "--plan-refresh=60"), log.Init(config.LoggingOpts{LogLevel: "error"}))
+	now := time.Now()
+	assert.True(t, sch.intervalChainsChanged(now, 0), "chains are not selected yet")
+	sch.intervalChanges, sch.intervalLoaded = 0, now
+	assert.False(t, sch.intervalChainsChanged(now.Add(time.Second), 0))
+	assert.True(t, sch.intervalChainsChanged(now.Add(time.Second), 1), "chains changed")
+	assert.True(t, sch.intervalChainsChanged(now.Add(time.Minute), 0), "refresh period passed")
+	sch.dropIntervalChains()
+	assert.True(t, sch.intervalChainsChanged(now.Add(time.Second), 0), "dropped chains are selected again")
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package scheduler
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in the new process group, so it can be signalled with all its children
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends SIGTERM, or SIGKILL if kill is true, to the process group led by the process
+func signalProcessGroup(p *os.Process, kill bool) error {
+	sig := syscall.SIGTERM
+	if kill {
+		sig = syscall.SIGKILL
+	}
+	return syscall.Kill(-p.Pid, sig)
+}
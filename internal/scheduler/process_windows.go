@@ -0,0 +1,22 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in the new process group, so console signals of the scheduler are not passed to it
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalProcessGroup kills the process, there is no graceful termination of process groups on Windows,
+// so the error is returned for the termination request and the process is killed without delay
+func signalProcessGroup(p *os.Process, kill bool) error {
+	if !kill {
+		return errors.New("graceful termination is not supported on Windows")
+	}
+	return p.Kill()
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendChainPolicy(t *testing.T) {
+	newScheduler := func(args ...string) *Scheduler {
+		args = append([]string{"scheduler_unit_test", "--queue-capacity=1"}, args...)
+		return New(pgengine.NewDB(nil, args...), log.Init(config.LoggingOpts{LogLevel: "error"}))
+	}
+
+	sch := newScheduler()
+	sch.SendChain(Chain{ChainID: 1})
+	sch.SendChain(Chain{ChainID: 2})
+	assert.Equal(t, 1, (<-sch.chainsChan).ChainID, "new chain is dropped")
+	assert.EqualValues(t, 1, sch.DroppedChains())
+
+	sch = newScheduler("--queue-policy=drop-oldest")
+	sch.SendChain(Chain{ChainID: 1})
+	sch.SendChain(Chain{ChainID: 2})
+	assert.Equal(t, 2, (<-sch.chainsChan).ChainID, "oldest chain is dropped")
+	assert.EqualValues(t, 1, sch.DroppedChains())
+
+	sch = newScheduler("--queue-policy=block", "--queue-timeout=1")
+	sch.SendChain(Chain{ChainID: 1})
+	go func() { <-sch.chainsChan }()
+	sch.SendChain(Chain{ChainID: 2})
+	assert.Equal(t, 2, (<-sch.chainsChan).ChainID, "chain waits for the place")
+	sch.SendChain(Chain{ChainID: 3})
+	sch.SendChain(Chain{ChainID: 4})
+	assert.EqualValues(t, 1, sch.DroppedChains(), "chain is dropped after the timeout")
+}
+
+func TestQueueCapacity(t *testing.T) {
+	assert.Equal(t, minChannelCapacity, queueCapacity(config.ResourceOpts{CronWorkers: 16}))
+	assert.Equal(t, 4096, queueCapacity(config.ResourceOpts{CronWorkers: 2048}))
+	assert.Equal(t, 10, queueCapacity(config.ResourceOpts{CronWorkers: 2048, QueueCapacity: 10}))
+}
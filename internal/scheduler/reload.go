@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"io"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+)
+
+// Reload re-reads the configuration and applies resource and logging settings without restart.
+// Chains being executed and chains already queued are not affected
+func (sch *Scheduler) Reload() error {
+	opts, err := config.NewConfig(io.Discard)
+	if err != nil {
+		return err
+	}
+	return sch.applyConfig(*opts)
+}
+
+// applyConfig applies reloadable settings: number of workers, timeouts and log level
+func (sch *Scheduler) applyConfig(opts config.CmdOptions) error {
+	if err := log.SetLevel(sch.l, opts.Logging.LogLevel); err != nil {
+		return err
+	}
+	sch.configMutex.Lock()
+	sch.pgengine.Resource = opts.Resource
+	sch.pgengine.Logging.LogLevel = opts.Logging.LogLevel
+	sch.configMutex.Unlock()
+	sch.setWorkers(opts.Resource.CronWorkers, opts.Resource.IntervalWorkers)
+	sch.l.WithField("resource", opts.Resource).WithField("log-level", opts.Logging.LogLevel).Info("Configuration reloaded")
+	return nil
+}
+
+// setWorkers starts or stops workers to match the specified numbers. Stopped workers finish the chain
+// being executed before exit. Does nothing if the main loop is not started yet
+func (sch *Scheduler) setWorkers(cronWorkers int, intervalWorkers int) {
+	sch.workersMutex.Lock()
+	defer sch.workersMutex.Unlock()
+	if sch.workersCtx == nil {
+		return
+	}
+	for len(sch.cronWorkers) < cronWorkers {
+		quit := make(chan struct{})
+		sch.cronWorkers = append(sch.cronWorkers, quit)
+		go sch.chainWorker(sch.workersCtx, quit, sch.chainsChan)
+	}
+	for len(sch.cronWorkers) > cronWorkers {
+		close(sch.cronWorkers[len(sch.cronWorkers)-1])
+		sch.cronWorkers = sch.cronWorkers[:len(sch.cronWorkers)-1]
+	}
+	for len(sch.intervalWorkers) < intervalWorkers {
+		quit := make(chan struct{})
+		sch.intervalWorkers = append(sch.intervalWorkers, quit)
+		go sch.intervalChainWorker(sch.workersCtx, quit, sch.ichainsChan)
+	}
+	for len(sch.intervalWorkers) > intervalWorkers {
+		close(sch.intervalWorkers[len(sch.intervalWorkers)-1])
+		sch.intervalWorkers = sch.intervalWorkers[:len(sch.intervalWorkers)-1]
+	}
+}
@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyConfig(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	opts := *config.NewCmdOptions("-c", "scheduler_unit_test", "--cron-workers=2", "--interval-workers=3",
+		"--task-timeout=100", "--log-level=debug")
+	// workers are not started yet
+	assert.NoError(t, sch.applyConfig(opts))
+	assert.Empty(t, sch.cronWorkers)
+	assert.Equal(t, 100, sch.Config().Resource.TaskTimeout)
+	assert.Equal(t, "debug", sch.Config().Logging.LogLevel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sch.workersCtx = ctx
+	assert.NoError(t, sch.applyConfig(opts))
+	assert.Len(t, sch.cronWorkers, 2)
+	assert.Len(t, sch.intervalWorkers, 3)
+
+	opts.Resource.CronWorkers = 1
+	opts.Resource.IntervalWorkers = 1
+	assert.NoError(t, sch.applyConfig(opts))
+	assert.Len(t, sch.cronWorkers, 1)
+	assert.Len(t, sch.intervalWorkers, 1)
+
+	opts.Logging.LogLevel = "foobar"
+	assert.Error(t, sch.applyConfig(opts))
+}
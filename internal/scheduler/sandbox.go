@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// sandboxPath is the only executable search path available to sandboxed commands
+const sandboxPath = "/usr/local/bin:/usr/bin:/bin"
+
+type sandboxKey struct{}
+
+// withSandbox returns the context requesting the PROGRAM task to run in the sandbox
+func withSandbox(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sandboxKey{}, true)
+}
+
+// sandboxed returns true if the PROGRAM task of the context must run in the sandbox
+func sandboxed(ctx context.Context) bool {
+	sandbox, _ := ctx.Value(sandboxKey{}).(bool)
+	return sandbox
+}
+
+// setSandbox makes the command run in the empty temporary directory with the cleared environment
+// containing only env, PATH, HOME and TMPDIR, and isolates it further where the platform allows.
+// The returned function removes the directory and must be called after the command finished
+func setSandbox(cmd *exec.Cmd, env []string) (cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "pg_timetable_sandbox_")
+	if err != nil {
+		return nil, err
+	}
+	cmd.Dir = dir
+	cmd.Env = append([]string{"PATH=" + sandboxPath, "HOME=" + dir, "TMPDIR=" + dir}, env...)
+	isolate(cmd)
+	return func() { _ = os.RemoveAll(dir) }, nil
+}
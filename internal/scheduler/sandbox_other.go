@@ -0,0 +1,9 @@
+//go:build !linux
+
+package scheduler
+
+import "os/exec"
+
+// isolate does nothing, namespaces are not available, so the sandbox is limited to the working directory
+// and the environment
+func isolate(cmd *exec.Cmd) {}
@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Linux specific commands are used")
+	}
+	t.Setenv("PGTT_SANDBOX_SECRET", "foo")
+	ctx := context.Background()
+	assert.False(t, sandboxed(ctx))
+	ctx = withRunID(withSandbox(ctx), "bar")
+	assert.True(t, sandboxed(ctx))
+
+	out, err := realCommander{}.CombinedOutput(ctx, "sh", "-c", "echo $PGTT_SANDBOX_SECRET/$PG_TIMETABLE_RUN_ID; pwd; echo $$")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "/bar", lines[0], "environment of the scheduler must be cleared")
+	assert.Contains(t, lines[1], "pg_timetable_sandbox_")
+	_, err = os.Stat(lines[1])
+	assert.True(t, os.IsNotExist(err), "working directory must be removed")
+	assert.Equal(t, "1", lines[2], "command must run in the new PID namespace")
+}
@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+)
+
+// GetChainNextRuns returns the upcoming fire times of the chain evaluated in the database time zone
+func (sch *Scheduler) GetChainNextRuns(ctx context.Context, chainID int, count int) ([]time.Time, error) {
+	runAt, timeZone, err := sch.pgengine.SelectChainSchedule(ctx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := cron.Parse(runAt)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		sch.l.WithError(err).WithField("timezone", timeZone).Warn("Cannot load database time zone, using UTC")
+		loc = time.UTC
+	}
+	return schedule.NextN(time.Now().In(loc), count), nil
+}
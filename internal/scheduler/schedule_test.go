@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/cron"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetChainNextRuns(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT.+run_at").WillReturnError(errors.New("error"))
+	_, err = sch.GetChainNextRuns(ctx, 42, 10)
+	assert.Error(t, err)
+
+	mock.ExpectQuery("SELECT.+run_at").
+		WillReturnRows(pgxmock.NewRows([]string{"run_at", "current_setting"}).AddRow("@reboot", "UTC"))
+	_, err = sch.GetChainNextRuns(ctx, 42, 10)
+	assert.ErrorIs(t, err, cron.ErrNotCron)
+
+	mock.ExpectQuery("SELECT.+run_at").
+		WillReturnRows(pgxmock.NewRows([]string{"run_at", "current_setting"}).AddRow("0 * * * *", "Unknown/Zone"))
+	runs, err := sch.GetChainNextRuns(ctx, 42, 3)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+	assert.Zero(t, runs[0].Minute())
+}
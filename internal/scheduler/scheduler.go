@@ -3,14 +3,16 @@ package scheduler
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/config"
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/metrics"
 	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
 )
 
-// the main loop period. Should be 60 (sec) for release configuration. Set to 10 (sec) for debug purposes
+// the default main loop period in seconds, used if the cron interval is not configured
 const refetchTimeout = 60
 
 // the min capacity of chains channels
@@ -26,22 +28,64 @@ const (
 	ContextCancelledStatus
 	// Shutdown specifies proper termination of the session
 	ShutdownStatus
+	// DrainedStatus specifies running chains are finished for the instance taking over
+	DrainedStatus
 )
 
 // Scheduler is the main class for running the tasks
 type Scheduler struct {
-	pgengine    *pgengine.PgEngine
-	l           log.LoggerIface
-	chainsChan  chan Chain         // channel for passing chains to workers
-	ichainsChan chan IntervalChain // channel for passing interval chains to workers
+	heartbeat     int64 // UnixNano time chains were retrieved from the database for the last time, accessed atomically, must be 64-bit aligned
+	droppedChains int64 // the number of scheduled chain runs dropped because the queue was full, accessed atomically, must be 64-bit aligned
+	pgengine      *pgengine.PgEngine
+	l             log.LoggerIface
+	chainsChan    chan Chain         // channel for passing chains to workers
+	ichainsChan   chan IntervalChain // channel for passing interval chains to workers
 
 	exclusiveMutex sync.RWMutex //read-write mutex for running regular and exclusive chains
 
-	activeChains     map[int]func() // map of chain ID with context cancel() function to abort chain by request
+	activeChains     map[int]*ActiveChain // map of chain ID with running chain information and cancel() function to abort it
 	activeChainMutex sync.Mutex
 
-	intervalChains     map[int]IntervalChain // map of active chains, updated every minute
+	chainLogLevels     map[int]string // map of chain ID with the log level set by SetChainLogLevel()
+	chainLogLevelMutex sync.Mutex
+
+	chainRunCounts     map[int]int // map of chain ID with the number of runs used for log sampling
+	chainRunCountMutex sync.Mutex
+
+	chainDurations     map[int]*durationHistory // map of chain ID with durations of recent successful runs
+	chainDurationMutex sync.Mutex
+
+	chainFailures     map[int]int // map of chain ID with the number of consecutive failed runs
+	chainFailureMutex sync.Mutex
+
+	intervalChains     map[int]IntervalChain // map of active chains, updated when chains change
+	intervalChanges    int64                 // the number of chain changes interval chains were selected after
+	intervalLoaded     time.Time             // time interval chains were selected for the last time
 	intervalChainMutex sync.Mutex
+	wheel              timerWheel // reschedules sub-minute interval chains
+
+	configMutex sync.RWMutex // protects configuration changed by Reload()
+
+	workersCtx      context.Context // context of the main loop, nil if workers are not started
+	cronWorkers     []chan struct{} // quit channels of running chain workers
+	intervalWorkers []chan struct{} // quit channels of running interval chain workers
+	workersMutex    sync.Mutex
+
+	maintenance int32 // 1 if only exclusive chains are executed, accessed atomically
+
+	busyCronWorkers int32 // the number of chain workers executing chains, accessed atomically
+
+	clockSkewed int32 // 1 if the clock skew exceeded the limit during the last check, accessed atomically
+
+	leader  int32         // 1 if chains are executed, i.e. the instance is the leader of the group, accessed atomically
+	elected chan struct{} // wakes up the main loop when the instance is elected as the leader
+
+	draining int32         // 1 if running chains are finished for the instance taking over, accessed atomically
+	drain    chan struct{} // wakes up the main loop to drain chains
+
+	lastCronCheck time.Time // the minute scheduled chains were checked for the last time, used by the main loop only
+
+	plan firePlan // live scheduled chains checked in memory
 
 	shutdown chan struct{} // closed when shutdown is called
 	status   RunStatus
@@ -55,18 +99,37 @@ func Max(x, y int) int {
 	return x
 }
 
+// queueCapacity returns the capacity of the channel passing scheduled chains to workers
+func queueCapacity(opts config.ResourceOpts) int {
+	if opts.QueueCapacity > 0 {
+		return opts.QueueCapacity
+	}
+	return Max(minChannelCapacity, opts.CronWorkers*2)
+}
+
 // New returns a new instance of Scheduler
 func New(pge *pgengine.PgEngine, logger log.LoggerIface) *Scheduler {
-	return &Scheduler{
+	sch := &Scheduler{
 		l:              logger,
 		pgengine:       pge,
-		chainsChan:     make(chan Chain, Max(minChannelCapacity, pge.Resource.CronWorkers*2)),
+		chainsChan:     make(chan Chain, queueCapacity(pge.Resource)),
 		ichainsChan:    make(chan IntervalChain, Max(minChannelCapacity, pge.Resource.IntervalWorkers*2)),
-		activeChains:   make(map[int]func()), //holds cancel() functions to stop chains
+		activeChains:   make(map[int]*ActiveChain), //holds cancel() functions to stop chains
 		intervalChains: make(map[int]IntervalChain),
+		chainLogLevels: make(map[int]string),
+		chainRunCounts: make(map[int]int),
+		chainDurations: make(map[int]*durationHistory),
+		chainFailures:  make(map[int]int),
+		elected:        make(chan struct{}, 1),
+		drain:          make(chan struct{}, 1),
 		shutdown:       make(chan struct{}),
 		status:         RunningStatus,
+		heartbeat:      time.Now().UnixNano(),
+	}
+	if !pge.HA.Enabled() {
+		sch.leader = 1
 	}
+	return sch
 }
 
 // Shutdown terminates the current session
@@ -76,6 +139,8 @@ func (sch *Scheduler) Shutdown() {
 
 // Config returns the current configuration for application
 func (sch *Scheduler) Config() config.CmdOptions {
+	sch.configMutex.RLock()
+	defer sch.configMutex.RUnlock()
 	return sch.pgengine.CmdOptions
 }
 
@@ -84,47 +149,95 @@ func (sch *Scheduler) IsReady() bool {
 	return sch.status == RunningStatus
 }
 
+// IsAlive returns true if the main loop retrieved chains from the database recently, i.e. the scheduler is not hung
+func (sch *Scheduler) IsAlive() bool {
+	if sch.Config().Start.Debug { // only asynchronous chains are executed, the main loop is not running
+		return true
+	}
+	return time.Since(time.Unix(0, atomic.LoadInt64(&sch.heartbeat))) < 2*sch.cronInterval()
+}
+
 // Run executes jobs. Returns RunStatus why it terminated.
 // There are only two possibilities: dropped connection and cancelled context.
 func (sch *Scheduler) Run(ctx context.Context) RunStatus {
 	// create sleeping workers waiting data on channel
-	for w := 1; w <= sch.Config().Resource.CronWorkers; w++ {
-		workerCtx, cancel := context.WithCancel(ctx)
-		defer cancel()
-		go sch.chainWorker(workerCtx, sch.chainsChan)
-	}
-	for w := 1; w <= sch.Config().Resource.IntervalWorkers; w++ {
-		workerCtx, cancel := context.WithCancel(ctx)
-		defer cancel()
-		go sch.intervalChainWorker(workerCtx, sch.ichainsChan)
-	}
+	workersCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sch.workersMutex.Lock()
+	sch.workersCtx = workersCtx
+	sch.workersMutex.Unlock()
+	sch.setWorkers(sch.Config().Resource.CronWorkers, sch.Config().Resource.IntervalWorkers)
 	ctx = log.WithLogger(ctx, sch.l)
 
 	/*
 		Loop forever or until we ask it to stop.
 		First loop fetches notifications.
-		Main loop works every refetchTimeout seconds and runs chains.
+		Main loop works every cron interval seconds and runs chains.
 	*/
 	sch.l.Info("Accepting asynchronous chains execution requests...")
 	go sch.retrieveAsyncChainsAndRun(ctx)
+	go sch.runTimerWheel(workersCtx)
 
 	if sch.Config().Start.Debug { //run blocking notifications receiving
 		sch.pgengine.HandleNotifications(ctx)
 		return ContextCancelledStatus
 	}
 
-	sch.l.Debug("Checking for @reboot task chains...")
-	sch.retrieveChainsAndRun(ctx, true)
+	if sch.Config().HA.Enabled() {
+		sch.campaign(workersCtx)
+		go sch.runElection(workersCtx)
+	}
+
+	if sch.Config().Digest.Enabled() {
+		go sch.runDigest(ctx)
+	}
 
+	if sch.Config().GitOps.Enabled() {
+		go sch.runGitOps(ctx)
+	}
+
+	if sch.Config().Archive.Enabled() {
+		go sch.runArchiver(ctx)
+	}
+
+	rebooted := false
 	for {
-		sch.l.Debug("Checking for task chains...")
-		go sch.retrieveChainsAndRun(ctx, false)
-		sch.l.Debug("Checking for interval task chains...")
-		go sch.retrieveIntervalChainsAndRun(ctx)
+		if !sch.checkClockSkew(ctx) {
+			sch.dropIntervalChains()
+			atomic.StoreInt64(&sch.heartbeat, time.Now().UnixNano())
+		} else if sch.IsLeader() {
+			if !rebooted {
+				sch.l.Debug("Checking for @reboot task chains...")
+				sch.retrieveChainsAndRun(ctx, true, 0)
+				rebooted = true
+			}
+			if sch.Config().Resource.LoadBalancing {
+				go sch.reportCapacity(ctx)
+			}
+			if minutes := sch.cronMinutesToCheck(time.Now()); minutes > 0 {
+				sch.l.Debug("Checking for task chains...")
+				go sch.retrieveChainsAndRun(ctx, false, minutes)
+			}
+			sch.l.Debug("Checking for interval task chains...")
+			go sch.retrieveIntervalChainsAndRun(ctx)
+			if metrics.Enabled() {
+				go sch.reportChainFreshness(ctx)
+			}
+		} else {
+			sch.l.Debug("Not the leader, skipping task chains")
+			sch.lastCronCheck = time.Time{} // minutes passed are checked by the leader
+			atomic.StoreInt64(&sch.heartbeat, time.Now().UnixNano())
+		}
+		go sch.reportStatus(ctx)
 
 		select {
-		case <-time.After(refetchTimeout * time.Second):
+		case <-time.After(sch.cronInterval()):
 			// pass
+		case <-sch.elected:
+			// start chains immediately
+		case <-sch.drain:
+			sch.status = DrainedStatus
+			sch.drainChains(ctx)
 		case <-ctx.Done():
 			sch.status = ContextCancelledStatus
 		case <-sch.shutdown:
@@ -137,3 +250,43 @@ func (sch *Scheduler) Run(ctx context.Context) RunStatus {
 		}
 	}
 }
+
+// reportChainFreshness sends the time passed since the last successful run of every chain to metrics
+func (sch *Scheduler) reportChainFreshness(ctx context.Context) {
+	var chains []pgengine.ChainFreshness
+	if err := sch.pgengine.SelectChainFreshness(ctx, &chains); err != nil {
+		sch.l.WithError(err).Error("Could not query chain freshness")
+		return
+	}
+	for _, chain := range chains {
+		if chain.SecondsSinceSuccess != nil {
+			metrics.ChainFreshness(chain.ChainName, *chain.SecondsSinceSuccess)
+		}
+	}
+}
+
+// cronInterval returns the period of the main loop
+func (sch *Scheduler) cronInterval() time.Duration {
+	if interval := sch.Config().Resource.CronInterval; interval > 0 {
+		return time.Duration(interval) * time.Second
+	}
+	return refetchTimeout * time.Second
+}
+
+// cronMinutesToCheck returns the number of minutes up to now that scheduled chains should be checked for.
+// Returns 0 if the current minute is already checked, and more than 1 if previous minutes were skipped,
+// because the cron interval is longer than a minute or the main loop was delayed
+func (sch *Scheduler) cronMinutesToCheck(now time.Time) int {
+	now = now.Truncate(time.Minute)
+	minutes := 1
+	if !sch.lastCronCheck.IsZero() {
+		minutes = int(now.Sub(sch.lastCronCheck) / time.Minute)
+	}
+	if maxMinutes := int(sch.cronInterval()/time.Minute) + 1; minutes > maxMinutes {
+		minutes = maxMinutes // do not catch up the time the scheduler was not running, e.g. system sleep
+	}
+	if minutes > 0 {
+		sch.lastCronCheck = now
+	}
+	return minutes
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/cybertec-postgresql/pg_timetable/internal/config"
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
 	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
 )
 
 var pge *pgengine.PgEngine
@@ -62,3 +63,34 @@ func TestRun(t *testing.T) {
 	assert.True(t, sch.IsReady())
 	assert.Equal(t, ShutdownStatus, sch.Run(context.Background()))
 }
+
+func TestCronMinutesToCheck(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	now := time.Date(2022, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	assert.Equal(t, time.Minute, sch.cronInterval())
+	assert.Equal(t, 1, sch.cronMinutesToCheck(now), "the current minute is checked at the start")
+	assert.Equal(t, 0, sch.cronMinutesToCheck(now.Add(10*time.Second)), "the same minute is checked only once")
+	assert.Equal(t, 1, sch.cronMinutesToCheck(now.Add(time.Minute)))
+	assert.Equal(t, 2, sch.cronMinutesToCheck(now.Add(3*time.Minute)), "skipped minute is caught up")
+	assert.Equal(t, 2, sch.cronMinutesToCheck(now.Add(time.Hour)), "no catching up after sleep")
+
+	pge.Resource.CronInterval = 300
+	assert.Equal(t, 5*time.Minute, sch.cronInterval())
+	assert.Equal(t, 5, sch.cronMinutesToCheck(now.Add(time.Hour+5*time.Minute)))
+}
+
+func TestIsAlive(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	assert.True(t, sch.IsAlive())
+	sch.heartbeat = time.Now().Add(-3 * time.Minute).UnixNano()
+	assert.False(t, sch.IsAlive(), "chains are not retrieved for two cron intervals")
+	pge.Start.Debug = true
+	assert.True(t, sch.IsAlive(), "the main loop is not running in debug mode")
+}
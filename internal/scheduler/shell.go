@@ -5,21 +5,61 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/tracing"
 )
 
 type commander interface {
 	CombinedOutput(context.Context, string, ...string) ([]byte, error)
 }
 
-type realCommander struct{}
+type realCommander struct {
+	Limits    ProgramLimits
+	KillGrace time.Duration // time to terminate gracefully on cancellation before the process group is killed
+	Environ   []string      // environment inherited from the scheduler, the whole one if nil
+	Runtime   string        // container engine CLI running tasks in containers, e.g. docker or podman
+}
 
-// CombinedOutput executes program command and returns combined stdout and stderr
+// CombinedOutput executes program command and returns combined stdout and stderr.
+// The chain execution identifier is passed in PG_TIMETABLE_RUN_ID environment variable,
+// the trace context of the task is passed in TRACEPARENT and TRACESTATE environment variables.
+// The command is started in its own process group, so child processes are terminated along with it.
+// Extra variables of the task are added to the inherited environment, sandboxed commands inherit nothing.
+// Commands of tasks with the container configured are executed in the container receiving only these variables
 func (c realCommander) CombinedOutput(ctx context.Context, command string, args ...string) ([]byte, error) {
-	cmd := exec.CommandContext(ctx, command, args...)
+	env := append(append([]string{}, taskEnv(ctx)...), tracing.Environ(ctx)...)
+	if runID := RunID(ctx); runID != "" {
+		env = append(env, "PG_TIMETABLE_RUN_ID="+runID)
+	}
+	if container := taskContainer(ctx); container != nil {
+		return c.containerOutput(ctx, container, env, command, args)
+	}
+	cmd := exec.Command(command, args...)
 	cmd.Stdin = nil
-	return cmd.CombinedOutput()
+	setProcessGroup(cmd)
+	if sandboxed(ctx) {
+		cleanup, err := setSandbox(cmd, env)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+	} else {
+		cmd.Env = append(c.environ(), env...)
+	}
+	return c.Limits.combinedOutput(ctx, cmd, c.KillGrace)
+}
+
+// environ returns the copy of the environment inherited by commands
+func (c realCommander) environ() []string {
+	if c.Environ == nil {
+		return os.Environ()
+	}
+	return append([]string{}, c.Environ...)
 }
 
 // Cmd executes a command
@@ -32,6 +72,15 @@ func (sch *Scheduler) ExecuteProgramCommand(ctx context.Context, command string,
 	if command == "" {
 		return -1, "", errors.New("Program command cannot be empty")
 	}
+	cmd := Cmd
+	if _, ok := cmd.(realCommander); ok {
+		cmd = realCommander{
+			Limits:    sch.programLimits(),
+			KillGrace: time.Duration(sch.Config().Resource.ProgramKillGrace) * time.Second,
+			Environ:   filterEnv(os.Environ(), sch.Config().Resource.ProgramEnvAllow, sch.Config().Resource.ProgramEnvDeny),
+			Runtime:   sch.Config().Resource.ContainerRuntime,
+		}
+	}
 	if len(paramValues) == 0 { //mimic empty param
 		paramValues = []string{""}
 	}
@@ -42,10 +91,10 @@ func (sch *Scheduler) ExecuteProgramCommand(ctx context.Context, command string,
 				return -1, "", err
 			}
 		}
-		out, err := Cmd.CombinedOutput(ctx, command, params...) // #nosec
+		out, err := cmd.CombinedOutput(ctx, command, params...) // #nosec
 		cmdLine := fmt.Sprintf("%s %v: ", command, params)
 		stdout = strings.TrimSpace(string(out))
-		l := sch.l.WithField("command", cmdLine).
+		l := log.GetLogger(ctx).WithField("command", cmdLine).
 			WithField("output", string(out))
 		if err != nil {
 			//check if we're dealing with an ExitError - i.e. return code other than 0
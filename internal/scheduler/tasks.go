@@ -23,12 +23,19 @@ var Tasks = map[string](func(context.Context, *Scheduler, string) (string, error
 	"CopyToFile":   taskCopyToFile,
 	"Shutdown":     taskShutdown}
 
+// tenantTasks are builtin tasks available to multi-tenant chains, others access files, the network
+// or the scheduler itself with the privileges of the scheduler
+var tenantTasks = map[string]bool{"NoOp": true, "Sleep": true, "Log": true}
+
 func (sch *Scheduler) executeTask(ctx context.Context, name string, paramValues []string) (stdout string, err error) {
 	var s string
 	f := Tasks[name]
 	if f == nil {
 		return "", errors.New("No built-in task found: " + name)
 	}
+	if sch.Config().BuiltinDisabled(name) {
+		return "", errors.New("Built-in task is disabled: " + name)
+	}
 	l := log.GetLogger(ctx)
 	l.WithField("name", name).Debugf("Executing builtin task with parameters %+q", paramValues)
 	if len(paramValues) == 0 {
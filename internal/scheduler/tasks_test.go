@@ -48,4 +48,10 @@ func TestExecuteTask(t *testing.T) {
 		"Downlod incorrect url should fail")
 
 	assert.NoError(t, et("Shutdown", []string{}))
+
+	pge.DisableBuiltins = "Shutdown, CopyFromFile"
+	assert.EqualError(t, et("Shutdown", []string{}), "Built-in task is disabled: Shutdown")
+	assert.EqualError(t, et("CopyFromFile", []string{`{"sql": "COPY", "filename": "foo"}`}),
+		"Built-in task is disabled: CopyFromFile")
+	assert.NoError(t, et("NoOp", []string{}))
 }
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrQueueFull is returned when the chain cannot be queued for execution
+var ErrQueueFull = errors.New("chain execution queue is full")
+
+// StartChain queues the live chain with the specified name for immediate execution
+// passing the optional payload to its tasks. It returns the ID of the chain
+func (sch *Scheduler) StartChain(ctx context.Context, chainName string, payload string) (int, error) {
+	var c Chain
+	if err := sch.pgengine.SelectChainByName(ctx, &c, chainName); err != nil {
+		return 0, err
+	}
+	return c.ChainID, sch.queueChain(c, payload)
+}
+
+// StartChainByID queues the chain with the specified ID for immediate execution
+// passing the optional payload to its tasks
+func (sch *Scheduler) StartChainByID(ctx context.Context, chainID int, payload string) error {
+	var c Chain
+	if err := sch.pgengine.SelectChain(ctx, &c, chainID); err != nil {
+		return err
+	}
+	return sch.queueChain(c, payload)
+}
+
+func (sch *Scheduler) queueChain(c Chain, payload string) error {
+	c.Payload = payload
+	select {
+	case sch.chainsChan <- c:
+		sch.l.WithField("chain", c.ChainID).Info("Chain started on demand")
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// QueueLength returns the number of chains waiting for a free worker
+func (sch *Scheduler) QueueLength() int {
+	return len(sch.chainsChan)
+}
+
+// DroppedChains returns the number of scheduled chain runs dropped because the queue was full
+func (sch *Scheduler) DroppedChains() int64 {
+	return atomic.LoadInt64(&sch.droppedChains)
+}
@@ -0,0 +1,52 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/pashagolub/pgxmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartChain(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	pge := pgengine.NewDB(mock, "scheduler_unit_test")
+	pge.ClientName = "scheduler_unit_test"
+	sch := New(pge, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT.+chain_name").WithArgs("scheduler_unit_test", "foo").WillReturnError(errors.New("error"))
+	_, err = sch.StartChain(ctx, "foo", "")
+	assert.Error(t, err)
+
+	rows := pgxmock.NewRows([]string{"chain_id", "chain_name", "self_destruct", "exclusive_execution", "timeout", "max_instances"})
+	mock.ExpectQuery("SELECT.+chain_name").WillReturnRows(rows.AddRow(42, "foo", false, false, 0, 16))
+	chainID, err := sch.StartChain(ctx, "foo", "payload")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 42, chainID)
+	assert.Equal(t, 1, sch.QueueLength())
+	select {
+	case c := <-sch.chainsChan:
+		assert.Equal(t, "payload", c.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("chain is not sent to the execution channel")
+	}
+
+	mock.ExpectQuery("SELECT.+chain_id").WithArgs("scheduler_unit_test", 42).WillReturnError(errors.New("error"))
+	assert.Error(t, sch.StartChainByID(ctx, 42, ""))
+
+	rows = pgxmock.NewRows([]string{"chain_id", "chain_name", "self_destruct", "exclusive_execution", "timeout", "max_instances"})
+	mock.ExpectQuery("SELECT.+chain_id").WillReturnRows(rows.AddRow(42, "foo", false, false, 0, 16))
+	assert.NoError(t, sch.StartChainByID(ctx, 42, ""))
+	assert.Equal(t, 1, sch.QueueLength())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
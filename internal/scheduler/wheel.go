@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// wheelSize is the number of one second slots of the timer wheel, interval chains running more often
+// than once a wheel turn are rescheduled with it
+const wheelSize = 60
+
+// timerWheel reschedules sub-minute interval chains. Instead of the timer for every run, chains are put into
+// one second slots and the single ticker sends chains of the current slot to workers
+type timerWheel struct {
+	sync.Mutex
+	slots   [wheelSize][]IntervalChain
+	pos     int
+	running bool
+}
+
+// add puts the chain into the slot its interval ahead, returns false if the chain cannot be kept in the wheel
+func (w *timerWheel) add(ichain IntervalChain) bool {
+	if ichain.Interval <= 0 || ichain.Interval >= wheelSize {
+		return false
+	}
+	w.Lock()
+	defer w.Unlock()
+	if !w.running {
+		return false
+	}
+	slot := (w.pos + ichain.Interval) % wheelSize
+	w.slots[slot] = append(w.slots[slot], ichain)
+	return true
+}
+
+// advance turns the wheel by one slot and returns chains of the slot
+func (w *timerWheel) advance() []IntervalChain {
+	w.Lock()
+	defer w.Unlock()
+	w.pos = (w.pos + 1) % wheelSize
+	due := w.slots[w.pos]
+	w.slots[w.pos] = nil
+	return due
+}
+
+// setRunning starts or stops accepting chains, chains left in the wheel are dropped on stop
+func (w *timerWheel) setRunning(running bool) {
+	w.Lock()
+	defer w.Unlock()
+	w.running = running
+	if !running {
+		w.slots = [wheelSize][]IntervalChain{}
+	}
+}
+
+// runTimerWheel turns the timer wheel every second until the context is cancelled
+func (sch *Scheduler) runTimerWheel(ctx context.Context) {
+	sch.wheel.setRunning(true)
+	defer sch.wheel.setRunning(false)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, ichain := range sch.wheel.advance() {
+				if sch.isValid(ichain) {
+					sch.SendIntervalChain(ichain)
+				}
+			}
+		}
+	}
+}
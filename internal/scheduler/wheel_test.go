@@ -0,0 +1,35 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimerWheel(t *testing.T) {
+	var w timerWheel
+	every2 := IntervalChain{Chain: Chain{ChainID: 1}, Interval: 2}
+	assert.False(t, w.add(every2), "stopped wheel accepts no chains")
+	w.setRunning(true)
+	assert.True(t, w.add(every2))
+	assert.False(t, w.add(IntervalChain{Chain: Chain{ChainID: 2}, Interval: wheelSize}), "chains of minute intervals use timers")
+	assert.Empty(t, w.advance())
+	assert.Equal(t, []IntervalChain{every2}, w.advance())
+	assert.Empty(t, w.advance())
+
+	assert.True(t, w.add(every2))
+	w.setRunning(false)
+	assert.Empty(t, w.advance())
+	assert.Empty(t, w.advance(), "chains are dropped on stop")
+}
+
+func TestLimitInterval(t *testing.T) {
+	sch := New(pgengine.NewDB(nil, "scheduler_unit_test", "--min-interval=5"), log.Init(config.LoggingOpts{LogLevel: "error"}))
+	assert.Equal(t, 5, sch.limitInterval(IntervalChain{Interval: 0}, true).Interval)
+	assert.Equal(t, 15, sch.limitInterval(IntervalChain{Interval: 15}, true).Interval)
+	exclusive := IntervalChain{Chain: Chain{ExclusiveExecution: true}, Interval: 15}
+	assert.Equal(t, wheelSize, sch.limitInterval(exclusive, false).Interval, "exclusive chains run at most once a minute")
+}
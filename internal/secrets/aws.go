@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// awsFetchFunc returns the value of the secret or parameter with the specified name
+type awsFetchFunc func(ctx context.Context, cfg aws.Config, name string) (string, error)
+
+// AWSStore reads secrets from AWS Secrets Manager or AWS SSM Parameter Store using the default
+// credential chain. Values are cached until Invalidate is called, e.g. after authentication failure
+type AWSStore struct {
+	region string
+	fetch  awsFetchFunc
+	mu     sync.Mutex
+	cfg    *aws.Config
+	cache  map[string]string
+}
+
+// NewSecretsManager returns store for aws-sm://<secret-id>#<key> references
+func NewSecretsManager(region string) *AWSStore {
+	return &AWSStore{region: region, fetch: getSecretValue, cache: make(map[string]string)}
+}
+
+// NewParameterStore returns store for aws-ssm://<parameter-name>#<key> references
+func NewParameterStore(region string) *AWSStore {
+	return &AWSStore{region: region, fetch: getParameter, cache: make(map[string]string)}
+}
+
+func getSecretValue(ctx context.Context, cfg aws.Config, name string) (string, error) {
+	out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.SecretString), nil
+}
+
+func getParameter(ctx context.Context, cfg aws.Config, name string) (string, error) {
+	out, err := ssm.NewFromConfig(cfg).GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(name), WithDecryption: aws.Bool(true)})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+// Get returns the value of the secret with the specified name. If key is not empty,
+// the value is treated as JSON object and the value of the key is returned
+func (s *AWSStore) Get(ctx context.Context, name string, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.cache[name]
+	if !ok {
+		if s.cfg == nil {
+			var opts []func(*awsconfig.LoadOptions) error
+			if s.region != "" {
+				opts = append(opts, awsconfig.WithRegion(s.region))
+			}
+			cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+			if err != nil {
+				return "", err
+			}
+			s.cfg = &cfg
+		}
+		var err error
+		if value, err = s.fetch(ctx, *s.cfg, name); err != nil {
+			return "", err
+		}
+		s.cache[name] = value
+	}
+	return keyValue(name, value, key)
+}
+
+// Invalidate drops cached values, so they are fetched again on the next access
+func (s *AWSStore) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = make(map[string]string)
+}
@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSStore(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	s := NewSecretsManager("eu-central-1")
+	s.cfg = &aws.Config{}
+	s.fetch = func(ctx context.Context, cfg aws.Config, name string) (string, error) {
+		calls++
+		switch name {
+		case "prod/pg_timetable":
+			return `{"username": "scheduler", "password": "secret"}`, nil
+		case "plain":
+			return "plain_secret", nil
+		}
+		return "", errors.New("ResourceNotFoundException")
+	}
+
+	value, err := s.Get(ctx, "prod/pg_timetable", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", value)
+	value, err = s.Get(ctx, "prod/pg_timetable", "username")
+	assert.NoError(t, err)
+	assert.Equal(t, "scheduler", value)
+	assert.Equal(t, 1, calls, "secret value should be cached")
+
+	_, err = s.Get(ctx, "prod/pg_timetable", "unknown")
+	assert.Error(t, err)
+
+	value, err = s.Get(ctx, "plain", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain_secret", value)
+	_, err = s.Get(ctx, "plain", "password")
+	assert.Error(t, err, "plain secret is not a JSON object")
+
+	_, err = s.Get(ctx, "unknown", "")
+	assert.Error(t, err)
+
+	calls = 0
+	s.Invalidate()
+	_, err = s.Get(ctx, "prod/pg_timetable", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls, "invalidated secret should be fetched again")
+}
@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// EncryptedStore decrypts values encrypted with AES-256-GCM referenced as encrypted://<base64>#<key>,
+// so the plain value, e.g. connection string of the remote database, is never stored in the database.
+// The encryption key itself may be a reference to another secret store, e.g. aws-sm://pg_timetable#key
+type EncryptedStore struct {
+	key     string
+	resolve func(ctx context.Context, value string) (string, error)
+	mu      sync.Mutex
+	aead    cipher.AEAD
+}
+
+// NewEncryptedStore returns store for encrypted://<base64>#<key> references, key is the base64 encoded 32 bytes
+// or the reference resolved with resolve
+func NewEncryptedStore(key string, resolve func(ctx context.Context, value string) (string, error)) *EncryptedStore {
+	return &EncryptedStore{key: key, resolve: resolve}
+}
+
+// cipher returns AEAD cipher created with the encryption key, the key is resolved once and cached
+func (s *EncryptedStore) cipher(ctx context.Context) (cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aead != nil {
+		return s.aead, nil
+	}
+	encoded, err := s.resolve(ctx, s.key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve encryption key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("invalid encryption key, base64 encoded 32 bytes expected")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if s.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	return s.aead, nil
+}
+
+// Get returns the decrypted value. If key is not empty, the value is treated as JSON object
+// and the value of the key is returned
+func (s *EncryptedStore) Get(ctx context.Context, path string, key string) (string, error) {
+	aead, err := s.cipher(ctx)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(path)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("invalid encrypted value")
+	}
+	value, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("cannot decrypt value, the encryption key is wrong or the value is corrupted")
+	}
+	return keyValue("encrypted value", string(value), key)
+}
+
+// Encrypt returns the encrypted://<base64> reference to the value
+func (s *EncryptedStore) Encrypt(ctx context.Context, value string) (string, error) {
+	aead, err := s.cipher(ctx)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return "encrypted://" + base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+// Invalidate drops the cached cipher, so the encryption key is resolved again on the next access
+func (s *EncryptedStore) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aead = nil
+}
@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileStore reads secrets from files, e.g. Kubernetes secrets mounted into the container.
+// Directories of the files are watched, because Kubernetes replaces the whole directory content
+// atomically, and OnRotate is called when the content of any file read changes
+type FileStore struct {
+	ctx      context.Context
+	l        log.LoggerIface
+	mu       sync.Mutex
+	values   map[string]string
+	watcher  *fsnotify.Watcher
+	dirs     map[string]bool
+	OnRotate func() // called when the content of any file read changes
+}
+
+// NewFileStore returns store for file://<path>#<key> references. Files are watched
+// since the first one is read until ctx is cancelled
+func NewFileStore(ctx context.Context, logger log.LoggerIface) *FileStore {
+	return &FileStore{ctx: ctx, l: logger, values: make(map[string]string), dirs: make(map[string]bool)}
+}
+
+func readSecretFile(name string) (string, error) {
+	content, err := os.ReadFile(name)
+	return strings.TrimRight(string(content), "\r\n"), err
+}
+
+// Get returns the content of the file without trailing newlines. If key is not empty,
+// the content is treated as JSON object and the value of the key is returned
+func (s *FileStore) Get(ctx context.Context, name string, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[name]
+	if !ok {
+		var err error
+		if value, err = readSecretFile(name); err != nil {
+			return "", err
+		}
+		s.values[name] = value
+		s.watch(filepath.Dir(name))
+	}
+	return keyValue(name, value, key)
+}
+
+func (s *FileStore) watch(dir string) {
+	if s.dirs[dir] {
+		return
+	}
+	if s.watcher == nil {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			s.l.WithError(err).Error("Cannot watch secret files, changes will not be detected")
+			return
+		}
+		s.watcher = watcher
+		go s.run(watcher)
+	}
+	if err := s.watcher.Add(dir); err != nil {
+		s.l.WithError(err).WithField("dir", dir).Error("Cannot watch secret files")
+		return
+	}
+	s.dirs[dir] = true
+}
+
+// run processes file system events until the store context is cancelled
+func (s *FileStore) run(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case err := <-watcher.Errors:
+			s.l.WithError(err).Error("Error watching secret files")
+		case event := <-watcher.Events:
+			s.l.WithField("event", event).Debug("Secret files changed")
+			if s.reload() && s.OnRotate != nil {
+				s.OnRotate()
+			}
+		}
+	}
+}
+
+// reload reads all files again and returns true if the content of any of them changed
+func (s *FileStore) reload() (rotated bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, value := range s.values {
+		fresh, err := readSecretFile(name)
+		if err != nil { // file may be absent for a moment while being replaced
+			s.l.WithError(err).WithField("file", name).Debug("Cannot read secret file")
+			continue
+		}
+		if fresh != value {
+			s.l.WithField("file", name).Info("Secret file changed")
+			s.values[name] = fresh
+			rotated = true
+		}
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	password := filepath.Join(dir, "password")
+	assert.NoError(t, os.WriteFile(password, []byte("first\n"), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "creds.json"), []byte(`{"user": "scheduler"}`), 0600))
+
+	s := NewFileStore(ctx, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	rotated := make(chan struct{}, 1)
+	s.OnRotate = func() { rotated <- struct{}{} }
+
+	value, err := s.Get(ctx, password, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value, "trailing newline should be trimmed")
+
+	value, err = s.Get(ctx, filepath.Join(dir, "creds.json"), "user")
+	assert.NoError(t, err)
+	assert.Equal(t, "scheduler", value)
+
+	_, err = s.Get(ctx, filepath.Join(dir, "absent"), "")
+	assert.Error(t, err)
+
+	assert.NoError(t, os.WriteFile(password, []byte("second"), 0600))
+	select {
+	case <-rotated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rotation is not detected")
+	}
+	value, err = s.Get(ctx, password, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", value)
+}
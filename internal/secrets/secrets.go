@@ -0,0 +1,147 @@
+// Package secrets resolves references to the values kept in external secret stores,
+// e.g. vault://database/creds/scheduler#password, aws-sm://prod/pg_timetable#password,
+// file:///var/run/secrets/db/password or encrypted://<base64>
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+)
+
+// ErrNotConfigured is returned when the reference points to the secret store not configured
+var ErrNotConfigured = errors.New("secret store is not configured")
+
+// Store is the interface implemented by each secret store
+type Store interface {
+	// Get returns the value of the key stored in the secret with the specified path
+	Get(ctx context.Context, path string, key string) (string, error)
+}
+
+// Resolver resolves secret references using the store registered for the reference scheme
+type Resolver struct {
+	l        log.LoggerIface
+	stores   map[string]Store
+	mu       sync.Mutex
+	onRotate []func()
+}
+
+// NewResolver creates resolver with all secret stores configured in opts.
+// Background routines, e.g. lease renewal, are stopped when ctx is cancelled
+func NewResolver(ctx context.Context, opts config.SecretOpts, logger log.LoggerIface) *Resolver {
+	r := &Resolver{l: logger, stores: make(map[string]Store)}
+	if opts.VaultAddr != "" {
+		vault := NewVault(opts.VaultAddr, opts.VaultToken, logger)
+		vault.OnRotate = r.rotated
+		go vault.Run(ctx)
+		r.stores["vault"] = vault
+	}
+	files := NewFileStore(ctx, logger)
+	files.OnRotate = r.rotated
+	r.stores["file"] = files
+	r.stores["aws-sm"] = NewSecretsManager(opts.AWSRegion)
+	r.stores["aws-ssm"] = NewParameterStore(opts.AWSRegion)
+	if opts.EncryptionKey != "" {
+		r.stores["encrypted"] = NewEncryptedStore(opts.EncryptionKey, r.Resolve)
+	}
+	return r
+}
+
+// IsReference returns true if the value is a reference to the secret store, e.g. vault://path#key
+func IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	switch scheme {
+	case "vault", "aws-sm", "aws-ssm", "file", "encrypted":
+		return true
+	}
+	return false
+}
+
+// TaskReference converts secret://<scheme>/<path>#<key> value, e.g. of the task environment variable, to the reference
+// <scheme>://<path>#<key>. Paths of file secrets are absolute, e.g. secret://file/run/secrets/backup#password.
+// The explicit prefix is required, so the plain values looking like URLs, e.g. file:///data, are not resolved
+func TaskReference(value string) (string, bool) {
+	if !strings.HasPrefix(value, "secret://") {
+		return "", false
+	}
+	scheme, path, _ := strings.Cut(strings.TrimPrefix(value, "secret://"), "/")
+	if scheme == "file" {
+		path = "/" + path
+	}
+	return scheme + "://" + path, true
+}
+
+// Resolve returns the secret value if value is a reference, otherwise value itself is returned
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	scheme, ref, _ := strings.Cut(value, "://")
+	path, key, _ := strings.Cut(ref, "#")
+	store, ok := r.stores[scheme]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotConfigured, scheme)
+	}
+	return store.Get(ctx, path, key)
+}
+
+// Encrypt returns the encrypted://<base64> reference to the value, so it can be stored in the database
+// and decrypted by the scheduler only
+func (r *Resolver) Encrypt(ctx context.Context, value string) (string, error) {
+	store, ok := r.stores["encrypted"].(*EncryptedStore)
+	if !ok {
+		return "", fmt.Errorf("%w: encryption key is not specified", ErrNotConfigured)
+	}
+	return store.Encrypt(ctx, value)
+}
+
+// keyValue returns the value itself if key is empty, otherwise the value is treated
+// as JSON object and the value of the key is returned
+func keyValue(name string, value string, key string) (string, error) {
+	if key == "" {
+		return value, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &values); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", name, err)
+	}
+	v, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret %s", key, name)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// Invalidate drops cached secrets, so they are read again on the next access, e.g. after authentication failure
+func (r *Resolver) Invalidate() {
+	for _, store := range r.stores {
+		if i, ok := store.(interface{ Invalidate() }); ok {
+			i.Invalidate()
+		}
+	}
+}
+
+// OnRotate registers function called when any of the resolved secrets changes
+func (r *Resolver) OnRotate(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onRotate = append(r.onRotate, f)
+}
+
+func (r *Resolver) rotated() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.l.Info("Secrets rotated")
+	for _, f := range r.onRotate {
+		f()
+	}
+}
@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type storeMock map[string]string
+
+func (s storeMock) Get(ctx context.Context, path string, key string) (string, error) {
+	return s[path+"#"+key], nil
+}
+
+func TestIsReference(t *testing.T) {
+	assert.True(t, IsReference("vault://database/creds/scheduler#password"))
+	assert.True(t, IsReference("aws-sm://prod/pg_timetable#password"))
+	assert.True(t, IsReference("aws-ssm:///pg_timetable/password"))
+	assert.True(t, IsReference("file:///var/run/secrets/db/password"))
+	assert.False(t, IsReference("postgres://user@host/db"))
+	assert.False(t, IsReference("very_strong_one"))
+}
+
+func TestTaskReference(t *testing.T) {
+	for value, expected := range map[string]string{
+		"secret://vault/database/creds/backup#password": "vault://database/creds/backup#password",
+		"secret://file/run/secrets/backup":              "file:///run/secrets/backup",
+		"secret://encrypted/kS3Jk":                      "encrypted://kS3Jk",
+	} {
+		ref, ok := TaskReference(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, expected, ref)
+	}
+	_, ok := TaskReference("file:///data")
+	assert.False(t, ok, "plain values are not resolved")
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	r := NewResolver(ctx, config.SecretOpts{}, log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	value, err := r.Resolve(ctx, "plain value")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain value", value)
+
+	_, err = r.Resolve(ctx, "vault://secret/foo#bar")
+	assert.ErrorIs(t, err, ErrNotConfigured)
+
+	r.stores["vault"] = storeMock{"secret/foo#bar": "baz"}
+	value, err = r.Resolve(ctx, "vault://secret/foo#bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", value)
+
+	var rotated bool
+	r.OnRotate(func() { rotated = true })
+	r.rotated()
+	assert.True(t, rotated)
+}
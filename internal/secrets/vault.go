@@ -0,0 +1,204 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+)
+
+const (
+	vaultCheckInterval   = 10 * time.Second // how often leases are checked for renewal
+	vaultRefreshInterval = 5 * time.Minute  // how often secrets without lease are re-read
+)
+
+// vaultSecret is the cached secret read from the Vault
+type vaultSecret struct {
+	data      map[string]string
+	leaseID   string
+	renewable bool
+	renewAt   time.Time
+}
+
+// vaultResponse is the Vault HTTP API response for the secret read or lease renewal
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	Renewable     bool                   `json:"renewable"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	Errors        []string               `json:"errors"`
+}
+
+// Vault reads secrets using HashiCorp Vault HTTP API. Both static (KV v1 and v2) and
+// dynamic secrets are supported. Leases of dynamic secrets are renewed automatically,
+// if the lease cannot be renewed the secret is read again and OnRotate is called
+type Vault struct {
+	addr     string
+	token    string
+	client   *http.Client
+	l        log.LoggerIface
+	mu       sync.Mutex
+	secrets  map[string]*vaultSecret
+	OnRotate func() // called when the value of any cached secret changes
+}
+
+// NewVault returns Vault client for the server with the specified address
+func NewVault(addr string, token string, logger log.LoggerIface) *Vault {
+	return &Vault{
+		addr:    strings.TrimSuffix(addr, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		l:       logger,
+		secrets: make(map[string]*vaultSecret),
+	}
+}
+
+// Get returns the value of the key stored in the secret with the specified path
+func (v *Vault) Get(ctx context.Context, path string, key string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	secret, ok := v.secrets[path]
+	if !ok {
+		var err error
+		if secret, err = v.read(ctx, path); err != nil {
+			return "", err
+		}
+		v.secrets[path] = secret
+	}
+	value, ok := secret.data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in vault secret %s", key, path)
+	}
+	return value, nil
+}
+
+// Invalidate drops cached secrets, so they are read again on the next access
+func (v *Vault) Invalidate() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets = make(map[string]*vaultSecret)
+}
+
+// Run renews leases and refreshes cached secrets until ctx is cancelled
+func (v *Vault) Run(ctx context.Context) {
+	ticker := time.NewTicker(vaultCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if v.refresh(ctx) && v.OnRotate != nil {
+				v.OnRotate()
+			}
+		}
+	}
+}
+
+// refresh renews or re-reads secrets due and returns true if any of them changed
+func (v *Vault) refresh(ctx context.Context) (rotated bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for path, secret := range v.secrets {
+		if time.Now().Before(secret.renewAt) {
+			continue
+		}
+		l := v.l.WithField("path", path)
+		if secret.renewable {
+			err := v.renew(ctx, secret)
+			if err == nil {
+				l.Debug("Vault lease renewed")
+				continue
+			}
+			l.WithError(err).Warn("Cannot renew vault lease")
+		}
+		fresh, err := v.read(ctx, path)
+		if err != nil {
+			l.WithError(err).Error("Cannot refresh vault secret")
+			continue
+		}
+		if !reflect.DeepEqual(fresh.data, secret.data) {
+			l.Info("Vault secret rotated")
+			rotated = true
+		}
+		v.secrets[path] = fresh
+	}
+	return
+}
+
+func (v *Vault) read(ctx context.Context, path string) (*vaultSecret, error) {
+	resp, err := v.do(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	data := resp.Data
+	// KV v2 keeps the secret in the nested data object along with metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	secret := &vaultSecret{
+		data:      make(map[string]string, len(data)),
+		leaseID:   resp.LeaseID,
+		renewable: resp.Renewable && resp.LeaseID != "",
+		renewAt:   renewTime(resp.LeaseDuration),
+	}
+	for k, val := range data {
+		secret.data[k] = fmt.Sprint(val)
+	}
+	return secret, nil
+}
+
+func (v *Vault) renew(ctx context.Context, secret *vaultSecret) error {
+	resp, err := v.do(ctx, http.MethodPut, "/v1/sys/leases/renew", map[string]string{"lease_id": secret.leaseID})
+	if err != nil {
+		return err
+	}
+	secret.renewable = resp.Renewable
+	secret.renewAt = renewTime(resp.LeaseDuration)
+	return nil
+}
+
+// renewTime returns the time the secret should be renewed at, i.e. after two thirds of the lease duration
+func renewTime(leaseDuration int) time.Time {
+	if leaseDuration <= 0 {
+		return time.Now().Add(vaultRefreshInterval)
+	}
+	return time.Now().Add(time.Duration(leaseDuration) * time.Second * 2 / 3)
+}
+
+func (v *Vault) do(ctx context.Context, method string, path string, body interface{}) (*vaultResponse, error) {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+path, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var vr vaultResponse
+	if err = json.NewDecoder(resp.Body).Decode(&vr); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault request %s %s failed with status %d: %s",
+			method, path, resp.StatusCode, strings.Join(vr.Errors, "; "))
+	}
+	return &vr, nil
+}
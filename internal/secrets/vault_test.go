@@ -0,0 +1,80 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVault(t *testing.T) {
+	password := "first"
+	renewable := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/pg_timetable":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": map[string]interface{}{"password": "kv"}, "metadata": map[string]interface{}{}},
+			})
+		case "/v1/database/creds/scheduler":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"lease_id": "database/creds/scheduler/1", "renewable": renewable, "lease_duration": 3600,
+				"data": map[string]interface{}{"username": "v-scheduler", "password": password},
+			})
+		case "/v1/sys/leases/renew":
+			if !renewable {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"lease_id": "database/creds/scheduler/1", "renewable": true, "lease_duration": 3600})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	v := NewVault(srv.URL, "token", log.Init(config.LoggingOpts{LogLevel: "error"}))
+
+	value, err := v.Get(ctx, "secret/data/pg_timetable", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "kv", value, "KV v2 secret should be unwrapped")
+
+	value, err = v.Get(ctx, "database/creds/scheduler", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value)
+
+	_, err = v.Get(ctx, "database/creds/scheduler", "unknown")
+	assert.Error(t, err)
+
+	_, err = v.Get(ctx, "unknown/path", "password")
+	assert.Error(t, err)
+
+	// renewable lease is renewed, secret stays the same
+	v.secrets["database/creds/scheduler"].renewAt = time.Now()
+	password = "second"
+	assert.False(t, v.refresh(ctx))
+	value, _ = v.Get(ctx, "database/creds/scheduler", "password")
+	assert.Equal(t, "first", value)
+
+	// lease cannot be renewed, secret is read again
+	v.secrets["database/creds/scheduler"].renewAt = time.Now()
+	renewable = false
+	assert.True(t, v.refresh(ctx))
+	value, _ = v.Get(ctx, "database/creds/scheduler", "password")
+	assert.Equal(t, "second", value)
+
+	v.token = "invalid"
+	_, err = v.Get(ctx, "secret/data/other", "password")
+	assert.ErrorContains(t, err, "permission denied")
+}
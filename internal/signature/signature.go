@@ -0,0 +1,162 @@
+// Package signature verifies detached signatures of chain definitions, so tampered job definitions are not installed
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/openpgp"
+)
+
+// ErrInvalidSignature is returned when the signature is missing or does not match the data and the trusted keys
+var ErrInvalidSignature = errors.New("invalid signature")
+
+const (
+	minisignAlg       = "Ed" // signature of the data itself
+	minisignHashedAlg = "ED" // signature of the BLAKE2b-512 hash of the data, default since minisign 0.10
+)
+
+// minisignKey is the Ed25519 public key generated by minisign
+type minisignKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+// Verifier checks signatures against the trusted minisign public keys and OpenPGP keyrings
+type Verifier struct {
+	minisign []minisignKey
+	keyring  openpgp.EntityList
+}
+
+// Load reads the trusted keys from the comma separated list of minisign public key files and
+// armored OpenPGP keyrings, e.g. exported with `gpg --armor --export`. Nil is returned if no files specified
+func Load(files string) (*Verifier, error) {
+	v := &Verifier{}
+	for _, file := range strings.Split(files, ",") {
+		if file = strings.TrimSpace(file); file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(data, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+			keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("cannot read OpenPGP keyring %s: %w", file, err)
+			}
+			v.keyring = append(v.keyring, keyring...)
+			continue
+		}
+		key, err := parseMinisignKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read minisign public key %s: %w", file, err)
+		}
+		v.minisign = append(v.minisign, key)
+	}
+	if len(v.minisign) == 0 && len(v.keyring) == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// Verify returns nil if sig is a valid minisign or OpenPGP detached signature of data made with any of the trusted keys
+func (v *Verifier) Verify(data []byte, sig []byte) error {
+	if len(bytes.TrimSpace(sig)) == 0 {
+		return fmt.Errorf("%w: signature is missing", ErrInvalidSignature)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("untrusted comment:")) {
+		return v.verifyMinisign(data, sig)
+	}
+	if len(v.keyring) == 0 {
+		return fmt.Errorf("%w: no OpenPGP keys trusted", ErrInvalidSignature)
+	}
+	var err error
+	if bytes.Contains(sig, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(v.keyring, bytes.NewReader(data), bytes.NewReader(sig))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(v.keyring, bytes.NewReader(data), bytes.NewReader(sig))
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+// verifyMinisign checks the minisign signature consisting of the untrusted comment, the signature,
+// the trusted comment and the global signature of the signature and the trusted comment
+func (v *Verifier) verifyMinisign(data []byte, sig []byte) error {
+	lines := readLines(sig)
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("%w: malformed minisign signature", ErrInvalidSignature)
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed minisign signature", ErrInvalidSignature)
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed minisign global signature", ErrInvalidSignature)
+	}
+	alg, signature := string(raw[:2]), raw[10:]
+	message := data
+	switch alg {
+	case minisignAlg:
+	case minisignHashedAlg:
+		hash := blake2b.Sum512(data)
+		message = hash[:]
+	default:
+		return fmt.Errorf("%w: unsupported minisign algorithm %q", ErrInvalidSignature, alg)
+	}
+	for _, key := range v.minisign {
+		if !bytes.Equal(key.id[:], raw[2:10]) {
+			continue
+		}
+		if !ed25519.Verify(key.key, message, signature) {
+			return fmt.Errorf("%w: signature does not match the data", ErrInvalidSignature)
+		}
+		trusted := append(append([]byte{}, signature...), strings.TrimPrefix(lines[2], "trusted comment: ")...)
+		if !ed25519.Verify(key.key, trusted, global) {
+			return fmt.Errorf("%w: trusted comment is tampered", ErrInvalidSignature)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: signed with unknown minisign key %X", ErrInvalidSignature, raw[2:10])
+}
+
+// parseMinisignKey parses the minisign public key file consisting of the untrusted comment and the key
+func parseMinisignKey(data []byte) (minisignKey, error) {
+	var key minisignKey
+	lines := readLines(data)
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "untrusted comment:") {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return key, errors.New("public key is missing")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != minisignAlg {
+		return key, errors.New("malformed public key")
+	}
+	copy(key.id[:], raw[2:10])
+	key.key = ed25519.PublicKey(raw[10:])
+	return key, nil
+}
+
+// readLines returns non-empty lines of the text without surrounding spaces
+func readLines(data []byte) (lines []string) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// minisign generates the key pair and returns the public key file content and the function signing data
+// the same way `minisign -S` does
+func minisign(t *testing.T, keyID string) (string, func(data []byte, alg string, comment string) []byte) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	pubKey := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append([]byte("Ed"+keyID), pub...)) + "\n"
+	return pubKey, func(data []byte, alg string, comment string) []byte {
+		if alg == minisignHashedAlg {
+			hash := blake2b.Sum512(data)
+			data = hash[:]
+		}
+		sig := ed25519.Sign(priv, data)
+		global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+		return []byte("untrusted comment: signature from minisign secret key\n" +
+			base64.StdEncoding.EncodeToString(append([]byte(alg+keyID), sig...)) + "\n" +
+			"trusted comment: " + comment + "\n" +
+			base64.StdEncoding.EncodeToString(global) + "\n")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	v, err := Load("")
+	assert.NoError(t, err)
+	assert.Nil(t, v, "verification is disabled without keys")
+
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.pub")
+	assert.NoError(t, os.WriteFile(bad, []byte("untrusted comment: foo\nbar\n"), 0644))
+	_, err = Load(bad)
+	assert.Error(t, err)
+
+	_, err = Load(filepath.Join(dir, "missing.pub"))
+	assert.Error(t, err)
+}
+
+func TestMinisign(t *testing.T) {
+	dir := t.TempDir()
+	pubKey, sign := minisign(t, "KEY00001")
+	keyFile := filepath.Join(dir, "minisign.pub")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(pubKey), 0644))
+	otherKey, signOther := minisign(t, "KEY00002")
+	otherFile := filepath.Join(dir, "other.pub")
+	assert.NoError(t, os.WriteFile(otherFile, []byte(otherKey), 0644))
+	_, signUnknown := minisign(t, "KEY00003")
+
+	v, err := Load(keyFile + ", " + otherFile)
+	assert.NoError(t, err)
+	data := []byte("name: foo\ntasks:\n  - command: SELECT 1\n")
+
+	assert.NoError(t, v.Verify(data, sign(data, minisignAlg, "timestamp:1")))
+	assert.NoError(t, v.Verify(data, sign(data, minisignHashedAlg, "timestamp:1")))
+	assert.NoError(t, v.Verify(data, signOther(data, minisignHashedAlg, "timestamp:1")), "any trusted key is accepted")
+
+	for name, sig := range map[string][]byte{
+		"missing":  nil,
+		"tampered": sign(append(data, '#'), minisignHashedAlg, "timestamp:1"),
+		"unknown":  signUnknown(data, minisignHashedAlg, "timestamp:1"),
+		"comment":  bytes.Replace(sign(data, minisignHashedAlg, "timestamp:1"), []byte("timestamp:1"), []byte("timestamp:2"), 1),
+		"garbage":  []byte("untrusted comment: foo\nbar\n"),
+	} {
+		assert.ErrorIs(t, v.Verify(data, sig), ErrInvalidSignature, name)
+	}
+}
+
+func TestOpenPGP(t *testing.T) {
+	entity, err := openpgp.NewEntity("pg_timetable", "", "deploy@example.com", nil)
+	assert.NoError(t, err)
+	var keyring bytes.Buffer
+	w, err := armor.Encode(&keyring, openpgp.PublicKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, entity.Serialize(w))
+	assert.NoError(t, w.Close())
+	keyFile := filepath.Join(t.TempDir(), "keyring.asc")
+	assert.NoError(t, os.WriteFile(keyFile, keyring.Bytes(), 0644))
+
+	v, err := Load(keyFile)
+	assert.NoError(t, err)
+	data := []byte(`{"name": "foo", "tasks": [{"command": "SELECT 1"}]}`)
+
+	var armored, binary bytes.Buffer
+	assert.NoError(t, openpgp.ArmoredDetachSign(&armored, entity, bytes.NewReader(data), nil))
+	assert.NoError(t, openpgp.DetachSign(&binary, entity, bytes.NewReader(data), nil))
+	assert.NoError(t, v.Verify(data, armored.Bytes()))
+	assert.NoError(t, v.Verify(data, binary.Bytes()))
+	assert.ErrorIs(t, v.Verify(append(data, ' '), armored.Bytes()), ErrInvalidSignature)
+
+	_, sign := minisign(t, "KEY00001")
+	assert.ErrorIs(t, v.Verify(data, sign(data, minisignHashedAlg, "timestamp:1")), ErrInvalidSignature, "minisign key is not trusted")
+}
@@ -0,0 +1,71 @@
+// Package systemd implements the service manager notification protocol, so pg_timetable
+// may run as a `Type=notify` systemd service with the watchdog enabled
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+)
+
+// Notify sends the state, e.g. "READY=1", to the service manager. Does nothing
+// if the process is not started by systemd with the notification socket
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' { // abstract namespace socket
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns the watchdog timeout configured for the service, 0 if the watchdog is disabled
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0 // the watchdog is meant for another process
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog pings the service manager every half of the watchdog timeout while alive returns true,
+// so the hung process is restarted by systemd. Returns immediately if the watchdog is disabled,
+// otherwise blocks until ctx is cancelled
+func Watchdog(ctx context.Context, alive func() bool, logger log.LoggerIface) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	logger.WithField("timeout", interval).Info("Systemd watchdog enabled")
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !alive() {
+				logger.Error("Scheduler is not responding, skipping systemd watchdog notification")
+				continue
+			}
+			if err := Notify("WATCHDOG=1"); err != nil {
+				logger.WithError(err).Error("Cannot send systemd watchdog notification")
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listen(t *testing.T) *net.UnixConn {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Setenv("NOTIFY_SOCKET", socket)
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 64)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	assert.NoError(t, Notify("READY=1"), "no socket means not started by systemd")
+
+	conn := listen(t)
+	defer conn.Close()
+	assert.NoError(t, Notify("READY=1"))
+	assert.Equal(t, "READY=1", receive(t, conn))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	assert.Zero(t, WatchdogInterval())
+	t.Setenv("WATCHDOG_USEC", "3000000")
+	assert.Equal(t, 3*time.Second, WatchdogInterval())
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	assert.Equal(t, 3*time.Second, WatchdogInterval())
+	t.Setenv("WATCHDOG_PID", "1")
+	assert.Zero(t, WatchdogInterval())
+}
+
+func TestWatchdog(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+	t.Setenv("WATCHDOG_USEC", "100000")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watchdog(ctx, func() bool { return true }, log.Init(config.LoggingOpts{LogLevel: "error"}))
+	assert.Equal(t, "WATCHDOG=1", receive(t, conn))
+}
@@ -0,0 +1,201 @@
+// Package top implements the terminal monitor of the scheduler cluster showing connected clients,
+// running chains and recent failures refreshed live from the database
+package top
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+)
+
+const (
+	failuresPeriod = time.Hour // period recent failures are shown for
+	maxFailures    = 10        // maximum number of recent failures shown
+	maxRunning     = 20        // maximum number of running chains shown
+	maxOutput      = 50        // maximum length of the failed task output shown
+
+	enterScreen = "\033[?1049h\033[?25l" // switch to the alternate screen and hide the cursor
+	leaveScreen = "\033[?25h\033[?1049l" // show the cursor and restore the main screen
+	clearScreen = "\033[H\033[2J"
+)
+
+// Source provides the state of the cluster, implemented by pgengine.PgEngine
+type Source interface {
+	SelectClusterStatus(ctx context.Context, dest interface{}) error
+	SelectRunningChains(ctx context.Context, dest interface{}) error
+	SelectRecentFailures(ctx context.Context, dest interface{}, since time.Time, limit int) error
+}
+
+// Snapshot is the state of the cluster shown on the screen
+type Snapshot struct {
+	Taken    time.Time
+	Clients  []pgengine.ClientStatus
+	Running  []pgengine.RunningChain
+	Failures []pgengine.FailedTask
+}
+
+// Collect returns the current state of the cluster
+func Collect(ctx context.Context, src Source, now time.Time) (s Snapshot, err error) {
+	s.Taken = now
+	if err = src.SelectClusterStatus(ctx, &s.Clients); err != nil {
+		return
+	}
+	if err = src.SelectRunningChains(ctx, &s.Running); err != nil {
+		return
+	}
+	err = src.SelectRecentFailures(ctx, &s.Failures, now.Add(-failuresPeriod), maxFailures)
+	return
+}
+
+// Run shows the state of the cluster refreshed every interval on the alternate screen until ctx is cancelled.
+// The state is output once without screen control sequences if interval is 0
+func Run(ctx context.Context, src Source, w io.Writer, interval time.Duration) error {
+	if interval > 0 {
+		fmt.Fprint(w, enterScreen)
+		defer fmt.Fprint(w, leaveScreen)
+	}
+	width, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	for {
+		s, err := Collect(ctx, src, time.Now())
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var b bytes.Buffer
+		if interval > 0 {
+			b.WriteString(clearScreen)
+		}
+		Render(&b, s, interval, width)
+		if _, err = w.Write(b.Bytes()); err != nil || interval == 0 {
+			return err
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Render outputs the snapshot as tables of clients, running chains and recent failures. Lines are truncated
+// to the width of the terminal if it's greater than 0
+func Render(w io.Writer, s Snapshot, interval time.Duration, width int) {
+	var b bytes.Buffer
+	healthy, queued := 0, 0
+	for _, c := range s.Clients {
+		if c.Healthy {
+			healthy++
+		}
+		if c.QueuedChains != nil {
+			queued += *c.QueuedChains
+		}
+	}
+	title := "pg_timetable top - " + s.Taken.Format("2006-01-02 15:04:05")
+	if interval > 0 {
+		title += fmt.Sprintf(", refresh every %s, Ctrl+C to quit", interval)
+	}
+	fmt.Fprintln(&b, title)
+	fmt.Fprintf(&b, "Clients: %d (%d healthy)  Running chains: %d  Queued chains: %d  Failures (%s): %d\n\n",
+		len(s.Clients), healthy, len(s.Running), queued, shortDuration(failuresPeriod), len(s.Failures))
+
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CLIENT\tVERSION\tSTATE\tHEALTHY\tCONNS\tRUNNING\tQUEUED")
+	for _, c := range s.Clients {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n", c.ClientName, orDash(c.Version), clientState(c),
+			yesNo(c.Healthy), c.Connections, c.ActiveChains, intOrDash(c.QueuedChains))
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintln(&b)
+	fmt.Fprintln(tw, "RUNNING CHAIN\tCLIENT\tSTARTED\tDURATION")
+	for i, r := range s.Running {
+		if i == maxRunning {
+			fmt.Fprintf(tw, "... and %d more\t\t\t\n", len(s.Running)-maxRunning)
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.ChainName, r.ClientName, r.StartedAt.In(s.Taken.Location()).Format("15:04:05"),
+			shortDuration(s.Taken.Sub(r.StartedAt)))
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintln(&b)
+	fmt.Fprintln(tw, "FAILED CHAIN\tCLIENT\tFINISHED\tCODE\tOUTPUT")
+	for _, f := range s.Failures {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", f.ChainName, f.ClientName, f.Finished.In(s.Taken.Location()).Format("15:04:05"),
+			f.ReturnCode, shorten(f.Output, maxOutput))
+	}
+	_ = tw.Flush()
+
+	for _, line := range strings.SplitAfter(b.String(), "\n") {
+		if width > 0 && len([]rune(strings.TrimSuffix(line, "\n"))) > width {
+			line = string([]rune(line)[:width]) + "\n"
+		}
+		fmt.Fprint(w, line)
+	}
+}
+
+// clientState returns the role of the client in the high availability group or the maintenance mode
+func clientState(c pgengine.ClientStatus) string {
+	switch {
+	case c.Maintenance != nil && *c.Maintenance:
+		return "maintenance"
+	case c.Leader == nil:
+		return "-"
+	case *c.Leader:
+		return "leader"
+	default:
+		return "follower"
+	}
+}
+
+func orDash(s *string) string {
+	if s == nil || *s == "" {
+		return "-"
+	}
+	return *s
+}
+
+func intOrDash(i *int) string {
+	if i == nil {
+		return "-"
+	}
+	return strconv.Itoa(*i)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// shortDuration returns the duration rounded to seconds without zero units, e.g. 1h instead of 1h0m0s
+func shortDuration(d time.Duration) string {
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// shorten returns the text collapsed to a single line and truncated to max characters
+func shorten(text string, max int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) > max {
+		return string(runes[:max-3]) + "..."
+	}
+	return string(runes)
+}
@@ -0,0 +1,92 @@
+package top
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC)
+
+type source struct {
+	err error
+}
+
+func (s source) SelectClusterStatus(_ context.Context, dest interface{}) error {
+	version, queued, leader := "5.9.0", 4, true
+	*dest.(*[]pgengine.ClientStatus) = []pgengine.ClientStatus{
+		{ClientName: "worker01", Version: &version, Connections: 3, Healthy: true, ActiveChains: 1, QueuedChains: &queued, Leader: &leader},
+		{ClientName: "legacy", Connections: 1},
+	}
+	return s.err
+}
+
+func (s source) SelectRunningChains(_ context.Context, dest interface{}) error {
+	*dest.(*[]pgengine.RunningChain) = []pgengine.RunningChain{{ChainID: 1, ChainName: "backup", ClientName: "worker01", StartedAt: now.Add(-90 * time.Second)}}
+	return nil
+}
+
+func (s source) SelectRecentFailures(_ context.Context, dest interface{}, _ time.Time, limit int) error {
+	if limit != maxFailures {
+		return errors.New("unexpected arguments")
+	}
+	*dest.(*[]pgengine.FailedTask) = []pgengine.FailedTask{{ChainID: 2, ChainName: "report", ClientName: "worker01",
+		Finished: now.Add(-time.Minute), ReturnCode: 1, Output: "ERROR: relation \"sales\"\ndoes not exist"}}
+	return nil
+}
+
+func TestRender(t *testing.T) {
+	s, err := Collect(context.Background(), source{}, now)
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	Render(&b, s, 2*time.Second, 0)
+	assert.Equal(t, `pg_timetable top - 2026-10-17 02:00:00, refresh every 2s, Ctrl+C to quit
+Clients: 2 (1 healthy)  Running chains: 1  Queued chains: 4  Failures (1h): 1
+
+CLIENT    VERSION  STATE   HEALTHY  CONNS  RUNNING  QUEUED
+worker01  5.9.0    leader  yes      3      1        4
+legacy    -        -       no       1      0        -
+
+RUNNING CHAIN  CLIENT    STARTED   DURATION
+backup         worker01  01:58:30  1m30s
+
+FAILED CHAIN  CLIENT    FINISHED  CODE  OUTPUT
+report        worker01  01:59:00  1     ERROR: relation "sales" does not exist
+`, b.String())
+
+	b.Reset()
+	Render(&b, s, 0, 20)
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		assert.LessOrEqual(t, len(line), 20)
+	}
+	assert.True(t, strings.HasPrefix(b.String(), "pg_timetable top - 2\n"), "refresh is not shown in the single output")
+}
+
+func TestRun(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, Run(context.Background(), source{}, &b, 0))
+	assert.NotContains(t, b.String(), "\033", "no screen control sequences in the single output")
+	assert.Contains(t, b.String(), "backup")
+
+	b.Reset()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, Run(ctx, source{}, &b, 10*time.Millisecond))
+	assert.True(t, strings.HasPrefix(b.String(), enterScreen+clearScreen))
+	assert.True(t, strings.HasSuffix(b.String(), leaveScreen), "the terminal is restored on exit")
+	assert.Greater(t, strings.Count(b.String(), clearScreen), 1, "the screen is refreshed")
+
+	assert.Error(t, Run(context.Background(), source{err: errors.New("connection lost")}, &b, 0))
+}
+
+func TestShortDuration(t *testing.T) {
+	assert.Equal(t, "1h", shortDuration(time.Hour))
+	assert.Equal(t, "2m", shortDuration(2*time.Minute))
+	assert.Equal(t, "1h0m5s", shortDuration(time.Hour+5*time.Second+300*time.Millisecond))
+}
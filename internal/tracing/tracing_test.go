@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestInit(t *testing.T) {
+	shutdown, err := Init(context.Background(), config.TracingOpts{}, "worker", "master")
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()), "tracing disabled without endpoint")
+
+	shutdown, err = Init(context.Background(), config.TracingOpts{Endpoint: "localhost:4317", Insecure: true}, "worker", "master")
+	require.NoError(t, err)
+	assert.IsType(t, &sdktrace.TracerProvider{}, otel.GetTracerProvider())
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestEnviron(t *testing.T) {
+	_, _ = Init(context.Background(), config.TracingOpts{}, "worker", "master")
+	assert.Nil(t, Environ(context.Background()), "no span in context")
+
+	provider := sdktrace.NewTracerProvider()
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "task")
+	defer span.End()
+	sc := span.SpanContext()
+	assert.Equal(t, []string{fmt.Sprintf("TRACEPARENT=00-%s-%s-01", sc.TraceID(), sc.SpanID())}, Environ(ctx))
+}
+
+func TestLogFields(t *testing.T) {
+	assert.Nil(t, LogFields(context.Background()), "no span in context")
+
+	provider := sdktrace.NewTracerProvider()
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "chain")
+	defer span.End()
+	sc := span.SpanContext()
+	assert.Equal(t, map[string]interface{}{"trace_id": sc.TraceID().String(), "span_id": sc.SpanID().String()}, LogFields(ctx))
+}
@@ -2,16 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/cybertec-postgresql/pg_timetable/internal/api"
 	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"github.com/cybertec-postgresql/pg_timetable/internal/grpcapi"
+	"github.com/cybertec-postgresql/pg_timetable/internal/kerberos"
 	"github.com/cybertec-postgresql/pg_timetable/internal/log"
+	"github.com/cybertec-postgresql/pg_timetable/internal/metrics"
 	"github.com/cybertec-postgresql/pg_timetable/internal/pgengine"
 	"github.com/cybertec-postgresql/pg_timetable/internal/scheduler"
+	"github.com/cybertec-postgresql/pg_timetable/internal/secrets"
+	"github.com/cybertec-postgresql/pg_timetable/internal/signature"
+	"github.com/cybertec-postgresql/pg_timetable/internal/systemd"
+	"github.com/cybertec-postgresql/pg_timetable/internal/tracing"
 )
 
 /**
@@ -36,6 +45,25 @@ func SetupCloseHandler(cancel context.CancelFunc) {
 	exitCode = ExitCodeUserCancel
 }
 
+// SetupReloadHandler reloads the configuration of the scheduler every time SIGHUP is received
+func SetupReloadHandler(ctx context.Context, sch *scheduler.Scheduler, logger log.LoggerIface) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-c:
+				if err := sch.Reload(); err != nil {
+					logger.WithError(err).Error("Cannot reload configuration")
+				}
+			case <-ctx.Done():
+				signal.Stop(c)
+				return
+			}
+		}
+	}()
+}
+
 const (
 	ExitCodeOK int = iota
 	ExitCodeConfigError
@@ -43,6 +71,8 @@ const (
 	ExitCodeUpgradeError
 	ExitCodeUserCancel
 	ExitCodeShutdownCommand
+	ExitCodeCommandError
+	ExitCodeServiceError
 )
 
 var exitCode = ExitCodeOK
@@ -52,7 +82,7 @@ var (
 	commit  string = "000000"
 	version string = "master"
 	date    string = "unknown"
-	dbapi   string = "00436"
+	dbapi   string = "01448"
 )
 
 func printVersion() {
@@ -64,6 +94,60 @@ func printVersion() {
 `, version, dbapi, commit, date)
 }
 
+// printCheckReport outputs the results of the configuration checks and returns false if any of them failed
+func printCheckReport(results []pgengine.CheckResult) (ok bool) {
+	ok = true
+	fmt.Printf("%-24s OK\n", "Configuration:")
+	for _, res := range results {
+		if len(res.Problems) == 0 {
+			fmt.Printf("%-24s OK\n", res.Check+":")
+			continue
+		}
+		ok = false
+		fmt.Printf("%-24s FAILED\n", res.Check+":")
+		for _, problem := range res.Problems {
+			fmt.Println("  -", problem)
+		}
+	}
+	return
+}
+
+// waitAsStandby validates the configuration, then blocks until the primary client with the same name stops.
+// With `--takeover` the primary is asked to drain first, and the minute scheduled chains are checked up to by
+// the primary is returned, it is zero if unknown
+func waitAsStandby(ctx context.Context, cmdOpts *config.CmdOptions, logger log.LoggerHookerIface) (checked time.Time, err error) {
+	ok := true
+	for _, res := range pgengine.CheckConfig(ctx, *cmdOpts, logger) {
+		for _, problem := range res.Problems {
+			logger.WithField("check", res.Check).Error(problem)
+			ok = false
+		}
+	}
+	if !ok {
+		return checked, errors.New("configuration check failed")
+	}
+	standby, err := pgengine.Connect(ctx, *cmdOpts, logger)
+	if err != nil {
+		return checked, err
+	}
+	defer standby.ConfigDb.Close()
+	if !cmdOpts.HA.Takeover {
+		return checked, standby.WaitPrimaryStopped(ctx)
+	}
+	requested := time.Now()
+	if err = standby.NotifyDrain(ctx); err != nil {
+		return checked, err
+	}
+	if err = standby.WaitPrimaryStopped(ctx); err != nil {
+		return checked, err
+	}
+	// the drained primary checks the minute it was asked at, unless the minute is over the next one is unknown
+	if now := time.Now(); now.Truncate(time.Minute).Equal(requested.Truncate(time.Minute)) {
+		checked = now
+	}
+	return checked, nil
+}
+
 func main() {
 	defer func() { os.Exit(exitCode) }()
 
@@ -71,6 +155,7 @@ func main() {
 	SetupCloseHandler(cancel)
 	defer cancel()
 
+	config.ChainNames = completeChainNames
 	cmdOpts, err := config.NewConfig(os.Stdout)
 	if err != nil {
 		if cmdOpts != nil && cmdOpts.VersionOnly() {
@@ -86,9 +171,148 @@ func main() {
 		printVersion()
 	}
 
+	if cmdOpts.Service != "" {
+		if err = manageService(ctx, cmdOpts); err != nil {
+			fmt.Println("Service error: ", err)
+			exitCode = ExitCodeServiceError
+		}
+		return
+	}
+	run(ctx, cmdOpts)
+}
+
+// run executes the command specified or starts the scheduler and blocks until it terminates or ctx is cancelled
+func run(ctx context.Context, cmdOpts *config.CmdOptions) {
+	var err error
+	if cmdOpts.Command == "completion" { // neither the password nor the database connection required
+		fmt.Print(completionScripts[cmdOpts.CommandArgs[0]])
+		return
+	}
+	if cmdOpts.Command == "cron-next" { // neither the password nor the database connection required
+		if err = printCronNext(cmdOpts.CommandArgs[0], cmdOpts.Commands.CronNext, time.Now(), os.Stdout); err != nil {
+			fmt.Println("Command failed: ", err)
+			exitCode = ExitCodeCommandError
+		}
+		return
+	}
+	if err = cmdOpts.ReadPassword(os.Stderr); err != nil {
+		fmt.Println("Configuration error: ", err)
+		exitCode = ExitCodeConfigError
+		return
+	}
+
+	switch cmdOpts.Command {
+	case "init":
+		cmdOpts.Start.Init = true
+	case "upgrade":
+		cmdOpts.Start.Init, cmdOpts.Start.Upgrade = true, true
+	case "validate":
+		cmdOpts.Start.Check = true
+	}
+
 	logger := log.Init(cmdOpts.Logging)
-	apiserver := api.Init(cmdOpts.RestApi, logger)
+	kerberos.Init(cmdOpts.Kerberos, logger)
+	if cmdOpts.Command == "doctor" {
+		if !printCheckReport(pgengine.Doctor(ctx, *cmdOpts, logger)) {
+			exitCode = ExitCodeCommandError
+		}
+		return
+	}
+	if cmdOpts.Start.Check {
+		if !printCheckReport(pgengine.CheckConfig(ctx, *cmdOpts, logger)) {
+			exitCode = ExitCodeConfigError
+		}
+		return
+	}
+	if cmdOpts.Command == "encrypt" { // no database connection required
+		if err = encryptValues(ctx, secrets.NewResolver(ctx, cmdOpts.Secrets, logger), cmdOpts.CommandArgs, os.Stdout); err != nil {
+			logger.WithError(err).Error("Command failed")
+			exitCode = ExitCodeCommandError
+		}
+		return
+	}
+	if cmdOpts.Command == "crontab" { // no database connection required
+		if err = convertCrontabs(cmdOpts.CommandArgs, cmdOpts.Commands.Crontab, logger, os.Stdout); err != nil {
+			logger.WithError(err).Error("Command failed")
+			exitCode = ExitCodeCommandError
+		}
+		return
+	}
+	if cmdOpts.Command == "cronjob" { // no database connection required
+		if err = convertCronJobs(cmdOpts.CommandArgs, cmdOpts.Commands.CronJob, logger, os.Stdout); err != nil {
+			logger.WithError(err).Error("Command failed")
+			exitCode = ExitCodeCommandError
+		}
+		return
+	}
+	verifier, err := signature.Load(cmdOpts.SigningKeys)
+	if err != nil {
+		logger.WithError(err).Error("Cannot load chain signing keys")
+		exitCode = ExitCodeConfigError
+		return
+	}
+	if !cmdOpts.IsRunCommand() && !cmdOpts.Start.Init && cmdOpts.Command != "exec" {
+		if pge, err = pgengine.Connect(ctx, *cmdOpts, logger); err != nil {
+			logger.WithError(err).Error("Connection failed")
+			exitCode = ExitCodeDBEngineError
+			return
+		}
+		defer pge.ConfigDb.Close()
+		if err = runCommand(log.WithLogger(ctx, logger), pge, verifier, cmdOpts.Command, cmdOpts.CommandArgs, os.Stdout); err != nil {
+			logger.WithError(err).Error("Command failed")
+			exitCode = ExitCodeCommandError
+		}
+		return
+	}
+	shutdownTracing, err := tracing.Init(ctx, cmdOpts.Tracing, cmdOpts.ClientName, version)
+	if err != nil {
+		logger.WithError(err).Error("Cannot initialize tracing")
+		exitCode = ExitCodeConfigError
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.WithError(err).Error("Cannot export pending traces")
+		}
+	}()
+	closeMetrics, err := metrics.Init(cmdOpts.Statsd, cmdOpts.ClientName)
+	if err != nil {
+		logger.WithError(err).Error("Cannot initialize StatsD metrics")
+		exitCode = ExitCodeConfigError
+		return
+	}
+	defer func() { _ = closeMetrics() }()
+	if cmdOpts.Sentry.DSN > "" {
+		sentryHook, err := log.NewSentryHook(cmdOpts.Sentry, cmdOpts.ClientName, version)
+		if err != nil {
+			logger.WithError(err).Error("Cannot initialize Sentry reporting")
+			exitCode = ExitCodeConfigError
+			return
+		}
+		logger.AddHook(sentryHook)
+		defer sentryHook.Flush(5 * time.Second)
+	}
+	tlsConfig, err := cmdOpts.TLS.ServerConfig()
+	if err != nil {
+		logger.WithError(err).Error("Cannot load TLS certificates of the API servers")
+		exitCode = ExitCodeConfigError
+		return
+	}
+	apiserver := api.Init(cmdOpts.RestApi, tlsConfig, logger)
+	grpcserver := grpcapi.Init(cmdOpts.Grpc, cmdOpts.RestApi, tlsConfig, logger)
 
+	var checked time.Time
+	if cmdOpts.HA.WaitPrimary() {
+		if checked, err = waitAsStandby(ctx, cmdOpts, logger); err != nil {
+			if ctx.Err() == nil {
+				logger.WithError(err).Error("Standby failed")
+				exitCode = ExitCodeConfigError
+			}
+			return
+		}
+	}
 	if pge, err = pgengine.New(ctx, *cmdOpts, logger); err != nil {
 		logger.WithError(err).Error("Connection failed")
 		exitCode = ExitCodeDBEngineError
@@ -117,10 +341,49 @@ func main() {
 	if cmdOpts.Start.Init {
 		return
 	}
+	if cmdOpts.Start.RunChain > "" || cmdOpts.Command == "exec" {
+		var res scheduler.ChainResult
+		if cmdOpts.Command == "exec" {
+			res, err = scheduler.New(pge, logger).ExecTask(pgengine.WithOperator(ctx, osUser()), execTask(cmdOpts.Commands.Exec))
+		} else {
+			res, err = scheduler.New(pge, logger).RunChainOnce(ctx, cmdOpts.Start.RunChain)
+		}
+		if err != nil {
+			logger.WithError(err).Error("Cannot run chain")
+			exitCode = ExitCodeCommandError
+			return
+		}
+		printChainResult(os.Stdout, res)
+		if !res.Succeeded {
+			exitCode = ExitCodeCommandError
+		}
+		return
+	}
+	if err = pge.RegisterNotifyChannel(ctx); err != nil {
+		logger.WithError(err).Error("Cannot register notification channel")
+		exitCode = ExitCodeDBEngineError
+		return
+	}
+	pgengine.Version = version
 	sch := scheduler.New(pge, logger)
+	if !checked.IsZero() {
+		sch.TakeOver(checked)
+	}
 	apiserver.Reporter = sch
+	apiserver.Verifier = verifier
+	grpcserver.Handler = sch
+	SetupReloadHandler(ctx, sch, logger)
+
+	if err = systemd.Notify("READY=1"); err != nil {
+		logger.WithError(err).Error("Cannot notify systemd")
+	}
+	defer func() { _ = systemd.Notify("STOPPING=1") }()
+	go systemd.Watchdog(ctx, sch.IsAlive, logger)
 
-	if sch.Run(ctx) == scheduler.ShutdownStatus {
+	switch sch.Run(ctx) {
+	case scheduler.ShutdownStatus:
 		exitCode = ExitCodeShutdownCommand
+	case scheduler.DrainedStatus:
+		logger.Info("Chains drained, the instance taking over continues")
 	}
 }
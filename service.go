@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+)
+
+// serviceName returns the name of the service, so several clients may be installed on the same host
+func serviceName(cmdOpts *config.CmdOptions) string {
+	return "pg_timetable_" + cmdOpts.ClientName
+}
+
+// serviceArgs returns the command line arguments the service is started with,
+// i.e. the current ones with the `--service` option replaced by `--service=run`
+func serviceArgs(args []string) []string {
+	res := make([]string, 0, len(args)+1)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--service":
+			i++ // skip the value
+		case strings.HasPrefix(args[i], "--service="):
+		default:
+			res = append(res, args[i])
+		}
+	}
+	return append(res, "--service=run")
+}
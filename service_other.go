@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+)
+
+// manageService is not supported on this platform
+func manageService(ctx context.Context, cmdOpts *config.CmdOptions) error {
+	return errors.New("Windows service is supported only on Windows, use systemd or another service manager")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cybertec-postgresql/pg_timetable/internal/config"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// manageService installs, uninstalls or runs pg_timetable as a Windows service
+func manageService(ctx context.Context, cmdOpts *config.CmdOptions) error {
+	name := serviceName(cmdOpts)
+	switch cmdOpts.Service {
+	case "install":
+		return installService(name)
+	case "uninstall":
+		return uninstallService(name)
+	case "run":
+		isService, err := svc.IsWindowsService()
+		if err != nil {
+			return err
+		}
+		if !isService {
+			return errors.New("not started by the service control manager, use --service=install")
+		}
+		return svc.Run(name, &windowsService{ctx: ctx, cmdOpts: cmdOpts})
+	}
+	return fmt.Errorf("unknown service action: %s", cmdOpts.Service)
+}
+
+func installService(name string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.Abs(exe); err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: name,
+		Description: "pg_timetable: advanced scheduling for PostgreSQL",
+		StartType:   mgr.StartAutomatic,
+	}, serviceArgs(os.Args[1:])...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	fmt.Printf("Service %s installed\n", name)
+	return nil
+}
+
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %w", name, err)
+	}
+	defer s.Close()
+	if err = s.Delete(); err != nil {
+		return err
+	}
+	fmt.Printf("Service %s uninstalled\n", name)
+	return nil
+}
+
+// windowsService runs the scheduler under the service control manager
+type windowsService struct {
+	ctx     context.Context
+	cmdOpts *config.CmdOptions
+}
+
+// Execute starts the scheduler and stops it gracefully, the same way as on SIGTERM, when the stop
+// or shutdown request is received
+func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	changes <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(ws.ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx, ws.cmdOpts)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: accepted}
+	for {
+		select {
+		case <-done: // scheduler terminated by itself, e.g. by the Shutdown builtin or because of the error
+			changes <- svc.Status{State: svc.StopPending}
+			return exitCode != ExitCodeOK, uint32(exitCode)
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending, WaitHint: uint32(30 * time.Second / time.Millisecond)}
+				cancel()
+				<-done
+				exitCode = ExitCodeOK
+				return false, 0
+			}
+		}
+	}
+}